@@ -5,16 +5,34 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/routes"
+	"github.com/ramseyjiang/go-micros/shared/healthcheck"
+	"github.com/ramseyjiang/go-micros/shared/tracing"
+	"github.com/ramseyjiang/go-micros/shared/viperconf"
+	"github.com/spf13/viper"
 )
 
 const (
 	tradeSalesPort   = "SALES_PORT"
 	defaultSalesPort = ":8080"
+	adminAddrEnvVar  = "ADMIN_ADDR"
+	defaultAdminAddr = "localhost:9090"
+
+	// shutdownDrainDelay is how long /readyz fails before the server stops accepting requests
+	shutdownDrainDelay = 5 * time.Second
+	shutdownTimeout    = 15 * time.Second
 )
 
 func main() {
+	// Read the config file, the flags and the environment variables (tracing-enabled or TRACING_ENABLED...)
+	if err := viperconf.SetupViperV2(nil, "grpc-gateway", func() {}, nil); err != nil {
+		log.Fatalf("Failed to load the config: %v", err)
+	}
+
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -24,10 +42,55 @@ func main() {
 		salesPort = defaultSalesPort
 	}
 
-	httpHandler := routes.SetupRoutes(ctx)
+	// Set up tracing before the routes, their instrumentation uses the tracer provider
+	shutdownTracing, err := tracing.Setup(ctx, viperconf.TracingOptions(viper.GetViper(), "grpc-gateway"))
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// /readyz reports NOT_SERVING while a backend is down and once the shutdown starts
+	health := healthcheck.NewAggregator(0)
+	httpHandler := routes.SetupRoutes(ctx, health)
+
+	// The admin endpoints are served on their own address, never exposed with the API.
+	// The metrics are served on metrics-addr while export-metrics is set (see viperconf).
+	adminAddr := os.Getenv(adminAddrEnvVar)
+	if adminAddr == "" {
+		adminAddr = defaultAdminAddr
+	}
+	go func() {
+		log.Printf("Starting admin server on %s\n", adminAddr)
+		if err := http.ListenAndServe(adminAddr, routes.SetupAdminRoutes()); err != nil {
+			log.Printf("Failed to serve the admin endpoints: %v", err)
+		}
+	}()
+
+	server := &http.Server{Addr: salesPort, Handler: httpHandler}
+
+	// Graceful shutdown: fail the readiness probe, give the load balancer time to notice, then drain.
+	// done is closed once the requests are drained, main waits for it before exiting.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		log.Println("Shutting down gRPC Gateway...")
+		health.SetShuttingDown()
+		time.Sleep(shutdownDrainDelay)
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to drain the requests: %v", err)
+		}
+	}()
 
 	log.Printf("Starting gRPC Gateway on port %s\n", salesPort)
-	if err := http.ListenAndServe(salesPort, httpHandler); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Failed to serve gRPC-Gateway: %v", err)
 	}
+	// ListenAndServe returns as soon as Shutdown is called, wait for the drain
+	<-done
+	log.Println("gRPC Gateway stopped")
 }
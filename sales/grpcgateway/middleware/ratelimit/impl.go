@@ -1,17 +1,26 @@
 package ratelimit
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+)
 
 const defaultKey = "test rate limit" // The key can be remoteIP, userID or something else.
 
+// DefaultPolicy labels the decisions of the default bucket in the rate limiter metrics
+const DefaultPolicy = "default"
+
 func Impl(bucketStore *BucketStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			allowed, _, _, _ := bucketStore.Take(defaultKey)
 			if !allowed {
+				metrics.RateLimitDecisions.WithLabelValues(DefaultPolicy, metrics.DecisionDeny).Inc()
 				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 				return
 			}
+			metrics.RateLimitDecisions.WithLabelValues(DefaultPolicy, metrics.DecisionAllow).Inc()
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -0,0 +1,13 @@
+package recovery
+
+import (
+	"net/http"
+
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+)
+
+// Impl recovers from panics in the wrapped handler, logs them as 500 APIErrors
+// and writes the error as JSON, so the gateway keeps serving.
+func Impl() func(http.Handler) http.Handler {
+	return apierror.RecoveryHandler
+}
@@ -0,0 +1,39 @@
+package recovery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImpl(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantCode int
+	}{
+		{
+			name:     "NoPanic",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) },
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "NilPointer",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var m map[string]*http.Request
+				_ = m["missing"].URL
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Impl()(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/products", nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
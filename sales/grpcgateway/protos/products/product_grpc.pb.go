@@ -19,8 +19,18 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	ProductService_GetProducts_FullMethodName   = "/products.ProductService/GetProducts"
-	ProductService_CreateProduct_FullMethodName = "/products.ProductService/CreateProduct"
+	ProductService_GetProducts_FullMethodName    = "/products.ProductService/GetProducts"
+	ProductService_CreateProduct_FullMethodName  = "/products.ProductService/CreateProduct"
+	ProductService_GetProduct_FullMethodName     = "/products.ProductService/GetProduct"
+	ProductService_ImportProducts_FullMethodName = "/products.ProductService/ImportProducts"
+	ProductService_ExportProducts_FullMethodName = "/products.ProductService/ExportProducts"
+	ProductService_CreateCategory_FullMethodName = "/products.ProductService/CreateCategory"
+	ProductService_GetCategory_FullMethodName    = "/products.ProductService/GetCategory"
+	ProductService_ListCategories_FullMethodName = "/products.ProductService/ListCategories"
+	ProductService_UpdateCategory_FullMethodName = "/products.ProductService/UpdateCategory"
+	ProductService_MoveCategory_FullMethodName   = "/products.ProductService/MoveCategory"
+	ProductService_DeleteCategory_FullMethodName = "/products.ProductService/DeleteCategory"
+	ProductService_GetVariant_FullMethodName     = "/products.ProductService/GetVariant"
 )
 
 // ProductServiceClient is the client API for ProductService service.
@@ -29,6 +39,19 @@ const (
 type ProductServiceClient interface {
 	GetProducts(ctx context.Context, in *GetProductsRequest, opts ...grpc.CallOption) (*GetProductsResponse, error)
 	CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// Not served by the gateway, the trade service looks the products of its sales up with it
+	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error)
+	// The gateway serves ImportProducts on POST /v1/products/import and ExportProducts on
+	// GET /v1/products/export, in CSV or NDJSON, see routes.importProductsHandler.
+	ImportProducts(ctx context.Context, opts ...grpc.CallOption) (ProductService_ImportProductsClient, error)
+	ExportProducts(ctx context.Context, in *ExportProductsRequest, opts ...grpc.CallOption) (ProductService_ExportProductsClient, error)
+	CreateCategory(ctx context.Context, in *CreateCategoryRequest, opts ...grpc.CallOption) (*Category, error)
+	GetCategory(ctx context.Context, in *GetCategoryRequest, opts ...grpc.CallOption) (*Category, error)
+	ListCategories(ctx context.Context, in *ListCategoriesRequest, opts ...grpc.CallOption) (*ListCategoriesResponse, error)
+	UpdateCategory(ctx context.Context, in *UpdateCategoryRequest, opts ...grpc.CallOption) (*Category, error)
+	MoveCategory(ctx context.Context, in *MoveCategoryRequest, opts ...grpc.CallOption) (*Category, error)
+	DeleteCategory(ctx context.Context, in *DeleteCategoryRequest, opts ...grpc.CallOption) (*DeleteCategoryResponse, error)
+	GetVariant(ctx context.Context, in *GetVariantRequest, opts ...grpc.CallOption) (*Variant, error)
 }
 
 type productServiceClient struct {
@@ -57,12 +80,163 @@ func (c *productServiceClient) CreateProduct(ctx context.Context, in *CreateProd
 	return out, nil
 }
 
+func (c *productServiceClient) GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, ProductService_GetProduct_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) ImportProducts(ctx context.Context, opts ...grpc.CallOption) (ProductService_ImportProductsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ProductService_ServiceDesc.Streams[0], ProductService_ImportProducts_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &productServiceImportProductsClient{stream}
+	return x, nil
+}
+
+type ProductService_ImportProductsClient interface {
+	Send(*ImportProductsRequest) error
+	CloseAndRecv() (*ImportProductsResponse, error)
+	grpc.ClientStream
+}
+
+type productServiceImportProductsClient struct {
+	grpc.ClientStream
+}
+
+func (x *productServiceImportProductsClient) Send(m *ImportProductsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *productServiceImportProductsClient) CloseAndRecv() (*ImportProductsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportProductsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *productServiceClient) ExportProducts(ctx context.Context, in *ExportProductsRequest, opts ...grpc.CallOption) (ProductService_ExportProductsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ProductService_ServiceDesc.Streams[1], ProductService_ExportProducts_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &productServiceExportProductsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ProductService_ExportProductsClient interface {
+	Recv() (*Product, error)
+	grpc.ClientStream
+}
+
+type productServiceExportProductsClient struct {
+	grpc.ClientStream
+}
+
+func (x *productServiceExportProductsClient) Recv() (*Product, error) {
+	m := new(Product)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *productServiceClient) CreateCategory(ctx context.Context, in *CreateCategoryRequest, opts ...grpc.CallOption) (*Category, error) {
+	out := new(Category)
+	err := c.cc.Invoke(ctx, ProductService_CreateCategory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) GetCategory(ctx context.Context, in *GetCategoryRequest, opts ...grpc.CallOption) (*Category, error) {
+	out := new(Category)
+	err := c.cc.Invoke(ctx, ProductService_GetCategory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) ListCategories(ctx context.Context, in *ListCategoriesRequest, opts ...grpc.CallOption) (*ListCategoriesResponse, error) {
+	out := new(ListCategoriesResponse)
+	err := c.cc.Invoke(ctx, ProductService_ListCategories_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) UpdateCategory(ctx context.Context, in *UpdateCategoryRequest, opts ...grpc.CallOption) (*Category, error) {
+	out := new(Category)
+	err := c.cc.Invoke(ctx, ProductService_UpdateCategory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) MoveCategory(ctx context.Context, in *MoveCategoryRequest, opts ...grpc.CallOption) (*Category, error) {
+	out := new(Category)
+	err := c.cc.Invoke(ctx, ProductService_MoveCategory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) DeleteCategory(ctx context.Context, in *DeleteCategoryRequest, opts ...grpc.CallOption) (*DeleteCategoryResponse, error) {
+	out := new(DeleteCategoryResponse)
+	err := c.cc.Invoke(ctx, ProductService_DeleteCategory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) GetVariant(ctx context.Context, in *GetVariantRequest, opts ...grpc.CallOption) (*Variant, error) {
+	out := new(Variant)
+	err := c.cc.Invoke(ctx, ProductService_GetVariant_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProductServiceServer is the server API for ProductService service.
 // All implementations must embed UnimplementedProductServiceServer
 // for forward compatibility
 type ProductServiceServer interface {
 	GetProducts(context.Context, *GetProductsRequest) (*GetProductsResponse, error)
 	CreateProduct(context.Context, *CreateProductRequest) (*Product, error)
+	// Not served by the gateway, the trade service looks the products of its sales up with it
+	GetProduct(context.Context, *GetProductRequest) (*Product, error)
+	// The gateway serves ImportProducts on POST /v1/products/import and ExportProducts on
+	// GET /v1/products/export, in CSV or NDJSON, see routes.importProductsHandler.
+	ImportProducts(ProductService_ImportProductsServer) error
+	ExportProducts(*ExportProductsRequest, ProductService_ExportProductsServer) error
+	CreateCategory(context.Context, *CreateCategoryRequest) (*Category, error)
+	GetCategory(context.Context, *GetCategoryRequest) (*Category, error)
+	ListCategories(context.Context, *ListCategoriesRequest) (*ListCategoriesResponse, error)
+	UpdateCategory(context.Context, *UpdateCategoryRequest) (*Category, error)
+	MoveCategory(context.Context, *MoveCategoryRequest) (*Category, error)
+	DeleteCategory(context.Context, *DeleteCategoryRequest) (*DeleteCategoryResponse, error)
+	GetVariant(context.Context, *GetVariantRequest) (*Variant, error)
 	mustEmbedUnimplementedProductServiceServer()
 }
 
@@ -76,6 +250,36 @@ func (UnimplementedProductServiceServer) GetProducts(context.Context, *GetProduc
 func (UnimplementedProductServiceServer) CreateProduct(context.Context, *CreateProductRequest) (*Product, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateProduct not implemented")
 }
+func (UnimplementedProductServiceServer) GetProduct(context.Context, *GetProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProduct not implemented")
+}
+func (UnimplementedProductServiceServer) ImportProducts(ProductService_ImportProductsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportProducts not implemented")
+}
+func (UnimplementedProductServiceServer) ExportProducts(*ExportProductsRequest, ProductService_ExportProductsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportProducts not implemented")
+}
+func (UnimplementedProductServiceServer) CreateCategory(context.Context, *CreateCategoryRequest) (*Category, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCategory not implemented")
+}
+func (UnimplementedProductServiceServer) GetCategory(context.Context, *GetCategoryRequest) (*Category, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCategory not implemented")
+}
+func (UnimplementedProductServiceServer) ListCategories(context.Context, *ListCategoriesRequest) (*ListCategoriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCategories not implemented")
+}
+func (UnimplementedProductServiceServer) UpdateCategory(context.Context, *UpdateCategoryRequest) (*Category, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCategory not implemented")
+}
+func (UnimplementedProductServiceServer) MoveCategory(context.Context, *MoveCategoryRequest) (*Category, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MoveCategory not implemented")
+}
+func (UnimplementedProductServiceServer) DeleteCategory(context.Context, *DeleteCategoryRequest) (*DeleteCategoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCategory not implemented")
+}
+func (UnimplementedProductServiceServer) GetVariant(context.Context, *GetVariantRequest) (*Variant, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVariant not implemented")
+}
 func (UnimplementedProductServiceServer) mustEmbedUnimplementedProductServiceServer() {}
 
 // UnsafeProductServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -125,6 +329,197 @@ func _ProductService_CreateProduct_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProductService_GetProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).GetProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_GetProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).GetProduct(ctx, req.(*GetProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_ImportProducts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ProductServiceServer).ImportProducts(&productServiceImportProductsServer{stream})
+}
+
+type ProductService_ImportProductsServer interface {
+	SendAndClose(*ImportProductsResponse) error
+	Recv() (*ImportProductsRequest, error)
+	grpc.ServerStream
+}
+
+type productServiceImportProductsServer struct {
+	grpc.ServerStream
+}
+
+func (x *productServiceImportProductsServer) SendAndClose(m *ImportProductsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *productServiceImportProductsServer) Recv() (*ImportProductsRequest, error) {
+	m := new(ImportProductsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ProductService_ExportProducts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportProductsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProductServiceServer).ExportProducts(m, &productServiceExportProductsServer{stream})
+}
+
+type ProductService_ExportProductsServer interface {
+	Send(*Product) error
+	grpc.ServerStream
+}
+
+type productServiceExportProductsServer struct {
+	grpc.ServerStream
+}
+
+func (x *productServiceExportProductsServer) Send(m *Product) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ProductService_CreateCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).CreateCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_CreateCategory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).CreateCategory(ctx, req.(*CreateCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_GetCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).GetCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_GetCategory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).GetCategory(ctx, req.(*GetCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_ListCategories_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCategoriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).ListCategories(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_ListCategories_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).ListCategories(ctx, req.(*ListCategoriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_UpdateCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).UpdateCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_UpdateCategory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).UpdateCategory(ctx, req.(*UpdateCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_MoveCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MoveCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).MoveCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_MoveCategory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).MoveCategory(ctx, req.(*MoveCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_DeleteCategory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCategoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).DeleteCategory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_DeleteCategory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).DeleteCategory(ctx, req.(*DeleteCategoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_GetVariant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVariantRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).GetVariant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_GetVariant_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).GetVariant(ctx, req.(*GetVariantRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProductService_ServiceDesc is the grpc.ServiceDesc for ProductService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -140,7 +535,50 @@ var ProductService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateProduct",
 			Handler:    _ProductService_CreateProduct_Handler,
 		},
+		{
+			MethodName: "GetProduct",
+			Handler:    _ProductService_GetProduct_Handler,
+		},
+		{
+			MethodName: "CreateCategory",
+			Handler:    _ProductService_CreateCategory_Handler,
+		},
+		{
+			MethodName: "GetCategory",
+			Handler:    _ProductService_GetCategory_Handler,
+		},
+		{
+			MethodName: "ListCategories",
+			Handler:    _ProductService_ListCategories_Handler,
+		},
+		{
+			MethodName: "UpdateCategory",
+			Handler:    _ProductService_UpdateCategory_Handler,
+		},
+		{
+			MethodName: "MoveCategory",
+			Handler:    _ProductService_MoveCategory_Handler,
+		},
+		{
+			MethodName: "DeleteCategory",
+			Handler:    _ProductService_DeleteCategory_Handler,
+		},
+		{
+			MethodName: "GetVariant",
+			Handler:    _ProductService_GetVariant_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportProducts",
+			Handler:       _ProductService_ImportProducts_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportProducts",
+			Handler:       _ProductService_ExportProducts_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "protos/products/product.proto",
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog/leveladmin"
+)
+
+// SetupAdminRoutes returns the handler of the admin address, which must not be exposed publicly
+func SetupAdminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	// GET the log levels, PUT {"package":"...","level":"debug","ttl":"15m"}, DELETE ?package=
+	mux.Handle("/admin/loglevels", leveladmin.NewServer(nil))
+	return mux
+}
@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/protos/products"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	importProductsPath = "/v1/products/import"
+	exportProductsPath = "/v1/products/export"
+
+	contentTypeCSV    = "text/csv"
+	contentTypeNDJSON = "application/x-ndjson"
+
+	// maxImportBytes caps the size of an imported file
+	maxImportBytes = 32 << 20
+	// maxNDJSONLine caps the length of an imported NDJSON line
+	maxNDJSONLine = 1 << 20
+)
+
+// importProductsHandler streams the rows of a CSV (text/csv, with a sku,name,price header) or NDJSON
+// (application/x-ndjson, a {"sku","name","price"} object per line) body to ImportProducts, and
+// writes its summary. The rows are numbered with their line in the body, ?dry_run=true only
+// validates them. A malformed body is rejected, the rows before it are imported.
+func importProductsHandler(mux *runtime.ServeMux, client products.ProductServiceClient) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		metrics.SetRoute(r.Context(), importProductsPath)
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux, r)
+
+		var readRows func(io.Reader, func(*products.ImportProductsRequest) error) error
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case contentTypeCSV:
+			readRows = readCSVRows
+		case contentTypeNDJSON, "application/ndjson":
+			readRows = readNDJSONRows
+		default:
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, status.Errorf(codes.InvalidArgument, "unsupported Content-Type %q, import %s or %s", mediaType, contentTypeCSV, contentTypeNDJSON))
+			return
+		}
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		// Cancelling the import (a malformed body) keeps the rows imported so far, CloseSend would
+		// import them as a complete file
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stream, err := client.ImportProducts(ctx)
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, err)
+			return
+		}
+		first := true
+		readErr := readRows(http.MaxBytesReader(w, r.Body, maxImportBytes), func(row *products.ImportProductsRequest) error {
+			row.DryRun = dryRun && first
+			first = false
+			return stream.Send(row)
+		})
+		// io.EOF from Send: the server ended the import, its error is returned by CloseAndRecv
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, status.Error(codes.InvalidArgument, readErr.Error()))
+			return
+		}
+
+		summary, err := stream.CloseAndRecv()
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, err)
+			return
+		}
+		// an empty file sends no row to carry the flag
+		summary.DryRun = dryRun
+		body, err := outboundMarshaler.Marshal(summary)
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", outboundMarshaler.ContentType(summary))
+		_, _ = w.Write(body)
+	}
+}
+
+// readCSVRows calls send with every row of a CSV file, the columns are named by its header
+func readCSVRows(body io.Reader, send func(*products.ImportProductsRequest) error) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Excel saves UTF-8 CSV files with a byte order mark
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"sku", "name", "price"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("invalid CSV header: no %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i := columns[name]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if err := send(&products.ImportProductsRequest{
+			Row:   int32(line),
+			Sku:   field(record, "sku"),
+			Name:  field(record, "name"),
+			Price: field(record, "price"),
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// ndjsonRow is an imported NDJSON line, the price is a string or a number
+type ndjsonRow struct {
+	SKU   string          `json:"sku"`
+	Name  string          `json:"name"`
+	Price json.RawMessage `json:"price"`
+}
+
+// readNDJSONRows calls send with every object of an NDJSON file, skipping the blank lines
+func readNDJSONRows(body io.Reader, send func(*products.ImportProductsRequest) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var row ndjsonRow
+		if err := json.Unmarshal(text, &row); err != nil {
+			return fmt.Errorf("invalid NDJSON on line %d: %v", line, err)
+		}
+		price := string(row.Price)
+		if strings.HasPrefix(price, `"`) {
+			if err := json.Unmarshal(row.Price, &price); err != nil {
+				return fmt.Errorf("invalid NDJSON price on line %d: %v", line, err)
+			}
+		}
+		if err := send(&products.ImportProductsRequest{Row: int32(line), Sku: row.SKU, Name: row.Name, Price: price}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("invalid NDJSON: %v", err)
+	}
+	return nil
+}
+
+// exportProductsHandler writes every product streamed by ExportProducts as CSV or NDJSON, chosen
+// with ?format=csv|ndjson, or else the Accept header (NDJSON unless text/csv is accepted)
+func exportProductsHandler(mux *runtime.ServeMux, client products.ProductServiceClient) runtime.HandlerFunc {
+	marshaler := &runtime.JSONPb{}
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		metrics.SetRoute(r.Context(), exportProductsPath)
+		_, outboundMarshaler := runtime.MarshalerForRequest(mux, r)
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "ndjson"
+			if strings.Contains(r.Header.Get("Accept"), contentTypeCSV) {
+				format = "csv"
+			}
+		}
+		if format != "csv" && format != "ndjson" {
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, status.Errorf(codes.InvalidArgument, "unsupported format %q, export csv or ndjson", format))
+			return
+		}
+
+		stream, err := client.ExportProducts(r.Context(), &products.ExportProductsRequest{})
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, err)
+			return
+		}
+		// the errors before the first product are still sent as HTTP errors
+		product, err := stream.Recv()
+		if err != nil && !errors.Is(err, io.EOF) {
+			runtime.HTTPError(r.Context(), mux, outboundMarshaler, w, r, err)
+			return
+		}
+
+		var write func(*products.Product) error
+		var flush func() error
+		if format == "csv" {
+			w.Header().Set("Content-Type", contentTypeCSV+"; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+			writer := csv.NewWriter(w)
+			if err := writer.Write([]string{"id", "sku", "name", "price"}); err != nil {
+				return
+			}
+			write = func(product *products.Product) error {
+				return writer.Write([]string{product.GetId(), product.GetSku(), product.GetName(), product.GetPrice()})
+			}
+			flush = func() error {
+				writer.Flush()
+				return writer.Error()
+			}
+		} else {
+			w.Header().Set("Content-Type", contentTypeNDJSON)
+			w.Header().Set("Content-Disposition", `attachment; filename="products.ndjson"`)
+			write = func(product *products.Product) error {
+				line, err := marshaler.Marshal(product)
+				if err != nil {
+					return err
+				}
+				_, err = w.Write(append(line, '\n'))
+				return err
+			}
+			flush = func() error { return nil }
+		}
+
+		for ; err == nil; product, err = stream.Recv() {
+			if err = write(product); err != nil {
+				break
+			}
+		}
+		if flushErr := flush(); err == nil || errors.Is(err, io.EOF) {
+			err = flushErr
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			// the status is sent, the truncated export can only be logged
+			srvlog.WithContext(r.Context()).Warnf("Product export truncated: %v", err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/protos/products"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeProductClient records the imported rows and exports its products
+type fakeProductClient struct {
+	products.ProductServiceClient
+	imported  []*products.ImportProductsRequest
+	exported  []*products.Product
+	exportErr error
+}
+
+func (f *fakeProductClient) ImportProducts(ctx context.Context, opts ...grpc.CallOption) (products.ProductService_ImportProductsClient, error) {
+	return &fakeImportClient{ctx: ctx, client: f}, nil
+}
+
+func (f *fakeProductClient) ExportProducts(ctx context.Context, in *products.ExportProductsRequest, opts ...grpc.CallOption) (products.ProductService_ExportProductsClient, error) {
+	return &fakeExportClient{products: f.exported, err: f.exportErr}, nil
+}
+
+type fakeImportClient struct {
+	grpc.ClientStream
+	ctx    context.Context
+	client *fakeProductClient
+}
+
+func (f *fakeImportClient) Send(row *products.ImportProductsRequest) error {
+	f.client.imported = append(f.client.imported, row)
+	return nil
+}
+
+func (f *fakeImportClient) CloseAndRecv() (*products.ImportProductsResponse, error) {
+	summary := &products.ImportProductsResponse{}
+	for _, row := range f.client.imported {
+		summary.DryRun = summary.DryRun || row.DryRun
+		summary.Total++
+		summary.Created++
+	}
+	return summary, nil
+}
+
+type fakeExportClient struct {
+	grpc.ClientStream
+	products []*products.Product
+	err      error
+}
+
+func (f *fakeExportClient) Recv() (*products.Product, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if len(f.products) == 0 {
+		return nil, io.EOF
+	}
+	product := f.products[0]
+	f.products = f.products[1:]
+	return product, nil
+}
+
+func TestImportProductsHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		target      string
+		body        string
+		wantCode    int
+		wantRows    []*products.ImportProductsRequest
+		wantDryRun  bool
+	}{
+		{
+			name:        "CSV",
+			contentType: "text/csv; charset=utf-8",
+			target:      importProductsPath + "?dry_run=true",
+			body:        "\ufeffName,SKU,Price,Notes\nKiwi,KIWI,2.50,green\n\"Feijoa, ripe\",FEIJOA,4\n",
+			wantCode:    http.StatusOK,
+			wantRows: []*products.ImportProductsRequest{
+				{DryRun: true, Row: 2, Sku: "KIWI", Name: "Kiwi", Price: "2.50"},
+				{Row: 3, Sku: "FEIJOA", Name: "Feijoa, ripe", Price: "4"},
+			},
+			wantDryRun: true,
+		},
+		{
+			name:        "NDJSON",
+			contentType: "application/x-ndjson",
+			target:      importProductsPath,
+			body:        "{\"sku\":\"KIWI\",\"name\":\"Kiwi\",\"price\":\"2.50\"}\n\n{\"sku\":\"FEIJOA\",\"name\":\"Feijoa\",\"price\":4}\n",
+			wantCode:    http.StatusOK,
+			wantRows: []*products.ImportProductsRequest{
+				{Row: 1, Sku: "KIWI", Name: "Kiwi", Price: "2.50"},
+				{Row: 3, Sku: "FEIJOA", Name: "Feijoa", Price: "4"},
+			},
+		},
+		{
+			name:        "CSV Without Price Column",
+			contentType: "text/csv",
+			target:      importProductsPath,
+			body:        "sku,name\nKIWI,Kiwi\n",
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Malformed NDJSON Line",
+			contentType: "application/x-ndjson",
+			target:      importProductsPath,
+			body:        "{\"sku\":\"KIWI\",\"name\":\"Kiwi\",\"price\":\"2.50\"}\n{\"sku\":\n",
+			wantCode:    http.StatusBadRequest,
+			wantRows:    []*products.ImportProductsRequest{{Row: 1, Sku: "KIWI", Name: "Kiwi", Price: "2.50"}},
+		},
+		{
+			name:        "Unsupported Content Type",
+			contentType: "application/json",
+			target:      importProductsPath,
+			body:        "[]",
+			wantCode:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeProductClient{}
+			mux := runtime.NewServeMux()
+			if err := mux.HandlePath(http.MethodPost, importProductsPath, importProductsHandler(mux, client)); err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("code %d, want %d: %s", recorder.Code, tt.wantCode, recorder.Body)
+			}
+			if len(client.imported) != len(tt.wantRows) {
+				t.Fatalf("imported %v, want %v", client.imported, tt.wantRows)
+			}
+			for i := range tt.wantRows {
+				if !proto.Equal(client.imported[i], tt.wantRows[i]) {
+					t.Errorf("imported row %v, want %v", client.imported[i], tt.wantRows[i])
+				}
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var summary struct {
+				DryRun bool  `json:"dryRun"`
+				Total  int32 `json:"total"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &summary); err != nil {
+				t.Fatalf("summary %s: %v", recorder.Body, err)
+			}
+			if summary.DryRun != tt.wantDryRun || summary.Total != int32(len(tt.wantRows)) {
+				t.Errorf("summary %s", recorder.Body)
+			}
+		})
+	}
+}
+
+func TestExportProductsHandler(t *testing.T) {
+	exported := []*products.Product{
+		{Id: "1", Sku: "KIWI", Name: "Kiwi", Price: "2.50"},
+		{Id: "2", Name: "Feijoa, ripe", Price: "4"},
+	}
+
+	tests := []struct {
+		name            string
+		target          string
+		accept          string
+		exportErr       error
+		wantCode        int
+		wantContentType string
+		wantBody        []string
+	}{
+		{
+			name:            "CSV",
+			target:          exportProductsPath + "?format=csv",
+			wantCode:        http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        []string{"id,sku,name,price\n1,KIWI,Kiwi,2.50\n2,,\"Feijoa, ripe\",4\n"},
+		},
+		{
+			name:            "CSV Accepted",
+			target:          exportProductsPath,
+			accept:          "text/csv",
+			wantCode:        http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+		},
+		{
+			name:            "NDJSON",
+			target:          exportProductsPath,
+			wantCode:        http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantBody:        []string{`"sku":`, `"Feijoa, ripe"`},
+		},
+		{
+			name:     "Unsupported Format",
+			target:   exportProductsPath + "?format=xlsx",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:      "Service Error",
+			target:    exportProductsPath,
+			exportErr: status.Error(codes.Unavailable, "redis is down"),
+			wantCode:  http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeProductClient{exported: exported, exportErr: tt.exportErr}
+			mux := runtime.NewServeMux()
+			if err := mux.HandlePath(http.MethodGet, exportProductsPath, exportProductsHandler(mux, client)); err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("code %d, want %d: %s", recorder.Code, tt.wantCode, recorder.Body)
+			}
+			if tt.wantContentType != "" && recorder.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("Content-Type %q, want %q", recorder.Header().Get("Content-Type"), tt.wantContentType)
+			}
+			if tt.wantContentType == contentTypeNDJSON {
+				if lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n"); len(lines) != len(exported) {
+					t.Errorf("%d NDJSON lines, want %d: %s", len(lines), len(exported), recorder.Body)
+				}
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("body %q does not contain %q", recorder.Body, want)
+				}
+			}
+		})
+	}
+}
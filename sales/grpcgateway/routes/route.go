@@ -4,9 +4,16 @@ import (
 	"context"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/middleware/ratelimit"
+	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/middleware/recovery"
 	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/protos/products"
 	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/protos/trade"
+	"github.com/ramseyjiang/go-micros/shared/healthcheck"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/requestlog"
+	"github.com/ramseyjiang/go-micros/shared/tracing"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"log"
 	"net/http"
 	"os"
@@ -19,9 +26,29 @@ const (
 	tradeServiceEnvVar    = "TRADE_SERVICE_ADDR"
 )
 
-func SetupRoutes(ctx context.Context) http.Handler {
-	mux := runtime.NewServeMux()
-	opts := []grpc.DialOption{grpc.WithInsecure()}
+// SetupRoutes returns the handler of the API, and of the /healthz and /readyz probes
+// reporting the health of the backends added to health
+func SetupRoutes(ctx context.Context, health *healthcheck.Aggregator) http.Handler {
+	mux := runtime.NewServeMux(
+		// Label the HTTP metrics with the matched route pattern, never the raw path
+		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			if pattern, ok := runtime.HTTPPathPattern(ctx); ok {
+				metrics.SetRoute(r.Context(), pattern)
+			}
+			return nil
+		}),
+	)
+	opts := []grpc.DialOption{
+		grpc.WithInsecure(),
+		// Trace the calls to the services as children of the HTTP request span
+		tracing.DialOption(),
+		// Count and time the calls to the services
+		metrics.UnaryClientInterceptor(),
+		metrics.StreamClientInterceptor(),
+		// Forward the request ID of the HTTP request to the services
+		requestlog.UnaryClientInterceptor(),
+		requestlog.StreamClientInterceptor(),
+	}
 
 	// ---------   Register gRPC handlers start   ---------------
 	// Register product service handler
@@ -29,23 +56,62 @@ func SetupRoutes(ctx context.Context) http.Handler {
 	if productServiceAddress == "" {
 		productServiceAddress = defaultProductService
 	}
-	if err := products.RegisterProductServiceHandlerFromEndpoint(ctx, mux, productServiceAddress, opts); err != nil {
+	productConn := dialService(ctx, productServiceAddress, opts)
+	if err := products.RegisterProductServiceHandler(ctx, mux, productConn); err != nil {
 		log.Fatalf("Failed to register product gRPC gateway: %v", err)
 	}
+	health.AddBackend("products", healthpb.NewHealthClient(productConn), "")
+	// The bulk import and export stream CSV or NDJSON, one request instead of one per product
+	productClient := products.NewProductServiceClient(productConn)
+	if err := mux.HandlePath(http.MethodPost, importProductsPath, importProductsHandler(mux, productClient)); err != nil {
+		log.Fatalf("Failed to register the product import: %v", err)
+	}
+	if err := mux.HandlePath(http.MethodGet, exportProductsPath, exportProductsHandler(mux, productClient)); err != nil {
+		log.Fatalf("Failed to register the product export: %v", err)
+	}
 
 	// Register trade service handler
 	tradeServiceAddress := os.Getenv(tradeServiceEnvVar)
 	if tradeServiceAddress == "" {
 		tradeServiceAddress = defaultTradeService
 	}
-	if err := trade.RegisterSalesServiceHandlerFromEndpoint(ctx, mux, tradeServiceAddress, opts); err != nil {
+	tradeConn := dialService(ctx, tradeServiceAddress, opts)
+	if err := trade.RegisterSalesServiceHandler(ctx, mux, tradeConn); err != nil {
 		log.Fatalf("Failed to register trade gRPC gateway: %v", err)
 	}
+	health.AddBackend("trade", healthpb.NewHealthClient(tradeConn), "")
+	// WatchSales is streamed as Server-Sent Events, ending with the shutdown so the clients reconnect elsewhere
+	if err := mux.HandlePath(http.MethodGet, watchSalesPath, watchSalesHandler(mux, trade.NewSalesServiceClient(tradeConn), health.ShuttingDown())); err != nil {
+		log.Fatalf("Failed to register the sales stream: %v", err)
+	}
 	// ---------   Register gRPC handlers end   ---------------
 
 	// Initialize the BucketStore
 	bucketStore := ratelimit.NewBucketStore(ratelimit.DefaultRate, ratelimit.DefaultWindow) // Default 10 requests per minute
 
-	// Apply the rate limiting middleware
-	return ratelimit.Impl(bucketStore)(mux)
+	// Apply the rate limiting middleware, recovering from panics in any handler,
+	// with every log line of a request sharing its X-Request-ID and its trace, and the request metrics recorded
+	api := tracing.HTTPMiddleware(metrics.HTTPMiddleware(requestlog.HTTPMiddleware(recovery.Impl()(ratelimit.Impl(bucketStore)(mux)))))
+
+	// The probes are never rate limited
+	root := http.NewServeMux()
+	root.Handle("/healthz", health.LivenessHandler())
+	root.Handle("/readyz", health.ReadinessHandler())
+	root.Handle("/", api)
+	return root
+}
+
+// dialService connects to a backend service, the connection is closed when ctx is done
+func dialService(ctx context.Context, address string, opts []grpc.DialOption) *grpc.ClientConn {
+	conn, err := grpc.DialContext(ctx, address, opts...)
+	if err != nil {
+		log.Fatalf("Failed to dial %s: %v", address, err)
+	}
+	go func() {
+		<-ctx.Done()
+		if err := conn.Close(); err != nil {
+			log.Printf("Failed to close conn to %s: %v", address, err)
+		}
+	}()
+	return conn
 }
@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/protos/trade"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// watchSalesPath streams the created sales as Server-Sent Events
+	watchSalesPath = "/v1/sales/watch"
+	// sseKeepAlive is the interval of the comments keeping idle streams open through proxies
+	sseKeepAlive = 15 * time.Second
+	// sseRetry is the reconnection delay sent to the clients, in milliseconds
+	sseRetry = 3000
+)
+
+// watchSalesHandler serves WatchSales as Server-Sent Events: a "sale" event per sale, its id being
+// the cursor, so a reconnecting EventSource (Last-Event-ID) resumes after the last sale it received.
+// The query parameters are product_id (repeatable), min_total and cursor. An error once streaming
+// is sent as an "error" event, and the stream ends once shuttingDown is closed.
+func watchSalesHandler(mux *runtime.ServeMux, client trade.SalesServiceClient, shuttingDown <-chan struct{}) runtime.HandlerFunc {
+	marshaler := &runtime.JSONPb{}
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		metrics.SetRoute(r.Context(), watchSalesPath)
+
+		req, err := watchSalesRequest(r)
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, err)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, status.Error(codes.Internal, "streaming is not supported"))
+			return
+		}
+		select {
+		case <-shuttingDown:
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, status.Error(codes.Unavailable, "shutting down"))
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stream, err := client.WatchSales(ctx, req)
+		if err != nil {
+			runtime.HTTPError(r.Context(), mux, marshaler, w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		// Not buffered by nginx
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+		flusher.Flush()
+
+		// Recv blocks, the keep-alives and the shutdown are handled while waiting for it
+		events := make(chan *trade.SaleEvent)
+		errs := make(chan error, 1)
+		go func() {
+			for {
+				event, err := stream.Recv()
+				if err != nil {
+					errs <- err
+					return
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case event := <-events:
+				data, err := marshaler.Marshal(event.GetSale())
+				if err != nil {
+					writeSSEError(w, marshaler, status.Errorf(codes.Internal, "unable to encode sale (%s): %v", event.GetSale().GetSaleId(), err))
+					flusher.Flush()
+					return
+				}
+				fmt.Fprintf(w, "id: %s\nevent: sale\ndata: %s\n\n", event.GetCursor(), data)
+				flusher.Flush()
+			case err := <-errs:
+				if !errors.Is(err, io.EOF) && r.Context().Err() == nil {
+					writeSSEError(w, marshaler, err)
+					flusher.Flush()
+				}
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case <-shuttingDown:
+				// the client reconnects, to another instance, from its last event
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// watchSalesRequest reads the WatchSales request from the query, the Last-Event-ID header of a
+// reconnecting EventSource taking precedence over the cursor parameter
+func watchSalesRequest(r *http.Request) (*trade.WatchSalesRequest, error) {
+	query := r.URL.Query()
+	req := &trade.WatchSalesRequest{
+		ProductIds: query["product_id"],
+		Cursor:     query.Get("cursor"),
+	}
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		req.Cursor = lastEventID
+	}
+	if minTotal := query.Get("min_total"); minTotal != "" {
+		value, err := strconv.ParseFloat(minTotal, 32)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid min_total %q", minTotal)
+		}
+		req.MinTotal = float32(value)
+	}
+	return req, nil
+}
+
+// writeSSEError sends err as an "error" event, its data being the JSON of the gRPC status
+func writeSSEError(w io.Writer, marshaler runtime.Marshaler, err error) {
+	data, marshalErr := marshaler.Marshal(status.Convert(err).Proto())
+	if marshalErr != nil {
+		data = []byte(`{"code":13,"message":"unable to encode the error"}`)
+	}
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+}
@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/ramseyjiang/go-micros/sales/grpc-gateway/protos/trade"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSalesClient streams events then ends with err
+type fakeSalesClient struct {
+	trade.SalesServiceClient
+	events []*trade.SaleEvent
+	err    error
+	req    *trade.WatchSalesRequest
+}
+
+func (f *fakeSalesClient) WatchSales(ctx context.Context, in *trade.WatchSalesRequest, opts ...grpc.CallOption) (trade.SalesService_WatchSalesClient, error) {
+	f.req = in
+	return &fakeWatchSalesClient{events: f.events, err: f.err}, nil
+}
+
+type fakeWatchSalesClient struct {
+	grpc.ClientStream
+	events []*trade.SaleEvent
+	err    error
+}
+
+func (f *fakeWatchSalesClient) Recv() (*trade.SaleEvent, error) {
+	if len(f.events) == 0 {
+		return nil, f.err
+	}
+	event := f.events[0]
+	f.events = f.events[1:]
+	return event, nil
+}
+
+func TestWatchSalesHandler(t *testing.T) {
+	sales := []*trade.SaleEvent{
+		{Cursor: "1-0", Sale: &trade.Sale{SaleId: "1", TotalPrice: 10}},
+		{Cursor: "2-0", Sale: &trade.Sale{SaleId: "2", TotalPrice: 20}},
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		lastEventID  string
+		shuttingDown bool
+		streamErr    error
+		wantCode     int
+		wantReq      *trade.WatchSalesRequest
+		wantBody     []string
+	}{
+		{
+			name:     "Streamed",
+			target:   watchSalesPath + "?product_id=1&product_id=2&min_total=5&cursor=1-0",
+			wantCode: http.StatusOK,
+			wantReq:  &trade.WatchSalesRequest{ProductIds: []string{"1", "2"}, MinTotal: 5, Cursor: "1-0"},
+			wantBody: []string{"retry: 3000\n\n", "id: 1-0\nevent: sale\ndata: {", `"saleId"`, "id: 2-0\nevent: sale\n"},
+		},
+		{
+			name:        "Resumed From Last Event ID",
+			target:      watchSalesPath + "?cursor=1-0",
+			lastEventID: "2-0",
+			wantCode:    http.StatusOK,
+			wantReq:     &trade.WatchSalesRequest{Cursor: "2-0"},
+		},
+		{
+			name:      "Error Event",
+			target:    watchSalesPath + "?cursor=last",
+			streamErr: status.Error(codes.InvalidArgument, "invalid cursor"),
+			wantCode:  http.StatusOK,
+			// protojson randomly adds spaces
+			wantBody: []string{"event: error\ndata: {", `"invalid cursor"`},
+		},
+		{
+			name:     "Invalid Min Total",
+			target:   watchSalesPath + "?min_total=lots",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Shutting Down",
+			target:       watchSalesPath,
+			shuttingDown: true,
+			wantCode:     http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamErr := tt.streamErr
+			if streamErr == nil {
+				streamErr = io.EOF
+			}
+			client := &fakeSalesClient{events: sales, err: streamErr}
+			shuttingDown := make(chan struct{})
+			if tt.shuttingDown {
+				close(shuttingDown)
+			}
+			mux := runtime.NewServeMux()
+			if err := mux.HandlePath(http.MethodGet, watchSalesPath, watchSalesHandler(mux, client, shuttingDown)); err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("code %d, want %d: %s", recorder.Code, tt.wantCode, recorder.Body)
+			}
+			if tt.wantCode == http.StatusOK && recorder.Header().Get("Content-Type") != "text/event-stream" {
+				t.Errorf("Content-Type %q", recorder.Header().Get("Content-Type"))
+			}
+			if tt.wantReq != nil && (client.req.GetCursor() != tt.wantReq.GetCursor() || client.req.GetMinTotal() != tt.wantReq.GetMinTotal() ||
+				strings.Join(client.req.GetProductIds(), ",") != strings.Join(tt.wantReq.GetProductIds(), ",")) {
+				t.Errorf("WatchSales() request %v, want %v", client.req, tt.wantReq)
+			}
+			body := recorder.Body.String()
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("body %q does not contain %q", body, want)
+				}
+			}
+		})
+	}
+}
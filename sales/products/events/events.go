@@ -0,0 +1,56 @@
+// Package events defines the events the products service publishes: the domain events
+// (product_events.proto) relayed from its outbox to a Redis Stream, and the product change events
+// published on Redis pub/sub, so that the services caching products (e.g. trade) invalidate them.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Channel is the Redis pub/sub channel of the product change events
+const Channel = "products:changes"
+
+// The domain events are queued in Outbox with the change, then relayed to Stream (see outbox.Relay)
+const (
+	Source = "products"
+	Outbox = "products:outbox"
+	Stream = "events:products"
+)
+
+// ChangeType is the kind of change of a ProductChanged event
+type ChangeType string
+
+// Product changes
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// ProductChanged is published when a product is created, updated or deleted
+type ProductChanged struct {
+	Type      ChangeType `json:"type"`
+	ProductID string     `json:"product_id"`
+}
+
+// Encode returns the pub/sub payload of the event
+func (e ProductChanged) Encode() (string, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// Decode parses the pub/sub payload of a ProductChanged event
+func Decode(payload string) (ProductChanged, error) {
+	var event ProductChanged
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return ProductChanged{}, fmt.Errorf("invalid product change event: %w", err)
+	}
+	if event.ProductID == "" {
+		return ProductChanged{}, fmt.Errorf("invalid product change event: no product ID")
+	}
+	return event, nil
+}
@@ -0,0 +1,267 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v4.25.1
+// source: product_events.proto
+
+package events
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ProductCreated is published once a product is stored
+type ProductCreated struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ProductId string `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Price     string `protobuf:"bytes,3,opt,name=price,proto3" json:"price,omitempty"`
+	Sku       string `protobuf:"bytes,4,opt,name=sku,proto3" json:"sku,omitempty"`
+}
+
+func (x *ProductCreated) Reset() {
+	*x = ProductCreated{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_product_events_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProductCreated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductCreated) ProtoMessage() {}
+
+func (x *ProductCreated) ProtoReflect() protoreflect.Message {
+	mi := &file_product_events_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductCreated.ProtoReflect.Descriptor instead.
+func (*ProductCreated) Descriptor() ([]byte, []int) {
+	return file_product_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ProductCreated) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *ProductCreated) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProductCreated) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *ProductCreated) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+// ProductUpdated is published once a product is changed, with its new values
+type ProductUpdated struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ProductId string `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Price     string `protobuf:"bytes,3,opt,name=price,proto3" json:"price,omitempty"`
+	Sku       string `protobuf:"bytes,4,opt,name=sku,proto3" json:"sku,omitempty"`
+}
+
+func (x *ProductUpdated) Reset() {
+	*x = ProductUpdated{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_product_events_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProductUpdated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductUpdated) ProtoMessage() {}
+
+func (x *ProductUpdated) ProtoReflect() protoreflect.Message {
+	mi := &file_product_events_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductUpdated.ProtoReflect.Descriptor instead.
+func (*ProductUpdated) Descriptor() ([]byte, []int) {
+	return file_product_events_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProductUpdated) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *ProductUpdated) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ProductUpdated) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *ProductUpdated) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+var File_product_events_proto protoreflect.FileDescriptor
+
+var file_product_events_proto_rawDesc = []byte{
+	0x0a, 0x14, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73,
+	0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x22, 0x6b, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x64, 0x75,
+	0x63, 0x74, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x6f,
+	0x64, 0x75, 0x63, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70,
+	0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05,
+	0x70, 0x72, 0x69, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x70, 0x72, 0x69,
+	0x63, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x6b, 0x75, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x73, 0x6b, 0x75, 0x22, 0x6b, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63,
+	0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x64,
+	0x75, 0x63, 0x74, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x69,
+	0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x12,
+	0x10, 0x0a, 0x03, 0x73, 0x6b, 0x75, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x73, 0x6b,
+	0x75, 0x42, 0x3f, 0x5a, 0x3d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x72, 0x61, 0x6d, 0x73, 0x65, 0x79, 0x6a, 0x69, 0x61, 0x6e, 0x67, 0x2f, 0x67, 0x6f, 0x2d, 0x6d,
+	0x69, 0x63, 0x72, 0x6f, 0x73, 0x2f, 0x73, 0x61, 0x6c, 0x65, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x64,
+	0x75, 0x63, 0x74, 0x73, 0x2f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x3b, 0x65, 0x76, 0x65, 0x6e,
+	0x74, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_product_events_proto_rawDescOnce sync.Once
+	file_product_events_proto_rawDescData = file_product_events_proto_rawDesc
+)
+
+func file_product_events_proto_rawDescGZIP() []byte {
+	file_product_events_proto_rawDescOnce.Do(func() {
+		file_product_events_proto_rawDescData = protoimpl.X.CompressGZIP(file_product_events_proto_rawDescData)
+	})
+	return file_product_events_proto_rawDescData
+}
+
+var file_product_events_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_product_events_proto_goTypes = []interface{}{
+	(*ProductCreated)(nil), // 0: products.events.ProductCreated
+	(*ProductUpdated)(nil), // 1: products.events.ProductUpdated
+}
+var file_product_events_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_product_events_proto_init() }
+func file_product_events_proto_init() {
+	if File_product_events_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_product_events_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProductCreated); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_product_events_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProductUpdated); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_product_events_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_product_events_proto_goTypes,
+		DependencyIndexes: file_product_events_proto_depIdxs,
+		MessageInfos:      file_product_events_proto_msgTypes,
+	}.Build()
+	File_product_events_proto = out.File
+	file_product_events_proto_rawDesc = nil
+	file_product_events_proto_goTypes = nil
+	file_product_events_proto_depIdxs = nil
+}
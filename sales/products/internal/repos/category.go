@@ -0,0 +1,384 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+)
+
+type CategoryRepositoryInterface interface {
+	// CreateCategory stores category with a new ID, under its parent if any
+	CreateCategory(ctx context.Context, category *pb.Category) error
+	// GetCategory returns the category with id, ErrCategoryNotFound if there is none
+	GetCategory(ctx context.Context, id string) (*pb.Category, error)
+	// ListCategories returns the subcategories of parentID, or the top level ones, by ID
+	ListCategories(ctx context.Context, parentID string) ([]*pb.Category, error)
+	// CategoryDescendants returns the ID of the category with id, then the ones of its subcategories at any depth
+	CategoryDescendants(ctx context.Context, id string) ([]string, error)
+	// UpdateCategory stores the name and attribute schema of category
+	UpdateCategory(ctx context.Context, category *pb.Category) error
+	// MoveCategory moves the category with id, and its subcategories, under parentID, top level when empty
+	MoveCategory(ctx context.Context, id, parentID string) (*pb.Category, error)
+	// DeleteCategory deletes the category with id, ErrCategoryNotEmpty if it has subcategories or products
+	DeleteCategory(ctx context.Context, id string) error
+}
+
+const (
+	// rootCategoriesKey is the set of the IDs of the top level categories
+	rootCategoriesKey = "categories:roots"
+	// childCategoriesPrefix prefixes the sets of the IDs of the subcategories by parent ID
+	childCategoriesPrefix = "categories:children:"
+	// maxCategoryRetries is how many times a category write is retried when the tree changed meanwhile
+	maxCategoryRetries = 5
+)
+
+var (
+	// ErrCategoryNotFound is returned for a category, or a parent, that does not exist
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryNotEmpty is returned when deleting a category with subcategories or products
+	ErrCategoryNotEmpty = errors.New("category has subcategories or products")
+	// ErrCategoryCycle is returned when moving a category under itself or one of its subcategories
+	ErrCategoryCycle = errors.New("category cannot be moved under itself or one of its subcategories")
+)
+
+// CategoryRepository handles the interaction with Redis for the category tree. A category is
+// a category:<id> hash, and the children of each category a set.
+type CategoryRepository struct {
+	redisClient *redis.Client
+}
+
+// NewCategoryRepository creates a new instance of CategoryRepository, on the client of the product repository.
+func NewCategoryRepository(redisClient *redis.Client) *CategoryRepository {
+	return &CategoryRepository{
+		redisClient: redisClient,
+	}
+}
+
+func categoryKey(id string) string {
+	return fmt.Sprintf("category:%s", id)
+}
+
+// childCategoriesKey returns the key of the set of the subcategories of parentID
+func childCategoriesKey(parentID string) string {
+	if parentID == "" {
+		return rootCategoriesKey
+	}
+	return childCategoriesPrefix + parentID
+}
+
+// watch runs fn in a transaction watching keys, retried when they changed meanwhile
+func (r *CategoryRepository) watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	var err error
+	for attempt := 0; attempt < maxCategoryRetries; attempt++ {
+		err = r.redisClient.Watch(ctx, fn, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+// CreateCategory stores category with a new ID, ErrCategoryNotFound if its parent does not exist.
+func (r *CategoryRepository) CreateCategory(ctx context.Context, category *pb.Category) error {
+	attributes, err := json.Marshal(attributeDefinitionsToJSON(category.Attributes))
+	if err != nil {
+		return fmt.Errorf("error encoding the category attributes: %v", err)
+	}
+
+	var watched []string
+	if category.ParentId != "" {
+		watched = append(watched, categoryKey(category.ParentId))
+	}
+	var categoryID string
+	err = r.watch(ctx, func(tx *redis.Tx) error {
+		if category.ParentId != "" {
+			exists, err := tx.Exists(ctx, categoryKey(category.ParentId)).Result()
+			if err != nil {
+				return fmt.Errorf("error retrieving the parent category: %v", err)
+			}
+			if exists == 0 {
+				return ErrCategoryNotFound
+			}
+		}
+		if categoryID == "" {
+			nextID, err := r.redisClient.Incr(ctx, "category:next_id").Result()
+			if err != nil {
+				return fmt.Errorf("error generating new ID for category: %v", err)
+			}
+			categoryID = strconv.FormatInt(nextID, 10)
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, categoryKey(categoryID), map[string]interface{}{
+				"id":         categoryID,
+				"name":       category.Name,
+				"parent_id":  category.ParentId,
+				"attributes": string(attributes),
+			})
+			pipe.SAdd(ctx, childCategoriesKey(category.ParentId), categoryID)
+			return nil
+		})
+		return err
+	}, watched...)
+	if err != nil {
+		return fmt.Errorf("error storing category in Redis: %w", err)
+	}
+
+	category.Id = categoryID
+	return nil
+}
+
+// GetCategory returns the category with id, ErrCategoryNotFound if there is none.
+func (r *CategoryRepository) GetCategory(ctx context.Context, id string) (*pb.Category, error) {
+	return getCategory(ctx, r.redisClient, id)
+}
+
+// getCategory reads the category with id through client, a *redis.Client or a *redis.Tx
+func getCategory(ctx context.Context, client redis.Cmdable, id string) (*pb.Category, error) {
+	categoryData, err := client.HGetAll(ctx, categoryKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving category from Redis: %v", err)
+	}
+	if len(categoryData) == 0 {
+		return nil, ErrCategoryNotFound
+	}
+	return categoryFromHash(categoryData)
+}
+
+// ListCategories returns the subcategories of parentID, or the top level categories, by ID.
+func (r *CategoryRepository) ListCategories(ctx context.Context, parentID string) ([]*pb.Category, error) {
+	ids, err := r.redisClient.SMembers(ctx, childCategoriesKey(parentID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error listing the categories: %v", err)
+	}
+	sortIDs(ids)
+
+	cmds, err := r.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.HGetAll(ctx, categoryKey(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving category from Redis: %v", err)
+	}
+	categories := make([]*pb.Category, 0, len(cmds))
+	for _, cmd := range cmds {
+		categoryData := cmd.(*redis.StringStringMapCmd).Val()
+		if len(categoryData) == 0 {
+			// deleted since listed
+			continue
+		}
+		category, err := categoryFromHash(categoryData)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+// CategoryDescendants returns id, then the IDs of its subcategories level by level,
+// ErrCategoryNotFound if there is no category with id.
+func (r *CategoryRepository) CategoryDescendants(ctx context.Context, id string) ([]string, error) {
+	exists, err := r.redisClient.Exists(ctx, categoryKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving category from Redis: %v", err)
+	}
+	if exists == 0 {
+		return nil, ErrCategoryNotFound
+	}
+
+	ids := []string{id}
+	for level := []string{id}; len(level) > 0; {
+		cmds, err := r.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, parentID := range level {
+				pipe.SMembers(ctx, childCategoriesKey(parentID))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing the subcategories: %v", err)
+		}
+		var next []string
+		for _, cmd := range cmds {
+			next = append(next, cmd.(*redis.StringSliceCmd).Val()...)
+		}
+		sortIDs(next)
+		ids = append(ids, next...)
+		level = next
+	}
+	return ids, nil
+}
+
+// UpdateCategory stores the name and attribute schema of category, ErrCategoryNotFound if it does not exist.
+func (r *CategoryRepository) UpdateCategory(ctx context.Context, category *pb.Category) error {
+	attributes, err := json.Marshal(attributeDefinitionsToJSON(category.Attributes))
+	if err != nil {
+		return fmt.Errorf("error encoding the category attributes: %v", err)
+	}
+
+	key := categoryKey(category.Id)
+	err = r.watch(ctx, func(tx *redis.Tx) error {
+		stored, err := getCategory(ctx, tx, category.Id)
+		if err != nil {
+			return err
+		}
+		category.ParentId = stored.ParentId
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, "name", category.Name, "attributes", string(attributes))
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return fmt.Errorf("error updating category in Redis: %w", err)
+	}
+	return nil
+}
+
+// MoveCategory moves the category with id under parentID, ErrCategoryCycle if parentID is the
+// category or one of its subcategories. The ancestors of the new parent are watched, so two
+// concurrent moves never make a cycle.
+func (r *CategoryRepository) MoveCategory(ctx context.Context, id, parentID string) (*pb.Category, error) {
+	var category *pb.Category
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		var err error
+		category, err = getCategory(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		// walk up from the new parent, the category must not be one of its ancestors
+		for ancestorID := parentID; ancestorID != ""; {
+			if ancestorID == id {
+				return ErrCategoryCycle
+			}
+			if err := tx.Watch(ctx, categoryKey(ancestorID)).Err(); err != nil {
+				return fmt.Errorf("error watching category: %v", err)
+			}
+			ancestor, err := getCategory(ctx, tx, ancestorID)
+			if err != nil {
+				return err
+			}
+			ancestorID = ancestor.ParentId
+		}
+
+		if category.ParentId == parentID {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SRem(ctx, childCategoriesKey(category.ParentId), id)
+			pipe.SAdd(ctx, childCategoriesKey(parentID), id)
+			pipe.HSet(ctx, categoryKey(id), "parent_id", parentID)
+			return nil
+		})
+		category.ParentId = parentID
+		return err
+	}, categoryKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("error moving category in Redis: %w", err)
+	}
+	return category, nil
+}
+
+// DeleteCategory deletes the category with id, ErrCategoryNotEmpty while it has subcategories or products.
+func (r *CategoryRepository) DeleteCategory(ctx context.Context, id string) error {
+	key := categoryKey(id)
+	childrenKey := childCategoriesKey(id)
+	productsKey := categoryIndexPrefix + id
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		category, err := getCategory(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		children, err := tx.SCard(ctx, childrenKey).Result()
+		if err != nil {
+			return fmt.Errorf("error counting the subcategories: %v", err)
+		}
+		products, err := tx.SCard(ctx, productsKey).Result()
+		if err != nil {
+			return fmt.Errorf("error counting the category products: %v", err)
+		}
+		if children > 0 || products > 0 {
+			return ErrCategoryNotEmpty
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			pipe.SRem(ctx, childCategoriesKey(category.ParentId), id)
+			return nil
+		})
+		return err
+	}, key, childrenKey, productsKey)
+	if err != nil {
+		return fmt.Errorf("error deleting category in Redis: %w", err)
+	}
+	return nil
+}
+
+// categoryFromHash returns the category stored in a category:<id> hash
+func categoryFromHash(categoryData map[string]string) (*pb.Category, error) {
+	category := &pb.Category{
+		Id:       categoryData["id"],
+		Name:     categoryData["name"],
+		ParentId: categoryData["parent_id"],
+	}
+	if encoded := categoryData["attributes"]; encoded != "" {
+		var definitions []attributeDefinitionJSON
+		if err := json.Unmarshal([]byte(encoded), &definitions); err != nil {
+			return nil, fmt.Errorf("error decoding the attributes of category (%s): %v", category.Id, err)
+		}
+		category.Attributes = attributeDefinitionsFromJSON(definitions)
+	}
+	return category, nil
+}
+
+// attributeDefinitionJSON is the stored form of a pb.AttributeDefinition
+type attributeDefinitionJSON struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	EnumValues []string `json:"enum_values,omitempty"`
+	Required   bool     `json:"required,omitempty"`
+}
+
+func attributeDefinitionsToJSON(definitions []*pb.AttributeDefinition) []attributeDefinitionJSON {
+	result := make([]attributeDefinitionJSON, 0, len(definitions))
+	for _, definition := range definitions {
+		result = append(result, attributeDefinitionJSON{
+			Name:       definition.Name,
+			Type:       definition.Type.String(),
+			EnumValues: definition.EnumValues,
+			Required:   definition.Required,
+		})
+	}
+	return result
+}
+
+func attributeDefinitionsFromJSON(definitions []attributeDefinitionJSON) []*pb.AttributeDefinition {
+	result := make([]*pb.AttributeDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		result = append(result, &pb.AttributeDefinition{
+			Name:       definition.Name,
+			Type:       pb.AttributeDefinition_Type(pb.AttributeDefinition_Type_value[definition.Type]),
+			EnumValues: definition.EnumValues,
+			Required:   definition.Required,
+		})
+	}
+	return result
+}
+
+// sortIDs orders numeric IDs by value
+func sortIDs(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.ParseInt(ids[i], 10, 64)
+		b, _ := strconv.ParseInt(ids[j], 10, 64)
+		return a < b
+	})
+}
@@ -0,0 +1,178 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCategoryRepository(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := NewCategoryRepository(client)
+	products := &ProductRepository{redisClient: client}
+
+	// Food > Fruit > Citrus, and Drinks
+	fruit := &pb.Category{Name: "Fruit", Attributes: []*pb.AttributeDefinition{
+		{Name: "origin", Type: pb.AttributeDefinition_TYPE_STRING, Required: true},
+		{Name: "grade", Type: pb.AttributeDefinition_TYPE_ENUM, EnumValues: []string{"A", "B"}},
+	}}
+	for _, category := range []*pb.Category{{Name: "Food"}, fruit, {Name: "Citrus"}, {Name: "Drinks"}} {
+		switch category.Name {
+		case "Fruit":
+			category.ParentId = "1"
+		case "Citrus":
+			category.ParentId = "2"
+		}
+		if err := repo.CreateCategory(ctx, category); err != nil {
+			t.Fatalf("CreateCategory(%s) error %v", category.Name, err)
+		}
+	}
+	if err := repo.CreateCategory(ctx, &pb.Category{Name: "Orphan", ParentId: "42"}); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("CreateCategory() under an unknown parent error %v, want %v", err, ErrCategoryNotFound)
+	}
+	if got, err := repo.GetCategory(ctx, "2"); err != nil || !proto.Equal(got, fruit) {
+		t.Errorf("GetCategory(2) = %v, %v, want %v", got, err, fruit)
+	}
+	if err := products.CreateProduct(ctx, &pb.Product{Name: "Lemon", Price: "1", Category: "3", Attributes: map[string]*pb.AttributeValue{
+		"origin": {Value: &pb.AttributeValue_StringValue{StringValue: "Gisborne"}},
+	}}); err != nil {
+		t.Fatalf("CreateProduct() error %v", err)
+	}
+
+	categoryIDs := func(categories []*pb.Category) string {
+		var ids []string
+		for _, category := range categories {
+			ids = append(ids, category.Id)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	tests := []struct {
+		name    string
+		run     func() (string, error)
+		want    string
+		wantErr error
+	}{
+		{
+			name: "list the top level categories",
+			run: func() (string, error) {
+				categories, err := repo.ListCategories(ctx, "")
+				return categoryIDs(categories), err
+			},
+			want: "1,4",
+		},
+		{
+			name: "descendants",
+			run: func() (string, error) {
+				ids, err := repo.CategoryDescendants(ctx, "1")
+				return strings.Join(ids, ","), err
+			},
+			want: "1,2,3",
+		},
+		{
+			name: "descendants of an unknown category",
+			run: func() (string, error) {
+				ids, err := repo.CategoryDescendants(ctx, "42")
+				return strings.Join(ids, ","), err
+			},
+			wantErr: ErrCategoryNotFound,
+		},
+		{
+			name: "move under a subcategory",
+			run: func() (string, error) {
+				_, err := repo.MoveCategory(ctx, "1", "3")
+				return "", err
+			},
+			wantErr: ErrCategoryCycle,
+		},
+		{
+			name: "move a subtree",
+			run: func() (string, error) {
+				category, err := repo.MoveCategory(ctx, "2", "4")
+				if err != nil {
+					return "", err
+				}
+				ids, err := repo.CategoryDescendants(ctx, "4")
+				return category.ParentId + ":" + strings.Join(ids, ","), err
+			},
+			want: "4:4,2,3",
+		},
+		{
+			name: "moved subtree left its parent",
+			run: func() (string, error) {
+				categories, err := repo.ListCategories(ctx, "1")
+				return categoryIDs(categories), err
+			},
+			want: "",
+		},
+		{
+			name: "update",
+			run: func() (string, error) {
+				if err := repo.UpdateCategory(ctx, &pb.Category{Id: "4", Name: "Beverages"}); err != nil {
+					return "", err
+				}
+				category, err := repo.GetCategory(ctx, "4")
+				return category.GetName() + ":" + category.GetParentId(), err
+			},
+			want: "Beverages:",
+		},
+		{
+			name: "delete a category with products",
+			run: func() (string, error) {
+				return "", repo.DeleteCategory(ctx, "3")
+			},
+			wantErr: ErrCategoryNotEmpty,
+		},
+		{
+			name: "delete a category with subcategories",
+			run: func() (string, error) {
+				return "", repo.DeleteCategory(ctx, "2")
+			},
+			wantErr: ErrCategoryNotEmpty,
+		},
+		{
+			name: "delete an empty category",
+			run: func() (string, error) {
+				if err := repo.DeleteCategory(ctx, "1"); err != nil {
+					return "", err
+				}
+				categories, err := repo.ListCategories(ctx, "")
+				return categoryIDs(categories), err
+			},
+			want: "4",
+		},
+		{
+			name: "delete an unknown category",
+			run: func() (string, error) {
+				return "", repo.DeleteCategory(ctx, "1")
+			},
+			wantErr: ErrCategoryNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.run()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// the products of the moved subtree are listed by category, with their attributes
+	got, err := products.GetProducts(ctx, ProductQuery{Categories: []string{"4", "2", "3"}})
+	if err != nil || len(got) != 1 || got[0].Attributes["origin"].GetStringValue() != "Gisborne" {
+		t.Errorf("GetProducts() = %v, %v, want the lemon with its attributes", got, err)
+	}
+}
@@ -0,0 +1,51 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+)
+
+// redisMetricsHook records the latency of every Redis command (and pipeline), see metrics.RedisCommandDuration
+type redisMetricsHook struct{}
+
+var _ redis.Hook = redisMetricsHook{}
+
+type redisStartKey struct{}
+
+func (redisMetricsHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisMetricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observeRedis(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
+
+func (redisMetricsHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (redisMetricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+			err = cmdErr
+			break
+		}
+	}
+	observeRedis(ctx, "pipeline", err)
+	return nil
+}
+
+// observeRedis records the command started by BeforeProcess, a missing key (redis.Nil) is not an error
+func observeRedis(ctx context.Context, command string, err error) {
+	start, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	metrics.ObserveRedisCommand(command, start, err != nil && !errors.Is(err, redis.Nil))
+}
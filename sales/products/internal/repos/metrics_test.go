@@ -0,0 +1,48 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+)
+
+func TestRedisMetricsHook(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmd         redis.Cmder
+		wantCommand string
+		wantResult  string
+	}{
+		{name: "Command", cmd: newErrCmd("hgetall", nil), wantCommand: "hgetall", wantResult: "ok"},
+		{name: "MissingKey", cmd: newErrCmd("get", redis.Nil), wantCommand: "get", wantResult: "ok"},
+		{name: "Error", cmd: newErrCmd("keys", errors.New("connection refused")), wantCommand: "keys", wantResult: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := redisObservations(t, tt.wantCommand, tt.wantResult)
+
+			hook := redisMetricsHook{}
+			ctx, _ := hook.BeforeProcess(context.Background(), tt.cmd)
+			_ = hook.AfterProcess(ctx, tt.cmd)
+
+			if got := redisObservations(t, tt.wantCommand, tt.wantResult) - before; got != 1 {
+				t.Errorf("redis_command_duration_seconds{command=%q,result=%q} observed %d times, want 1", tt.wantCommand, tt.wantResult, got)
+			}
+		})
+	}
+}
+
+// redisObservations returns the number of Redis commands observed with command and result
+func redisObservations(t *testing.T, command, result string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.RedisCommandDuration.WithLabelValues(command, result).(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
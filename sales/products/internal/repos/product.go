@@ -2,64 +2,144 @@ package repos
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/sales/products/events"
 	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/outbox"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type ProductRepositoryInterface interface {
-	GetProducts(ctx context.Context) ([]*pb.Product, error)
+	// GetProducts returns the products matching query, in its order
+	GetProducts(ctx context.Context, query ProductQuery) ([]*pb.Product, error)
+	// GetProduct returns the product with id, ErrProductNotFound if there is none
+	GetProduct(ctx context.Context, id string) (*pb.Product, error)
 	CreateProduct(ctx context.Context, product *pb.Product) error
+	// UpsertProduct updates the product with the SKU of product, or creates it, and reports whether it was created
+	UpsertProduct(ctx context.Context, product *pb.Product) (bool, error)
+	// ProductIDBySKU returns the ID of the product with sku, empty if there is none
+	ProductIDBySKU(ctx context.Context, sku string) (string, error)
+	// ScanProducts calls fn with every product, without loading them all at once
+	ScanProducts(ctx context.Context, fn func(*pb.Product) error) error
+	// GetVariant returns the variant with sku, or the product without variants with sku as a variant, ErrSKUNotFound if there is none
+	GetVariant(ctx context.Context, sku string) (*pb.Variant, error)
 }
 
+const (
+	// skuIndexKey is the hash of the product IDs by SKU, not matching product:*
+	skuIndexKey = "products:sku"
+	// maxSKURetries is how many times a product write is retried when the SKU index changed meanwhile
+	maxSKURetries = 5
+)
+
+var (
+	// ErrSKUExists is returned when creating a product, or a variant, with the SKU of another product or variant
+	ErrSKUExists = errors.New("SKU already used by a product or variant")
+	// ErrProductNotFound is returned when getting a product that does not exist
+	ErrProductNotFound = errors.New("product not found")
+)
+
 // ProductRepository handles the interaction with Redis for product data.
 type ProductRepository struct {
 	redisClient *redis.Client
 }
 
 // NewProductRepository creates a new instance of ProductRepository.
+// Every Redis command is traced as a child span of the request, and its latency recorded.
 func NewProductRepository(redisClient *redis.Client) *ProductRepository {
+	redisClient.AddHook(redisTracingHook{})
+	redisClient.AddHook(redisMetricsHook{})
 	return &ProductRepository{
 		redisClient: redisClient,
 	}
 }
 
-// GetProducts retrieves all products from Redis.
-func (r *ProductRepository) GetProducts(ctx context.Context) ([]*pb.Product, error) {
-	// Get all keys for product from redis cli
-	productKeys, err := r.redisClient.Keys(ctx, "product:*").Result()
-	if err != nil {
-		return nil, err
+// CreateProduct stores a new product in Redis, ErrSKUExists if its SKU is taken.
+func (r *ProductRepository) CreateProduct(ctx context.Context, product *pb.Product) error {
+	_, err := r.saveProduct(ctx, product, false)
+	return err
+}
+
+// UpsertProduct updates the name and price of the product with the SKU of product, or creates it.
+// product.Id is set to the ID of the stored product.
+func (r *ProductRepository) UpsertProduct(ctx context.Context, product *pb.Product) (bool, error) {
+	if product.Sku == "" {
+		return false, fmt.Errorf("no SKU to upsert the product by")
 	}
+	return r.saveProduct(ctx, product, true)
+}
 
-	var products []*pb.Product
-	for _, key := range productKeys {
-		if key == "product:next_id" {
-			// Skip the product:next_id key
-			continue
+// saveProduct creates product, or updates the product with its SKU when update is set. The SKU
+// indexes are watched, so two writes of a SKU never create two products or variants.
+func (r *ProductRepository) saveProduct(ctx context.Context, product *pb.Product, update bool) (bool, error) {
+	if product.Sku == "" && len(product.Variants) == 0 {
+		if err := r.insertProduct(ctx, r.redisClient, product); err != nil {
+			return false, err
 		}
+		r.publishChange(ctx, events.ChangeCreated, product.Id)
+		return true, nil
+	}
 
-		// Because the data in the key is hash, that's why here uses HGetAll method
-		productData, err := r.redisClient.HGetAll(ctx, key).Result()
-		if err != nil {
-			return nil, fmt.Errorf("error retrieving product from Redis: %v", err)
-		}
+	var created bool
+	var err error
+	for attempt := 0; attempt < maxSKURetries; attempt++ {
+		err = r.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			var existingID string
+			if product.Sku != "" {
+				var err error
+				existingID, err = tx.HGet(ctx, skuIndexKey, product.Sku).Result()
+				if err != nil && !errors.Is(err, redis.Nil) {
+					return fmt.Errorf("error looking the product SKU up: %v", err)
+				}
+			}
+			if existingID != "" {
+				if !update {
+					return fmt.Errorf("%w: %s", ErrSKUExists, product.Sku)
+				}
+				created = false
+				return r.updateProduct(ctx, tx, existingID, product)
+			}
 
-		product := &pb.Product{
-			Id:    productData["id"],
-			Name:  productData["name"],
-			Price: productData["price"],
+			sku, err := takenSKU(ctx, tx, product)
+			if err != nil {
+				return err
+			}
+			if sku != "" {
+				return fmt.Errorf("%w: %s", ErrSKUExists, sku)
+			}
+			created = true
+			return r.insertProduct(ctx, tx, product)
+		}, skuIndexKey, variantSKUIndexKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
 		}
-		products = append(products, product)
+	}
+	if err != nil {
+		return false, err
 	}
 
-	return products, nil
+	if created {
+		r.publishChange(ctx, events.ChangeCreated, product.Id)
+	} else {
+		r.publishChange(ctx, events.ChangeUpdated, product.Id)
+	}
+	return created, nil
 }
 
-// CreateProduct stores a new product in Redis.
-func (r *ProductRepository) CreateProduct(ctx context.Context, product *pb.Product) error {
+// txPipeliner runs a transaction, a *redis.Client or, watching keys, a *redis.Tx
+type txPipeliner interface {
+	TxPipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+}
+
+// insertProduct stores product with a new ID, with its SKUs, its index entries and its ProductCreated event in the same transaction
+func (r *ProductRepository) insertProduct(ctx context.Context, tx txPipeliner, product *pb.Product) error {
 	// Increment an integer value stored at product:next_id.
 	// If it doesn’t exist, Redis creates it and sets it to 1.
 	// This is a way to keep track of the number of products and ensure each one has a unique ID.
@@ -70,18 +150,267 @@ func (r *ProductRepository) CreateProduct(ctx context.Context, product *pb.Produ
 
 	productID := strconv.FormatInt(nextID, 10)
 	productKey := fmt.Sprintf("product:%s", productID)
+	stored := &pb.Product{
+		Id:         productID,
+		Name:       product.Name,
+		Price:      product.Price,
+		Sku:        product.Sku,
+		Category:   product.Category,
+		Stock:      product.Stock,
+		CreatedAt:  timestamppb.New(time.Now().UTC()),
+		Attributes: product.Attributes,
+		Options:    product.Options,
+		Variants:   product.Variants,
+	}
 
-	// Store the product data in a hash
-	if _, err = r.redisClient.HMSet(ctx, productKey, map[string]interface{}{
-		"id":    productID,
-		"name":  product.Name,
-		"price": product.Price,
-	}).Result(); err != nil {
-		return fmt.Errorf("error storing product in Redis: %v", err)
+	hash, err := productHash(stored)
+	if err != nil {
+		return err
+	}
+	event, err := outbox.NewEvent(ctx, events.Source, productID, &events.ProductCreated{
+		ProductId: productID,
+		Name:      product.Name,
+		Price:     product.Price,
+		Sku:       product.Sku,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Store the product data in a hash, with its ProductCreated event in the same transaction
+	if _, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HMSet(ctx, productKey, hash)
+		if product.Sku != "" {
+			pipe.HSet(ctx, skuIndexKey, product.Sku, productID)
+		}
+		for _, variant := range product.Variants {
+			pipe.HSet(ctx, variantSKUIndexKey, variant.Sku, productID)
+		}
+		indexProduct(ctx, pipe, stored)
+		return outbox.Enqueue(ctx, pipe, events.Outbox, event)
+	}); err != nil {
+		return fmt.Errorf("error storing product in Redis: %w", err)
 	}
 
 	// Update the product's ID with the new unique ID
 	product.Id = productID
+	product.CreatedAt = stored.CreatedAt
+	resolveVariants(product)
+	return nil
+}
+
+// updateProduct stores the name and price of product as the ones of productID, which keeps its category,
+// stock, attributes and variants, moving its index entries, with its ProductUpdated event. The stored
+// product is read through tx, which watches it.
+func (r *ProductRepository) updateProduct(ctx context.Context, tx *redis.Tx, productID string, product *pb.Product) error {
+	productKey := fmt.Sprintf("product:%s", productID)
+	if err := tx.Watch(ctx, productKey).Err(); err != nil {
+		return fmt.Errorf("error watching product: %v", err)
+	}
+	productData, err := tx.HGetAll(ctx, productKey).Result()
+	if err != nil {
+		return fmt.Errorf("error retrieving product from Redis: %v", err)
+	}
+	previous := productFromHash(productData)
+	stored := &pb.Product{
+		Id:         productID,
+		Name:       product.Name,
+		Price:      product.Price,
+		Sku:        product.Sku,
+		Category:   previous.Category,
+		Stock:      previous.Stock,
+		CreatedAt:  previous.CreatedAt,
+		Attributes: previous.Attributes,
+		Options:    previous.Options,
+		Variants:   previous.Variants,
+	}
+	// the variants without a price override have the new price
+	resolveVariants(stored)
+
+	hash, err := productHash(stored)
+	if err != nil {
+		return err
+	}
+	event, err := outbox.NewEvent(ctx, events.Source, productID, &events.ProductUpdated{
+		ProductId: productID,
+		Name:      product.Name,
+		Price:     product.Price,
+		Sku:       product.Sku,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HMSet(ctx, productKey, hash)
+		unindexProduct(ctx, pipe, previous)
+		indexProduct(ctx, pipe, stored)
+		return outbox.Enqueue(ctx, pipe, events.Outbox, event)
+	}); err != nil {
+		return fmt.Errorf("error updating product in Redis: %w", err)
+	}
 
+	product.Id = productID
+	product.Category = stored.Category
+	product.Stock = stored.Stock
+	product.CreatedAt = stored.CreatedAt
+	product.Attributes = stored.Attributes
+	product.Options = stored.Options
+	product.Variants = stored.Variants
 	return nil
 }
+
+// GetProduct returns the product with id, ErrProductNotFound if there is none.
+func (r *ProductRepository) GetProduct(ctx context.Context, id string) (*pb.Product, error) {
+	productData, err := r.redisClient.HGetAll(ctx, "product:"+id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving product from Redis: %v", err)
+	}
+	if len(productData) == 0 {
+		return nil, ErrProductNotFound
+	}
+	return productFromHash(productData), nil
+}
+
+// ProductIDBySKU returns the ID of the product with sku, empty if there is none.
+func (r *ProductRepository) ProductIDBySKU(ctx context.Context, sku string) (string, error) {
+	productID, err := r.redisClient.HGet(ctx, skuIndexKey, sku).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error looking the product SKU up: %v", err)
+	}
+	return productID, nil
+}
+
+// ScanProducts calls fn with every product, scanning the keys in batches. The products created
+// or deleted meanwhile may or may not be scanned.
+func (r *ProductRepository) ScanProducts(ctx context.Context, fn func(*pb.Product) error) error {
+	iter := r.redisClient.Scan(ctx, 0, "product:*", 100).Iterator()
+	for iter.Next(ctx) {
+		if iter.Val() == "product:next_id" {
+			continue
+		}
+		productData, err := r.redisClient.HGetAll(ctx, iter.Val()).Result()
+		if err != nil {
+			return fmt.Errorf("error retrieving product from Redis: %v", err)
+		}
+		if len(productData) == 0 {
+			// deleted since scanned
+			continue
+		}
+		if err := fn(productFromHash(productData)); err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("error scanning the products: %v", err)
+	}
+	return nil
+}
+
+// productHash returns the fields of the product:<id> hash storing product
+func productHash(product *pb.Product) (map[string]interface{}, error) {
+	fields := map[string]interface{}{
+		"id":       product.Id,
+		"name":     product.Name,
+		"price":    product.Price,
+		"sku":      product.Sku,
+		"category": product.Category,
+		"stock":    product.Stock,
+	}
+	if product.CreatedAt != nil {
+		fields["created_at"] = product.CreatedAt.AsTime().Format(time.RFC3339Nano)
+	}
+	attributes, err := attributesToJSON(product.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the attributes of product (%s): %v", product.Id, err)
+	}
+	fields["attributes"] = attributes
+	options, variants, err := variantsToJSON(product)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the variants of product (%s): %v", product.Id, err)
+	}
+	fields["options"] = options
+	fields["variants"] = variants
+	return fields, nil
+}
+
+// attributesToJSON encodes the attribute values as a JSON object, each value of its JSON type
+func attributesToJSON(attributes map[string]*pb.AttributeValue) (string, error) {
+	values := make(map[string]interface{}, len(attributes))
+	for name, value := range attributes {
+		switch v := value.GetValue().(type) {
+		case *pb.AttributeValue_StringValue:
+			values[name] = v.StringValue
+		case *pb.AttributeValue_NumberValue:
+			values[name] = v.NumberValue
+		case *pb.AttributeValue_BoolValue:
+			values[name] = v.BoolValue
+		}
+	}
+	encoded, err := json.Marshal(values)
+	return string(encoded), err
+}
+
+// attributesFromJSON decodes the attribute values encoded by attributesToJSON
+func attributesFromJSON(encoded string) (map[string]*pb.AttributeValue, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(encoded), &values); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	attributes := make(map[string]*pb.AttributeValue, len(values))
+	for name, value := range values {
+		switch v := value.(type) {
+		case string:
+			attributes[name] = &pb.AttributeValue{Value: &pb.AttributeValue_StringValue{StringValue: v}}
+		case float64:
+			attributes[name] = &pb.AttributeValue{Value: &pb.AttributeValue_NumberValue{NumberValue: v}}
+		case bool:
+			attributes[name] = &pb.AttributeValue{Value: &pb.AttributeValue_BoolValue{BoolValue: v}}
+		}
+	}
+	return attributes, nil
+}
+
+// productFromHash returns the product stored in a product:<id> hash
+func productFromHash(productData map[string]string) *pb.Product {
+	product := &pb.Product{
+		Id:       productData["id"],
+		Name:     productData["name"],
+		Price:    productData["price"],
+		Sku:      productData["sku"],
+		Category: productData["category"],
+	}
+	if stock, err := strconv.ParseInt(productData["stock"], 10, 32); err == nil {
+		product.Stock = int32(stock)
+	}
+	// the products stored before created_at have none
+	if createdAt, err := time.Parse(time.RFC3339Nano, productData["created_at"]); err == nil {
+		product.CreatedAt = timestamppb.New(createdAt)
+	}
+	if encoded := productData["attributes"]; encoded != "" {
+		// an undecodable value is dropped rather than failing every listing of the product
+		product.Attributes, _ = attributesFromJSON(encoded)
+	}
+	if err := variantsFromJSON(product, productData["options"], productData["variants"]); err != nil {
+		product.Options, product.Variants = nil, nil
+	}
+	return product
+}
+
+// publishChange tells the services caching products that one changed. A failure is only
+// logged, the product is stored and the caches expire their entries anyway.
+func (r *ProductRepository) publishChange(ctx context.Context, changeType events.ChangeType, productID string) {
+	payload, err := events.ProductChanged{Type: changeType, ProductID: productID}.Encode()
+	if err == nil {
+		err = r.redisClient.Publish(ctx, events.Channel, payload).Err()
+	}
+	if err != nil {
+		srvlog.WithContext(ctx).Warnf("Unable to publish the %s event of product (%s): %v", changeType, productID, err)
+	}
+}
@@ -0,0 +1,136 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/sales/products/events"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/outbox"
+	"google.golang.org/protobuf/proto"
+)
+
+// queuedEventTypes returns the types of the events queued in the outbox, oldest first
+func queuedEventTypes(t *testing.T, client *redis.Client) []string {
+	t.Helper()
+	payloads := client.LRange(context.Background(), events.Outbox, 0, -1).Val()
+	var types []string
+	for i := len(payloads) - 1; i >= 0; i-- {
+		var event outbox.CloudEvent
+		if err := proto.Unmarshal([]byte(payloads[i]), &event); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, event.GetType())
+	}
+	return types
+}
+
+func TestProductRepositorySKU(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &ProductRepository{redisClient: client}
+
+	tests := []struct {
+		name        string
+		write       func() (bool, error)
+		wantCreated bool
+		wantErr     error
+		wantEvent   string
+	}{
+		{
+			name: "create with a SKU",
+			write: func() (bool, error) {
+				return true, repo.CreateProduct(ctx, &pb.Product{Name: "Kiwi", Price: "2", Sku: "KIWI"})
+			},
+			wantCreated: true,
+			wantEvent:   "products.events.ProductCreated",
+		},
+		{
+			name: "create with a taken SKU",
+			write: func() (bool, error) {
+				return true, repo.CreateProduct(ctx, &pb.Product{Name: "Kiwi", Price: "3", Sku: "KIWI"})
+			},
+			wantErr: ErrSKUExists,
+		},
+		{
+			name: "upsert an existing SKU",
+			write: func() (bool, error) {
+				return repo.UpsertProduct(ctx, &pb.Product{Name: "Gold kiwi", Price: "3", Sku: "KIWI"})
+			},
+			wantEvent: "products.events.ProductUpdated",
+		},
+		{
+			name: "upsert a new SKU",
+			write: func() (bool, error) {
+				return repo.UpsertProduct(ctx, &pb.Product{Name: "Feijoa", Price: "4", Sku: "FEIJOA"})
+			},
+			wantCreated: true,
+			wantEvent:   "products.events.ProductCreated",
+		},
+		{
+			name:        "create without a SKU",
+			write:       func() (bool, error) { return true, repo.CreateProduct(ctx, &pb.Product{Name: "Pavlova", Price: "12"}) },
+			wantCreated: true,
+			wantEvent:   "products.events.ProductCreated",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.Del(ctx, events.Outbox)
+			created, err := tt.write()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if types := queuedEventTypes(t, client); len(types) != 0 {
+					t.Errorf("events %v queued for a rejected write", types)
+				}
+				return
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created %v, want %v", created, tt.wantCreated)
+			}
+			if types := queuedEventTypes(t, client); len(types) != 1 || types[0] != tt.wantEvent {
+				t.Errorf("queued events %v, want %s", types, tt.wantEvent)
+			}
+		})
+	}
+
+	if id, err := repo.ProductIDBySKU(ctx, "KIWI"); err != nil || id != "1" {
+		t.Errorf("ProductIDBySKU(KIWI) = %q, %v, want 1", id, err)
+	}
+	if id, err := repo.ProductIDBySKU(ctx, "TAMARILLO"); err != nil || id != "" {
+		t.Errorf("ProductIDBySKU(TAMARILLO) = %q, %v, want none", id, err)
+	}
+
+	if product, err := repo.GetProduct(ctx, "1"); err != nil || product.Name != "Gold kiwi" || product.Sku != "KIWI" {
+		t.Errorf("GetProduct(1) = %v, %v, want the Gold kiwi", product, err)
+	}
+	if product, err := repo.GetProduct(ctx, "42"); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("GetProduct(42) = %v, %v, want %v", product, err, ErrProductNotFound)
+	}
+
+	var scanned []string
+	if err := repo.ScanProducts(ctx, func(product *pb.Product) error {
+		scanned = append(scanned, product.Id+":"+product.Name+":"+product.Sku)
+		return nil
+	}); err != nil {
+		t.Fatalf("ScanProducts() error %v", err)
+	}
+	sort.Strings(scanned)
+	want := []string{"1:Gold kiwi:KIWI", "2:Feijoa:FEIJOA", "3:Pavlova:"}
+	if len(scanned) != len(want) {
+		t.Fatalf("ScanProducts() scanned %v, want %v", scanned, want)
+	}
+	for i := range want {
+		if scanned[i] != want[i] {
+			t.Errorf("ScanProducts() scanned %v, want %v", scanned, want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/helpers"
+)
+
+// The secondary indexes of the products, none matching product:*
+const (
+	// priceIndexKey is a sorted set of the product IDs scored by price
+	priceIndexKey = "products:by_price"
+	// createdIndexKey is a sorted set of the product IDs scored by creation time (Unix milliseconds),
+	// it lists every product
+	createdIndexKey = "products:by_created"
+	// nameIndexKey is a sorted set of the <normalized name>\x00<ID> members, all scored 0 so they are
+	// ordered by name and a prefix is a ZRANGEBYLEX range
+	nameIndexKey = "products:by_name"
+	// trigramIndexPrefix prefixes the sets of the product IDs by trigram of their normalized name
+	trigramIndexPrefix = "products:trigram:"
+	// categoryIndexPrefix prefixes the sets of the product IDs by category
+	categoryIndexPrefix = "products:category:"
+	// inStockIndexKey is the set of the IDs of the products in stock
+	inStockIndexKey = "products:in_stock"
+)
+
+// SortField orders the products of a ProductQuery
+type SortField string
+
+// Product orders
+const (
+	SortByID      SortField = ""
+	SortByName    SortField = "name"
+	SortByPrice   SortField = "price"
+	SortByCreated SortField = "created"
+)
+
+// ProductQuery filters and sorts the products, the zero value lists every product by ID.
+// The name filters are case and macron insensitive.
+type ProductQuery struct {
+	NamePrefix   string
+	NameContains string
+	MinPrice     *float64
+	MaxPrice     *float64
+	// Categories keeps the products of any of these categories
+	Categories []string
+	InStock    bool
+	SortBy     SortField
+	Descending bool
+}
+
+// NormalizeName returns the form of a product name the name filters compare: lower case, without macrons
+func NormalizeName(name string) string {
+	return strings.ToLower(helpers.ConvertToNonMacrons(strings.TrimSpace(name)))
+}
+
+// trigrams returns the distinct 3 rune sequences of s
+func trigrams(s string) []string {
+	runes := []rune(s)
+	seen := map[string]bool{}
+	var result []string
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+		if !seen[trigram] {
+			seen[trigram] = true
+			result = append(result, trigram)
+		}
+	}
+	return result
+}
+
+func nameIndexMember(product *pb.Product) string {
+	return NormalizeName(product.Name) + "\x00" + product.Id
+}
+
+// indexProduct adds product to the secondary indexes, in the transaction storing it
+func indexProduct(ctx context.Context, pipe redis.Pipeliner, product *pb.Product) {
+	if price, err := strconv.ParseFloat(product.Price, 64); err == nil {
+		pipe.ZAdd(ctx, priceIndexKey, &redis.Z{Score: price, Member: product.Id})
+	}
+	var created float64
+	if product.CreatedAt != nil {
+		created = float64(product.CreatedAt.AsTime().UnixMilli())
+	}
+	pipe.ZAdd(ctx, createdIndexKey, &redis.Z{Score: created, Member: product.Id})
+	pipe.ZAdd(ctx, nameIndexKey, &redis.Z{Member: nameIndexMember(product)})
+	for _, trigram := range trigrams(NormalizeName(product.Name)) {
+		pipe.SAdd(ctx, trigramIndexPrefix+trigram, product.Id)
+	}
+	if product.Category != "" {
+		pipe.SAdd(ctx, categoryIndexPrefix+product.Category, product.Id)
+	}
+	if inStock(product) {
+		pipe.SAdd(ctx, inStockIndexKey, product.Id)
+	} else {
+		pipe.SRem(ctx, inStockIndexKey, product.Id)
+	}
+}
+
+// unindexProduct removes the stored product from the indexes of its values, before indexing its new ones
+func unindexProduct(ctx context.Context, pipe redis.Pipeliner, product *pb.Product) {
+	pipe.ZRem(ctx, nameIndexKey, nameIndexMember(product))
+	for _, trigram := range trigrams(NormalizeName(product.Name)) {
+		pipe.SRem(ctx, trigramIndexPrefix+trigram, product.Id)
+	}
+	if product.Category != "" {
+		pipe.SRem(ctx, categoryIndexPrefix+product.Category, product.Id)
+	}
+}
+
+// ReindexProducts adds every product to the secondary indexes, e.g. the products stored before the
+// indexes existed. It is idempotent.
+func (r *ProductRepository) ReindexProducts(ctx context.Context) (int, error) {
+	count := 0
+	err := r.ScanProducts(ctx, func(product *pb.Product) error {
+		if _, err := r.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			indexProduct(ctx, pipe, product)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("error indexing product (%s): %v", product.Id, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// GetProducts retrieves the products matching query from Redis. Each filter reads the IDs
+// of its index, only the products matching every filter are loaded.
+func (r *ProductRepository) GetProducts(ctx context.Context, query ProductQuery) ([]*pb.Product, error) {
+	var ids map[string]bool
+	// restrict keeps the IDs matching the previous filters and this one
+	restrict := func(matching []string) {
+		next := make(map[string]bool, len(matching))
+		for _, id := range matching {
+			if ids == nil || ids[id] {
+				next[id] = true
+			}
+		}
+		ids = next
+	}
+
+	if query.NamePrefix != "" {
+		prefix := NormalizeName(query.NamePrefix)
+		members, err := r.redisClient.ZRangeByLex(ctx, nameIndexKey, &redis.ZRangeBy{Min: "[" + prefix, Max: "[" + prefix + "\xff"}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error querying the product names: %v", err)
+		}
+		restrict(nameIndexIDs(members, ""))
+	}
+	if query.NameContains != "" {
+		matching, err := r.productIDsByNameContaining(ctx, NormalizeName(query.NameContains))
+		if err != nil {
+			return nil, err
+		}
+		restrict(matching)
+	}
+	if query.MinPrice != nil || query.MaxPrice != nil {
+		rangeBy := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+		if query.MinPrice != nil {
+			rangeBy.Min = strconv.FormatFloat(*query.MinPrice, 'f', -1, 64)
+		}
+		if query.MaxPrice != nil {
+			rangeBy.Max = strconv.FormatFloat(*query.MaxPrice, 'f', -1, 64)
+		}
+		matching, err := r.redisClient.ZRangeByScore(ctx, priceIndexKey, rangeBy).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error querying the product prices: %v", err)
+		}
+		restrict(matching)
+	}
+	if len(query.Categories) > 0 {
+		keys := make([]string, 0, len(query.Categories))
+		for _, category := range query.Categories {
+			keys = append(keys, categoryIndexPrefix+category)
+		}
+		matching, err := r.redisClient.SUnion(ctx, keys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error querying the product categories: %v", err)
+		}
+		restrict(matching)
+	}
+	if query.InStock {
+		matching, err := r.redisClient.SMembers(ctx, inStockIndexKey).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error querying the products in stock: %v", err)
+		}
+		restrict(matching)
+	}
+	if ids == nil {
+		// no filter, every product
+		matching, err := r.redisClient.ZRange(ctx, createdIndexKey, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error listing the products: %v", err)
+		}
+		restrict(matching)
+	}
+
+	products, err := r.loadProducts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	sortProducts(products, query.SortBy, query.Descending)
+	return products, nil
+}
+
+// productIDsByNameContaining returns the IDs of the products whose normalized name contains
+// substring: the products having all its trigrams, then checked, or with the shorter substrings
+// every name of the name index
+func (r *ProductRepository) productIDsByNameContaining(ctx context.Context, substring string) ([]string, error) {
+	substringTrigrams := trigrams(substring)
+	if len(substringTrigrams) == 0 {
+		members, err := r.redisClient.ZRange(ctx, nameIndexKey, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error querying the product names: %v", err)
+		}
+		return nameIndexIDs(members, substring), nil
+	}
+
+	keys := make([]string, 0, len(substringTrigrams))
+	for _, trigram := range substringTrigrams {
+		keys = append(keys, trigramIndexPrefix+trigram)
+	}
+	candidates, err := r.redisClient.SInter(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error querying the product names: %v", err)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	// the trigrams may be in another order, e.g. "kiwik" has the trigrams of "iwiki"
+	cmds, err := r.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range candidates {
+			pipe.HGet(ctx, "product:"+id, "name")
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("error retrieving the product names: %v", err)
+	}
+	var matching []string
+	for i, cmd := range cmds {
+		if name, err := cmd.(*redis.StringCmd).Result(); err == nil && strings.Contains(NormalizeName(name), substring) {
+			matching = append(matching, candidates[i])
+		}
+	}
+	return matching, nil
+}
+
+// nameIndexIDs returns the IDs of the name index members whose name contains substring
+func nameIndexIDs(members []string, substring string) []string {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		name, id, ok := strings.Cut(member, "\x00")
+		if ok && strings.Contains(name, substring) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// loadProducts loads the products of ids in one round trip, skipping the ones deleted meanwhile
+func (r *ProductRepository) loadProducts(ctx context.Context, ids map[string]bool) ([]*pb.Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	cmds, err := r.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for id := range ids {
+			pipe.HGetAll(ctx, "product:"+id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving product from Redis: %v", err)
+	}
+	products := make([]*pb.Product, 0, len(cmds))
+	for _, cmd := range cmds {
+		productData := cmd.(*redis.StringStringMapCmd).Val()
+		if len(productData) == 0 {
+			continue
+		}
+		products = append(products, productFromHash(productData))
+	}
+	return products, nil
+}
+
+// sortProducts orders products by field, then by ID
+func sortProducts(products []*pb.Product, field SortField, descending bool) {
+	id := func(product *pb.Product) int64 {
+		value, _ := strconv.ParseInt(product.Id, 10, 64)
+		return value
+	}
+	price := func(product *pb.Product) float64 {
+		value, err := strconv.ParseFloat(product.Price, 64)
+		if err != nil {
+			return math.Inf(1)
+		}
+		return value
+	}
+	// compare returns <0, 0 or >0 as a is before, with or after b in ascending order
+	compare := func(a, b *pb.Product) int {
+		switch field {
+		case SortByName:
+			return strings.Compare(NormalizeName(a.Name), NormalizeName(b.Name))
+		case SortByPrice:
+			switch pa, pb := price(a), price(b); {
+			case pa < pb:
+				return -1
+			case pa > pb:
+				return 1
+			}
+		case SortByCreated:
+			return a.CreatedAt.AsTime().Compare(b.CreatedAt.AsTime())
+		}
+		return 0
+	}
+	sort.SliceStable(products, func(i, j int) bool {
+		c := compare(products[i], products[j])
+		if c == 0 {
+			c = int(id(products[i]) - id(products[j]))
+			if c == 0 {
+				return false
+			}
+			// the ID orders the equal products the same way in both directions
+			return c < 0
+		}
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
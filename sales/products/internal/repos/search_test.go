@@ -0,0 +1,113 @@
+package repos
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+)
+
+func TestProductRepositoryGetProducts(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &ProductRepository{redisClient: client}
+
+	for _, product := range []*pb.Product{
+		{Name: "Kiwifruit", Price: "2.5", Sku: "KIWI", Category: "fruit", Stock: 10},
+		{Name: "Feijoa", Price: "4", Category: "fruit"},
+		{Name: "Pāua fritter", Price: "12", Category: "seafood", Stock: 3},
+		{Name: "Kūmara chips", Price: "6", Category: "vegetable", Stock: 1},
+		{Name: "Kiwi burger", Price: "9", Category: "takeaway"},
+	} {
+		if err := repo.CreateProduct(ctx, product); err != nil {
+			t.Fatalf("CreateProduct(%s) error %v", product.Name, err)
+		}
+	}
+	// a price and a name moved by an upsert, the stock and category are kept
+	if _, err := repo.UpsertProduct(ctx, &pb.Product{Name: "Gold kiwifruit", Price: "3.5", Sku: "KIWI"}); err != nil {
+		t.Fatalf("UpsertProduct() error %v", err)
+	}
+
+	price := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name    string
+		query   ProductQuery
+		wantIDs string
+	}{
+		{name: "every product by ID", query: ProductQuery{}, wantIDs: "1,2,3,4,5"},
+		{name: "name prefix", query: ProductQuery{NamePrefix: "KI"}, wantIDs: "5"},
+		{name: "name prefix without macrons", query: ProductQuery{NamePrefix: "kumara"}, wantIDs: "4"},
+		{name: "name prefix with macrons", query: ProductQuery{NamePrefix: "Pāua"}, wantIDs: "3"},
+		{name: "moved name prefix", query: ProductQuery{NamePrefix: "gold"}, wantIDs: "1"},
+		{name: "former name prefix", query: ProductQuery{NamePrefix: "kiwif"}, wantIDs: ""},
+		{name: "name contains", query: ProductQuery{NameContains: "KIWI"}, wantIDs: "1,5"},
+		{name: "name contains a short substring", query: ProductQuery{NameContains: "ua"}, wantIDs: "3"},
+		{name: "name contains the trigrams elsewhere", query: ProductQuery{NameContains: "ifritt"}, wantIDs: ""},
+		{name: "price range", query: ProductQuery{MinPrice: price(3.5), MaxPrice: price(9)}, wantIDs: "1,2,4,5"},
+		{name: "minimum price", query: ProductQuery{MinPrice: price(9)}, wantIDs: "3,5"},
+		{name: "category", query: ProductQuery{Categories: []string{"fruit"}}, wantIDs: "1,2"},
+		{name: "categories", query: ProductQuery{Categories: []string{"seafood", "vegetable", "dessert"}}, wantIDs: "3,4"},
+		{name: "in stock", query: ProductQuery{InStock: true}, wantIDs: "1,3,4"},
+		{name: "combined filters", query: ProductQuery{NameContains: "i", InStock: true, MaxPrice: price(10)}, wantIDs: "1,4"},
+		{name: "by name", query: ProductQuery{SortBy: SortByName}, wantIDs: "2,1,5,4,3"},
+		{name: "by price descending", query: ProductQuery{SortBy: SortByPrice, Descending: true}, wantIDs: "3,5,4,2,1"},
+		{name: "by created", query: ProductQuery{SortBy: SortByCreated}, wantIDs: "1,2,3,4,5"},
+		{name: "no match", query: ProductQuery{Categories: []string{"dessert"}}, wantIDs: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := repo.GetProducts(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("GetProducts() error %v", err)
+			}
+			ids := make([]string, 0, len(products))
+			for _, product := range products {
+				ids = append(ids, product.Id)
+			}
+			if got := strings.Join(ids, ","); got != tt.wantIDs {
+				t.Errorf("GetProducts() IDs %s, want %s", got, tt.wantIDs)
+			}
+		})
+	}
+
+	products, err := repo.GetProducts(ctx, ProductQuery{NamePrefix: "gold"})
+	if err != nil || len(products) != 1 {
+		t.Fatalf("GetProducts() = %v, %v", products, err)
+	}
+	if got := products[0]; got.Category != "fruit" || got.Stock != 10 || got.CreatedAt == nil {
+		t.Errorf("upserted product %v, want its category, stock and creation time kept", got)
+	}
+}
+
+func TestProductRepositoryReindexProducts(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &ProductRepository{redisClient: client}
+
+	// products stored before the indexes
+	server.HSet("product:1", "id", "1", "name", "Pavlova", "price", "12")
+	server.HSet("product:2", "id", "2", "name", "Lamington", "price", "3")
+	server.Set("product:next_id", "2")
+
+	if products, err := repo.GetProducts(ctx, ProductQuery{}); err != nil || len(products) != 0 {
+		t.Fatalf("GetProducts() before indexing = %v, %v, want none", products, err)
+	}
+	for i := 0; i < 2; i++ {
+		indexed, err := repo.ReindexProducts(ctx)
+		if err != nil || indexed != 2 {
+			t.Fatalf("ReindexProducts() = %d, %v, want 2", indexed, err)
+		}
+	}
+	products, err := repo.GetProducts(ctx, ProductQuery{NamePrefix: "lam", MaxPrice: func() *float64 { v := 5.0; return &v }()})
+	if err != nil || len(products) != 1 || products[0].Id != "2" {
+		t.Errorf("GetProducts() after indexing = %v, %v, want product 2", products, err)
+	}
+}
@@ -0,0 +1,64 @@
+package repos
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// redisTracingHook creates a client span for every Redis command (and pipeline), as a child
+// of the span in the command context. Keys and arguments are left out of the spans.
+type redisTracingHook struct{}
+
+var _ redis.Hook = redisTracingHook{}
+
+func (redisTracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = startRedisSpan(ctx, "redis "+cmd.Name(), attribute.String("db.operation", cmd.Name()))
+	return ctx, nil
+}
+
+func (redisTracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (redisTracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = startRedisSpan(ctx, "redis pipeline", attribute.Int("db.redis.num_cmd", len(cmds)))
+	return ctx, nil
+}
+
+func (redisTracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+			err = cmdErr
+			break
+		}
+	}
+	endRedisSpan(ctx, err)
+	return nil
+}
+
+func startRedisSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endRedisSpan ends the span started by BeforeProcess, a missing key (redis.Nil) is not an error
+func endRedisSpan(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,74 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/shared/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRedisTracingHook(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{ServiceName: "products", Enabled: true, SpanExporter: exporter, Syncer: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	tests := []struct {
+		name       string
+		cmds       []redis.Cmder
+		pipeline   bool
+		wantName   string
+		wantStatus codes.Code
+	}{
+		{name: "Command", cmds: []redis.Cmder{redis.NewStringCmd(context.Background(), "hgetall", "product:1")}, wantName: "redis hgetall", wantStatus: codes.Unset},
+		{name: "MissingKey", cmds: []redis.Cmder{newErrCmd("get", redis.Nil)}, wantName: "redis get", wantStatus: codes.Unset},
+		{name: "Error", cmds: []redis.Cmder{newErrCmd("keys", errors.New("connection refused"))}, wantName: "redis keys", wantStatus: codes.Error},
+		{name: "Pipeline", cmds: []redis.Cmder{newErrCmd("incr", nil), newErrCmd("hset", errors.New("READONLY"))}, pipeline: true, wantName: "redis pipeline", wantStatus: codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			ctx, parent := tracing.Tracer().Start(context.Background(), "CreateProduct")
+
+			hook := redisTracingHook{}
+			if tt.pipeline {
+				cmdCtx, _ := hook.BeforeProcessPipeline(ctx, tt.cmds)
+				_ = hook.AfterProcessPipeline(cmdCtx, tt.cmds)
+			} else {
+				cmdCtx, _ := hook.BeforeProcess(ctx, tt.cmds[0])
+				_ = hook.AfterProcess(cmdCtx, tt.cmds[0])
+			}
+			parent.End()
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("expected the Redis and the parent span, got %d spans", len(spans))
+			}
+			redisSpan := spans[0]
+			if redisSpan.Name != tt.wantName || redisSpan.SpanKind != trace.SpanKindClient {
+				t.Errorf("span %q (%s), want client span %q", redisSpan.Name, redisSpan.SpanKind, tt.wantName)
+			}
+			if redisSpan.Parent.SpanID() != parent.SpanContext().SpanID() || redisSpan.SpanContext.TraceID() != parent.SpanContext().TraceID() {
+				t.Errorf("Redis span is not a child of the request span")
+			}
+			if redisSpan.Status.Code != tt.wantStatus {
+				t.Errorf("span status %v, want %v", redisSpan.Status.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func newErrCmd(name string, err error) redis.Cmder {
+	cmd := redis.NewStatusCmd(context.Background(), name)
+	if err != nil {
+		cmd.SetErr(err)
+	}
+	return cmd
+}
@@ -0,0 +1,186 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+)
+
+// variantSKUIndexKey is the hash of the product IDs by variant SKU, the product SKUs are in skuIndexKey
+const variantSKUIndexKey = "products:variant_sku"
+
+// ErrSKUNotFound is returned for a SKU of no product nor variant
+var ErrSKUNotFound = errors.New("no product nor variant with this SKU")
+
+// GetVariant returns the variant with sku, with its resolved price. The SKU of a product without
+// variants resolves to the product as its single variant, without options. The SKU of a product
+// with variants resolves to none: one of its variants has to be picked.
+func (r *ProductRepository) GetVariant(ctx context.Context, sku string) (*pb.Variant, error) {
+	productID, err := r.redisClient.HGet(ctx, variantSKUIndexKey, sku).Result()
+	isVariant := err == nil
+	if errors.Is(err, redis.Nil) {
+		productID, err = r.redisClient.HGet(ctx, skuIndexKey, sku).Result()
+	}
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSKUNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking the SKU up: %v", err)
+	}
+
+	productData, err := r.redisClient.HGetAll(ctx, "product:"+productID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving product from Redis: %v", err)
+	}
+	if len(productData) == 0 {
+		return nil, ErrSKUNotFound
+	}
+	product := productFromHash(productData)
+	if !isVariant {
+		if len(product.Variants) > 0 {
+			return nil, ErrSKUNotFound
+		}
+		return &pb.Variant{Sku: sku, ProductId: product.Id, Price: product.Price, Stock: product.Stock}, nil
+	}
+	for _, variant := range product.Variants {
+		if variant.Sku == sku {
+			return variant, nil
+		}
+	}
+	return nil, ErrSKUNotFound
+}
+
+// takenSKU returns the SKU of product, or of one of its variants, used by another product or variant,
+// empty if there is none. The product SKU may be the one of the product being updated.
+func takenSKU(ctx context.Context, tx *redis.Tx, product *pb.Product) (string, error) {
+	skus := make([]string, 0, len(product.Variants)+1)
+	if product.Sku != "" {
+		skus = append(skus, product.Sku)
+	}
+	for _, variant := range product.Variants {
+		skus = append(skus, variant.Sku)
+	}
+	if len(skus) == 0 {
+		return "", nil
+	}
+
+	variantOwners, err := tx.HMGet(ctx, variantSKUIndexKey, skus...).Result()
+	if err != nil {
+		return "", fmt.Errorf("error looking the variant SKUs up: %v", err)
+	}
+	for i, owner := range variantOwners {
+		if owner != nil {
+			return skus[i], nil
+		}
+	}
+	if len(product.Variants) == 0 {
+		return "", nil
+	}
+	productOwners, err := tx.HMGet(ctx, skuIndexKey, skus[len(skus)-len(product.Variants):]...).Result()
+	if err != nil {
+		return "", fmt.Errorf("error looking the product SKUs up: %v", err)
+	}
+	for i, owner := range productOwners {
+		if owner != nil {
+			return product.Variants[i].Sku, nil
+		}
+	}
+	return "", nil
+}
+
+// inStock reports whether product, or one of its variants, is in stock
+func inStock(product *pb.Product) bool {
+	if product.Stock > 0 {
+		return true
+	}
+	for _, variant := range product.Variants {
+		if variant.Stock > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// productOptionJSON is the stored form of a pb.ProductOption
+type productOptionJSON struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// variantJSON is the stored form of a pb.Variant, its product ID and price are the ones of the product hash
+type variantJSON struct {
+	SKU           string            `json:"sku"`
+	Options       map[string]string `json:"options"`
+	PriceOverride string            `json:"price_override,omitempty"`
+	Stock         int32             `json:"stock"`
+	Barcode       string            `json:"barcode,omitempty"`
+}
+
+// variantsToJSON encodes the options and variants of product
+func variantsToJSON(product *pb.Product) (string, string, error) {
+	options := make([]productOptionJSON, 0, len(product.Options))
+	for _, option := range product.Options {
+		options = append(options, productOptionJSON{Name: option.Name, Values: option.Values})
+	}
+	variants := make([]variantJSON, 0, len(product.Variants))
+	for _, variant := range product.Variants {
+		variants = append(variants, variantJSON{
+			SKU:           variant.Sku,
+			Options:       variant.Options,
+			PriceOverride: variant.PriceOverride,
+			Stock:         variant.Stock,
+			Barcode:       variant.Barcode,
+		})
+	}
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return "", "", err
+	}
+	encodedVariants, err := json.Marshal(variants)
+	return string(encodedOptions), string(encodedVariants), err
+}
+
+// variantsFromJSON sets the options and variants of product decoded from the hash fields, resolved
+func variantsFromJSON(product *pb.Product, encodedOptions, encodedVariants string) error {
+	if encodedOptions != "" {
+		var options []productOptionJSON
+		if err := json.Unmarshal([]byte(encodedOptions), &options); err != nil {
+			return err
+		}
+		for _, option := range options {
+			product.Options = append(product.Options, &pb.ProductOption{Name: option.Name, Values: option.Values})
+		}
+	}
+	if encodedVariants != "" {
+		var variants []variantJSON
+		if err := json.Unmarshal([]byte(encodedVariants), &variants); err != nil {
+			return err
+		}
+		for _, variant := range variants {
+			product.Variants = append(product.Variants, &pb.Variant{
+				Sku:           variant.SKU,
+				Options:       variant.Options,
+				PriceOverride: variant.PriceOverride,
+				Stock:         variant.Stock,
+				Barcode:       variant.Barcode,
+			})
+		}
+	}
+	resolveVariants(product)
+	return nil
+}
+
+// resolveVariants sets the product ID and the price of the variants of product
+func resolveVariants(product *pb.Product) {
+	for _, variant := range product.Variants {
+		variant.ProductId = product.Id
+		variant.Price = variant.PriceOverride
+		if variant.Price == "" {
+			variant.Price = product.Price
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProductRepositoryVariants(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &ProductRepository{redisClient: client}
+
+	tee := &pb.Product{
+		Name: "Tee", Price: "30", Sku: "TEE",
+		Options: []*pb.ProductOption{{Name: "size", Values: []string{"S", "M"}}},
+		Variants: []*pb.Variant{
+			{Sku: "TEE-S", Options: map[string]string{"size": "S"}, Barcode: "9421000000004"},
+			{Sku: "TEE-M", Options: map[string]string{"size": "M"}, PriceOverride: "32", Stock: 2},
+		},
+	}
+	if err := repo.CreateProduct(ctx, tee); err != nil {
+		t.Fatalf("CreateProduct() error %v", err)
+	}
+	if tee.Variants[0].ProductId != tee.Id || tee.Variants[0].Price != "30" || tee.Variants[1].Price != "32" {
+		t.Errorf("CreateProduct() variants %v, want their product ID and resolved price", tee.Variants)
+	}
+
+	writes := []struct {
+		name    string
+		product *pb.Product
+	}{
+		{name: "variant SKU of another variant", product: &pb.Product{Name: "Hoodie", Price: "60", Variants: []*pb.Variant{{Sku: "TEE-S"}}}},
+		{name: "variant SKU of a product", product: &pb.Product{Name: "Hoodie", Price: "60", Variants: []*pb.Variant{{Sku: "TEE"}}}},
+		{name: "product SKU of a variant", product: &pb.Product{Name: "Hoodie", Price: "60", Sku: "TEE-M"}},
+	}
+	for _, tt := range writes {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.CreateProduct(ctx, tt.product); !errors.Is(err, ErrSKUExists) {
+				t.Errorf("CreateProduct() error %v, want %v", err, ErrSKUExists)
+			}
+		})
+	}
+	if _, err := repo.UpsertProduct(ctx, &pb.Product{Name: "Tee", Price: "28", Sku: "TEE-S"}); !errors.Is(err, ErrSKUExists) {
+		t.Errorf("UpsertProduct() by a variant SKU error %v, want %v", err, ErrSKUExists)
+	}
+
+	// repriced, the variants without a price override follow
+	if _, err := repo.UpsertProduct(ctx, &pb.Product{Name: "Tee", Price: "28", Sku: "TEE"}); err != nil {
+		t.Fatalf("UpsertProduct() error %v", err)
+	}
+	mug := &pb.Product{Name: "Mug", Price: "12", Sku: "MUG"}
+	if err := repo.CreateProduct(ctx, mug); err != nil {
+		t.Fatalf("CreateProduct() error %v", err)
+	}
+
+	lookups := []struct {
+		name    string
+		sku     string
+		want    *pb.Variant
+		wantErr error
+	}{
+		{
+			name: "variant with the product price",
+			sku:  "TEE-S",
+			want: &pb.Variant{Sku: "TEE-S", ProductId: "1", Options: map[string]string{"size": "S"}, Price: "28", Barcode: "9421000000004"},
+		},
+		{
+			name: "variant with a price override",
+			sku:  "TEE-M",
+			want: &pb.Variant{Sku: "TEE-M", ProductId: "1", Options: map[string]string{"size": "M"}, PriceOverride: "32", Price: "32", Stock: 2},
+		},
+		{
+			name:    "SKU of a product with variants",
+			sku:     "TEE",
+			wantErr: ErrSKUNotFound,
+		},
+		{
+			name: "SKU of a product without variants",
+			sku:  "MUG",
+			want: &pb.Variant{Sku: "MUG", ProductId: mug.Id, Price: "12"},
+		},
+		{
+			name:    "unknown SKU",
+			sku:     "TEE-XL",
+			wantErr: ErrSKUNotFound,
+		},
+	}
+	for _, tt := range lookups {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetVariant(ctx, tt.sku)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetVariant() error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !proto.Equal(got, tt.want) {
+				t.Errorf("GetVariant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// in stock through its medium variant
+	products, err := repo.GetProducts(ctx, ProductQuery{InStock: true})
+	if err != nil || len(products) != 1 || len(products[0].Variants) != 2 {
+		t.Errorf("GetProducts() in stock = %v, %v, want the tee with its variants", products, err)
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ramseyjiang/go-micros/sales/products/internal/repos"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+)
+
+// CreateCategory creates a category, under its parent if any.
+func (s *ProductService) CreateCategory(ctx context.Context, req *pb.CreateCategoryRequest) (*pb.Category, error) {
+	if err := validateCategory(ctx, req.Name, req.Attributes); err != nil {
+		return nil, err
+	}
+
+	category := &pb.Category{Name: req.Name, ParentId: req.ParentId, Attributes: req.Attributes}
+	err := s.categories.CreateCategory(ctx, category)
+	if errors.Is(err, repos.ErrCategoryNotFound) {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 400, "parent_id", "parent category %q does not exist", req.ParentId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// GetCategory returns a category, 404 if it does not exist.
+func (s *ProductService) GetCategory(ctx context.Context, req *pb.GetCategoryRequest) (*pb.Category, error) {
+	category, err := s.categories.GetCategory(ctx, req.Id)
+	if err != nil {
+		return nil, categoryError(ctx, err, req.Id)
+	}
+	return category, nil
+}
+
+// ListCategories returns the subcategories of a category, or the top level categories. The
+// recursive listing returns the subcategories at any depth, each after its parent.
+func (s *ProductService) ListCategories(ctx context.Context, req *pb.ListCategoriesRequest) (*pb.ListCategoriesResponse, error) {
+	if req.ParentId != "" {
+		if _, err := s.categories.GetCategory(ctx, req.ParentId); err != nil {
+			return nil, categoryError(ctx, err, req.ParentId)
+		}
+	}
+
+	categories, err := s.categories.ListCategories(ctx, req.ParentId)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Recursive {
+		return &pb.ListCategoriesResponse{Categories: categories}, nil
+	}
+
+	var tree []*pb.Category
+	for _, category := range categories {
+		tree = append(tree, category)
+		subcategories, err := s.ListCategories(ctx, &pb.ListCategoriesRequest{ParentId: category.Id, Recursive: true})
+		var apiErr *apierror.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode == 404 {
+			// deleted since listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, subcategories.Categories...)
+	}
+	return &pb.ListCategoriesResponse{Categories: tree}, nil
+}
+
+// UpdateCategory renames a category and replaces its attribute schema. The products of the
+// category keep their attributes, they are validated against the new schema on their next write.
+func (s *ProductService) UpdateCategory(ctx context.Context, req *pb.UpdateCategoryRequest) (*pb.Category, error) {
+	if err := validateCategory(ctx, req.Name, req.Attributes); err != nil {
+		return nil, err
+	}
+
+	category := &pb.Category{Id: req.Id, Name: req.Name, Attributes: req.Attributes}
+	if err := s.categories.UpdateCategory(ctx, category); err != nil {
+		return nil, categoryError(ctx, err, req.Id)
+	}
+	return category, nil
+}
+
+// MoveCategory moves a category, with its subcategories and their products, under another
+// parent, or to the top level.
+func (s *ProductService) MoveCategory(ctx context.Context, req *pb.MoveCategoryRequest) (*pb.Category, error) {
+	if _, err := s.categories.GetCategory(ctx, req.Id); err != nil {
+		return nil, categoryError(ctx, err, req.Id)
+	}
+
+	category, err := s.categories.MoveCategory(ctx, req.Id, req.ParentId)
+	switch {
+	case errors.Is(err, repos.ErrCategoryCycle):
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 400, "parent_id", "category %q cannot be moved under itself or one of its subcategories", req.Id)
+	case errors.Is(err, repos.ErrCategoryNotFound):
+		// the category exists, unless deleted meanwhile, so the parent does not
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 400, "parent_id", "parent category %q does not exist", req.ParentId)
+	case err != nil:
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory deletes a category, 409 while it has subcategories or products.
+func (s *ProductService) DeleteCategory(ctx context.Context, req *pb.DeleteCategoryRequest) (*pb.DeleteCategoryResponse, error) {
+	err := s.categories.DeleteCategory(ctx, req.Id)
+	if errors.Is(err, repos.ErrCategoryNotEmpty) {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 409, "id", "category %q still has subcategories or products", req.Id)
+	}
+	if err != nil {
+		return nil, categoryError(ctx, err, req.Id)
+	}
+	return &pb.DeleteCategoryResponse{}, nil
+}
+
+// categoryError returns a 404 APIError when the category with id does not exist, else err
+func categoryError(ctx context.Context, err error, id string) error {
+	if errors.Is(err, repos.ErrCategoryNotFound) {
+		return apierror.NewAPIErrorWithContext(ctx, err, 404, "id", "category %q does not exist", id)
+	}
+	return err
+}
+
+// validateCategory checks the name and the attribute schema of a category, the errors are 400 APIErrors
+func validateCategory(ctx context.Context, name string, definitions []*pb.AttributeDefinition) error {
+	if name == "" {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, "name", "category name cannot be empty")
+	}
+
+	names := map[string]bool{}
+	for i, definition := range definitions {
+		field := fmt.Sprintf("attributes[%d]", i)
+		if definition.Name == "" {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "attribute name cannot be empty")
+		}
+		if names[definition.Name] {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "attribute %q is defined twice", definition.Name)
+		}
+		names[definition.Name] = true
+
+		switch definition.Type {
+		case pb.AttributeDefinition_TYPE_STRING, pb.AttributeDefinition_TYPE_NUMBER, pb.AttributeDefinition_TYPE_BOOL:
+			if len(definition.EnumValues) > 0 {
+				return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "attribute %q has enum values but is not an enum", definition.Name)
+			}
+		case pb.AttributeDefinition_TYPE_ENUM:
+			if len(definition.EnumValues) == 0 {
+				return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "enum attribute %q has no values", definition.Name)
+			}
+			values := map[string]bool{}
+			for _, value := range definition.EnumValues {
+				if value == "" || values[value] {
+					return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "enum attribute %q has an empty or repeated value", definition.Name)
+				}
+				values[value] = true
+			}
+		default:
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "attribute %q has no type", definition.Name)
+		}
+	}
+	return nil
+}
+
+// validateAttributes checks the attributes of a product against the schema of its category,
+// the errors are 400 APIErrors naming the attribute
+func (s *ProductService) validateAttributes(ctx context.Context, categoryID string, attributes map[string]*pb.AttributeValue) error {
+	if categoryID == "" {
+		if len(attributes) > 0 {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, "attributes", "a product without category cannot have attributes")
+		}
+		return nil
+	}
+
+	category, err := s.categories.GetCategory(ctx, categoryID)
+	if errors.Is(err, repos.ErrCategoryNotFound) {
+		return apierror.NewAPIErrorWithContext(ctx, err, 400, "category", "category %q does not exist", categoryID)
+	}
+	if err != nil {
+		return err
+	}
+
+	definitions := make(map[string]*pb.AttributeDefinition, len(category.Attributes))
+	for _, definition := range category.Attributes {
+		definitions[definition.Name] = definition
+		if _, ok := attributes[definition.Name]; definition.Required && !ok {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, "attributes."+definition.Name, "attribute %q is required in category %q", definition.Name, category.Name)
+		}
+	}
+	for name, value := range attributes {
+		definition, ok := definitions[name]
+		if !ok {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, "attributes."+name, "attribute %q is not defined in category %q", name, category.Name)
+		}
+		if !validAttributeValue(definition, value) {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, "attributes."+name, "attribute %q must be a %s", name, attributeTypeName(definition))
+		}
+	}
+	return nil
+}
+
+// validAttributeValue reports whether value is of the type of definition
+func validAttributeValue(definition *pb.AttributeDefinition, value *pb.AttributeValue) bool {
+	switch v := value.GetValue().(type) {
+	case *pb.AttributeValue_StringValue:
+		switch definition.Type {
+		case pb.AttributeDefinition_TYPE_STRING:
+			return true
+		case pb.AttributeDefinition_TYPE_ENUM:
+			for _, allowed := range definition.EnumValues {
+				if v.StringValue == allowed {
+					return true
+				}
+			}
+		}
+	case *pb.AttributeValue_NumberValue:
+		return definition.Type == pb.AttributeDefinition_TYPE_NUMBER && !math.IsNaN(v.NumberValue) && !math.IsInf(v.NumberValue, 0)
+	case *pb.AttributeValue_BoolValue:
+		return definition.Type == pb.AttributeDefinition_TYPE_BOOL
+	}
+	return false
+}
+
+// attributeTypeName describes the values of definition for the error messages
+func attributeTypeName(definition *pb.AttributeDefinition) string {
+	switch definition.Type {
+	case pb.AttributeDefinition_TYPE_STRING:
+		return "string"
+	case pb.AttributeDefinition_TYPE_NUMBER:
+		return "finite number"
+	case pb.AttributeDefinition_TYPE_BOOL:
+		return "bool"
+	default:
+		return fmt.Sprintf("one of %q", definition.EnumValues)
+	}
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/ramseyjiang/go-micros/sales/products/internal/repos"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"google.golang.org/protobuf/proto"
+)
+
+type mockCategoryRepository struct {
+	categories map[string]*pb.Category
+	// products counts the products by category, for DeleteCategory
+	products map[string]int
+	err      error
+}
+
+func newMockCategoryRepository(categories ...*pb.Category) *mockCategoryRepository {
+	m := &mockCategoryRepository{categories: map[string]*pb.Category{}, products: map[string]int{}}
+	for _, category := range categories {
+		m.categories[category.Id] = category
+	}
+	return m
+}
+
+// children returns the IDs of the subcategories of parentID, by ID
+func (m *mockCategoryRepository) children(parentID string) []string {
+	var ids []string
+	for id, category := range m.categories {
+		if category.ParentId == parentID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	return ids
+}
+
+func (m *mockCategoryRepository) CreateCategory(ctx context.Context, category *pb.Category) error {
+	if m.err != nil {
+		return m.err
+	}
+	if _, ok := m.categories[category.ParentId]; category.ParentId != "" && !ok {
+		return repos.ErrCategoryNotFound
+	}
+	category.Id = strconv.Itoa(len(m.categories) + 1)
+	m.categories[category.Id] = category
+	return nil
+}
+
+func (m *mockCategoryRepository) GetCategory(ctx context.Context, id string) (*pb.Category, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	category, ok := m.categories[id]
+	if !ok {
+		return nil, repos.ErrCategoryNotFound
+	}
+	return category, nil
+}
+
+func (m *mockCategoryRepository) ListCategories(ctx context.Context, parentID string) ([]*pb.Category, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var categories []*pb.Category
+	for _, id := range m.children(parentID) {
+		categories = append(categories, m.categories[id])
+	}
+	return categories, nil
+}
+
+func (m *mockCategoryRepository) CategoryDescendants(ctx context.Context, id string) ([]string, error) {
+	if _, err := m.GetCategory(ctx, id); err != nil {
+		return nil, err
+	}
+	ids := []string{id}
+	for level := []string{id}; len(level) > 0; {
+		var next []string
+		for _, parentID := range level {
+			next = append(next, m.children(parentID)...)
+		}
+		ids = append(ids, next...)
+		level = next
+	}
+	return ids, nil
+}
+
+func (m *mockCategoryRepository) UpdateCategory(ctx context.Context, category *pb.Category) error {
+	stored, err := m.GetCategory(ctx, category.Id)
+	if err != nil {
+		return err
+	}
+	stored.Name, stored.Attributes = category.Name, category.Attributes
+	category.ParentId = stored.ParentId
+	return nil
+}
+
+func (m *mockCategoryRepository) MoveCategory(ctx context.Context, id, parentID string) (*pb.Category, error) {
+	category, err := m.GetCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for ancestorID := parentID; ancestorID != ""; {
+		if ancestorID == id {
+			return nil, repos.ErrCategoryCycle
+		}
+		ancestor, err := m.GetCategory(ctx, ancestorID)
+		if err != nil {
+			return nil, err
+		}
+		ancestorID = ancestor.ParentId
+	}
+	category.ParentId = parentID
+	return category, nil
+}
+
+func (m *mockCategoryRepository) DeleteCategory(ctx context.Context, id string) error {
+	if _, err := m.GetCategory(ctx, id); err != nil {
+		return err
+	}
+	if len(m.children(id)) > 0 || m.products[id] > 0 {
+		return repos.ErrCategoryNotEmpty
+	}
+	delete(m.categories, id)
+	return nil
+}
+
+// mockCategoryTree returns the categories Food > Fruit > Citrus and Drinks, Fruit with an attribute schema
+func mockCategoryTree() *mockCategoryRepository {
+	return newMockCategoryRepository(
+		&pb.Category{Id: "1", Name: "Food"},
+		&pb.Category{Id: "2", Name: "Fruit", ParentId: "1", Attributes: []*pb.AttributeDefinition{
+			{Name: "origin", Type: pb.AttributeDefinition_TYPE_STRING, Required: true},
+			{Name: "weight", Type: pb.AttributeDefinition_TYPE_NUMBER},
+			{Name: "organic", Type: pb.AttributeDefinition_TYPE_BOOL},
+			{Name: "grade", Type: pb.AttributeDefinition_TYPE_ENUM, EnumValues: []string{"A", "B"}},
+		}},
+		&pb.Category{Id: "3", Name: "Citrus", ParentId: "2"},
+		&pb.Category{Id: "4", Name: "Drinks"},
+	)
+}
+
+// assertAPIErrorCode fails unless err is an APIError with code, or nil when code is 0
+func assertAPIErrorCode(t *testing.T, err error, code int) {
+	t.Helper()
+	if code == 0 {
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return
+	}
+	var apiErr *apierror.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != code {
+		t.Fatalf("error %v, want an APIError %d", err, code)
+	}
+}
+
+func stringValue(s string) *pb.AttributeValue {
+	return &pb.AttributeValue{Value: &pb.AttributeValue_StringValue{StringValue: s}}
+}
+
+func TestCreateCategory(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		req      *pb.CreateCategoryRequest
+		wantCode int
+	}{
+		{
+			name: "Success",
+			req: &pb.CreateCategoryRequest{Name: "Vegetables", ParentId: "1", Attributes: []*pb.AttributeDefinition{
+				{Name: "season", Type: pb.AttributeDefinition_TYPE_ENUM, EnumValues: []string{"summer", "winter"}},
+			}},
+		},
+		{name: "TopLevel", req: &pb.CreateCategoryRequest{Name: "Dairy"}},
+		{name: "EmptyName", req: &pb.CreateCategoryRequest{}, wantCode: http.StatusBadRequest},
+		{name: "UnknownParent", req: &pb.CreateCategoryRequest{Name: "Dairy", ParentId: "42"}, wantCode: http.StatusBadRequest},
+		{
+			name: "AttributeWithoutType",
+			req: &pb.CreateCategoryRequest{Name: "Dairy", Attributes: []*pb.AttributeDefinition{
+				{Name: "fat"},
+			}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "RepeatedAttribute",
+			req: &pb.CreateCategoryRequest{Name: "Dairy", Attributes: []*pb.AttributeDefinition{
+				{Name: "fat", Type: pb.AttributeDefinition_TYPE_NUMBER},
+				{Name: "fat", Type: pb.AttributeDefinition_TYPE_STRING},
+			}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "EnumWithoutValues",
+			req: &pb.CreateCategoryRequest{Name: "Dairy", Attributes: []*pb.AttributeDefinition{
+				{Name: "milk", Type: pb.AttributeDefinition_TYPE_ENUM},
+			}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "ValuesOfNotEnum",
+			req: &pb.CreateCategoryRequest{Name: "Dairy", Attributes: []*pb.AttributeDefinition{
+				{Name: "milk", Type: pb.AttributeDefinition_TYPE_STRING, EnumValues: []string{"cow"}},
+			}},
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			categories := mockCategoryTree()
+			got, err := NewProductService(&mockProductRepository{}, categories).CreateCategory(ctx, tt.req)
+			assertAPIErrorCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if got.Id == "" || got.ParentId != tt.req.ParentId || !proto.Equal(got, categories.categories[got.Id]) {
+				t.Errorf("CreateCategory() = %v, stored %v", got, categories.categories[got.Id])
+			}
+		})
+	}
+}
+
+func TestListCategories(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		req      *pb.ListCategoriesRequest
+		wantIDs  []string
+		wantCode int
+	}{
+		{name: "TopLevel", req: &pb.ListCategoriesRequest{}, wantIDs: []string{"1", "4"}},
+		{name: "Subcategories", req: &pb.ListCategoriesRequest{ParentId: "1"}, wantIDs: []string{"2"}},
+		{name: "Recursive", req: &pb.ListCategoriesRequest{Recursive: true}, wantIDs: []string{"1", "2", "3", "4"}},
+		{name: "RecursiveSubcategories", req: &pb.ListCategoriesRequest{ParentId: "2", Recursive: true}, wantIDs: []string{"3"}},
+		{name: "UnknownParent", req: &pb.ListCategoriesRequest{ParentId: "42"}, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewProductService(&mockProductRepository{}, mockCategoryTree()).ListCategories(ctx, tt.req)
+			assertAPIErrorCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			var ids []string
+			for _, category := range got.Categories {
+				ids = append(ids, category.Id)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("ListCategories() IDs %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("ListCategories() IDs %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateMoveDeleteCategory(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		call     func(s *ProductService) error
+		wantCode int
+	}{
+		{
+			name: "Update",
+			call: func(s *ProductService) error {
+				_, err := s.UpdateCategory(ctx, &pb.UpdateCategoryRequest{Id: "4", Name: "Beverages"})
+				return err
+			},
+		},
+		{
+			name: "UpdateUnknown",
+			call: func(s *ProductService) error {
+				_, err := s.UpdateCategory(ctx, &pb.UpdateCategoryRequest{Id: "42", Name: "Beverages"})
+				return err
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "GetUnknown",
+			call: func(s *ProductService) error {
+				_, err := s.GetCategory(ctx, &pb.GetCategoryRequest{Id: "42"})
+				return err
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "MoveSubtree",
+			call: func(s *ProductService) error {
+				_, err := s.MoveCategory(ctx, &pb.MoveCategoryRequest{Id: "2", ParentId: "4"})
+				return err
+			},
+		},
+		{
+			name: "MoveToTopLevel",
+			call: func(s *ProductService) error {
+				_, err := s.MoveCategory(ctx, &pb.MoveCategoryRequest{Id: "3"})
+				return err
+			},
+		},
+		{
+			name: "MoveUnderItself",
+			call: func(s *ProductService) error {
+				_, err := s.MoveCategory(ctx, &pb.MoveCategoryRequest{Id: "2", ParentId: "2"})
+				return err
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "MoveUnderSubcategory",
+			call: func(s *ProductService) error {
+				_, err := s.MoveCategory(ctx, &pb.MoveCategoryRequest{Id: "1", ParentId: "3"})
+				return err
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "MoveUnderUnknown",
+			call: func(s *ProductService) error {
+				_, err := s.MoveCategory(ctx, &pb.MoveCategoryRequest{Id: "4", ParentId: "42"})
+				return err
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "MoveUnknown",
+			call: func(s *ProductService) error {
+				_, err := s.MoveCategory(ctx, &pb.MoveCategoryRequest{Id: "42"})
+				return err
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "DeleteLeaf",
+			call: func(s *ProductService) error {
+				_, err := s.DeleteCategory(ctx, &pb.DeleteCategoryRequest{Id: "3"})
+				return err
+			},
+		},
+		{
+			name: "DeleteWithSubcategories",
+			call: func(s *ProductService) error {
+				_, err := s.DeleteCategory(ctx, &pb.DeleteCategoryRequest{Id: "1"})
+				return err
+			},
+			wantCode: http.StatusConflict,
+		},
+		{
+			name: "DeleteWithProducts",
+			call: func(s *ProductService) error {
+				_, err := s.DeleteCategory(ctx, &pb.DeleteCategoryRequest{Id: "4"})
+				return err
+			},
+			wantCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			categories := mockCategoryTree()
+			categories.products["4"] = 1
+			assertAPIErrorCode(t, tt.call(NewProductService(&mockProductRepository{}, categories)), tt.wantCode)
+		})
+	}
+}
+
+func TestCreateProductAttributes(t *testing.T) {
+	ctx := context.Background()
+	// valid returns valid attributes of a fruit, with the attribute name set to value, or removed when nil
+	valid := func(name string, value *pb.AttributeValue) map[string]*pb.AttributeValue {
+		attributes := map[string]*pb.AttributeValue{
+			"origin":  stringValue("Gisborne"),
+			"weight":  {Value: &pb.AttributeValue_NumberValue{NumberValue: 0.2}},
+			"organic": {Value: &pb.AttributeValue_BoolValue{BoolValue: true}},
+			"grade":   stringValue("A"),
+		}
+		if value == nil {
+			delete(attributes, name)
+		} else {
+			attributes[name] = value
+		}
+		return attributes
+	}
+
+	tests := []struct {
+		name       string
+		category   string
+		attributes map[string]*pb.AttributeValue
+		wantCode   int
+		wantField  string
+	}{
+		{
+			name:       "Valid",
+			category:   "2",
+			attributes: valid("grade", stringValue("B")),
+		},
+		{
+			name:       "OnlyRequired",
+			category:   "2",
+			attributes: map[string]*pb.AttributeValue{"origin": stringValue("Kerikeri")},
+		},
+		{
+			name: "NoCategoryNorAttributes",
+		},
+		{
+			name:     "CategoryWithoutSchema",
+			category: "4",
+		},
+		{
+			name:       "MissingRequired",
+			category:   "2",
+			attributes: valid("origin", nil),
+			wantCode:   http.StatusBadRequest,
+			wantField:  "attributes.origin",
+		},
+		{
+			name:       "Undefined",
+			category:   "2",
+			attributes: valid("colour", stringValue("green")),
+			wantCode:   http.StatusBadRequest,
+			wantField:  "attributes.colour",
+		},
+		{
+			name:       "WrongType",
+			category:   "2",
+			attributes: valid("weight", stringValue("heavy")),
+			wantCode:   http.StatusBadRequest,
+			wantField:  "attributes.weight",
+		},
+		{
+			name:       "UnknownEnumValue",
+			category:   "2",
+			attributes: valid("grade", stringValue("C")),
+			wantCode:   http.StatusBadRequest,
+			wantField:  "attributes.grade",
+		},
+		{
+			name:       "EmptyValue",
+			category:   "2",
+			attributes: valid("organic", &pb.AttributeValue{}),
+			wantCode:   http.StatusBadRequest,
+			wantField:  "attributes.organic",
+		},
+		{
+			name:       "AttributesWithoutCategory",
+			attributes: valid("grade", stringValue("A")),
+			wantCode:   http.StatusBadRequest,
+			wantField:  "attributes",
+		},
+		{
+			name:      "UnknownCategory",
+			category:  "42",
+			wantCode:  http.StatusBadRequest,
+			wantField: "category",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockProductRepository{}
+			req := &pb.CreateProductRequest{Name: "Feijoa", Price: "4", Category: tt.category, Attributes: tt.attributes}
+			got, err := NewProductService(repo, mockCategoryTree()).CreateProduct(ctx, req)
+			assertAPIErrorCode(t, err, tt.wantCode)
+			if err != nil {
+				var apiErr *apierror.APIError
+				if errors.As(err, &apiErr) && apiErr.ErrorField != tt.wantField {
+					t.Errorf("error field %q, want %q", apiErr.ErrorField, tt.wantField)
+				}
+				if len(repo.products) != 0 {
+					t.Errorf("invalid product stored")
+				}
+				return
+			}
+			if got.Category != tt.category || len(got.Attributes) != len(tt.attributes) {
+				t.Errorf("CreateProduct() = %v, want the category and attributes of %v", got, req)
+			}
+		})
+	}
+}
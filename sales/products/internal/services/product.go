@@ -5,50 +5,240 @@ import (
 	"errors"
 	"github.com/ramseyjiang/go-micros/sales/products/internal/repos"
 	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"io"
 	"strconv"
 )
 
 type ProductService struct {
 	//  the UnimplementedProductServiceServer satisfies the gRPC interface, including the forward compatibility method.
 	pb.UnimplementedProductServiceServer
-	repo repos.ProductRepositoryInterface
+	repo       repos.ProductRepositoryInterface
+	categories repos.CategoryRepositoryInterface
 }
 
-func NewProductService(repo repos.ProductRepositoryInterface) *ProductService {
-	return &ProductService{repo: repo}
+func NewProductService(repo repos.ProductRepositoryInterface, categories repos.CategoryRepositoryInterface) *ProductService {
+	return &ProductService{repo: repo, categories: categories}
+}
+
+// sortFields maps the requested orders to the ones of the repository
+var sortFields = map[pb.GetProductsRequest_SortBy]repos.SortField{
+	pb.GetProductsRequest_SORT_BY_UNSPECIFIED: repos.SortByID,
+	pb.GetProductsRequest_SORT_BY_NAME:        repos.SortByName,
+	pb.GetProductsRequest_SORT_BY_PRICE:       repos.SortByPrice,
+	pb.GetProductsRequest_SORT_BY_CREATED:     repos.SortByCreated,
 }
 
 func (s *ProductService) GetProducts(ctx context.Context, req *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
-	products, err := s.repo.GetProducts(ctx)
+	sortBy, ok := sortFields[req.SortBy]
+	if !ok {
+		return nil, apierror.NewAPIErrorWithContext(ctx, nil, 400, "sort_by", "unknown sort order %d", req.SortBy)
+	}
+	query := repos.ProductQuery{
+		NamePrefix:   req.NamePrefix,
+		NameContains: req.NameContains,
+		InStock:      req.InStock,
+		SortBy:       sortBy,
+		Descending:   req.Descending,
+	}
+	if req.MinPrice != nil {
+		minPrice := req.MinPrice.Value
+		query.MinPrice = &minPrice
+	}
+	if req.MaxPrice != nil {
+		maxPrice := req.MaxPrice.Value
+		query.MaxPrice = &maxPrice
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return nil, apierror.NewAPIErrorWithContext(ctx, nil, 400, "min_price", "min_price cannot be greater than max_price")
+	}
+	switch {
+	case req.Category != "" && req.IncludeSubcategories:
+		categories, err := s.categories.CategoryDescendants(ctx, req.Category)
+		if errors.Is(err, repos.ErrCategoryNotFound) {
+			return nil, apierror.NewAPIErrorWithContext(ctx, err, 404, "category", "category %q does not exist", req.Category)
+		}
+		if err != nil {
+			return nil, err
+		}
+		query.Categories = categories
+	case req.Category != "":
+		query.Categories = []string{req.Category}
+	}
+
+	products, err := s.repo.GetProducts(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	return &pb.GetProductsResponse{Products: products}, nil
 }
 
-func (s *ProductService) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.Product, error) {
-	// Validate product name
-	if req.Name == "" {
-		return nil, errors.New("product name cannot be empty")
+// GetProduct returns the product with an ID, a 404 APIError if there is none.
+func (s *ProductService) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	if req.Id == "" {
+		return nil, apierror.NewAPIErrorWithContext(ctx, nil, 400, "id", "id cannot be empty")
+	}
+	product, err := s.repo.GetProduct(ctx, req.Id)
+	if errors.Is(err, repos.ErrProductNotFound) {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 404, "id", "no product with ID %q", req.Id)
 	}
-
-	// Validate product price
-	price, err := strconv.ParseFloat(req.Price, 64)
 	if err != nil {
-		return nil, errors.New("invalid price format")
+		return nil, err
+	}
+	return product, nil
+}
+
+func (s *ProductService) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.Product, error) {
+	if err := validateProduct(ctx, req.Name, req.Price); err != nil {
+		return nil, err
 	}
-	if price <= 0 {
-		return nil, errors.New("price must be greater than 0")
+	if req.Stock < 0 {
+		return nil, apierror.NewAPIErrorWithContext(ctx, nil, 400, "stock", "stock cannot be negative")
+	}
+	if err := s.validateAttributes(ctx, req.Category, req.Attributes); err != nil {
+		return nil, err
+	}
+	if err := validateVariants(ctx, req.Sku, req.Stock, req.Options, req.Variants); err != nil {
+		return nil, err
 	}
 
 	product := &pb.Product{
 		// Generate a unique ID for the product and assign it here
-		Name:  req.Name,
-		Price: req.Price,
+		Name:       req.Name,
+		Price:      req.Price,
+		Sku:        req.Sku,
+		Category:   req.Category,
+		Stock:      req.Stock,
+		Attributes: req.Attributes,
+		Options:    req.Options,
+		Variants:   req.Variants,
+	}
+	err := s.repo.CreateProduct(ctx, product)
+	if errors.Is(err, repos.ErrSKUExists) {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 409, "sku", "%v", err)
 	}
-	err = s.repo.CreateProduct(ctx, product)
 	if err != nil {
 		return nil, err
 	}
+	metrics.ProductsCreated.Inc()
 	return product, nil
 }
+
+// validateProduct applies the rules every stored product follows, the errors are 400 APIErrors
+// naming the invalid field
+func validateProduct(ctx context.Context, name, price string) error {
+	// Validate product name
+	if name == "" {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, "name", "product name cannot be empty")
+	}
+
+	// Validate product price
+	return validatePrice(ctx, "price", price)
+}
+
+// validatePrice checks a price is a positive number, the error is a 400 APIError naming field
+func validatePrice(ctx context.Context, field, price string) error {
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "invalid price format")
+	}
+	if value <= 0 {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "price must be greater than 0")
+	}
+	return nil
+}
+
+// ImportProducts creates or updates, by SKU, the streamed products. A row failing the
+// validation of CreateProduct is reported in the summary, the other rows are imported.
+// A dry run validates the rows and reports what would be created or updated.
+func (s *ProductService) ImportProducts(stream pb.ProductService_ImportProductsServer) error {
+	ctx := stream.Context()
+	summary := &pb.ImportProductsResponse{}
+	// the SKUs imported so far in a dry run, a repeated SKU updates the product of the first row
+	dryRunSKUs := map[string]bool{}
+
+	for n := int32(1); ; n++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(summary)
+		}
+		if err != nil {
+			return err
+		}
+		if n == 1 {
+			summary.DryRun = req.DryRun
+		}
+		row := req.Row
+		if row == 0 {
+			row = n
+		}
+		summary.Total++
+
+		created, err := s.importProduct(ctx, req, summary.DryRun, dryRunSKUs)
+		var rowErr *apierror.APIError
+		switch {
+		case errors.As(err, &rowErr) && rowErr.ErrorCode >= 400 && rowErr.ErrorCode < 500:
+			summary.Failed++
+			summary.Errors = append(summary.Errors, &pb.ImportRowError{
+				Row:          row,
+				Sku:          req.Sku,
+				ErrorCode:    int32(rowErr.ErrorCode),
+				ErrorField:   rowErr.ErrorField,
+				ErrorMessage: rowErr.ErrorMessage,
+			})
+		case err != nil:
+			// the rows before are imported, the caller can retry the import as the rows are upserted
+			return apierror.NewAPIErrorWithContext(ctx, err, 0, "", "error importing row %d, after %d created and %d updated products", row, summary.Created, summary.Updated)
+		case created:
+			summary.Created++
+		default:
+			summary.Updated++
+		}
+	}
+}
+
+// importProduct validates and upserts a row, reporting whether the product is (or would be) created
+func (s *ProductService) importProduct(ctx context.Context, req *pb.ImportProductsRequest, dryRun bool, dryRunSKUs map[string]bool) (bool, error) {
+	if req.Sku == "" {
+		return false, apierror.NewAPIErrorWithContext(ctx, nil, 400, "sku", "sku is required to import a product")
+	}
+	if err := validateProduct(ctx, req.Name, req.Price); err != nil {
+		return false, err
+	}
+
+	if dryRun {
+		if dryRunSKUs[req.Sku] {
+			return false, nil
+		}
+		dryRunSKUs[req.Sku] = true
+		productID, err := s.repo.ProductIDBySKU(ctx, req.Sku)
+		if err != nil || productID != "" {
+			return false, err
+		}
+		// the SKU of a variant is rejected as by UpsertProduct
+		_, err = s.repo.GetVariant(ctx, req.Sku)
+		if err == nil {
+			return false, apierror.NewAPIErrorWithContext(ctx, repos.ErrSKUExists, 409, "sku", "%v: %s", repos.ErrSKUExists, req.Sku)
+		}
+		if errors.Is(err, repos.ErrSKUNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	created, err := s.repo.UpsertProduct(ctx, &pb.Product{Name: req.Name, Price: req.Price, Sku: req.Sku})
+	if errors.Is(err, repos.ErrSKUExists) {
+		// the SKU of a variant
+		return false, apierror.NewAPIErrorWithContext(ctx, err, 409, "sku", "%v", err)
+	}
+	if err == nil && created {
+		metrics.ProductsCreated.Inc()
+	}
+	return created, err
+}
+
+// ExportProducts streams every product.
+func (s *ProductService) ExportProducts(req *pb.ExportProductsRequest, stream pb.ProductService_ExportProductsServer) error {
+	return s.repo.ScanProducts(stream.Context(), stream.Send)
+}
@@ -3,29 +3,137 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"reflect"
+	"strconv"
 	"testing"
 
+	"github.com/ramseyjiang/go-micros/sales/products/internal/repos"
 	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 type mockProductRepository struct {
 	products []*pb.Product
 	err      error
+	// query is the last query of GetProducts
+	query repos.ProductQuery
 }
 
-func (m *mockProductRepository) GetProducts(ctx context.Context) ([]*pb.Product, error) {
+// productBySKU returns the mocked product with sku
+func (m *mockProductRepository) productBySKU(sku string) *pb.Product {
+	for _, product := range m.products {
+		if product.Sku == sku {
+			return product
+		}
+	}
+	return nil
+}
+
+// variantBySKU returns the mocked variant with sku
+func (m *mockProductRepository) variantBySKU(sku string) *pb.Variant {
+	for _, product := range m.products {
+		for _, variant := range product.Variants {
+			if variant.Sku == sku {
+				return variant
+			}
+		}
+	}
+	return nil
+}
+
+// skuTaken reports whether sku is the one of a mocked product or variant
+func (m *mockProductRepository) skuTaken(sku string) bool {
+	return m.productBySKU(sku) != nil || m.variantBySKU(sku) != nil
+}
+
+func (m *mockProductRepository) GetProducts(ctx context.Context, query repos.ProductQuery) ([]*pb.Product, error) {
+	m.query = query
 	return m.products, m.err
 }
 
+func (m *mockProductRepository) GetProduct(ctx context.Context, id string) (*pb.Product, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, product := range m.products {
+		if product.Id == id {
+			return product, nil
+		}
+	}
+	return nil, repos.ErrProductNotFound
+}
+
 func (m *mockProductRepository) CreateProduct(ctx context.Context, product *pb.Product) error {
 	if m.err != nil {
 		return m.err
 	}
+	if product.Sku != "" && m.skuTaken(product.Sku) {
+		return repos.ErrSKUExists
+	}
+	for _, variant := range product.Variants {
+		if m.skuTaken(variant.Sku) {
+			return repos.ErrSKUExists
+		}
+	}
+	product.Id = strconv.Itoa(len(m.products) + 1)
 	m.products = append(m.products, product)
 	return nil
 }
 
+func (m *mockProductRepository) UpsertProduct(ctx context.Context, product *pb.Product) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.variantBySKU(product.Sku) != nil {
+		return false, fmt.Errorf("%w: %s", repos.ErrSKUExists, product.Sku)
+	}
+	if existing := m.productBySKU(product.Sku); existing != nil {
+		existing.Name, existing.Price = product.Name, product.Price
+		product.Id = existing.Id
+		return false, nil
+	}
+	return true, m.CreateProduct(ctx, product)
+}
+
+func (m *mockProductRepository) ProductIDBySKU(ctx context.Context, sku string) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	if product := m.productBySKU(sku); product != nil {
+		return product.Id, nil
+	}
+	return "", nil
+}
+
+func (m *mockProductRepository) GetVariant(ctx context.Context, sku string) (*pb.Variant, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if variant := m.variantBySKU(sku); variant != nil {
+		return variant, nil
+	}
+	if product := m.productBySKU(sku); product != nil {
+		return &pb.Variant{Sku: sku, ProductId: product.Id, Price: product.Price, Stock: product.Stock}, nil
+	}
+	return nil, repos.ErrSKUNotFound
+}
+
+func (m *mockProductRepository) ScanProducts(ctx context.Context, fn func(*pb.Product) error) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, product := range m.products {
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func TestGetProducts(t *testing.T) {
 	ctx := context.Background()
 	mockProducts := []*pb.Product{
@@ -33,21 +141,80 @@ func TestGetProducts(t *testing.T) {
 		{Id: "2", Name: "Product 2", Price: "15.99"},
 	}
 
+	minPrice, maxPrice := 10.0, 20.0
+	categories := newMockCategoryRepository(&pb.Category{Id: "1", Name: "Food"}, &pb.Category{Id: "2", Name: "Fruit", ParentId: "1"})
+
 	tests := []struct {
 		name         string
 		mockRepo     *mockProductRepository
+		req          *pb.GetProductsRequest
+		wantQuery    repos.ProductQuery
 		wantProducts []*pb.Product
 		wantErr      bool
 	}{
 		{
 			name:         "Success",
 			mockRepo:     &mockProductRepository{products: mockProducts},
+			req:          &pb.GetProductsRequest{},
 			wantProducts: mockProducts,
 			wantErr:      false,
 		},
+		{
+			name:     "Filtered",
+			mockRepo: &mockProductRepository{products: mockProducts},
+			req: &pb.GetProductsRequest{
+				NamePrefix:   "prod",
+				NameContains: "uct",
+				MinPrice:     wrapperspb.Double(minPrice),
+				MaxPrice:     wrapperspb.Double(maxPrice),
+				Category:     "fruit",
+				InStock:      true,
+				SortBy:       pb.GetProductsRequest_SORT_BY_PRICE,
+				Descending:   true,
+			},
+			wantQuery: repos.ProductQuery{
+				NamePrefix:   "prod",
+				NameContains: "uct",
+				MinPrice:     &minPrice,
+				MaxPrice:     &maxPrice,
+				Categories:   []string{"fruit"},
+				InStock:      true,
+				SortBy:       repos.SortByPrice,
+				Descending:   true,
+			},
+			wantProducts: mockProducts,
+			wantErr:      false,
+		},
+		{
+			name:         "Subcategories",
+			mockRepo:     &mockProductRepository{products: mockProducts},
+			req:          &pb.GetProductsRequest{Category: "1", IncludeSubcategories: true},
+			wantQuery:    repos.ProductQuery{Categories: []string{"1", "2"}},
+			wantProducts: mockProducts,
+			wantErr:      false,
+		},
+		{
+			name:     "UnknownCategory",
+			mockRepo: &mockProductRepository{products: mockProducts},
+			req:      &pb.GetProductsRequest{Category: "3", IncludeSubcategories: true},
+			wantErr:  true,
+		},
+		{
+			name:     "InvertedPriceRange",
+			mockRepo: &mockProductRepository{products: mockProducts},
+			req:      &pb.GetProductsRequest{MinPrice: wrapperspb.Double(maxPrice), MaxPrice: wrapperspb.Double(minPrice)},
+			wantErr:  true,
+		},
+		{
+			name:     "UnknownSortOrder",
+			mockRepo: &mockProductRepository{products: mockProducts},
+			req:      &pb.GetProductsRequest{SortBy: 42},
+			wantErr:  true,
+		},
 		{
 			name:         "RepoError",
 			mockRepo:     &mockProductRepository{err: errors.New("error")},
+			req:          &pb.GetProductsRequest{},
 			wantProducts: nil,
 			wantErr:      true,
 		},
@@ -55,12 +222,18 @@ func TestGetProducts(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewProductService(tt.mockRepo)
-			got, err := s.GetProducts(ctx, &pb.GetProductsRequest{})
+			s := NewProductService(tt.mockRepo, categories)
+			got, err := s.GetProducts(ctx, tt.req)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ProductService.GetProducts() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(tt.mockRepo.query, tt.wantQuery) {
+				t.Errorf("ProductService.GetProducts() query = %+v, want %+v", tt.mockRepo.query, tt.wantQuery)
+			}
 			if !reflect.DeepEqual(got.GetProducts(), tt.wantProducts) {
 				t.Errorf("ProductService.GetProducts() got = %v, want %v", got.GetProducts(), tt.wantProducts)
 			}
@@ -68,6 +241,34 @@ func TestGetProducts(t *testing.T) {
 	}
 }
 
+func TestGetProduct(t *testing.T) {
+	ctx := context.Background()
+	product := &pb.Product{Id: "1", Name: "Product 1", Price: "10.99"}
+
+	tests := []struct {
+		name     string
+		mockRepo *mockProductRepository
+		id       string
+		want     *pb.Product
+		wantCode int
+	}{
+		{name: "Success", mockRepo: &mockProductRepository{products: []*pb.Product{product}}, id: "1", want: product},
+		{name: "EmptyID", mockRepo: &mockProductRepository{}, wantCode: http.StatusBadRequest},
+		{name: "NotFound", mockRepo: &mockProductRepository{products: []*pb.Product{product}}, id: "2", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewProductService(tt.mockRepo, newMockCategoryRepository())
+			got, err := s.GetProduct(ctx, &pb.GetProductRequest{Id: tt.id})
+			assertAPIErrorCode(t, err, tt.wantCode)
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("ProductService.GetProduct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCreateProduct(t *testing.T) {
 	ctx := context.Background()
 
@@ -107,11 +308,23 @@ func TestCreateProduct(t *testing.T) {
 			req:      &pb.CreateProductRequest{Name: "Valid Name", Price: "abc"},
 			wantErr:  true,
 		},
+		{
+			name:     "NegativeStock",
+			mockRepo: &mockProductRepository{},
+			req:      &pb.CreateProductRequest{Name: "Valid Name", Price: "20.99", Stock: -1},
+			wantErr:  true,
+		},
+		{
+			name:     "DuplicateSKU",
+			mockRepo: &mockProductRepository{products: []*pb.Product{{Id: "1", Name: "Product 1", Price: "10.99", Sku: "SKU-1"}}},
+			req:      &pb.CreateProductRequest{Name: "Valid Name", Price: "20.99", Sku: "SKU-1"},
+			wantErr:  true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewProductService(tt.mockRepo)
+			s := NewProductService(tt.mockRepo, newMockCategoryRepository())
 			_, err := s.CreateProduct(ctx, tt.req)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ProductService.CreateProduct() error = %v, wantErr %v", err, tt.wantErr)
@@ -119,3 +332,156 @@ func TestCreateProduct(t *testing.T) {
 		})
 	}
 }
+
+// importStream streams the rows to ImportProducts and records the summary
+type importStream struct {
+	pb.ProductService_ImportProductsServer
+	rows    []*pb.ImportProductsRequest
+	summary *pb.ImportProductsResponse
+}
+
+func (s *importStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *importStream) Recv() (*pb.ImportProductsRequest, error) {
+	if len(s.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+func (s *importStream) SendAndClose(summary *pb.ImportProductsResponse) error {
+	s.summary = summary
+	return nil
+}
+
+func TestImportProducts(t *testing.T) {
+	existing := func() []*pb.Product {
+		return []*pb.Product{{
+			Id: "1", Name: "Product 1", Price: "10.99", Sku: "SKU-1",
+			Options:  []*pb.ProductOption{{Name: "size", Values: []string{"S"}}},
+			Variants: []*pb.Variant{{Sku: "SKU-1-S", ProductId: "1", Options: map[string]string{"size": "S"}, Price: "10.99"}},
+		}}
+	}
+	rows := func(dryRun bool) []*pb.ImportProductsRequest {
+		return []*pb.ImportProductsRequest{
+			{DryRun: dryRun, Row: 2, Sku: "SKU-1", Name: "Product 1 v2", Price: "11.99"},
+			{Row: 3, Sku: "SKU-2", Name: "Product 2", Price: "5"},
+			{Row: 4, Sku: "SKU-3", Name: "", Price: "5"},
+			{Row: 5, Sku: "SKU-4", Name: "Product 4", Price: "free"},
+			{Row: 6, Name: "No SKU", Price: "5"},
+			{Row: 7, Sku: "SKU-2", Name: "Product 2 v2", Price: "6"},
+			{Row: 8, Sku: "SKU-1-S", Name: "Product 1 small", Price: "9"},
+		}
+	}
+	wantErrors := []*pb.ImportRowError{
+		{Row: 4, Sku: "SKU-3", ErrorCode: 400, ErrorField: "name", ErrorMessage: "product name cannot be empty"},
+		{Row: 5, Sku: "SKU-4", ErrorCode: 400, ErrorField: "price", ErrorMessage: "invalid price format"},
+		{Row: 6, ErrorCode: 400, ErrorField: "sku", ErrorMessage: "sku is required to import a product"},
+		{Row: 8, Sku: "SKU-1-S", ErrorCode: 409, ErrorField: "sku", ErrorMessage: "SKU already used by a product or variant: SKU-1-S"},
+	}
+
+	tests := []struct {
+		name         string
+		dryRun       bool
+		repoErr      error
+		wantSummary  *pb.ImportProductsResponse
+		wantProducts []*pb.Product
+		wantErr      bool
+	}{
+		{
+			name:        "Import",
+			wantSummary: &pb.ImportProductsResponse{Total: 7, Created: 1, Updated: 2, Failed: 4, Errors: wantErrors},
+			wantProducts: []*pb.Product{
+				{
+					Id: "1", Name: "Product 1 v2", Price: "11.99", Sku: "SKU-1",
+					Options:  existing()[0].Options,
+					Variants: existing()[0].Variants,
+				},
+				{Id: "2", Name: "Product 2 v2", Price: "6", Sku: "SKU-2"},
+			},
+		},
+		{
+			name:         "DryRun",
+			dryRun:       true,
+			wantSummary:  &pb.ImportProductsResponse{DryRun: true, Total: 7, Created: 1, Updated: 2, Failed: 4, Errors: wantErrors},
+			wantProducts: existing(),
+		},
+		{
+			name:    "RepoError",
+			repoErr: errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockProductRepository{products: existing(), err: tt.repoErr}
+			stream := &importStream{rows: rows(tt.dryRun)}
+			err := NewProductService(repo, newMockCategoryRepository()).ImportProducts(stream)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProductService.ImportProducts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !proto.Equal(stream.summary, tt.wantSummary) {
+				t.Errorf("ProductService.ImportProducts() summary = %v, want %v", stream.summary, tt.wantSummary)
+			}
+			if len(repo.products) != len(tt.wantProducts) {
+				t.Fatalf("ProductService.ImportProducts() stored %v, want %v", repo.products, tt.wantProducts)
+			}
+			for i := range repo.products {
+				if !proto.Equal(repo.products[i], tt.wantProducts[i]) {
+					t.Errorf("ProductService.ImportProducts() stored %v, want %v", repo.products[i], tt.wantProducts[i])
+				}
+			}
+		})
+	}
+}
+
+// exportStream records the streamed products
+type exportStream struct {
+	pb.ProductService_ExportProductsServer
+	products []*pb.Product
+}
+
+func (s *exportStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *exportStream) Send(product *pb.Product) error {
+	s.products = append(s.products, product)
+	return nil
+}
+
+func TestExportProducts(t *testing.T) {
+	products := []*pb.Product{
+		{Id: "1", Name: "Product 1", Price: "10.99", Sku: "SKU-1"},
+		{Id: "2", Name: "Product 2", Price: "15.99"},
+	}
+	tests := []struct {
+		name         string
+		mockRepo     *mockProductRepository
+		wantProducts []*pb.Product
+		wantErr      bool
+	}{
+		{name: "Success", mockRepo: &mockProductRepository{products: products}, wantProducts: products},
+		{name: "RepoError", mockRepo: &mockProductRepository{err: errors.New("error")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &exportStream{}
+			err := NewProductService(tt.mockRepo, newMockCategoryRepository()).ExportProducts(&pb.ExportProductsRequest{}, stream)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProductService.ExportProducts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(stream.products, tt.wantProducts) {
+				t.Errorf("ProductService.ExportProducts() streamed %v, want %v", stream.products, tt.wantProducts)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ramseyjiang/go-micros/sales/products/internal/repos"
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+)
+
+// GetVariant returns the variant with a SKU, or the product without variants with the SKU as its single variant, 404 if there is none.
+func (s *ProductService) GetVariant(ctx context.Context, req *pb.GetVariantRequest) (*pb.Variant, error) {
+	if req.Sku == "" {
+		return nil, apierror.NewAPIErrorWithContext(ctx, nil, 400, "sku", "sku cannot be empty")
+	}
+	variant, err := s.repo.GetVariant(ctx, req.Sku)
+	if errors.Is(err, repos.ErrSKUNotFound) {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 404, "sku", "no product nor variant with SKU %q", req.Sku)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+// validateVariants checks the option dimensions and the variants of a product, the errors are
+// 400 APIErrors naming the invalid option or variant
+func validateVariants(ctx context.Context, sku string, stock int32, options []*pb.ProductOption, variants []*pb.Variant) error {
+	if len(options) == 0 && len(variants) == 0 {
+		return nil
+	}
+	if len(options) == 0 {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, "options", "a product with variants needs options")
+	}
+	if len(variants) == 0 {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, "variants", "a product with options needs variants")
+	}
+	if stock != 0 {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, "stock", "the stock of a product with variants is the one of its variants")
+	}
+
+	values := make(map[string]map[string]bool, len(options))
+	for i, option := range options {
+		field := fmt.Sprintf("options[%d]", i)
+		if option.Name == "" {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "option name cannot be empty")
+		}
+		if values[option.Name] != nil {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "option %q is defined twice", option.Name)
+		}
+		if len(option.Values) == 0 {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "option %q has no values", option.Name)
+		}
+		values[option.Name] = make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			if value == "" || values[option.Name][value] {
+				return apierror.NewAPIErrorWithContext(ctx, nil, 400, field, "option %q has an empty or repeated value", option.Name)
+			}
+			values[option.Name][value] = true
+		}
+	}
+
+	skus := map[string]bool{}
+	combinations := map[string]bool{}
+	for i, variant := range variants {
+		field := fmt.Sprintf("variants[%d]", i)
+		if variant.Sku == "" {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field+".sku", "variant sku is required")
+		}
+		if variant.Sku == sku || skus[variant.Sku] {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field+".sku", "SKU %q is used twice", variant.Sku)
+		}
+		skus[variant.Sku] = true
+
+		if len(variant.Options) != len(options) {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field+".options", "variant %q needs a value of each option", variant.Sku)
+		}
+		for name, value := range variant.Options {
+			if !values[name][value] {
+				return apierror.NewAPIErrorWithContext(ctx, nil, 400, field+".options", "variant %q has no option %q of value %q", variant.Sku, name, value)
+			}
+		}
+		combination := optionsKey(variant.Options)
+		if combinations[combination] {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field+".options", "variant %q has the options of another variant", variant.Sku)
+		}
+		combinations[combination] = true
+
+		if variant.PriceOverride != "" {
+			if err := validatePrice(ctx, field+".price_override", variant.PriceOverride); err != nil {
+				return err
+			}
+		}
+		if variant.Stock < 0 {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field+".stock", "stock cannot be negative")
+		}
+		if variant.Barcode != "" && !validGTIN(variant.Barcode) {
+			return apierror.NewAPIErrorWithContext(ctx, nil, 400, field+".barcode", "barcode %q is not a valid EAN-8, UPC-A, EAN-13 or GTIN-14", variant.Barcode)
+		}
+	}
+	return nil
+}
+
+// optionsKey returns the same key for the same option values
+func optionsKey(options map[string]string) string {
+	pairs := make([]string, 0, len(options))
+	for name, value := range options {
+		pairs = append(pairs, name+"\x00"+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x00")
+}
+
+// validGTIN reports whether barcode is a GTIN-8, 12, 13 or 14 with a valid check digit
+func validGTIN(barcode string) bool {
+	switch len(barcode) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	sum := 0
+	for i := len(barcode) - 1; i >= 0; i-- {
+		digit := int(barcode[i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		// from the right, the check digit has weight 1, then the weights alternate 3 and 1
+		if (len(barcode)-1-i)%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCreateProductVariants(t *testing.T) {
+	ctx := context.Background()
+	options := []*pb.ProductOption{
+		{Name: "size", Values: []string{"S", "M"}},
+		{Name: "colour", Values: []string{"red", "blue"}},
+	}
+	variant := func(sku, size, colour string) *pb.Variant {
+		return &pb.Variant{Sku: sku, Options: map[string]string{"size": size, "colour": colour}}
+	}
+
+	tests := []struct {
+		name      string
+		req       *pb.CreateProductRequest
+		wantCode  int
+		wantField string
+	}{
+		{
+			name: "Success",
+			req: &pb.CreateProductRequest{Name: "Tee", Price: "30", Sku: "TEE", Options: options, Variants: []*pb.Variant{
+				{Sku: "TEE-S-RED", Options: map[string]string{"size": "S", "colour": "red"}, Stock: 3, Barcode: "9421000000004"},
+				{Sku: "TEE-M-BLUE", Options: map[string]string{"size": "M", "colour": "blue"}, PriceOverride: "32.5", Barcode: "036000291452"},
+			}},
+		},
+		{
+			name:      "VariantsWithoutOptions",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Variants: []*pb.Variant{variant("TEE-S", "S", "red")}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "options",
+		},
+		{
+			name:      "OptionsWithoutVariants",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants",
+		},
+		{
+			name:      "ProductStock",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Stock: 5, Options: options, Variants: []*pb.Variant{variant("TEE-S", "S", "red")}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "stock",
+		},
+		{
+			name: "RepeatedOptionValue",
+			req: &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: []*pb.ProductOption{{Name: "size", Values: []string{"S", "S"}}}, Variants: []*pb.Variant{
+				{Sku: "TEE-S", Options: map[string]string{"size": "S"}},
+			}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "options[0]",
+		},
+		{
+			name:      "MissingSKU",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{variant("", "S", "red")}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[0].sku",
+		},
+		{
+			name:      "SKUOfTheProduct",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Sku: "TEE", Options: options, Variants: []*pb.Variant{variant("TEE", "S", "red")}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[0].sku",
+		},
+		{
+			name:      "MissingOption",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{{Sku: "TEE-S", Options: map[string]string{"size": "S"}}}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[0].options",
+		},
+		{
+			name:      "UnknownOptionValue",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{variant("TEE-XL", "XL", "red")}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[0].options",
+		},
+		{
+			name:      "RepeatedCombination",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{variant("TEE-1", "S", "red"), variant("TEE-2", "S", "red")}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[1].options",
+		},
+		{
+			name: "InvalidPriceOverride",
+			req: &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{
+				{Sku: "TEE-S", Options: map[string]string{"size": "S", "colour": "red"}, PriceOverride: "-1"},
+			}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[0].price_override",
+		},
+		{
+			name: "NegativeStock",
+			req: &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{
+				{Sku: "TEE-S", Options: map[string]string{"size": "S", "colour": "red"}, Stock: -1},
+			}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[0].stock",
+		},
+		{
+			name: "InvalidBarcode",
+			req: &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{
+				{Sku: "TEE-S", Options: map[string]string{"size": "S", "colour": "red"}, Barcode: "9421000000006"},
+			}},
+			wantCode:  http.StatusBadRequest,
+			wantField: "variants[0].barcode",
+		},
+		{
+			name:      "TakenSKU",
+			req:       &pb.CreateProductRequest{Name: "Tee", Price: "30", Options: options, Variants: []*pb.Variant{variant("SKU-1", "S", "red")}},
+			wantCode:  http.StatusConflict,
+			wantField: "sku",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockProductRepository{products: []*pb.Product{{Id: "1", Name: "Product 1", Price: "10.99", Sku: "SKU-1"}}}
+			got, err := NewProductService(repo, newMockCategoryRepository()).CreateProduct(ctx, tt.req)
+			assertAPIErrorCode(t, err, tt.wantCode)
+			if err != nil {
+				var apiErr *apierror.APIError
+				if errors.As(err, &apiErr) && apiErr.ErrorField != tt.wantField {
+					t.Errorf("error field %q, want %q", apiErr.ErrorField, tt.wantField)
+				}
+				return
+			}
+			if len(got.Options) != len(tt.req.Options) || len(got.Variants) != len(tt.req.Variants) {
+				t.Errorf("CreateProduct() = %v, want the options and variants of %v", got, tt.req)
+			}
+		})
+	}
+}
+
+func TestGetVariant(t *testing.T) {
+	ctx := context.Background()
+	variant := &pb.Variant{Sku: "TEE-S", ProductId: "1", Options: map[string]string{"size": "S"}, PriceOverride: "25", Price: "25", Stock: 2}
+	repo := &mockProductRepository{products: []*pb.Product{
+		{Id: "1", Name: "Tee", Price: "30", Options: []*pb.ProductOption{{Name: "size", Values: []string{"S"}}}, Variants: []*pb.Variant{variant}},
+		{Id: "2", Name: "Cap", Price: "15", Sku: "CAP", Stock: 4},
+	}}
+
+	tests := []struct {
+		name     string
+		sku      string
+		want     *pb.Variant
+		wantCode int
+	}{
+		{name: "Variant", sku: "TEE-S", want: variant},
+		{name: "ProductSKU", sku: "CAP", want: &pb.Variant{Sku: "CAP", ProductId: "2", Price: "15", Stock: 4}},
+		{name: "UnknownSKU", sku: "HAT", wantCode: http.StatusNotFound},
+		{name: "EmptySKU", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewProductService(repo, newMockCategoryRepository()).GetVariant(ctx, &pb.GetVariantRequest{Sku: tt.sku})
+			assertAPIErrorCode(t, err, tt.wantCode)
+			if err == nil && !proto.Equal(got, tt.want) {
+				t.Errorf("GetVariant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidGTIN(t *testing.T) {
+	tests := []struct {
+		barcode string
+		want    bool
+	}{
+		{barcode: "96385074", want: true},         // EAN-8
+		{barcode: "036000291452", want: true},     // UPC-A
+		{barcode: "9421000000004", want: true},    // EAN-13
+		{barcode: "10012345678902", want: true},   // GTIN-14
+		{barcode: "9421000000005", want: false},   // wrong check digit
+		{barcode: "942100000000", want: false},    // check digit of another length
+		{barcode: "94210000000O5", want: false},   // not a digit
+		{barcode: "942100000000005", want: false}, // no GTIN of 15 digits
+	}
+	for _, tt := range tests {
+		t.Run(tt.barcode, func(t *testing.T) {
+			if got := validGTIN(tt.barcode); got != tt.want {
+				t.Errorf("validGTIN(%s) = %v, want %v", tt.barcode, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"flag"
 	"log"
 	"net"
 	"os"
@@ -10,9 +9,19 @@ import (
 	"syscall"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/sales/products/events"
 	"github.com/ramseyjiang/go-micros/sales/products/internal/repos"
 	"github.com/ramseyjiang/go-micros/sales/products/internal/services"
 	pb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"github.com/ramseyjiang/go-micros/shared/healthcheck"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"github.com/ramseyjiang/go-micros/shared/outbox"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/leveladmin"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/requestlog"
+	"github.com/ramseyjiang/go-micros/shared/tracing"
+	"github.com/ramseyjiang/go-micros/shared/viperconf"
+	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -22,10 +31,25 @@ const (
 	redisEnvVar               = "REDIS_ADDR"
 	defaultProductServicePort = ":9011"
 	defaultRedisPort          = "localhost:6379" // listen on localhost:6379 port, not listen all :6379
+	defaultMetricsAddr        = "localhost:9101"
 )
 
 func main() {
-	flag.Parse()
+	// The metrics are served on metrics-addr while export-metrics is set, each service has its own default
+	viper.SetDefault("metrics-addr", defaultMetricsAddr)
+
+	// Read the config file, the flags and the environment variables (tracing-enabled or TRACING_ENABLED...)
+	if err := viperconf.SetupViperV2(nil, "products", func() {}, nil); err != nil {
+		log.Fatalf("Failed to load the config: %v", err)
+	}
+
+	// Set up tracing before the gRPC server and clients, their instrumentation uses the tracer provider
+	shutdownTracing, err := tracing.Setup(context.Background(), viperconf.TracingOptions(viper.GetViper(), "products"))
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	// Export the spans still buffered once the server has stopped
+	defer shutdownTracing(context.Background())
 
 	// Initialize a Redis client
 	redisAddr := os.Getenv(redisEnvVar) // For example: "localhost:6379"
@@ -38,16 +62,30 @@ func main() {
 		DB:       0,  // use default DB
 	})
 
-	// Test Redis connection
+	// Test Redis connection, the health check reports NOT_SERVING until Redis is reachable
 	if err := redisClient.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		log.Printf("Failed to connect to Redis: %v", err)
 	}
 
 	// Initialize the product repository
 	productRepo := repos.NewProductRepository(redisClient)
 
-	// Initialize the product service with the repository
-	productSvc := services.NewProductService(productRepo)
+	// Index the products stored before the search indexes existed, the indexes are then kept with the writes
+	if indexed, err := productRepo.ReindexProducts(context.Background()); err != nil {
+		log.Printf("Failed to index the products: %v", err)
+	} else {
+		log.Printf("Indexed %d products", indexed)
+	}
+
+	// Relay the domain events queued with the product changes to their stream, with its own client:
+	// its blocking reads are neither traced nor timed with the requests
+	relayClient := redis.NewClient(redisClient.Options())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go outbox.NewRelay(relayClient, outbox.RelayOptions{Outbox: events.Outbox, Stream: events.Stream}).Run(ctx)
+
+	// Initialize the product service with the repositories
+	productSvc := services.NewProductService(productRepo, repos.NewCategoryRepository(redisClient))
 
 	productServicePort := os.Getenv(productServiceEnvVar)
 	if productServicePort == "" {
@@ -59,11 +97,38 @@ func main() {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		// Continue the trace of the caller (traceparent) with a span per RPC
+		tracing.ServerOption(),
+		// Count and time every call, first so the recorded code is the one sent to the caller
+		metrics.UnaryServerInterceptor(),
+		metrics.StreamServerInterceptor(),
+		// Share one request ID across every log line written for a request, the APIError ones included
+		requestlog.UnaryServerInterceptor(),
+		requestlog.StreamServerInterceptor(),
+		// Convert every returned error into an APIError, so callers receive the full error stack.
+		// Chained after the metrics: grpc.UnaryInterceptor (apierror.UnaryInterceptor) would run before them.
+		grpc.ChainUnaryInterceptor(apierror.UnaryServerInt()),
+		grpc.ChainStreamInterceptor(apierror.StreamServerInt()),
+		// Turn panicking handlers into 500 APIErrors instead of crashing the process
+		apierror.RecoveryUnaryInterceptor(),
+		apierror.RecoveryStreamInterceptor(),
+	)
 
 	// Register ProductServiceServer
 	pb.RegisterProductServiceServer(grpcServer, productSvc)
 
+	// Get and set the log levels (per package, with an optional TTL) without restarting
+	leveladmin.RegisterLogLevelServiceServer(grpcServer, leveladmin.NewServer(nil))
+
+	// Report the health (grpc.health.v1) of the server and its services from the Redis connection
+	healthChecker := healthcheck.NewChecker(healthcheck.Options{})
+	healthChecker.AddCheck("redis", func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	})
+	healthChecker.Register(grpcServer)
+	healthChecker.Start()
+
 	// Register reflection service on gRPC server.
 	// It is also used for grpcurl sending in the terminal.
 	reflection.Register(grpcServer)
@@ -74,7 +139,11 @@ func main() {
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		<-c
 		log.Println("Shutting down gRPC server...")
+		// Stop receiving new requests before draining the current ones
+		healthChecker.Shutdown()
 		grpcServer.GracefulStop()
+		cancel()
+		relayClient.Close()
 		redisClient.Close()
 		log.Println("Server has been stopped.")
 	}()
@@ -0,0 +1,10 @@
+// Package events defines the domain events the trade service publishes (trade_events.proto), relayed
+// from its outbox to a Redis Stream.
+package events
+
+// The domain events are queued in Outbox with the change, then relayed to Stream (see outbox.Relay)
+const (
+	Source = "trade"
+	Outbox = "trade:outbox"
+	Stream = "events:trade"
+)
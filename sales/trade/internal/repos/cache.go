@@ -0,0 +1,196 @@
+package repos
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/sales/products/events"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheOptions configures the product cache of the trade repository
+type CacheOptions struct {
+	// TTL of a cached product, the change events usually invalidate it earlier. 0 disables the cache.
+	TTL time.Duration
+	// NegativeTTL of a product ID found unknown
+	NegativeTTL time.Duration
+	// MaxEntries bounds the cache, the least recently used products are evicted first
+	MaxEntries int
+}
+
+// DefaultCacheOptions are the defaults of the product-cache-* config
+func DefaultCacheOptions() CacheOptions {
+	return CacheOptions{TTL: 5 * time.Minute, NegativeTTL: 30 * time.Second, MaxEntries: 10000}
+}
+
+// cachedProduct is a product lookup result, exists is false for an unknown ID (negative caching).
+// A SKU lookup is cached by skuCacheKey, with the ID of the product of the SKU.
+type cachedProduct struct {
+	id        string
+	productID string
+	exists    bool
+	price     float32
+	// hasVariants is set for the products sold by variant SKU only
+	hasVariants bool
+	expires     time.Time
+}
+
+// skuCacheKey is the cache key of a SKU lookup, never a product ID
+func skuCacheKey(sku string) string {
+	return "sku:" + sku
+}
+
+// productCache is a read-through, size bound LRU cache of the product lookups
+type productCache struct {
+	opts CacheOptions
+	now  func() time.Time
+	// misses collapses the concurrent lookups of the same product into one call
+	misses singleflight.Group
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// generation changes on every invalidation, results fetched before it are not cached
+	generation uint64
+}
+
+func newProductCache(opts CacheOptions) *productCache {
+	return &productCache{
+		opts:    opts,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+func (c *productCache) enabled() bool {
+	return c != nil && c.opts.TTL > 0
+}
+
+// get returns the cached lookup of productID, if not expired
+func (c *productCache) get(productID string) (cachedProduct, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[productID]
+	if !ok {
+		return cachedProduct{}, false
+	}
+	entry := element.Value.(cachedProduct)
+	if !c.now().Before(entry.expires) {
+		c.removeLocked(element)
+		return cachedProduct{}, false
+	}
+	c.lru.MoveToFront(element)
+	return entry, true
+}
+
+// currentGeneration is read before fetching, see setIfCurrent
+func (c *productCache) currentGeneration() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+// setIfCurrent caches the fetched lookups, unless an invalidation happened since generation
+// was read: the fetched products may predate the change
+func (c *productCache) setIfCurrent(generation uint64, products ...cachedProduct) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation != c.generation {
+		return
+	}
+	now := c.now()
+	for _, product := range products {
+		if product.exists {
+			product.expires = now.Add(c.opts.TTL)
+		} else {
+			if c.opts.NegativeTTL <= 0 {
+				continue
+			}
+			product.expires = now.Add(c.opts.NegativeTTL)
+		}
+		if element, ok := c.entries[product.id]; ok {
+			element.Value = product
+			c.lru.MoveToFront(element)
+			continue
+		}
+		c.entries[product.id] = c.lru.PushFront(product)
+		for c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries {
+			c.removeLocked(c.lru.Back())
+		}
+	}
+}
+
+// invalidate removes productID (cached or negatively cached), and the SKUs of its variants
+func (c *productCache) invalidate(productID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	if element, ok := c.entries[productID]; ok {
+		c.removeLocked(element)
+	}
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(cachedProduct).productID == productID {
+			c.removeLocked(element)
+		}
+		element = next
+	}
+}
+
+// purge removes every product, when change events may have been missed
+func (c *productCache) purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+}
+
+func (c *productCache) removeLocked(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(cachedProduct).id)
+}
+
+// handleEvent invalidates the product of a change event: a created product may be negatively
+// cached, an updated one repriced and a deleted one gone
+func (c *productCache) handleEvent(payload string) {
+	event, err := events.Decode(payload)
+	if err != nil {
+		srvlog.Warnf("Ignoring product change event: %v", err)
+		return
+	}
+	c.invalidate(event.ProductID)
+}
+
+// watch invalidates the cache from the product change events until ctx is done. The cache is
+// purged on every (re)subscription, as the events published while disconnected are lost.
+func (c *productCache) watch(ctx context.Context, redisClient *redis.Client) {
+	pubsub := redisClient.Subscribe(ctx, events.Channel)
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.ChannelWithSubscriptions(ctx, 100)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				switch message := message.(type) {
+				case *redis.Subscription:
+					if message.Kind == "subscribe" {
+						c.purge()
+					}
+				case *redis.Message:
+					c.handleEvent(message.Payload)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,261 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/sales/products/events"
+	productpb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeProductClient gets products by ID, optionally waiting for release before answering
+type fakeProductClient struct {
+	productpb.ProductServiceClient
+	mutex    sync.Mutex
+	products []*productpb.Product
+	calls    atomic.Int32
+	release  chan struct{}
+	// variants are resolved by GetVariant, counted by variantCalls
+	variants     map[string]*productpb.Variant
+	variantCalls atomic.Int32
+}
+
+func (f *fakeProductClient) GetProduct(ctx context.Context, in *productpb.GetProductRequest, opts ...grpc.CallOption) (*productpb.Product, error) {
+	f.calls.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, product := range f.products {
+		if product.Id == in.Id {
+			return proto.Clone(product).(*productpb.Product), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no product with ID %q", in.Id)
+}
+
+func (f *fakeProductClient) GetVariant(ctx context.Context, in *productpb.GetVariantRequest, opts ...grpc.CallOption) (*productpb.Variant, error) {
+	f.variantCalls.Add(1)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	variant, ok := f.variants[in.Sku]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no product nor variant with SKU %q", in.Sku)
+	}
+	return proto.Clone(variant).(*productpb.Variant), nil
+}
+
+func (f *fakeProductClient) setPrice(id, price string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, product := range f.products {
+		if product.Id == id {
+			product.Price = price
+			return
+		}
+	}
+	f.products = append(f.products, &productpb.Product{Id: id, Name: "product " + id, Price: price})
+}
+
+func newCachedRepository(client *fakeProductClient, opts CacheOptions) (*tradeRepositoryImpl, *time.Time) {
+	now := time.Now()
+	cache := newProductCache(opts)
+	cache.now = func() time.Time { return now }
+	return &tradeRepositoryImpl{productServiceClient: client, cache: cache}, &now
+}
+
+func TestProductCache(t *testing.T) {
+	client := &fakeProductClient{products: []*productpb.Product{
+		{Id: "1", Price: "10"},
+		{Id: "2", Price: "20"},
+		{Id: "4", Price: "40", Variants: []*productpb.Variant{{Sku: "TEE-S", ProductId: "4"}}},
+	}}
+	repo, now := newCachedRepository(client, CacheOptions{TTL: time.Minute, NegativeTTL: 10 * time.Second, MaxEntries: 10})
+
+	tests := []struct {
+		name      string
+		before    func()
+		productID string
+		wantFound bool
+		wantPrice float32
+		wantErr   error
+		wantCalls int32
+	}{
+		{name: "MissFetches", productID: "1", wantFound: true, wantPrice: 10, wantCalls: 1},
+		{name: "Hit", productID: "1", wantFound: true, wantPrice: 10, wantCalls: 1},
+		{name: "OtherProductFetches", productID: "2", wantFound: true, wantPrice: 20, wantCalls: 2},
+		{name: "VariantRequired", productID: "4", wantFound: true, wantPrice: 40, wantErr: ErrVariantRequired, wantCalls: 3},
+		{name: "VariantRequiredCached", productID: "4", wantFound: true, wantPrice: 40, wantErr: ErrVariantRequired, wantCalls: 3},
+		{name: "UnknownFetches", productID: "3", wantCalls: 4},
+		{name: "UnknownNegativelyCached", productID: "3", wantCalls: 4},
+		{name: "CreatedEventInvalidatesNegativeEntry", before: func() {
+			client.setPrice("3", "30")
+			repo.cache.handleEvent(`{"type":"created","product_id":"3"}`)
+		}, productID: "3", wantFound: true, wantPrice: 30, wantCalls: 5},
+		{name: "UpdatedEventReprices", before: func() {
+			client.setPrice("1", "15")
+			repo.cache.handleEvent(`{"type":"updated","product_id":"1"}`)
+		}, productID: "1", wantFound: true, wantPrice: 15, wantCalls: 6},
+		{name: "TTLExpires", before: func() {
+			client.setPrice("2", "25")
+			*now = now.Add(2 * time.Minute)
+		}, productID: "2", wantFound: true, wantPrice: 25, wantCalls: 7},
+		{name: "InvalidEventIgnored", before: func() {
+			repo.cache.handleEvent(`not json`)
+		}, productID: "2", wantFound: true, wantPrice: 25, wantCalls: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.before != nil {
+				tt.before()
+			}
+			found, price, err := repo.CheckProductExists(context.Background(), tt.productID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckProductExists(%s) error %v, want %v", tt.productID, err, tt.wantErr)
+			}
+			if found != tt.wantFound || price != tt.wantPrice {
+				t.Errorf("CheckProductExists(%s) = %v, %v, want %v, %v", tt.productID, found, price, tt.wantFound, tt.wantPrice)
+			}
+			if got := client.calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d calls to the product service, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestProductCacheSKU(t *testing.T) {
+	client := &fakeProductClient{variants: map[string]*productpb.Variant{
+		"TEE-S": {Sku: "TEE-S", ProductId: "7", Price: "30"},
+		"TEE-M": {Sku: "TEE-M", ProductId: "7", Price: "32"},
+		"CAP":   {Sku: "CAP", ProductId: "8", Price: "15"},
+	}}
+	repo, _ := newCachedRepository(client, CacheOptions{TTL: time.Minute, NegativeTTL: 10 * time.Second, MaxEntries: 10})
+
+	tests := []struct {
+		name          string
+		before        func()
+		sku           string
+		wantProductID string
+		wantPrice     float32
+		wantCalls     int32
+	}{
+		{name: "MissFetches", sku: "TEE-S", wantProductID: "7", wantPrice: 30, wantCalls: 1},
+		{name: "Hit", sku: "TEE-S", wantProductID: "7", wantPrice: 30, wantCalls: 1},
+		{name: "OtherVariantFetches", sku: "TEE-M", wantProductID: "7", wantPrice: 32, wantCalls: 2},
+		{name: "UnknownFetches", sku: "TEE-XL", wantCalls: 3},
+		{name: "UnknownNotCached", sku: "TEE-XL", wantCalls: 4},
+		{name: "UpdatedEventInvalidatesTheVariants", before: func() {
+			client.mutex.Lock()
+			client.variants["TEE-S"].Price = "28"
+			client.mutex.Unlock()
+			repo.cache.handleEvent(`{"type":"updated","product_id":"7"}`)
+		}, sku: "TEE-S", wantProductID: "7", wantPrice: 28, wantCalls: 5},
+		{name: "OtherProductKept", before: func() {
+			if _, _, err := repo.ResolveSKU(context.Background(), "CAP"); err != nil {
+				t.Fatal(err)
+			}
+			repo.cache.handleEvent(`{"type":"updated","product_id":"7"}`)
+		}, sku: "CAP", wantProductID: "8", wantPrice: 15, wantCalls: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.before != nil {
+				tt.before()
+			}
+			productID, price, err := repo.ResolveSKU(context.Background(), tt.sku)
+			if err != nil {
+				t.Fatalf("ResolveSKU() error %v", err)
+			}
+			if productID != tt.wantProductID || price != tt.wantPrice {
+				t.Errorf("ResolveSKU() = %q, %v, want %q, %v", productID, price, tt.wantProductID, tt.wantPrice)
+			}
+			if calls := client.variantCalls.Load(); calls != tt.wantCalls {
+				t.Errorf("product service called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestProductCacheSizeBound(t *testing.T) {
+	cache := newProductCache(CacheOptions{TTL: time.Minute, MaxEntries: 2})
+	generation := cache.currentGeneration()
+	cache.setIfCurrent(generation, cachedProduct{id: "1", exists: true}, cachedProduct{id: "2", exists: true})
+	cache.get("1") // 2 is now the least recently used
+	cache.setIfCurrent(generation, cachedProduct{id: "3", exists: true})
+
+	tests := []struct {
+		productID  string
+		wantCached bool
+	}{
+		{productID: "1", wantCached: true},
+		{productID: "2", wantCached: false},
+		{productID: "3", wantCached: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.productID, func(t *testing.T) {
+			if _, cached := cache.get(tt.productID); cached != tt.wantCached {
+				t.Errorf("product %s cached %v, want %v", tt.productID, cached, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestProductCacheStaleFetchNotCached(t *testing.T) {
+	cache := newProductCache(CacheOptions{TTL: time.Minute})
+	generation := cache.currentGeneration()
+	// the product changed while it was fetched
+	cache.invalidate("1")
+	cache.setIfCurrent(generation, cachedProduct{id: "1", exists: true, price: 10})
+	if _, cached := cache.get("1"); cached {
+		t.Error("a product fetched before its change event must not be cached")
+	}
+}
+
+func TestProductCacheCollapsesMisses(t *testing.T) {
+	client := &fakeProductClient{products: []*productpb.Product{{Id: "1", Price: "10"}}, release: make(chan struct{})}
+	repo, _ := newCachedRepository(client, DefaultCacheOptions())
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if found, price, err := repo.CheckProductExists(context.Background(), "1"); err != nil || !found || price != 10 {
+				t.Errorf("CheckProductExists() = %v, %v, %v", found, price, err)
+			}
+		}()
+	}
+	// let the callers pile up on the first call
+	for client.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(client.release)
+	wg.Wait()
+
+	if got := client.calls.Load(); got != 1 {
+		t.Errorf("%d concurrent misses made %d calls, want 1", callers, got)
+	}
+}
+
+func TestProductChangedEncoding(t *testing.T) {
+	payload, err := events.ProductChanged{Type: events.ChangeDeleted, ProductID: "7"}.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := events.Decode(payload)
+	if err != nil || event.Type != events.ChangeDeleted || event.ProductID != "7" {
+		t.Errorf("Decode(%s) = %+v, %v", payload, event, err)
+	}
+}
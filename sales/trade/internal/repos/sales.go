@@ -0,0 +1,251 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/sales/trade/events"
+	"github.com/ramseyjiang/go-micros/shared/outbox"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+)
+
+// SaleLine is a sold product, with the unit price it was sold at. SKU is the sold variant, or
+// product, SKU when the line item referenced one.
+type SaleLine struct {
+	ProductID string  `json:"product_id"`
+	Quantity  int32   `json:"quantity"`
+	UnitPrice float32 `json:"unit_price"`
+	SKU       string  `json:"sku,omitempty"`
+}
+
+// Sale is a stored sale
+type Sale struct {
+	ID             string
+	Lines          []SaleLine
+	DiscountAmount float32
+	TotalPrice     float32
+	CreatedAt      time.Time
+}
+
+// CreateSale stores sale, setting its ID and creation time, with its SaleCreated event in the
+// same transaction: the event is published if and only if the sale is stored.
+func (r *tradeRepositoryImpl) CreateSale(ctx context.Context, sale *Sale) error {
+	if r.redisClient == nil {
+		return fmt.Errorf("no Redis client to store the sale")
+	}
+	nextID, err := r.redisClient.Incr(ctx, "sale:next_id").Result()
+	if err != nil {
+		return fmt.Errorf("error generating new ID for sale: %v", err)
+	}
+	saleID := strconv.FormatInt(nextID, 10)
+
+	lines, err := json.Marshal(sale.Lines)
+	if err != nil {
+		return fmt.Errorf("error encoding the sale lines: %v", err)
+	}
+	createdAt := time.Now().UTC()
+
+	created := &events.SaleCreated{
+		SaleId:         saleID,
+		DiscountAmount: sale.DiscountAmount,
+		TotalPrice:     sale.TotalPrice,
+	}
+	for _, line := range sale.Lines {
+		created.Lines = append(created.Lines, &events.SaleLine{ProductId: line.ProductID, Quantity: line.Quantity, UnitPrice: line.UnitPrice, Sku: line.SKU})
+	}
+	event, err := outbox.NewEvent(ctx, events.Source, saleID, created)
+	if err != nil {
+		return err
+	}
+
+	if _, err = r.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, "sale:"+saleID, map[string]interface{}{
+			"id":              saleID,
+			"lines":           string(lines),
+			"discount_amount": strconv.FormatFloat(float64(sale.DiscountAmount), 'f', -1, 32),
+			"total_price":     strconv.FormatFloat(float64(sale.TotalPrice), 'f', -1, 32),
+			"created_at":      createdAt.Format(time.RFC3339Nano),
+		})
+		return outbox.Enqueue(ctx, pipe, events.Outbox, event)
+	}); err != nil {
+		return fmt.Errorf("error storing sale in Redis: %v", err)
+	}
+
+	sale.ID = saleID
+	sale.CreatedAt = createdAt
+	return nil
+}
+
+// maxRefundRetries is how many times a refund is retried when the sale changed meanwhile
+const maxRefundRetries = 5
+
+var (
+	// ErrSaleNotFound is returned when refunding a sale not stored
+	ErrSaleNotFound = errors.New("sale not found")
+	// ErrRefundExceedsTotal is returned when a refund is not positive or exceeds what is left of the sale total
+	ErrRefundExceedsTotal = errors.New("refund exceeds the sale total")
+)
+
+// RefundSale refunds amount of the sale, what is left of its total when amount is 0, and returns the
+// refunded amount and the amount of every refund of the sale. Its SaleRefunded event is queued in the
+// same transaction: the event is published if and only if the refund is stored.
+func (r *tradeRepositoryImpl) RefundSale(ctx context.Context, saleID string, amount float32, reason string) (float32, float32, error) {
+	if r.redisClient == nil {
+		return 0, 0, fmt.Errorf("no Redis client to refund the sale")
+	}
+	key := "sale:" + saleID
+
+	var refunded, totalRefunded float32
+	var err error
+	for attempt := 0; attempt < maxRefundRetries; attempt++ {
+		err = r.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.HMGet(ctx, key, "total_price", "refunded_amount").Result()
+			if err != nil {
+				return fmt.Errorf("error retrieving sale from Redis: %v", err)
+			}
+			totalField, _ := data[0].(string)
+			if totalField == "" {
+				return fmt.Errorf("%w: %s", ErrSaleNotFound, saleID)
+			}
+			total, err := strconv.ParseFloat(totalField, 32)
+			if err != nil {
+				return fmt.Errorf("error parsing the total of sale (%s): %v", saleID, err)
+			}
+			var previous float64
+			if refundedField, _ := data[1].(string); refundedField != "" {
+				if previous, err = strconv.ParseFloat(refundedField, 32); err != nil {
+					return fmt.Errorf("error parsing the refunded amount of sale (%s): %v", saleID, err)
+				}
+			}
+
+			left := float32(total) - float32(previous)
+			refunded = amount
+			if refunded == 0 {
+				refunded = left
+			}
+			if refunded <= 0 || refunded > left {
+				return fmt.Errorf("%w: %s, %v left to refund", ErrRefundExceedsTotal, saleID, left)
+			}
+			totalRefunded = float32(previous) + refunded
+
+			event, err := outbox.NewEvent(ctx, events.Source, saleID, &events.SaleRefunded{SaleId: saleID, Amount: refunded, Reason: reason})
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, key, "refunded_amount", strconv.FormatFloat(float64(totalRefunded), 'f', -1, 32))
+				return outbox.Enqueue(ctx, pipe, events.Outbox, event)
+			})
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if errors.Is(err, ErrSaleNotFound) || errors.Is(err, ErrRefundExceedsTotal) {
+		return 0, 0, err
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("error refunding sale in Redis: %v", err)
+	}
+	return refunded, totalRefunded, nil
+}
+
+// SaleEvent is a created sale, Cursor is the ID of its SaleCreated event in the stream
+type SaleEvent struct {
+	Cursor string
+	Sale   *Sale
+}
+
+// LatestSaleCursor returns the cursor of the last event, reading after it returns the sales created from now on
+func (r *tradeRepositoryImpl) LatestSaleCursor(ctx context.Context) (string, error) {
+	if r.redisClient == nil {
+		return "", fmt.Errorf("no Redis client to read the sales")
+	}
+	entries, err := r.redisClient.XRevRangeN(ctx, events.Stream, "+", "-", 1).Result()
+	if err != nil {
+		return "", fmt.Errorf("error reading the sale events: %v", err)
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// ReadSales returns up to count sales created after cursor, waiting up to block for one, and the
+// cursor to read the next ones from. The events are read from the stream the outbox is relayed to,
+// the sales from their storage. The cursors older than the stream (see outbox.RelayOptions.MaxLen)
+// resume from its first event.
+func (r *tradeRepositoryImpl) ReadSales(ctx context.Context, cursor string, count int64, block time.Duration) ([]SaleEvent, string, error) {
+	if r.redisClient == nil {
+		return nil, cursor, fmt.Errorf("no Redis client to read the sales")
+	}
+	streams, err := r.redisClient.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{events.Stream, cursor},
+		Count:   count,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, cursor, nil
+	}
+	if err != nil {
+		return nil, cursor, fmt.Errorf("error reading the sale events: %v", err)
+	}
+
+	saleCreated := string((&events.SaleCreated{}).ProtoReflect().Descriptor().FullName())
+	var sales []SaleEvent
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			cursor = message.ID
+			if message.Values[outbox.FieldType] != saleCreated {
+				continue
+			}
+			saleID, _ := message.Values[outbox.FieldSubject].(string)
+			sale, err := r.getSale(ctx, saleID)
+			if err != nil {
+				return nil, cursor, err
+			}
+			if sale == nil {
+				srvlog.WithContext(ctx).Warnf("Skipping the event (%s) of sale (%s), the sale is not stored", message.ID, saleID)
+				continue
+			}
+			sales = append(sales, SaleEvent{Cursor: message.ID, Sale: sale})
+		}
+	}
+	return sales, cursor, nil
+}
+
+// getSale returns the stored sale, nil if there is none
+func (r *tradeRepositoryImpl) getSale(ctx context.Context, saleID string) (*Sale, error) {
+	data, err := r.redisClient.HGetAll(ctx, "sale:"+saleID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving sale from Redis: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	sale := &Sale{ID: data["id"]}
+	if err := json.Unmarshal([]byte(data["lines"]), &sale.Lines); err != nil {
+		return nil, fmt.Errorf("error decoding the lines of sale (%s): %v", saleID, err)
+	}
+	discount, err := strconv.ParseFloat(data["discount_amount"], 32)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the discount of sale (%s): %v", saleID, err)
+	}
+	total, err := strconv.ParseFloat(data["total_price"], 32)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the total of sale (%s): %v", saleID, err)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, data["created_at"])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the creation time of sale (%s): %v", saleID, err)
+	}
+	sale.DiscountAmount, sale.TotalPrice, sale.CreatedAt = float32(discount), float32(total), createdAt
+	return sale, nil
+}
@@ -0,0 +1,210 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/sales/trade/events"
+	"github.com/ramseyjiang/go-micros/shared/outbox"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCreateSale(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &tradeRepositoryImpl{redisClient: client}
+
+	tests := []struct {
+		name      string
+		sale      *Sale
+		failWrite bool
+		wantID    string
+		wantErr   bool
+	}{
+		{
+			name:   "stored with its event",
+			sale:   &Sale{Lines: []SaleLine{{ProductID: "1", Quantity: 2, UnitPrice: 10}}, DiscountAmount: 5, TotalPrice: 15},
+			wantID: "1",
+		},
+		{
+			name:      "neither stored nor published when Redis fails",
+			sale:      &Sale{Lines: []SaleLine{{ProductID: "2", Quantity: 1, UnitPrice: 20}}, TotalPrice: 20},
+			failWrite: true,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.FlushAll()
+			if tt.failWrite {
+				server.SetError("ERR unable to write")
+			}
+			err := repo.CreateSale(ctx, tt.sale)
+			server.SetError("")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateSale() error %v, wantErr %v", err, tt.wantErr)
+			}
+
+			queued := client.LRange(ctx, events.Outbox, 0, -1).Val()
+			if tt.wantErr {
+				if len(queued) != 0 {
+					t.Errorf("%d events queued for a sale not stored", len(queued))
+				}
+				return
+			}
+			if tt.sale.ID != tt.wantID || tt.sale.CreatedAt.IsZero() {
+				t.Errorf("CreateSale() ID %q created at %v, want ID %q", tt.sale.ID, tt.sale.CreatedAt, tt.wantID)
+			}
+			if stored := client.HGet(ctx, "sale:"+tt.wantID, "total_price").Val(); stored != "15" {
+				t.Errorf("stored total price %q, want 15", stored)
+			}
+			if len(queued) != 1 {
+				t.Fatalf("%d events queued, want 1", len(queued))
+			}
+			var event outbox.CloudEvent
+			if err := proto.Unmarshal([]byte(queued[0]), &event); err != nil {
+				t.Fatal(err)
+			}
+			data, err := outbox.UnpackData(&event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			created, ok := data.(*events.SaleCreated)
+			if !ok || event.GetSubject() != tt.wantID || created.GetSaleId() != tt.wantID || len(created.GetLines()) != 1 || created.GetTotalPrice() != 15 {
+				t.Errorf("queued event %v with %v", &event, data)
+			}
+		})
+	}
+}
+
+func TestReadSales(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &tradeRepositoryImpl{redisClient: client}
+	relay := outbox.NewRelay(client, outbox.RelayOptions{Outbox: events.Outbox, Stream: events.Stream})
+
+	start, err := repo.LatestSaleCursor(ctx)
+	if err != nil || start != "0-0" {
+		t.Fatalf("LatestSaleCursor() of an empty stream = %q, %v", start, err)
+	}
+	for _, total := range []float32{10, 20, 30} {
+		if err := repo.CreateSale(ctx, &Sale{Lines: []SaleLine{{ProductID: "1", Quantity: 1, UnitPrice: total}}, TotalPrice: total}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := relay.RelayPending(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// an event of another type, and one of a sale no longer stored, are skipped
+	client.XAdd(ctx, &redis.XAddArgs{Stream: events.Stream, Values: map[string]interface{}{outbox.FieldType: "trade.events.SaleRefunded", outbox.FieldSubject: "1"}})
+	client.XAdd(ctx, &redis.XAddArgs{Stream: events.Stream, Values: map[string]interface{}{outbox.FieldType: "trade.events.SaleCreated", outbox.FieldSubject: "42"}})
+	latest, _ := repo.LatestSaleCursor(ctx)
+
+	tests := []struct {
+		name        string
+		cursor      string
+		count       int64
+		wantSaleIDs []string
+		wantNext    string
+	}{
+		{name: "from the start", cursor: start, count: 10, wantSaleIDs: []string{"1", "2", "3"}, wantNext: latest},
+		{name: "in batches", cursor: start, count: 2, wantSaleIDs: []string{"1", "2"}},
+		{name: "from the latest", cursor: latest, count: 10, wantNext: latest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sales, next, err := repo.ReadSales(ctx, tt.cursor, tt.count, time.Millisecond)
+			if err != nil {
+				t.Fatalf("ReadSales() error %v", err)
+			}
+			var saleIDs []string
+			for _, sale := range sales {
+				saleIDs = append(saleIDs, sale.Sale.ID)
+				if sale.Cursor == "" || sale.Sale.CreatedAt.IsZero() || sale.Sale.TotalPrice != sale.Sale.Lines[0].UnitPrice {
+					t.Errorf("ReadSales() sale %+v", sale)
+				}
+			}
+			if strings.Join(saleIDs, ",") != strings.Join(tt.wantSaleIDs, ",") {
+				t.Errorf("ReadSales() sales %v, want %v", saleIDs, tt.wantSaleIDs)
+			}
+			if len(sales) > 0 && tt.wantNext == "" && next != sales[len(sales)-1].Cursor {
+				t.Errorf("ReadSales() next cursor %q, want the last sale one %q", next, sales[len(sales)-1].Cursor)
+			}
+			if tt.wantNext != "" && next != tt.wantNext {
+				t.Errorf("ReadSales() next cursor %q, want %q", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestRefundSale(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	repo := &tradeRepositoryImpl{redisClient: client}
+
+	if err := repo.CreateSale(ctx, &Sale{Lines: []SaleLine{{ProductID: "1", Quantity: 2, UnitPrice: 20}}, TotalPrice: 40}); err != nil {
+		t.Fatal(err)
+	}
+	client.Del(ctx, events.Outbox)
+
+	tests := []struct {
+		name              string
+		saleID            string
+		amount            float32
+		wantRefunded      float32
+		wantTotalRefunded float32
+		wantErr           error
+	}{
+		{name: "partial", saleID: "1", amount: 15, wantRefunded: 15, wantTotalRefunded: 15},
+		{name: "exceeding what is left", saleID: "1", amount: 30, wantErr: ErrRefundExceedsTotal},
+		{name: "what is left", saleID: "1", wantRefunded: 25, wantTotalRefunded: 40},
+		{name: "fully refunded", saleID: "1", wantErr: ErrRefundExceedsTotal},
+		{name: "not stored", saleID: "42", amount: 5, wantErr: ErrSaleNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refunded, totalRefunded, err := repo.RefundSale(ctx, tt.saleID, tt.amount, "damaged")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefundSale() error %v, want %v", err, tt.wantErr)
+			}
+
+			queued := client.LRange(ctx, events.Outbox, 0, -1).Val()
+			client.Del(ctx, events.Outbox)
+			if tt.wantErr != nil {
+				if len(queued) != 0 {
+					t.Errorf("%d events queued for a refund not stored", len(queued))
+				}
+				return
+			}
+			if refunded != tt.wantRefunded || totalRefunded != tt.wantTotalRefunded {
+				t.Errorf("RefundSale() = %v, %v, want %v, %v", refunded, totalRefunded, tt.wantRefunded, tt.wantTotalRefunded)
+			}
+			if len(queued) != 1 {
+				t.Fatalf("%d events queued, want 1", len(queued))
+			}
+			var event outbox.CloudEvent
+			if err := proto.Unmarshal([]byte(queued[0]), &event); err != nil {
+				t.Fatal(err)
+			}
+			data, err := outbox.UnpackData(&event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			refund, ok := data.(*events.SaleRefunded)
+			if !ok || event.GetSubject() != tt.saleID || refund.GetSaleId() != tt.saleID || refund.GetAmount() != tt.wantRefunded || refund.GetReason() != "damaged" {
+				t.Errorf("queued event %v with %v", &event, data)
+			}
+		})
+	}
+}
@@ -2,52 +2,193 @@ package repos
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	// Import the product proto package if you're using gRPC to get products.
 	productpb "github.com/ramseyjiang/go-micros/sales/products/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"github.com/ramseyjiang/go-micros/shared/apierror/resilience"
+	"github.com/ramseyjiang/go-micros/shared/healthcheck"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/requestlog"
+	"github.com/ramseyjiang/go-micros/shared/tracing"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// ErrVariantRequired is returned for a product with variants, one of its variants has to be sold by SKU
+var ErrVariantRequired = errors.New("product has variants, a variant SKU is required")
+
 type TradeRepository interface {
+	// CheckProductExists returns whether the product exists and its price, ErrVariantRequired if it has variants
 	CheckProductExists(ctx context.Context, productID string) (bool, float32, error)
+	// ResolveSKU returns the product ID and the price of the variant, or product, with sku, no product ID if there is none
+	ResolveSKU(ctx context.Context, sku string) (string, float32, error)
+	// CheckProductService returns an error unless the product service is SERVING (grpc.health.v1)
+	CheckProductService(ctx context.Context) error
+	// CreateSale stores a sale and queues its SaleCreated event
+	CreateSale(ctx context.Context, sale *Sale) error
+	// RefundSale refunds amount of a sale (what is left of it when 0) and queues its SaleRefunded event,
+	// it returns the refunded amount and the amount of every refund of the sale
+	RefundSale(ctx context.Context, saleID string, amount float32, reason string) (float32, float32, error)
+	// LatestSaleCursor returns the cursor to read the sales created from now on
+	LatestSaleCursor(ctx context.Context) (string, error)
+	// ReadSales returns the sales created after cursor, and the cursor to read the next ones from
+	ReadSales(ctx context.Context, cursor string, count int64, block time.Duration) ([]SaleEvent, string, error)
 }
 
 type tradeRepositoryImpl struct {
 	productServiceClient productpb.ProductServiceClient
+	productServiceHealth healthcheck.Check
+	cache                *productCache
+	redisClient          *redis.Client
 }
 
-// NewTradeRepository creates a new instance of a TradeRepository.
-func NewTradeRepository(productServiceAddress string) (TradeRepository, error) {
-	conn, err := grpc.Dial(productServiceAddress, grpc.WithInsecure())
+// NewTradeRepository creates a new instance of a TradeRepository. The calls to the product service
+// get the deadline, circuit breaker and retries of clientOpts, GetProduct and GetVariant (idempotent
+// reads) are retried and hedged unless other methods are configured.
+// The products are cached with cacheOpts, invalidated by the product change events received
+// with redisClient until ctx is done (a nil redisClient only relies on the TTL).
+// The sales are stored with redisClient.
+func NewTradeRepository(ctx context.Context, productServiceAddress string, clientOpts resilience.Options, cacheOpts CacheOptions, redisClient *redis.Client) (TradeRepository, error) {
+	if len(clientOpts.RetryMethods) == 0 {
+		clientOpts.RetryMethods = []string{productpb.ProductService_GetProduct_FullMethodName, productpb.ProductService_GetVariant_FullMethodName}
+	}
+	if len(clientOpts.HedgeMethods) == 0 {
+		clientOpts.HedgeMethods = []string{productpb.ProductService_GetProduct_FullMethodName, productpb.ProductService_GetVariant_FullMethodName}
+	}
+	conn, err := grpc.Dial(productServiceAddress,
+		grpc.WithInsecure(),
+		// Trace the calls to the product service as children of the sales RPC span
+		tracing.DialOption(),
+		// Count and time the calls to the product service
+		metrics.UnaryClientInterceptor(),
+		metrics.StreamClientInterceptor(),
+		// Rehydrate APIErrors returned by the product service, so they keep their remote stack
+		apierror.UnaryClientInterceptor(),
+		apierror.StreamClientInterceptor(),
+		requestlog.UnaryClientInterceptor(),
+		requestlog.StreamClientInterceptor(),
+		// Innermost: every retried or hedged attempt carries the request ID and gets its own span,
+		// the metrics and the rehydrated error are the ones of the whole call
+		resilience.UnaryClientInterceptor("products", clientOpts),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to product service: %v", err)
 	}
 	client := productpb.NewProductServiceClient(conn)
-	return &tradeRepositoryImpl{productServiceClient: client}, nil
+	cache := newProductCache(cacheOpts)
+	if cache.enabled() && redisClient != nil {
+		cache.watch(ctx, redisClient)
+	}
+	return &tradeRepositoryImpl{
+		productServiceClient: client,
+		productServiceHealth: healthcheck.GRPCCheck(conn, productpb.ProductService_ServiceDesc.ServiceName),
+		cache:                cache,
+		redisClient:          redisClient,
+	}, nil
 }
 
-// CheckProductExists checks if the product with the given ID exists and returns its price.
+// CheckProductExists checks if the product with the given ID exists and returns its price,
+// ErrVariantRequired if it has variants. The lookups are cached (unknown IDs included),
+// concurrent misses of a product make one call.
 func (r *tradeRepositoryImpl) CheckProductExists(ctx context.Context, productID string) (bool, float32, error) {
-	// Get all products from the product service.
-	products, err := r.productServiceClient.GetProducts(ctx, &productpb.GetProductsRequest{})
+	if !r.cache.enabled() {
+		product, err := r.fetchProduct(ctx, productID)
+		return productLookup(product, err)
+	}
+	if product, ok := r.cache.get(productID); ok {
+		return productLookup(product, nil)
+	}
+
+	result, err, _ := r.cache.misses.Do(productID, func() (interface{}, error) {
+		generation := r.cache.currentGeneration()
+		// shared by the collapsed callers, so not cancelled with the first one (the client deadline applies)
+		product, err := r.fetchProduct(context.WithoutCancel(ctx), productID)
+		if err == nil {
+			r.cache.setIfCurrent(generation, product)
+		}
+		return product, err
+	})
+	return productLookup(result.(cachedProduct), err)
+}
+
+// productLookup returns the result of CheckProductExists for product
+func productLookup(product cachedProduct, err error) (bool, float32, error) {
+	if err == nil && product.hasVariants {
+		err = fmt.Errorf("%w: %s", ErrVariantRequired, product.id)
+	}
+	return product.exists, product.price, err
+}
+
+// fetchProduct looks productID up with the product service, an unknown product does not
+// exist (exists is false) and is negatively cached
+func (r *tradeRepositoryImpl) fetchProduct(ctx context.Context, productID string) (cachedProduct, error) {
+	unknown := cachedProduct{id: productID}
+	product, err := r.productServiceClient.GetProduct(ctx, &productpb.GetProductRequest{Id: productID})
+	if status.Code(err) == codes.NotFound {
+		return unknown, nil
+	}
 	if err != nil {
-		return false, 0, fmt.Errorf("failed to retrieve products: %v", err)
-	}
-
-	// Search for the product by ID and retrieve its price.
-	for _, product := range products.Products {
-		if product.Id == productID {
-			// Assuming the price is a string, convert it to float32.
-			price, err := strconv.ParseFloat(product.Price, 32)
-			if err != nil {
-				return true, 0, fmt.Errorf("failed to parse product price: %v", err)
-			}
-			return true, float32(price), nil
+		return unknown, apierror.NewAPIErrorWithContext(ctx, err, 0, "", "failed to retrieve product %q", productID)
+	}
+	// Assuming the price is a string, convert it to float32.
+	price, err := strconv.ParseFloat(product.Price, 32)
+	if err != nil {
+		return cachedProduct{id: productID, exists: true}, fmt.Errorf("failed to parse product price: %v", err)
+	}
+	return cachedProduct{id: productID, exists: true, price: float32(price), hasVariants: len(product.Variants) > 0}, nil
+}
+
+// ResolveSKU returns the product ID and the price of the variant with sku, or of the product with sku,
+// resolved by the product service. The SKUs found are cached until a change event of their product,
+// the unknown ones are not: the events of the created products do not name their SKUs.
+func (r *tradeRepositoryImpl) ResolveSKU(ctx context.Context, sku string) (string, float32, error) {
+	if !r.cache.enabled() {
+		variant, err := r.fetchVariant(ctx, sku)
+		return variant.productID, variant.price, err
+	}
+	key := skuCacheKey(sku)
+	if variant, ok := r.cache.get(key); ok {
+		return variant.productID, variant.price, nil
+	}
+
+	result, err, _ := r.cache.misses.Do(key, func() (interface{}, error) {
+		generation := r.cache.currentGeneration()
+		// shared by the collapsed callers, so not cancelled with the first one (the client deadline applies)
+		variant, err := r.fetchVariant(context.WithoutCancel(ctx), sku)
+		if err == nil && variant.exists {
+			r.cache.setIfCurrent(generation, variant)
 		}
+		return variant, err
+	})
+	variant := result.(cachedProduct)
+	return variant.productID, variant.price, err
+}
+
+// fetchVariant resolves sku with the product service
+func (r *tradeRepositoryImpl) fetchVariant(ctx context.Context, sku string) (cachedProduct, error) {
+	unknown := cachedProduct{id: skuCacheKey(sku)}
+	variant, err := r.productServiceClient.GetVariant(ctx, &productpb.GetVariantRequest{Sku: sku})
+	if status.Code(err) == codes.NotFound {
+		return unknown, nil
+	}
+	if err != nil {
+		return unknown, apierror.NewAPIErrorWithContext(ctx, err, 0, "", "failed to resolve SKU %q", sku)
 	}
+	price, err := strconv.ParseFloat(variant.Price, 32)
+	if err != nil {
+		return unknown, fmt.Errorf("failed to parse variant price: %v", err)
+	}
+	return cachedProduct{id: unknown.id, productID: variant.ProductId, exists: true, price: float32(price)}, nil
+}
 
-	// Product not found.
-	return false, 0, nil
+// CheckProductService checks the product service is SERVING, trade cannot create sales without it.
+func (r *tradeRepositoryImpl) CheckProductService(ctx context.Context) error {
+	return r.productServiceHealth(ctx)
 }
@@ -2,12 +2,19 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ramseyjiang/go-micros/sales/trade/internal/repos"
 	tradepb "github.com/ramseyjiang/go-micros/sales/trade/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
@@ -23,20 +30,19 @@ func NewSalesService(repo repos.TradeRepository) *SalesService {
 
 func (s *SalesService) CreateSale(ctx context.Context, req *tradepb.CreateSaleRequest) (*tradepb.CreateSaleResponse, error) {
 	var totalSalePrice float32
+	lines := make([]repos.SaleLine, 0, len(req.LineItems))
 
-	for _, item := range req.LineItems {
-		// Check if product exists and get its price
-		exists, price, err := s.repo.CheckProductExists(ctx, item.ProductId)
+	for i, item := range req.LineItems {
+		// Check the product or variant exists and get its price
+		productID, price, err := s.resolveLineItem(ctx, i, item)
 		if err != nil {
-			return nil, fmt.Errorf("error checking product existence: %v", err)
-		}
-		if !exists {
-			return nil, status.Errorf(codes.NotFound, "product with ID %s does not exist", item.ProductId)
+			return nil, err
 		}
 
 		// Calculate total price for the line item
 		lineTotal := price * float32(item.Quantity)
 		totalSalePrice += lineTotal
+		lines = append(lines, repos.SaleLine{ProductID: productID, Quantity: item.Quantity, UnitPrice: price, SKU: item.Sku})
 	}
 
 	// Apply flat discount if applicable
@@ -49,9 +55,169 @@ func (s *SalesService) CreateSale(ctx context.Context, req *tradepb.CreateSaleRe
 		totalSalePrice = 0
 	}
 
+	// Store the sale, its SaleCreated event is published with it
+	sale := &repos.Sale{Lines: lines, DiscountAmount: req.DiscountAmount, TotalPrice: totalSalePrice}
+	if err := s.repo.CreateSale(ctx, sale); err != nil {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 0, "", "error storing the sale")
+	}
+
+	metrics.SalesCreated.Inc()
+	metrics.SalesRevenue.Add(float64(totalSalePrice))
+
 	// Create the sale response with the total sale price and line items
 	return &tradepb.CreateSaleResponse{
+		SaleId:     sale.ID,
 		TotalPrice: &wrapperspb.FloatValue{Value: totalSalePrice},
-		LineItems:  req.LineItems,
+		LineItems:  lineItemsToProto(lines),
 	}, nil
 }
+
+// resolveLineItem returns the product ID and the unit price of the line item at index, referencing
+// a product by ID, or a variant (or product) by SKU, resolved through the product service
+func (s *SalesService) resolveLineItem(ctx context.Context, index int, item *tradepb.LineItem) (string, float32, error) {
+	if item.Sku == "" {
+		exists, price, err := s.repo.CheckProductExists(ctx, item.ProductId)
+		if errors.Is(err, repos.ErrVariantRequired) {
+			return "", 0, apierror.NewAPIErrorWithContext(ctx, err, 400, fmt.Sprintf("line_items[%d].sku", index), "product %s has variants, a variant SKU is required", item.ProductId)
+		}
+		if err != nil {
+			return "", 0, apierror.NewAPIErrorWithContext(ctx, err, 0, "", "error checking product existence")
+		}
+		if !exists {
+			return "", 0, status.Errorf(codes.NotFound, "product with ID %s does not exist", item.ProductId)
+		}
+		return item.ProductId, price, nil
+	}
+
+	productID, price, err := s.repo.ResolveSKU(ctx, item.Sku)
+	if err != nil {
+		return "", 0, apierror.NewAPIErrorWithContext(ctx, err, 0, "", "error resolving SKU %q", item.Sku)
+	}
+	if productID == "" {
+		return "", 0, status.Errorf(codes.NotFound, "no product nor variant with SKU %s", item.Sku)
+	}
+	if item.ProductId != "" && item.ProductId != productID {
+		return "", 0, apierror.NewAPIErrorWithContext(ctx, nil, 400, fmt.Sprintf("line_items[%d].sku", index), "SKU %q is not a SKU of product %s", item.Sku, item.ProductId)
+	}
+	return productID, price, nil
+}
+
+// RefundSale refunds a sale, fully when req.Amount is 0, its SaleRefunded event is published with the refund
+func (s *SalesService) RefundSale(ctx context.Context, req *tradepb.RefundSaleRequest) (*tradepb.RefundSaleResponse, error) {
+	if req.SaleId == "" {
+		return nil, apierror.NewAPIErrorWithContext(ctx, nil, 400, "sale_id", "a sale ID is required")
+	}
+	if req.Amount < 0 {
+		return nil, apierror.NewAPIErrorWithContext(ctx, nil, 400, "amount", "the refunded amount cannot be negative")
+	}
+
+	refunded, totalRefunded, err := s.repo.RefundSale(ctx, req.SaleId, req.Amount, req.Reason)
+	if errors.Is(err, repos.ErrSaleNotFound) {
+		return nil, status.Errorf(codes.NotFound, "sale with ID %s does not exist", req.SaleId)
+	}
+	if errors.Is(err, repos.ErrRefundExceedsTotal) {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 400, "amount", "the refund exceeds what is left of the sale total")
+	}
+	if err != nil {
+		return nil, apierror.NewAPIErrorWithContext(ctx, err, 0, "", "error refunding the sale")
+	}
+
+	return &tradepb.RefundSaleResponse{SaleId: req.SaleId, RefundedAmount: refunded, TotalRefunded: totalRefunded}, nil
+}
+
+const (
+	// watchBatchSize is the maximum number of sales read at once by WatchSales
+	watchBatchSize = 100
+	// watchBlock is how long WatchSales waits for a sale before checking the stream is still open
+	watchBlock = 5 * time.Second
+)
+
+// WatchSales streams the created sales matching the request filters. The stream starts after
+// req.Cursor, the cursor of a previously streamed event, or with the sales created from now on.
+func (s *SalesService) WatchSales(req *tradepb.WatchSalesRequest, stream tradepb.SalesService_WatchSalesServer) error {
+	ctx := stream.Context()
+
+	cursor := req.Cursor
+	if cursor == "" {
+		latest, err := s.repo.LatestSaleCursor(ctx)
+		if err != nil {
+			return apierror.NewAPIErrorWithContext(ctx, err, 0, "", "error reading the sales")
+		}
+		cursor = latest
+	} else if !validCursor(cursor) {
+		return apierror.NewAPIErrorWithContext(ctx, nil, 400, "cursor", "invalid cursor %q", cursor)
+	}
+
+	productIDs := make(map[string]bool, len(req.ProductIds))
+	for _, productID := range req.ProductIds {
+		productIDs[productID] = true
+	}
+
+	for {
+		sales, next, err := s.repo.ReadSales(ctx, cursor, watchBatchSize, watchBlock)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// the client has gone
+			return ctxErr
+		}
+		if err != nil {
+			return apierror.NewAPIErrorWithContext(ctx, err, 0, "", "error reading the sales")
+		}
+		for _, event := range sales {
+			if !saleMatches(event.Sale, productIDs, req.MinTotal) {
+				continue
+			}
+			if err := stream.Send(&tradepb.SaleEvent{Cursor: event.Cursor, Sale: saleToProto(event.Sale)}); err != nil {
+				return err
+			}
+		}
+		cursor = next
+	}
+}
+
+// validCursor reports whether cursor is a stream entry ID, <milliseconds>-<sequence>
+func validCursor(cursor string) bool {
+	milliseconds, sequence, ok := strings.Cut(cursor, "-")
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseUint(milliseconds, 10, 64); err != nil {
+		return false
+	}
+	_, err := strconv.ParseUint(sequence, 10, 64)
+	return err == nil
+}
+
+// saleMatches reports whether sale totals at least minTotal and includes one of productIDs (any product when empty)
+func saleMatches(sale *repos.Sale, productIDs map[string]bool, minTotal float32) bool {
+	if sale.TotalPrice < minTotal {
+		return false
+	}
+	if len(productIDs) == 0 {
+		return true
+	}
+	for _, line := range sale.Lines {
+		if productIDs[line.ProductID] {
+			return true
+		}
+	}
+	return false
+}
+
+func saleToProto(sale *repos.Sale) *tradepb.Sale {
+	return &tradepb.Sale{
+		SaleId:         sale.ID,
+		LineItems:      lineItemsToProto(sale.Lines),
+		DiscountAmount: sale.DiscountAmount,
+		TotalPrice:     sale.TotalPrice,
+		CreatedAt:      timestamppb.New(sale.CreatedAt),
+	}
+}
+
+// lineItemsToProto returns the line items of the sold lines, with their resolved product IDs
+func lineItemsToProto(lines []repos.SaleLine) []*tradepb.LineItem {
+	lineItems := make([]*tradepb.LineItem, 0, len(lines))
+	for _, line := range lines {
+		lineItems = append(lineItems, &tradepb.LineItem{ProductId: line.ProductID, Quantity: line.Quantity, Sku: line.SKU})
+	}
+	return lineItems
+}
@@ -2,9 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ramseyjiang/go-micros/sales/trade/internal/repos"
 	tradepb "github.com/ramseyjiang/go-micros/sales/trade/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -14,13 +22,89 @@ type MockTradeRepository struct {
 	// Add fields to simulate different responses
 	productExists bool
 	price         float32
-	err           error
+	// hasVariants makes CheckProductExists return ErrVariantRequired
+	hasVariants bool
+	// variants are the resolved SKUs
+	variants map[string]repos.SaleLine
+	err      error
+	saleErr  error
+	sales    []*repos.Sale
+	// the batches returned by ReadSales, cancel is called once they are all read
+	batches [][]repos.SaleEvent
+	cursors []string
+	cancel  context.CancelFunc
+	readErr error
+	// the refund returned by RefundSale
+	refunded, totalRefunded float32
+	refundErr               error
 }
 
 func (m *MockTradeRepository) CheckProductExists(ctx context.Context, productID string) (bool, float32, error) {
+	if m.hasVariants {
+		return m.productExists, m.price, repos.ErrVariantRequired
+	}
 	return m.productExists, m.price, m.err
 }
 
+func (m *MockTradeRepository) ResolveSKU(ctx context.Context, sku string) (string, float32, error) {
+	variant := m.variants[sku]
+	return variant.ProductID, variant.UnitPrice, m.err
+}
+
+func (m *MockTradeRepository) CheckProductService(ctx context.Context) error {
+	return m.err
+}
+
+func (m *MockTradeRepository) CreateSale(ctx context.Context, sale *repos.Sale) error {
+	if m.saleErr != nil {
+		return m.saleErr
+	}
+	m.sales = append(m.sales, sale)
+	sale.ID = strconv.Itoa(len(m.sales))
+	return nil
+}
+
+func (m *MockTradeRepository) RefundSale(ctx context.Context, saleID string, amount float32, reason string) (float32, float32, error) {
+	return m.refunded, m.totalRefunded, m.refundErr
+}
+
+func (m *MockTradeRepository) LatestSaleCursor(ctx context.Context) (string, error) {
+	return "5-0", m.readErr
+}
+
+func (m *MockTradeRepository) ReadSales(ctx context.Context, cursor string, count int64, block time.Duration) ([]repos.SaleEvent, string, error) {
+	m.cursors = append(m.cursors, cursor)
+	if m.readErr != nil {
+		return nil, cursor, m.readErr
+	}
+	if len(m.batches) == 0 {
+		m.cancel()
+		return nil, cursor, ctx.Err()
+	}
+	batch := m.batches[0]
+	m.batches = m.batches[1:]
+	if len(batch) > 0 {
+		cursor = batch[len(batch)-1].Cursor
+	}
+	return batch, cursor, nil
+}
+
+// watchSalesStream collects the streamed events
+type watchSalesStream struct {
+	tradepb.SalesService_WatchSalesServer
+	ctx  context.Context
+	sent []*tradepb.SaleEvent
+}
+
+func (s *watchSalesStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchSalesStream) Send(event *tradepb.SaleEvent) error {
+	s.sent = append(s.sent, event)
+	return nil
+}
+
 func TestSalesService_CreateSale(t *testing.T) {
 	// Define test cases
 	tests := []struct {
@@ -30,6 +114,8 @@ func TestSalesService_CreateSale(t *testing.T) {
 		mockRepo       *MockTradeRepository
 		wantTotalPrice float32
 		wantErrCode    codes.Code
+		// wantLines are the stored lines, when set
+		wantLines []repos.SaleLine
 	}{
 		{
 			name: "Successful Sale",
@@ -58,6 +144,69 @@ func TestSalesService_CreateSale(t *testing.T) {
 			wantTotalPrice: 0,
 			wantErrCode:    codes.NotFound,
 		},
+		{
+			name: "Sale Not Stored",
+			lineItems: []*tradepb.LineItem{
+				{ProductId: "1", Quantity: 1},
+			},
+			mockRepo: &MockTradeRepository{
+				productExists: true,
+				price:         10,
+				saleErr:       errors.New("connection refused"),
+			},
+			wantErrCode: codes.Internal,
+		},
+		{
+			name: "Variant Sale",
+			lineItems: []*tradepb.LineItem{
+				{Sku: "TEE-M", Quantity: 2},
+				{ProductId: "7", Sku: "TEE-L", Quantity: 1},
+				{ProductId: "1", Quantity: 1},
+			},
+			mockRepo: &MockTradeRepository{
+				productExists: true,
+				price:         10,
+				variants: map[string]repos.SaleLine{
+					"TEE-M": {ProductID: "7", UnitPrice: 30},
+					"TEE-L": {ProductID: "7", UnitPrice: 32},
+				},
+			},
+			wantTotalPrice: 102, // 2*30 + 1*32 + 1*10
+			wantErrCode:    codes.OK,
+			wantLines: []repos.SaleLine{
+				{ProductID: "7", Quantity: 2, UnitPrice: 30, SKU: "TEE-M"},
+				{ProductID: "7", Quantity: 1, UnitPrice: 32, SKU: "TEE-L"},
+				{ProductID: "1", Quantity: 1, UnitPrice: 10},
+			},
+		},
+		{
+			name: "SKU Not Exists",
+			lineItems: []*tradepb.LineItem{
+				{Sku: "TEE-XL", Quantity: 1},
+			},
+			mockRepo:    &MockTradeRepository{productExists: true, price: 10},
+			wantErrCode: codes.NotFound,
+		},
+		{
+			name: "SKU Of Another Product",
+			lineItems: []*tradepb.LineItem{
+				{ProductId: "1", Sku: "TEE-M", Quantity: 1},
+			},
+			mockRepo: &MockTradeRepository{
+				productExists: true,
+				price:         10,
+				variants:      map[string]repos.SaleLine{"TEE-M": {ProductID: "7", UnitPrice: 30}},
+			},
+			wantErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "Product With Variants Without SKU",
+			lineItems: []*tradepb.LineItem{
+				{ProductId: "7", Quantity: 1},
+			},
+			mockRepo:    &MockTradeRepository{productExists: true, price: 30, hasVariants: true},
+			wantErrCode: codes.InvalidArgument,
+		},
 		// Add more test cases as needed...
 	}
 
@@ -75,9 +224,166 @@ func TestSalesService_CreateSale(t *testing.T) {
 				t.Errorf("CreateSale() error = %v, wantErr %v", err, tt.wantErrCode)
 				return
 			}
+			if err == nil && tt.wantErrCode != codes.OK {
+				t.Errorf("CreateSale() no error, wantErr %v", tt.wantErrCode)
+				return
+			}
 			if err == nil && resp.TotalPrice.Value != tt.wantTotalPrice {
 				t.Errorf("CreateSale() got total price = %v, want %v", resp.TotalPrice.Value, tt.wantTotalPrice)
 			}
+			if err == nil && (len(tt.mockRepo.sales) != 1 || resp.SaleId != tt.mockRepo.sales[0].ID || tt.mockRepo.sales[0].TotalPrice != tt.wantTotalPrice) {
+				t.Errorf("CreateSale() sale ID %q, stored sales %v", resp.SaleId, tt.mockRepo.sales)
+			}
+			if err == nil && tt.wantLines != nil {
+				if !reflect.DeepEqual(tt.mockRepo.sales[0].Lines, tt.wantLines) {
+					t.Errorf("CreateSale() stored lines %v, want %v", tt.mockRepo.sales[0].Lines, tt.wantLines)
+				}
+				for i, item := range resp.LineItems {
+					if item.ProductId != tt.wantLines[i].ProductID || item.Sku != tt.wantLines[i].SKU {
+						t.Errorf("CreateSale() line item %v, want the product and SKU of %v", item, tt.wantLines[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSalesService_RefundSale(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *tradepb.RefundSaleRequest
+		mockRepo    *MockTradeRepository
+		wantErrCode codes.Code
+	}{
+		{
+			name:        "Refunded",
+			req:         &tradepb.RefundSaleRequest{SaleId: "1", Amount: 15, Reason: "damaged"},
+			mockRepo:    &MockTradeRepository{refunded: 15, totalRefunded: 25},
+			wantErrCode: codes.OK,
+		},
+		{
+			name:        "Missing Sale ID",
+			req:         &tradepb.RefundSaleRequest{Amount: 15},
+			mockRepo:    &MockTradeRepository{},
+			wantErrCode: codes.InvalidArgument,
+		},
+		{
+			name:        "Negative Amount",
+			req:         &tradepb.RefundSaleRequest{SaleId: "1", Amount: -1},
+			mockRepo:    &MockTradeRepository{},
+			wantErrCode: codes.InvalidArgument,
+		},
+		{
+			name:        "Sale Not Exists",
+			req:         &tradepb.RefundSaleRequest{SaleId: "42"},
+			mockRepo:    &MockTradeRepository{refundErr: repos.ErrSaleNotFound},
+			wantErrCode: codes.NotFound,
+		},
+		{
+			name:        "Exceeds Total",
+			req:         &tradepb.RefundSaleRequest{SaleId: "1", Amount: 100},
+			mockRepo:    &MockTradeRepository{refundErr: repos.ErrRefundExceedsTotal},
+			wantErrCode: codes.InvalidArgument,
+		},
+		{
+			name:        "Refund Not Stored",
+			req:         &tradepb.RefundSaleRequest{SaleId: "1"},
+			mockRepo:    &MockTradeRepository{refundErr: errors.New("connection refused")},
+			wantErrCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := NewSalesService(tt.mockRepo).RefundSale(context.Background(), tt.req)
+			if status.Code(err) != tt.wantErrCode {
+				t.Fatalf("RefundSale() error = %v, wantErr %v", err, tt.wantErrCode)
+			}
+			if err == nil && (resp.SaleId != tt.req.SaleId || resp.RefundedAmount != tt.mockRepo.refunded || resp.TotalRefunded != tt.mockRepo.totalRefunded) {
+				t.Errorf("RefundSale() = %v", resp)
+			}
+		})
+	}
+}
+
+func TestSalesService_WatchSales(t *testing.T) {
+	sale := func(cursor, id string, total float32, productIDs ...string) repos.SaleEvent {
+		event := repos.SaleEvent{Cursor: cursor, Sale: &repos.Sale{ID: id, TotalPrice: total}}
+		for _, productID := range productIDs {
+			event.Sale.Lines = append(event.Sale.Lines, repos.SaleLine{ProductID: productID, Quantity: 1, UnitPrice: total})
+		}
+		return event
+	}
+	batches := func() [][]repos.SaleEvent {
+		return [][]repos.SaleEvent{
+			{sale("6-0", "1", 10, "1"), sale("7-0", "2", 50, "2")},
+			{},
+			{sale("9-0", "3", 100, "1", "3")},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		req         *tradepb.WatchSalesRequest
+		readErr     error
+		wantSaleIDs []string
+		wantCursors []string
+		wantErrCode codes.Code
+	}{
+		{
+			name:        "From Now On",
+			req:         &tradepb.WatchSalesRequest{},
+			wantSaleIDs: []string{"1", "2", "3"},
+			wantCursors: []string{"5-0", "7-0", "7-0", "9-0"},
+			wantErrCode: codes.Canceled,
+		},
+		{
+			name:        "Resumed And Filtered",
+			req:         &tradepb.WatchSalesRequest{Cursor: "1-0", ProductIds: []string{"1"}, MinTotal: 20},
+			wantSaleIDs: []string{"3"},
+			wantCursors: []string{"1-0", "7-0", "7-0", "9-0"},
+			wantErrCode: codes.Canceled,
+		},
+		{
+			name:        "Invalid Cursor",
+			req:         &tradepb.WatchSalesRequest{Cursor: "last"},
+			wantErrCode: codes.InvalidArgument,
+		},
+		{
+			name:        "Read Error",
+			req:         &tradepb.WatchSalesRequest{Cursor: "1-0"},
+			readErr:     errors.New("connection refused"),
+			wantCursors: []string{"1-0"},
+			wantErrCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			repo := &MockTradeRepository{batches: batches(), cancel: cancel, readErr: tt.readErr}
+			stream := &watchSalesStream{ctx: ctx}
+
+			// the error as returned to the client
+			err := apierror.StreamServerInt()(NewSalesService(repo), stream, &grpc.StreamServerInfo{FullMethod: tradepb.SalesService_WatchSales_FullMethodName, IsServerStream: true},
+				func(srv interface{}, _ grpc.ServerStream) error {
+					return srv.(tradepb.SalesServiceServer).WatchSales(tt.req, stream)
+				})
+			if status.Code(err) != tt.wantErrCode {
+				t.Errorf("WatchSales() error = %v, wantErr %v", err, tt.wantErrCode)
+			}
+
+			var saleIDs []string
+			for _, event := range stream.sent {
+				saleIDs = append(saleIDs, event.Sale.SaleId)
+			}
+			if strings.Join(saleIDs, ",") != strings.Join(tt.wantSaleIDs, ",") {
+				t.Errorf("WatchSales() sent sales %v, want %v", saleIDs, tt.wantSaleIDs)
+			}
+			if strings.Join(repo.cursors, ",") != strings.Join(tt.wantCursors, ",") {
+				t.Errorf("WatchSales() read from cursors %v, want %v", repo.cursors, tt.wantCursors)
+			}
 		})
 	}
 }
@@ -1,16 +1,28 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"log"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/sales/trade/events"
 	"github.com/ramseyjiang/go-micros/sales/trade/internal/repos"
 	"github.com/ramseyjiang/go-micros/sales/trade/internal/services"
 	tradepb "github.com/ramseyjiang/go-micros/sales/trade/proto"
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+	"github.com/ramseyjiang/go-micros/shared/healthcheck"
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"github.com/ramseyjiang/go-micros/shared/outbox"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/leveladmin"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/requestlog"
+	"github.com/ramseyjiang/go-micros/shared/tracing"
+	"github.com/ramseyjiang/go-micros/shared/viperconf"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
@@ -20,10 +32,27 @@ const (
 	defaultTradeServicePort   = ":9012"
 	productServiceEnvVar      = "PRODUCT_SERVICE_ADDR"
 	tradeServiceEnvVar        = "TRADE_SERVICE_ADDR"
+	redisEnvVar               = "REDIS_ADDR"
+	defaultRedisPort          = "localhost:6379"
+	defaultMetricsAddr        = "localhost:9102"
 )
 
 func main() {
-	flag.Parse()
+	// The metrics are served on metrics-addr while export-metrics is set, each service has its own default
+	viper.SetDefault("metrics-addr", defaultMetricsAddr)
+
+	// Read the config file, the flags and the environment variables (tracing-enabled or TRACING_ENABLED...)
+	if err := viperconf.SetupViperV2(nil, "trade", setupProductCacheFlags, nil); err != nil {
+		log.Fatalf("Failed to load the config: %v", err)
+	}
+
+	// Set up tracing before the gRPC server and clients, their instrumentation uses the tracer provider
+	shutdownTracing, err := tracing.Setup(context.Background(), viperconf.TracingOptions(viper.GetViper(), "trade"))
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	// Export the spans still buffered once the server has stopped
+	defer shutdownTracing(context.Background())
 
 	productServicePort := os.Getenv(productServiceEnvVar)
 	if productServicePort == "" {
@@ -35,12 +64,26 @@ func main() {
 		tradeServicePort = defaultTradeServicePort
 	}
 
+	// The sales are stored in Redis, where the product change events invalidating the product cache are received
+	redisAddr := os.Getenv(redisEnvVar)
+	if redisAddr == "" {
+		redisAddr = defaultRedisPort
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: redisAddr})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Set up a connection to the ProductService
-	tradeRepo, err := repos.NewTradeRepository(productServicePort)
+	// Deadline, retries, circuit breaker and hedging of the calls to the products service (grpc-client-* config),
+	// the products are cached (product-cache-* config)
+	tradeRepo, err := repos.NewTradeRepository(ctx, productServicePort, viperconf.GRPCClientOptions(viper.GetViper()), productCacheOptions(viper.GetViper()), redisClient)
 	if err != nil {
 		log.Fatalf("Failed to initialize trade repository: %v", err)
 	}
 
+	// Relay the domain events queued with the sales to their stream
+	go outbox.NewRelay(redisClient, outbox.RelayOptions{Outbox: events.Outbox, Stream: events.Stream}).Run(ctx)
+
 	// Create a new SalesService instance
 	salesService := services.NewSalesService(tradeRepo)
 
@@ -51,11 +94,40 @@ func main() {
 	}
 
 	// Create a new gRPC server instance
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		// Continue the trace of the caller (traceparent) with a span per RPC
+		tracing.ServerOption(),
+		// Count and time every call, first so the recorded code is the one sent to the caller
+		metrics.UnaryServerInterceptor(),
+		metrics.StreamServerInterceptor(),
+		// Share one request ID across every log line written for a request, the APIError ones included
+		requestlog.UnaryServerInterceptor(),
+		requestlog.StreamServerInterceptor(),
+		// Convert every returned error into an APIError, so callers receive the full error stack.
+		// Chained after the metrics: grpc.UnaryInterceptor (apierror.UnaryInterceptor) would run before them.
+		grpc.ChainUnaryInterceptor(apierror.UnaryServerInt()),
+		grpc.ChainStreamInterceptor(apierror.StreamServerInt()),
+		// Turn panicking handlers into 500 APIErrors instead of crashing the process
+		apierror.RecoveryUnaryInterceptor(),
+		apierror.RecoveryStreamInterceptor(),
+	)
 
 	// Register the service with the gRPC server
 	tradepb.RegisterSalesServiceServer(grpcServer, salesService)
 
+	// Get and set the log levels (per package, with an optional TTL) without restarting
+	leveladmin.RegisterLogLevelServiceServer(grpcServer, leveladmin.NewServer(nil))
+
+	// Report the health (grpc.health.v1) of the server and its services from the product service health
+	// and the Redis connection, the sales and their events are stored in Redis
+	healthChecker := healthcheck.NewChecker(healthcheck.Options{})
+	healthChecker.AddCheck("products", tradeRepo.CheckProductService)
+	healthChecker.AddCheck("redis", func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	})
+	healthChecker.Register(grpcServer)
+	healthChecker.Start()
+
 	// Register reflection service on gRPC server.
 	// It is also used for grpcurl sending in the terminal.
 	reflection.Register(grpcServer)
@@ -66,7 +138,11 @@ func main() {
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		<-c
 		log.Println("Shutting down gRPC server...")
+		// Stop receiving new requests before draining the current ones
+		healthChecker.Shutdown()
 		grpcServer.GracefulStop()
+		cancel()
+		redisClient.Close()
 	}()
 	log.Println("Starting server on port ", tradeServicePort)
 
@@ -75,3 +151,41 @@ func main() {
 		log.Fatalf("Failed to serve: %v", err)
 	}
 }
+
+// setupProductCacheFlags defines the flags read by productCacheOptions
+func setupProductCacheFlags() {
+	defaults := repos.DefaultCacheOptions()
+
+	if viper.Get("product-cache-ttl") == nil {
+		viper.SetDefault("product-cache-ttl", defaults.TTL)
+	}
+	if pflag.Lookup("product-cache-ttl") == nil {
+		pflag.Duration("product-cache-ttl", viper.GetDuration("product-cache-ttl"), "TTL of a cached product, the change events usually invalidate it earlier (0 disables the cache)")
+		viper.BindPFlag("product-cache-ttl", pflag.Lookup("product-cache-ttl"))
+	}
+
+	if viper.Get("product-cache-negative-ttl") == nil {
+		viper.SetDefault("product-cache-negative-ttl", defaults.NegativeTTL)
+	}
+	if pflag.Lookup("product-cache-negative-ttl") == nil {
+		pflag.Duration("product-cache-negative-ttl", viper.GetDuration("product-cache-negative-ttl"), "TTL of a product ID found unknown")
+		viper.BindPFlag("product-cache-negative-ttl", pflag.Lookup("product-cache-negative-ttl"))
+	}
+
+	if viper.Get("product-cache-max-entries") == nil {
+		viper.SetDefault("product-cache-max-entries", defaults.MaxEntries)
+	}
+	if pflag.Lookup("product-cache-max-entries") == nil {
+		pflag.Int("product-cache-max-entries", viper.GetInt("product-cache-max-entries"), "Maximum cached products, the least recently used ones are evicted first")
+		viper.BindPFlag("product-cache-max-entries", pflag.Lookup("product-cache-max-entries"))
+	}
+}
+
+// productCacheOptions returns the product cache options of the config (product-cache-ttl or PRODUCT_CACHE_TTL...)
+func productCacheOptions(viperCfg *viper.Viper) repos.CacheOptions {
+	return repos.CacheOptions{
+		TTL:         viperCfg.GetDuration("product-cache-ttl"),
+		NegativeTTL: viperCfg.GetDuration("product-cache-negative-ttl"),
+		MaxEntries:  viperCfg.GetInt("product-cache-max-entries"),
+	}
+}
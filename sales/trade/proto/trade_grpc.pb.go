@@ -20,6 +20,8 @@ const _ = grpc.SupportPackageIsVersion7
 
 const (
 	SalesService_CreateSale_FullMethodName = "/trade.SalesService/CreateSale"
+	SalesService_WatchSales_FullMethodName = "/trade.SalesService/WatchSales"
+	SalesService_RefundSale_FullMethodName = "/trade.SalesService/RefundSale"
 )
 
 // SalesServiceClient is the client API for SalesService service.
@@ -27,6 +29,10 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type SalesServiceClient interface {
 	CreateSale(ctx context.Context, in *CreateSaleRequest, opts ...grpc.CallOption) (*CreateSaleResponse, error)
+	// WatchSales streams the created sales matching the filters, from the cursor of a previous event
+	WatchSales(ctx context.Context, in *WatchSalesRequest, opts ...grpc.CallOption) (SalesService_WatchSalesClient, error)
+	// RefundSale refunds a sale, fully or partially, up to its total
+	RefundSale(ctx context.Context, in *RefundSaleRequest, opts ...grpc.CallOption) (*RefundSaleResponse, error)
 }
 
 type salesServiceClient struct {
@@ -46,11 +52,56 @@ func (c *salesServiceClient) CreateSale(ctx context.Context, in *CreateSaleReque
 	return out, nil
 }
 
+func (c *salesServiceClient) WatchSales(ctx context.Context, in *WatchSalesRequest, opts ...grpc.CallOption) (SalesService_WatchSalesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SalesService_ServiceDesc.Streams[0], SalesService_WatchSales_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &salesServiceWatchSalesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SalesService_WatchSalesClient interface {
+	Recv() (*SaleEvent, error)
+	grpc.ClientStream
+}
+
+type salesServiceWatchSalesClient struct {
+	grpc.ClientStream
+}
+
+func (x *salesServiceWatchSalesClient) Recv() (*SaleEvent, error) {
+	m := new(SaleEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *salesServiceClient) RefundSale(ctx context.Context, in *RefundSaleRequest, opts ...grpc.CallOption) (*RefundSaleResponse, error) {
+	out := new(RefundSaleResponse)
+	err := c.cc.Invoke(ctx, SalesService_RefundSale_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SalesServiceServer is the server API for SalesService service.
 // All implementations must embed UnimplementedSalesServiceServer
 // for forward compatibility
 type SalesServiceServer interface {
 	CreateSale(context.Context, *CreateSaleRequest) (*CreateSaleResponse, error)
+	// WatchSales streams the created sales matching the filters, from the cursor of a previous event
+	WatchSales(*WatchSalesRequest, SalesService_WatchSalesServer) error
+	// RefundSale refunds a sale, fully or partially, up to its total
+	RefundSale(context.Context, *RefundSaleRequest) (*RefundSaleResponse, error)
 	mustEmbedUnimplementedSalesServiceServer()
 }
 
@@ -61,6 +112,12 @@ type UnimplementedSalesServiceServer struct {
 func (UnimplementedSalesServiceServer) CreateSale(context.Context, *CreateSaleRequest) (*CreateSaleResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateSale not implemented")
 }
+func (UnimplementedSalesServiceServer) WatchSales(*WatchSalesRequest, SalesService_WatchSalesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchSales not implemented")
+}
+func (UnimplementedSalesServiceServer) RefundSale(context.Context, *RefundSaleRequest) (*RefundSaleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefundSale not implemented")
+}
 func (UnimplementedSalesServiceServer) mustEmbedUnimplementedSalesServiceServer() {}
 
 // UnsafeSalesServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -92,6 +149,45 @@ func _SalesService_CreateSale_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SalesService_WatchSales_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchSalesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SalesServiceServer).WatchSales(m, &salesServiceWatchSalesServer{stream})
+}
+
+type SalesService_WatchSalesServer interface {
+	Send(*SaleEvent) error
+	grpc.ServerStream
+}
+
+type salesServiceWatchSalesServer struct {
+	grpc.ServerStream
+}
+
+func (x *salesServiceWatchSalesServer) Send(m *SaleEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SalesService_RefundSale_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefundSaleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SalesServiceServer).RefundSale(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SalesService_RefundSale_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SalesServiceServer).RefundSale(ctx, req.(*RefundSaleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SalesService_ServiceDesc is the grpc.ServiceDesc for SalesService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -103,7 +199,17 @@ var SalesService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateSale",
 			Handler:    _SalesService_CreateSale_Handler,
 		},
+		{
+			MethodName: "RefundSale",
+			Handler:    _SalesService_RefundSale_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchSales",
+			Handler:       _SalesService_WatchSales_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/trade.proto",
 }
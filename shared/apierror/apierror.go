@@ -43,6 +43,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+
+	"google.golang.org/grpc/codes"
 )
 
 // APIError is the common error response struct used for all errors
@@ -73,6 +75,24 @@ type APIError struct {
 
 	// Stack is the calling stack for this error
 	Stack *APIError `json:"stack,omitempty"`
+	// Causes contains the branches of a multi-error (errors.Join or fmt.Errorf with multiple %w)
+	Causes []*APIError `json:"causes,omitempty"`
+
+	// RequestID is the request/correlation ID of the request that generated this error
+	RequestID string `json:"request_id,omitempty"`
+	// TraceID is the distributed trace ID of the request that generated this error
+	TraceID string `json:"trace_id,omitempty"`
+	// SpanID is the distributed trace span ID of the request that generated this error
+	SpanID string `json:"span_id,omitempty"`
+	// UserID is the ID of the user making the request
+	UserID string `json:"user_id,omitempty"`
+	// Tenant is the tenant the request was made for
+	Tenant string `json:"tenant,omitempty"`
+
+	// RemoteMethod is the gRPC method that returned this error, for errors rehydrated from a remote call
+	RemoteMethod string `json:"remote_method,omitempty"`
+	// RemotePeer is the address of the service that returned this error, for errors rehydrated from a remote call
+	RemotePeer string `json:"remote_peer,omitempty"`
 
 	// originErr contains the original error
 	originErr error `json:"-"`
@@ -88,6 +108,9 @@ type APIError struct {
 
 	originPCs []uintptr `json:"-"`
 
+	// grpcCode is the gRPC code this error was received with (see GRPCCode)
+	grpcCode codes.Code
+
 	context context.Context `json:"-"`
 	isNil   bool            `json:"-"`
 }
@@ -112,6 +135,12 @@ type echoV4HTTPError interface {
 	Unwrap() error
 }
 
+// multiUnwrapError is implemented by errors.Join and fmt.Errorf with multiple %w verbs
+type multiUnwrapError interface {
+	error
+	Unwrap() []error
+}
+
 var (
 	// NilError is a non-nil pointer used to carry a nil payload error.
 	NilError = APIError{isNil: true}
@@ -131,6 +160,21 @@ var (
 
 // NewAPIErrorCustomCallers returns a new APIError with custom caller depth
 func NewAPIErrorCustomCallers(ctx context.Context, callersNum int, errType ErrorType, err error, errcode int, errfield, errmsg string, fields ...interface{}) *APIError {
+	resp := newAPIErrorNoLog(ctx, callersNum+1, errType, err, errcode, errfield, errmsg, fields...)
+	if resp == nil {
+		return nil
+	}
+
+	// (optional) Logging
+	if Logger != nil {
+		Logger.HandleLogMessageWithContext(ctx, resp)
+	}
+
+	return resp
+}
+
+// newAPIErrorNoLog builds a new APIError with custom caller depth, without logging it
+func newAPIErrorNoLog(ctx context.Context, callersNum int, errType ErrorType, err error, errcode int, errfield, errmsg string, fields ...interface{}) *APIError {
 	resp := APIError{
 		context:            ctx,
 		ErrorCode:          errcode,
@@ -199,13 +243,9 @@ func NewAPIErrorCustomCallers(ctx context.Context, callersNum int, errType Error
 		return nil
 	}
 
+	resp.applyContextData(ctx)
 	resp.ApplyFieldInheritance()
 
-	// (optional) Logging
-	if Logger != nil {
-		Logger.HandleLogMessageWithContext(ctx, &resp)
-	}
-
 	// if ctx != nil {
 	// 	if md, ok := metadata.FromOutgoingContext(ctx); ok {
 	// 		grpc.SendHeader(ctx, md)
@@ -445,18 +485,27 @@ func (ae *APIError) GetContext() context.Context {
 	return ae.context
 }
 
-// Unwrap gets the error at the top of the stack
-func (ae *APIError) Unwrap() error {
+// Unwrap gets the errors below this one in the error tree: the origin error,
+// the stack and any multi-error causes. This allows errors.Is and errors.As
+// to search every branch.
+func (ae *APIError) Unwrap() []error {
 	if ae == nil {
 		return nil
 	}
 
+	var resp []error
 	if ae.originErr != nil {
-		return ae.originErr
+		resp = append(resp, ae.originErr)
 	}
-	// fmt.Printf("APIError.Unwrap: %v\n", ae.GetJSONStringPretty())
-	// fmt.Printf("APIError.Unwrap.Stack: %v\n", ae.Stack)
-	return ae.Stack
+	if ae.Stack != nil {
+		resp = append(resp, ae.Stack)
+	}
+	for _, cause := range ae.Causes {
+		if cause != nil {
+			resp = append(resp, cause)
+		}
+	}
+	return resp
 }
 
 // Cause gets the error at the top of the stack
@@ -0,0 +1,256 @@
+package apierror
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/requestlog"
+	"google.golang.org/grpc/metadata"
+)
+
+// ContextData is the request scoped information attached to an APIError so
+// that error logs can be joined with request logs and traces
+type ContextData struct {
+	RequestID string
+	TraceID   string
+	SpanID    string
+	UserID    string
+	Tenant    string
+}
+
+// IsEmpty checks if no context data has been set
+func (cd ContextData) IsEmpty() bool {
+	return cd == ContextData{}
+}
+
+// merge fills the empty fields of cd from other
+func (cd *ContextData) merge(other ContextData) {
+	if cd.RequestID == "" {
+		cd.RequestID = other.RequestID
+	}
+	if cd.TraceID == "" {
+		cd.TraceID = other.TraceID
+	}
+	if cd.SpanID == "" {
+		cd.SpanID = other.SpanID
+	}
+	if cd.UserID == "" {
+		cd.UserID = other.UserID
+	}
+	if cd.Tenant == "" {
+		cd.Tenant = other.Tenant
+	}
+}
+
+// ContextExtractor pulls request scoped information out of a context.
+// Extractors should only fill fields that are still empty.
+type ContextExtractor interface {
+	ExtractContextData(ctx context.Context, data *ContextData)
+}
+
+// ContextExtractorFunc is a convenience type to avoid having to declare a struct
+// to implement the ContextExtractor interface, it can be used like this:
+//
+//	apierror.RegisterContextExtractor("session", apierror.ContextExtractorFunc(func(ctx context.Context, data *apierror.ContextData) {
+//		if data.UserID == "" {
+//			data.UserID = session.UserIDFromContext(ctx)
+//		}
+//	}))
+type ContextExtractorFunc func(ctx context.Context, data *ContextData)
+
+// ExtractContextData implements the ContextExtractor interface for the above-mentioned ContextExtractorFunc convenience type
+func (f ContextExtractorFunc) ExtractContextData(ctx context.Context, data *ContextData) {
+	f(ctx, data)
+}
+
+type namedContextExtractor struct {
+	name      string
+	extractor ContextExtractor
+}
+
+var (
+	contextExtractorsMutex sync.RWMutex
+	contextExtractors      = []namedContextExtractor{
+		{name: ContextExtractorValues, extractor: ContextExtractorFunc(extractContextValues)},
+		{name: ContextExtractorGRPCMetadata, extractor: ContextExtractorFunc(extractGRPCMetadata)},
+		{name: ContextExtractorSrvlogFields, extractor: ContextExtractorFunc(extractSrvlogFields)},
+	}
+)
+
+const (
+	// ContextExtractorValues is the name of the default extractor reading values set with ContextWithData
+	ContextExtractorValues = "context-values"
+	// ContextExtractorGRPCMetadata is the name of the default extractor reading incoming gRPC metadata
+	ContextExtractorGRPCMetadata = "grpc-metadata"
+	// ContextExtractorSrvlogFields is the name of the default extractor reading the srvlog request scoped fields
+	ContextExtractorSrvlogFields = "srvlog-fields"
+)
+
+// Metadata (and HTTP header) keys read by the default gRPC metadata extractor,
+// shared with the srvlog requestlog interceptors
+const (
+	MetadataRequestID   = requestlog.MetadataRequestID
+	MetadataTraceParent = requestlog.MetadataTraceParent
+	MetadataB3TraceID   = "x-b3-traceid"
+	MetadataB3SpanID    = "x-b3-spanid"
+	MetadataUserID      = requestlog.MetadataUserID
+	MetadataTenant      = requestlog.MetadataTenant
+)
+
+// RegisterContextExtractor adds (or replaces) a named extractor.
+// Extractors run in the order they were registered, the default extractors run first.
+func RegisterContextExtractor(name string, extractor ContextExtractor) {
+	contextExtractorsMutex.Lock()
+	defer contextExtractorsMutex.Unlock()
+
+	for i := range contextExtractors {
+		if contextExtractors[i].name == name {
+			contextExtractors[i].extractor = extractor
+			return
+		}
+	}
+	contextExtractors = append(contextExtractors, namedContextExtractor{name: name, extractor: extractor})
+}
+
+// UnregisterContextExtractor removes a named extractor
+func UnregisterContextExtractor(name string) {
+	contextExtractorsMutex.Lock()
+	defer contextExtractorsMutex.Unlock()
+
+	for i := range contextExtractors {
+		if contextExtractors[i].name == name {
+			contextExtractors = append(contextExtractors[:i], contextExtractors[i+1:]...)
+			return
+		}
+	}
+}
+
+// ExtractContextData runs all registered extractors against ctx
+func ExtractContextData(ctx context.Context) ContextData {
+	data := ContextData{}
+	if ctx == nil {
+		return data
+	}
+
+	contextExtractorsMutex.RLock()
+	defer contextExtractorsMutex.RUnlock()
+
+	for _, ce := range contextExtractors {
+		if ce.extractor != nil {
+			ce.extractor.ExtractContextData(ctx, &data)
+		}
+	}
+	return data
+}
+
+type contextDataKey struct{}
+
+// ContextWithData returns a copy of ctx carrying data, merged with any data already on ctx
+func ContextWithData(ctx context.Context, data ContextData) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if existing, ok := ctx.Value(contextDataKey{}).(ContextData); ok {
+		data.merge(existing)
+	}
+	return context.WithValue(ctx, contextDataKey{}, data)
+}
+
+// ContextDataFromContext returns the data set with ContextWithData
+func ContextDataFromContext(ctx context.Context) (ContextData, bool) {
+	if ctx == nil {
+		return ContextData{}, false
+	}
+	data, ok := ctx.Value(contextDataKey{}).(ContextData)
+	return data, ok
+}
+
+func extractContextValues(ctx context.Context, data *ContextData) {
+	if values, ok := ContextDataFromContext(ctx); ok {
+		data.merge(values)
+	}
+}
+
+func extractGRPCMetadata(ctx context.Context, data *ContextData) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return
+	}
+
+	first := func(key string) string {
+		if vals := md.Get(key); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	}
+
+	fromMD := ContextData{
+		RequestID: first(MetadataRequestID),
+		UserID:    first(MetadataUserID),
+		Tenant:    first(MetadataTenant),
+	}
+	if traceID, spanID, ok := requestlog.ParseTraceParent(first(MetadataTraceParent)); ok {
+		fromMD.TraceID = traceID
+		fromMD.SpanID = spanID
+	} else {
+		fromMD.TraceID = first(MetadataB3TraceID)
+		fromMD.SpanID = first(MetadataB3SpanID)
+	}
+	data.merge(fromMD)
+}
+
+func extractSrvlogFields(ctx context.Context, data *ContextData) {
+	fields := srvlog.FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return
+	}
+
+	str := func(key string) string {
+		value, _ := fields[key].(string)
+		return value
+	}
+	data.merge(ContextData{
+		RequestID: str(srvlog.FieldRequestID),
+		TraceID:   str(srvlog.FieldTraceID),
+		SpanID:    str(srvlog.FieldSpanID),
+		UserID:    str(srvlog.FieldUserID),
+		Tenant:    str(srvlog.FieldTenant),
+	})
+}
+
+// applyContextData stores the request scoped information from ctx on the APIError
+func (ae *APIError) applyContextData(ctx context.Context) {
+	if ae == nil || ctx == nil {
+		return
+	}
+	ae.SetContextData(ExtractContextData(ctx))
+}
+
+// SetContextData fills the empty request scoped fields of the APIError
+func (ae *APIError) SetContextData(data ContextData) {
+	if ae == nil {
+		return
+	}
+	current := ae.GetContextData()
+	current.merge(data)
+	ae.RequestID = current.RequestID
+	ae.TraceID = current.TraceID
+	ae.SpanID = current.SpanID
+	ae.UserID = current.UserID
+	ae.Tenant = current.Tenant
+}
+
+// GetContextData returns the request scoped fields of the APIError
+func (ae *APIError) GetContextData() ContextData {
+	if ae == nil {
+		return ContextData{}
+	}
+	return ContextData{
+		RequestID: ae.RequestID,
+		TraceID:   ae.TraceID,
+		SpanID:    ae.SpanID,
+		UserID:    ae.UserID,
+		Tenant:    ae.Tenant,
+	}
+}
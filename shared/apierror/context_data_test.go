@@ -0,0 +1,86 @@
+package apierror
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestExtractContextData(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want ContextData
+	}{
+		{
+			name: "ContextValues",
+			ctx:  ContextWithData(context.Background(), ContextData{RequestID: "req-1", UserID: "user-1"}),
+			want: ContextData{RequestID: "req-1", UserID: "user-1"},
+		},
+		{
+			name: "IncomingMetadataTraceParent",
+			ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				MetadataRequestID, "req-2",
+				MetadataTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				MetadataTenant, "acme",
+			)),
+			want: ContextData{RequestID: "req-2", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Tenant: "acme"},
+		},
+		{
+			name: "IncomingMetadataB3",
+			ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				MetadataB3TraceID, "abc",
+				MetadataB3SpanID, "def",
+			)),
+			want: ContextData{TraceID: "abc", SpanID: "def"},
+		},
+		{
+			name: "ContextValuesWinOverMetadata",
+			ctx: ContextWithData(
+				metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataRequestID, "from-md", MetadataUserID, "md-user")),
+				ContextData{RequestID: "from-ctx"},
+			),
+			want: ContextData{RequestID: "from-ctx", UserID: "md-user"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractContextData(tt.ctx); got != tt.want {
+				t.Errorf("ExtractContextData() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIErrorContextData(t *testing.T) {
+	RegisterContextExtractor("test", ContextExtractorFunc(func(ctx context.Context, data *ContextData) {
+		if data.Tenant == "" {
+			data.Tenant = "test-tenant"
+		}
+	}))
+	defer UnregisterContextExtractor("test")
+
+	ctx := ContextWithData(context.Background(), ContextData{RequestID: "req-1", TraceID: "trace-1"})
+	ae := NewAPIErrorWithContext(ctx, nil, 500, "", "redis down")
+	if ae.RequestID != "req-1" || ae.TraceID != "trace-1" || ae.Tenant != "test-tenant" {
+		t.Fatalf("expected context data on APIError, got %+v", ae.GetContextData())
+	}
+
+	fields := ae.GetMapStringInterface()
+	if fields["request_id"] != "req-1" || fields["trace_id"] != "trace-1" {
+		t.Errorf("expected context data in log fields, got %v", fields)
+	}
+
+	remote := GetOriginGRPCError(ae.GRPCError())
+	if remote == nil || remote.GetContextData() != ae.GetContextData() {
+		t.Fatalf("expected context data to survive gRPC status, got %+v", remote)
+	}
+
+	// a local error wrapping the remote one inherits its ids
+	wrapped := NewAPIError(remote, 0, "", "sale failed")
+	if wrapped.RequestID != "req-1" {
+		t.Errorf("expected RequestID inherited from the stack, got %q", wrapped.RequestID)
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"runtime"
 )
 
-// convertWrappedToAPIError converts a chain of wrapped errors
+// convertWrappedToAPIError converts a tree of wrapped errors
 // to an APIError. Supports StatusHTTP and Source methods.
 // This allows other error handling packages to be compatible
 // with APIError without requiring a dependency on APIError.
 // They do require the common method signatures noted below.
+// Errors wrapping a single error are converted into the Stack,
+// multi-errors (errors.Join or multiple %w) are converted into Causes.
 func convertWrappedToAPIError(err error) *APIError {
 	// supported interfaces
 	type httpError interface{ StatusHTTP() (int, string) }
@@ -31,8 +33,6 @@ func convertWrappedToAPIError(err error) *APIError {
 	var converted *APIError
 
 	switch v := err.(type) {
-	case *APIError:
-		converted = v
 	case httpError:
 		code, msg := v.StatusHTTP()
 		converted = &APIError{
@@ -42,7 +42,13 @@ func convertWrappedToAPIError(err error) *APIError {
 			ErrorMessage: err.Error(),
 			App:          ApplicationName,
 		}
-
+	default:
+		converted = &APIError{
+			originErr:          err,
+			ErrorMessage:       err.Error(),
+			OriginErrorMessage: err.Error(),
+			App:                ApplicationName,
+		}
 	}
 
 	var v traceableError
@@ -50,15 +56,31 @@ func convertWrappedToAPIError(err error) *APIError {
 	if ok {
 		if pc, file, line, ok := v.Source(); ok {
 			runtimeFuncPtr := runtime.FuncForPC(pc)
-			converted.traceFunc = runtimeFuncPtr.Name()
+			if runtimeFuncPtr != nil {
+				converted.traceFunc = runtimeFuncPtr.Name()
+			}
 			converted.traceFile = file
 			converted.traceLine = line
 		}
 	}
 
-	for err := errors.Unwrap(err); err != nil; err = errors.Unwrap(err) {
-		converted.Stack = convertWrappedToAPIError(err)
+	switch wrapped := err.(type) {
+	case multiUnwrapError:
+		converted.Causes = convertMultiErrorBranches(wrapped)
+	case interface{ Unwrap() error }:
+		converted.Stack = convertWrappedToAPIError(wrapped.Unwrap())
 	}
 
 	return converted
 }
+
+// convertMultiErrorBranches converts every branch of a multi-error to an APIError
+func convertMultiErrorBranches(err multiUnwrapError) []*APIError {
+	var causes []*APIError
+	for _, branch := range err.Unwrap() {
+		if converted := convertWrappedToAPIError(branch); converted != nil {
+			causes = append(causes, converted)
+		}
+	}
+	return causes
+}
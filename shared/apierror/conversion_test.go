@@ -0,0 +1,143 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var (
+	errRedisDown   = errors.New("redis down")
+	errCacheMissed = errors.New("cache missed")
+)
+
+type codedError struct {
+	code int
+}
+
+func (ce *codedError) Error() string {
+	return fmt.Sprintf("coded error %d", ce.code)
+}
+
+func TestMultiErrorIsAs(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{
+			name: "ErrorsJoin",
+			err:  errors.Join(errRedisDown, &codedError{code: 409}, errCacheMissed),
+		},
+		{
+			name: "MultipleWrapVerbs",
+			err:  fmt.Errorf("lookup failed: %w, %w, %w", errRedisDown, &codedError{code: 409}, errCacheMissed),
+		},
+		{
+			name: "NestedJoin",
+			err:  errors.Join(fmt.Errorf("outer: %w", errors.Join(errRedisDown, &codedError{code: 409})), errCacheMissed),
+		},
+		{
+			name: "APIErrorBranch",
+			err:  errors.Join(NewAPIError(errRedisDown, 503, "", "redis unavailable"), &codedError{code: 409}, errCacheMissed),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ae := NewAPIError(tt.err, 0, "", "")
+			if ae == nil {
+				t.Fatal("expected APIError")
+			}
+			if !errors.Is(ae, errRedisDown) {
+				t.Errorf("errors.Is(ae, errRedisDown) = false, want true")
+			}
+			if !errors.Is(ae, errCacheMissed) {
+				t.Errorf("errors.Is(ae, errCacheMissed) = false, want true")
+			}
+			var ce *codedError
+			if !errors.As(ae, &ce) || ce.code != 409 {
+				t.Errorf("errors.As(ae, *codedError) failed, got %v", ce)
+			}
+
+			// wrapping again keeps the tree reachable
+			outer := NewAPIError(ae, 0, "", "outer")
+			if !errors.Is(outer, errCacheMissed) {
+				t.Errorf("errors.Is(outer, errCacheMissed) = false, want true")
+			}
+		})
+	}
+}
+
+func TestMultiErrorCauses(t *testing.T) {
+	ae := NewAPIError(errors.Join(NewAPIError(nil, 404, "id", "product not found"), errRedisDown), 0, "", "sale failed")
+	if len(ae.Causes) != 2 {
+		t.Fatalf("expected 2 causes, got %d", len(ae.Causes))
+	}
+	if ae.ErrorCode != 404 {
+		t.Errorf("expected ErrorCode inherited from first cause (404), got %d", ae.ErrorCode)
+	}
+
+	// JSON
+	jsonBytes, err := ae.GetJSONBytes()
+	if err != nil {
+		t.Fatalf("GetJSONBytes: %v", err)
+	}
+	decoded, err := NewAPIErrorFromJSONBytes(jsonBytes)
+	if err != nil {
+		t.Fatalf("NewAPIErrorFromJSONBytes: %v", err)
+	}
+	if len(decoded.Causes) != 2 || decoded.Causes[1].ErrorMessage != "redis down" {
+		t.Errorf("JSON round trip lost causes: %s", string(jsonBytes))
+	}
+
+	// Proto
+	packed, err := ae.ToPackedDataBase64(false)
+	if err != nil {
+		t.Fatalf("ToPackedDataBase64: %v", err)
+	}
+	unpacked := &APIError{}
+	if err := unpacked.FromPackedDataBase64(packed); err != nil {
+		t.Fatalf("FromPackedDataBase64: %v", err)
+	}
+	if len(unpacked.Causes) != 2 || unpacked.Causes[0].ErrorField != "id" {
+		t.Errorf("proto round trip lost causes: %v", unpacked.GetJSONString())
+	}
+
+	// gRPC status
+	remote := GetOriginGRPCError(ae.GRPCError())
+	if remote == nil || len(remote.Causes) != 2 {
+		t.Errorf("gRPC round trip lost causes: %v", remote)
+	}
+
+	// Logs
+	fields := ae.GetMapStringInterface()
+	causes, ok := fields["causes"].([]map[string]interface{})
+	if !ok || len(causes) != 2 {
+		t.Fatalf("expected 2 causes in log fields, got %v", fields["causes"])
+	}
+	if !strings.Contains(fmt.Sprintf("%v", causes[1]["err_message"]), "redis down") {
+		t.Errorf("expected redis down cause in log fields, got %v", causes[1])
+	}
+
+	if safe := ae.GetExternalSafeAPIError(); len(safe.Causes) != 0 {
+		t.Errorf("expected causes to be stripped from the external safe APIError")
+	}
+}
+
+func TestConvertWrappedToAPIError(t *testing.T) {
+	err := fmt.Errorf("handler: %w", fmt.Errorf("repo: %w", errors.Join(errRedisDown, errCacheMissed)))
+	converted := convertWrappedToAPIError(err)
+	if converted == nil || converted.Stack == nil || converted.Stack.Stack == nil {
+		t.Fatalf("expected a two level stack, got %v", converted)
+	}
+	if got := len(converted.Stack.Stack.Causes); got != 2 {
+		t.Errorf("expected 2 causes at the bottom of the stack, got %d", got)
+	}
+
+	jsonBytes, _ := json.Marshal(converted)
+	if !strings.Contains(string(jsonBytes), "cache missed") {
+		t.Errorf("expected every branch rendered in JSON, got %s", string(jsonBytes))
+	}
+}
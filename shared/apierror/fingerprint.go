@@ -0,0 +1,40 @@
+package apierror
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog/msgtemplate"
+)
+
+// NormaliseErrorMessage strips the variable parts (quoted values, ids, numbers)
+// out of an error message so that messages from the same call site compare equal
+func NormaliseErrorMessage(msg string) string {
+	return msgtemplate.Normalise(msg)
+}
+
+// Fingerprint returns a stable identifier for the type of error, built from
+// the SourceFunc, ErrorCode, ErrorNumber and the normalised ErrorMessage.
+// Two errors generated by the same code path for the same reason share a fingerprint.
+func (ae *APIError) Fingerprint() string {
+	if ae == nil {
+		return ""
+	}
+
+	msg := ae.ErrorMessage
+	if msg == "" {
+		msg = ae.OriginErrorMessage
+	}
+
+	h := sha1.New()
+	h.Write([]byte(ae.SourceFunc))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(ae.ErrorCode)))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(ae.ErrorNumber)))
+	h.Write([]byte{0})
+	h.Write([]byte(NormaliseErrorMessage(msg)))
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
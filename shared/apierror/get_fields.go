@@ -1,5 +1,8 @@
 package apierror
 
+import "github.com/ramseyjiang/go-micros/shared/srvlog/redact"
+
+// GetMapStringInterface returns the APIError as log fields, redacted by redact.Default
 func (ae *APIError) GetMapStringInterface() map[string]interface{} {
 	fields := make(map[string]interface{})
 
@@ -37,11 +40,44 @@ func (ae *APIError) GetMapStringInterface() map[string]interface{} {
 		fields["message"] = ae.Message
 	}
 
+	if ae.RequestID != "" {
+		fields["request_id"] = ae.RequestID
+	}
+	if ae.TraceID != "" {
+		fields["trace_id"] = ae.TraceID
+	}
+	if ae.SpanID != "" {
+		fields["span_id"] = ae.SpanID
+	}
+	if ae.UserID != "" {
+		fields["user_id"] = ae.UserID
+	}
+	if ae.Tenant != "" {
+		fields["tenant"] = ae.Tenant
+	}
+
+	if ae.RemoteMethod != "" {
+		fields["remote_method"] = ae.RemoteMethod
+	}
+	if ae.RemotePeer != "" {
+		fields["remote_peer"] = ae.RemotePeer
+	}
+
 	if ae.Stack != nil {
 		fields["stack"] = ae.Stack.GetMapStringInterface()
 	}
 
-	return fields
+	if len(ae.Causes) > 0 {
+		causes := make([]map[string]interface{}, 0, len(ae.Causes))
+		for _, cause := range ae.Causes {
+			if cause != nil {
+				causes = append(causes, cause.GetMapStringInterface())
+			}
+		}
+		fields["causes"] = causes
+	}
+
+	return redact.Fields(fields)
 }
 
 // Error implements the standard error handler interface
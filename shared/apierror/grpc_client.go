@@ -0,0 +1,139 @@
+package apierror
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInt should be passed to grpc.Dial so that errors returned by a
+// remote service are rehydrated into a local APIError. The remote APIError
+// (with its stack) becomes the Stack of the local error, the remote method
+// and peer are recorded and the gRPC code is preserved.
+func UnaryClientInt() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		remotePeer := &peer.Peer{}
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(remotePeer))...)
+		if err == nil {
+			return nil
+		}
+		return RehydrateRemoteError(ctx, err, method, peerAddress(remotePeer, cc))
+	}
+}
+
+// UnaryClientInterceptor returns a dial option installing UnaryClientInt.
+func UnaryClientInterceptor() grpc.DialOption {
+	return grpc.WithUnaryInterceptor(UnaryClientInt())
+}
+
+// StreamClientInt should be passed to grpc.Dial so that errors returned by a
+// remote streaming service are rehydrated into a local APIError (see UnaryClientInt).
+func StreamClientInt() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		remotePeer := &peer.Peer{}
+		cs, err := streamer(ctx, desc, cc, method, append(opts, grpc.Peer(remotePeer))...)
+		if err != nil {
+			return nil, RehydrateRemoteError(ctx, err, method, peerAddress(remotePeer, cc))
+		}
+		return &rehydratingClientStream{ClientStream: cs, ctx: ctx, method: method, peer: remotePeer, cc: cc}, nil
+	}
+}
+
+// StreamClientInterceptor returns a dial option installing StreamClientInt.
+func StreamClientInterceptor() grpc.DialOption {
+	return grpc.WithStreamInterceptor(StreamClientInt())
+}
+
+type rehydratingClientStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	method string
+	peer   *peer.Peer
+	cc     *grpc.ClientConn
+}
+
+func (s *rehydratingClientStream) SendMsg(m interface{}) error {
+	return s.rehydrate(s.ClientStream.SendMsg(m))
+}
+
+func (s *rehydratingClientStream) RecvMsg(m interface{}) error {
+	return s.rehydrate(s.ClientStream.RecvMsg(m))
+}
+
+func (s *rehydratingClientStream) CloseSend() error {
+	return s.rehydrate(s.ClientStream.CloseSend())
+}
+
+func (s *rehydratingClientStream) rehydrate(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return RehydrateRemoteError(s.ctx, err, s.method, peerAddress(s.peer, s.cc))
+}
+
+func peerAddress(p *peer.Peer, cc *grpc.ClientConn) string {
+	if p != nil && p.Addr != nil {
+		return p.Addr.String()
+	}
+	if cc != nil {
+		return cc.Target()
+	}
+	return ""
+}
+
+// RehydrateRemoteError converts an error returned by a remote gRPC call into a
+// local APIError. If the remote service returned an APIError it becomes the
+// Stack of the new error, otherwise the gRPC status is used.
+func RehydrateRemoteError(ctx context.Context, err error, method string, remotePeer string) error {
+	if err == nil {
+		return nil
+	}
+
+	st, isStatus := status.FromError(err)
+	if !isStatus || st.Code() == codes.OK {
+		return err
+	}
+
+	var cause error = err
+	if remote := GetOriginGRPCError(err); remote != nil {
+		cause = remote
+	}
+
+	ae := newAPIErrorNoLog(ctx, BackProcs+1, ErrorType_ERROR, cause, GRPCCodeToHTTPCode(st.Code()), "", "")
+	if ae == nil {
+		return err
+	}
+	ae.RemoteMethod = method
+	ae.RemotePeer = remotePeer
+	ae.grpcCode = st.Code()
+	if ae.ErrorMessage == "" {
+		ae.ErrorMessage = st.Message()
+	}
+
+	if Logger != nil {
+		Logger.HandleLogMessageWithContext(ctx, ae)
+	}
+
+	return ae
+}
+
+// GRPCCode returns the gRPC code for this error. Errors rehydrated from a remote
+// call keep the code they were received with, otherwise it is derived from the ErrorCode.
+func (ae *APIError) GRPCCode() codes.Code {
+	if ae == nil {
+		return codes.OK
+	}
+	if ae.grpcCode != codes.OK {
+		return ae.grpcCode
+	}
+	if ae.Stack != nil && ae.Stack.ErrorCode == ae.ErrorCode {
+		if stackCode := ae.Stack.GRPCCode(); stackCode != codes.OK {
+			return stackCode
+		}
+	}
+	return HTTPCodeToGRPCCode(ae.ErrorCode)
+}
@@ -0,0 +1,73 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryClientIntRehydratesRemoteError(t *testing.T) {
+	tests := []struct {
+		name        string
+		remoteErr   error
+		wantCode    codes.Code
+		wantHTTP    int
+		wantStack   bool
+		wantMessage string
+	}{
+		{
+			name:        "RemoteAPIError",
+			remoteErr:   NewAPIError(nil, 404, "id", "product not found").GRPCError(),
+			wantCode:    codes.NotFound,
+			wantHTTP:    404,
+			wantStack:   true,
+			wantMessage: "product not found",
+		},
+		{
+			name:        "PlainStatus",
+			remoteErr:   status.Error(codes.Unavailable, "connection refused"),
+			wantCode:    codes.Unavailable,
+			wantHTTP:    GRPCCodeToHTTPCode(codes.Unavailable),
+			wantMessage: "connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return tt.remoteErr
+			}
+
+			err := UnaryClientInt()(context.Background(), "/products.ProductService/GetProducts", nil, nil, nil, invoker)
+
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("expected APIError, got %T %v", err, err)
+			}
+			if ae.RemoteMethod != "/products.ProductService/GetProducts" {
+				t.Errorf("RemoteMethod = %q", ae.RemoteMethod)
+			}
+			if ae.ErrorCode != tt.wantHTTP {
+				t.Errorf("ErrorCode = %d, want %d", ae.ErrorCode, tt.wantHTTP)
+			}
+			if got := ae.GRPCCode(); got != tt.wantCode {
+				t.Errorf("GRPCCode() = %v, want %v", got, tt.wantCode)
+			}
+			if tt.wantStack && (ae.Stack == nil || ae.Stack.ErrorField != "id") {
+				t.Errorf("expected the remote APIError as Stack, got %v", ae.Stack)
+			}
+			if ae.ErrorMessage != tt.wantMessage {
+				t.Errorf("message = %q, want %q", ae.ErrorMessage, tt.wantMessage)
+			}
+
+			// returning the rehydrated error from a server keeps the original code
+			if got := status.Code(ae.GRPCError()); got != tt.wantCode {
+				t.Errorf("re-sent code = %v, want %v", got, tt.wantCode)
+			}
+		})
+	}
+}
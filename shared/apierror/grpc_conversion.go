@@ -367,7 +367,7 @@ func (ae *APIError) GRPCStatus() *status.Status {
 	if ae == nil {
 		return nil
 	}
-	st := status.New(HTTPCodeToGRPCCode(ae.ErrorCode), ae.ErrorMessage)
+	st := status.New(ae.GRPCCode(), ae.ErrorMessage)
 
 	var details []protoiface.MessageV1
 	if str, encodeErr := ae.ToPackedDataBase64(false); encodeErr == nil {
@@ -398,12 +398,12 @@ func GRPCSendContext(ctx context.Context) {
 		if md, ok := metadata.FromOutgoingContext(ctx); ok {
 			sendErr := grpc.SendHeader(ctx, md)
 			if sendErr != nil {
-				srvlog.Warnf("GRPCSendContext grpc.SendHeader(md): %v", sendErr)
+				srvlog.WithContext(ctx).Warnf("GRPCSendContext grpc.SendHeader(md): %v", sendErr)
 			}
 		} else {
 			sendErr := grpc.SendHeader(ctx, nil)
 			if sendErr != nil {
-				srvlog.Warnf("GRPCSendContext grpc.SendHeader(nil): %v", sendErr)
+				srvlog.WithContext(ctx).Warnf("GRPCSendContext grpc.SendHeader(nil): %v", sendErr)
 			}
 		}
 	} else {
@@ -423,19 +423,19 @@ func UnaryServerInt() grpc.UnaryServerInterceptor {
 			var internal *APIError
 			switch {
 			case errors.As(err, &internal):
-				srvlog.Warn("APIError: ", internal.GetJSONString())
+				srvlog.WithContext(ctx).Warn("APIError: ", internal.GetJSONString())
 				GRPCSendContext(ctx)
 				return nil, internal
 			default:
 				if grpcErr := GetOriginGRPCError(err); grpcErr != nil {
-					srvlog.Warn("DEFAULT APIError: ", grpcErr.GetJSONString())
+					srvlog.WithContext(ctx).Warn("DEFAULT APIError: ", grpcErr.GetJSONString())
 					// this is already a GRPC error
 					GRPCSendContext(ctx)
 					return nil, grpcErr
 				}
 
 				wrappedErr := convertWrappedToAPIError(err)
-				srvlog.Warn("untraceable apierror returned by ", info.FullMethod)
+				srvlog.WithContext(ctx).Warn("untraceable apierror returned by ", info.FullMethod)
 				GRPCSendContext(ctx)
 				return nil, NewAPIError(wrappedErr, 0, "", "")
 			}
@@ -452,33 +452,81 @@ func UnaryInterceptor() grpc.ServerOption {
 // function so that all errors are converted to APIErrors even
 // if they are not generated by this package or have missed
 // being called with GRPCError().
+//
+// A stream ended because its client cancelled it or its deadline passed
+// returns Canceled or DeadlineExceeded, it is not an error of the server.
 func StreamServerInt() grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		err := handler(ss.Context(), ss)
+		stream := &headerTrackingServerStream{ServerStream: ss}
+		err := handler(srv, stream)
 		if err != nil {
+			ctx := ss.Context()
+			if ctx.Err() != nil && isContextError(err) {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+
 			var internal *APIError
 			switch {
 			case errors.As(err, &internal):
-				srvlog.Warn("apierror")
-				GRPCSendContext(ss.Context())
+				srvlog.WithContext(ctx).Warn("APIError: ", internal.GetJSONString())
+				stream.sendContext()
 				return internal
 			default:
 				if grpcErr := GetOriginGRPCError(err); grpcErr != nil {
 					// this is already a GRPC error
-					GRPCSendContext(ss.Context())
+					stream.sendContext()
 					return err
 				}
 
+				// a plain status keeps its code
+				httpCode := 500
+				if st, ok := status.FromError(err); ok {
+					httpCode = GRPCCodeToHTTPCode(st.Code())
+				}
 				wrappedErr := convertWrappedToAPIError(err)
-				srvlog.Warn("untraceable apierror returned by ", info.FullMethod)
-				GRPCSendContext(ss.Context())
-				return NewAPIErrorWithContext(ss.Context(), wrappedErr, 500, "", "").GRPCError()
+				srvlog.WithContext(ctx).Warn("untraceable apierror returned by ", info.FullMethod)
+				stream.sendContext()
+				return NewAPIErrorWithContext(ctx, wrappedErr, httpCode, "", "").GRPCError()
 			}
 		}
 		return nil
 	}
 }
 
+// isContextError reports whether err is the error of a done context, as returned by
+// the stream methods once the client has gone
+func isContextError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	code := status.Code(err)
+	return code == codes.Canceled || code == codes.DeadlineExceeded
+}
+
+// headerTrackingServerStream records whether the headers of the stream were sent,
+// they are sent with the first message and cannot be sent again with the error
+type headerTrackingServerStream struct {
+	grpc.ServerStream
+	headerSent bool
+}
+
+func (s *headerTrackingServerStream) SendHeader(md metadata.MD) error {
+	s.headerSent = true
+	return s.ServerStream.SendHeader(md)
+}
+
+func (s *headerTrackingServerStream) SendMsg(m interface{}) error {
+	s.headerSent = true
+	return s.ServerStream.SendMsg(m)
+}
+
+// sendContext sends the context metadata (see GRPCSendContext) unless the headers were sent
+func (s *headerTrackingServerStream) sendContext() {
+	if !s.headerSent {
+		GRPCSendContext(s.Context())
+	}
+}
+
 func StreamInterceptor() grpc.ServerOption {
 	return grpc.StreamInterceptor(StreamServerInt())
 }
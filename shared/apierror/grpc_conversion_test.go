@@ -0,0 +1,136 @@
+package apierror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testService struct{}
+
+// contextServerStream is a stream of ctx, counting the sent messages
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent int
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *contextServerStream) SendMsg(m interface{}) error {
+	s.sent++
+	return nil
+}
+
+func TestStreamServerInt(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		handlerFn func(stream grpc.ServerStream) error
+		wantCode  codes.Code
+		wantAPI   bool
+	}{
+		{
+			name:      "NoError",
+			ctx:       context.Background(),
+			handlerFn: func(stream grpc.ServerStream) error { return stream.SendMsg(nil) },
+			wantCode:  codes.OK,
+		},
+		{
+			name: "APIErrorAfterMessages",
+			ctx:  context.Background(),
+			handlerFn: func(stream grpc.ServerStream) error {
+				_ = stream.SendMsg(nil)
+				return NewAPIError(nil, 400, "cursor", "invalid cursor")
+			},
+			wantCode: codes.InvalidArgument,
+			wantAPI:  true,
+		},
+		{
+			name:      "Status",
+			ctx:       context.Background(),
+			handlerFn: func(stream grpc.ServerStream) error { return status.Error(codes.NotFound, "no such sale") },
+			wantCode:  codes.NotFound,
+			wantAPI:   true,
+		},
+		{
+			name:      "PlainError",
+			ctx:       context.Background(),
+			handlerFn: func(stream grpc.ServerStream) error { return errors.New("connection refused") },
+			wantCode:  codes.Internal,
+			wantAPI:   true,
+		},
+		{
+			name: "ClientCancelled",
+			ctx:  cancelled,
+			handlerFn: func(stream grpc.ServerStream) error {
+				return fmt.Errorf("reading the feed: %w", stream.Context().Err())
+			},
+			wantCode: codes.Canceled,
+		},
+		{
+			name:      "ContextErrorOfALiveStream",
+			ctx:       context.Background(),
+			handlerFn: func(stream grpc.ServerStream) error { return context.DeadlineExceeded },
+			wantCode:  codes.Internal,
+			wantAPI:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				// the generated handlers assert srv is the service
+				if _, ok := srv.(*testService); !ok {
+					t.Errorf("handler srv %T, want the service", srv)
+				}
+				return tt.handlerFn(stream)
+			}
+
+			err := StreamServerInt()(&testService{}, &contextServerStream{ctx: tt.ctx}, &grpc.StreamServerInfo{FullMethod: "/trade.SalesService/WatchSales", IsServerStream: true}, handler)
+
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("code = %v, want %v (%v)", got, tt.wantCode, err)
+			}
+			if tt.wantAPI && GetOriginGRPCError(err) == nil {
+				t.Errorf("expected an APIError, got %T %v", err, err)
+			}
+		})
+	}
+}
+
+func TestUnaryServerIntLogsWithRequestID(t *testing.T) {
+	logs := &bytes.Buffer{}
+	out := srvlog.GlobalLogger.Output()
+	srvlog.GlobalLogger.SetOutput(logs)
+	defer srvlog.GlobalLogger.SetOutput(out)
+
+	ctx := srvlog.ContextWithField(context.Background(), srvlog.FieldRequestID, "req-123")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, NewAPIError(nil, 400, "sku", "invalid SKU")
+	}
+	if _, err := UnaryServerInt()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test/Unary"}, handler); err == nil {
+		t.Fatal("expected the APIError to be returned")
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if strings.Contains(line, "APIError: ") && !strings.Contains(line, "req-123") {
+			t.Errorf("APIError logged without the request ID: %s", line)
+		}
+	}
+	if !strings.Contains(logs.String(), "APIError: ") {
+		t.Errorf("expected the APIError to be logged, got %q", logs.String())
+	}
+}
@@ -224,10 +224,17 @@ func (aep *APIErrorProto) FromAPIError(apiError *APIError, saveSpace bool) {
 	aep.ErrorMessage = apiError.ErrorMessage
 	aep.Message = apiError.Message
 	aep.SourceFile = apiError.SourceFile
+	aep.RequestID = apiError.RequestID
+	aep.TraceID = apiError.TraceID
+	aep.SpanID = apiError.SpanID
+	aep.UserID = apiError.UserID
+	aep.Tenant = apiError.Tenant
 	if !saveSpace {
 		aep.ErrorType = apiError.ErrorType
 		aep.SourceFunc = apiError.SourceFunc
 		aep.OriginErrorMessage = apiError.OriginErrorMessage
+		aep.RemoteMethod = apiError.RemoteMethod
+		aep.RemotePeer = apiError.RemotePeer
 	}
 
 	for i := range apiError.traceFrames {
@@ -239,6 +246,15 @@ func (aep *APIErrorProto) FromAPIError(apiError *APIError, saveSpace bool) {
 		stackAEP.FromAPIError(apiError.Stack, saveSpace)
 		aep.Stack = &stackAEP
 	}
+
+	for _, cause := range apiError.Causes {
+		if cause == nil {
+			continue
+		}
+		causeAEP := APIErrorProto{}
+		causeAEP.FromAPIError(cause, saveSpace)
+		aep.Causes = append(aep.Causes, &causeAEP)
+	}
 }
 
 // FromAPIErrorProto loads an APIErrorProto into an APIError
@@ -256,6 +272,13 @@ func (ae *APIError) FromAPIErrorProto(aep *APIErrorProto) {
 	ae.Message = aep.Message
 	ae.SourceFile = aep.SourceFile
 	ae.SourceFunc = aep.SourceFunc
+	ae.RequestID = aep.RequestID
+	ae.TraceID = aep.TraceID
+	ae.SpanID = aep.SpanID
+	ae.UserID = aep.UserID
+	ae.Tenant = aep.Tenant
+	ae.RemoteMethod = aep.RemoteMethod
+	ae.RemotePeer = aep.RemotePeer
 
 	for i := range aep.TraceFrames {
 		if aep.TraceFrames[i] == nil {
@@ -269,4 +292,13 @@ func (ae *APIError) FromAPIErrorProto(aep *APIErrorProto) {
 		stackAE.FromAPIErrorProto(aep.Stack)
 		ae.Stack = &stackAE
 	}
+
+	for _, cause := range aep.Causes {
+		if cause == nil {
+			continue
+		}
+		causeAE := APIError{}
+		causeAE.FromAPIErrorProto(cause)
+		ae.Causes = append(ae.Causes, &causeAE)
+	}
 }
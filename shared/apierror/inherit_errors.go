@@ -69,6 +69,12 @@ func (ae *APIError) ApplyFieldInheritance() {
 	if ae.ErrorCode == 0 && ae.Stack != nil {
 		ae.ErrorCode = ae.Stack.ErrorCode
 	}
+	// Then from the first cause that has one
+	for i := 0; ae.ErrorCode == 0 && i < len(ae.Causes); i++ {
+		if ae.Causes[i] != nil {
+			ae.ErrorCode = ae.Causes[i].ErrorCode
+		}
+	}
 	if ae.ErrorCode == 0 && ae.ErrorType == ErrorType_ERROR {
 		ae.ErrorCode = 500
 	}
@@ -77,6 +83,11 @@ func (ae *APIError) ApplyFieldInheritance() {
 	if ae.ErrorNumber == 0 && ae.Stack != nil {
 		ae.ErrorNumber = ae.Stack.ErrorNumber
 	}
+
+	// Inherit request scoped fields from the Stack, so errors decoded from a remote service keep them
+	if ae.Stack != nil {
+		ae.SetContextData(ae.Stack.GetContextData())
+	}
 }
 
 // ApplyForeignError applies a foreign error to this APIError object (must return ae if successful, or nil if its not actually an error)
@@ -144,6 +155,14 @@ func (ae *APIError) ApplyForeignError(err error, errcode int, errfield string) e
 		}
 	}
 
+	// Multi-errors (errors.Join or fmt.Errorf with multiple %w) keep every branch as a cause
+	if multiErr, ok := err.(multiUnwrapError); ok && multiErr != nil {
+		ae.originErr = err
+		ae.OriginErrorMessage = err.Error()
+		ae.Causes = convertMultiErrorBranches(multiErr)
+		return ae
+	}
+
 	if echo4Err, ok := err.(echoV4HTTPError); ok && echo4Err != nil {
 		if echo4Err.Unwrap() != nil {
 			err = echo4Err.Unwrap() // promote the underlying internal error (it might be a json or validation error)
@@ -42,26 +42,28 @@ func (h APILoggerHandler) HandleLogMessageWithContext(ctx context.Context, ae *A
 	}
 }
 
+// DefaultAPILoggerHandler logs APIErrors through the srvlog GlobalLogger, so they
+// reach its slog handler (see srvlog.SetHandler) with the request scoped fields of ctx
 type DefaultAPILoggerHandler struct{}
 
 func (obj *DefaultAPILoggerHandler) HandleLogMessageWithContext(ctx context.Context, ae *APIError) {
 	if obj == nil || ae == nil || srvlog.GlobalLogger == nil {
 		return
 	}
-	fields := ae.GetMapStringInterface()
+	entry := srvlog.WithContext(ctx).WithFields(ae.GetMapStringInterface())
 	switch ae.ErrorType {
 	case ErrorType_WARNING:
-		srvlog.GlobalLogger.WithFields(fields).Warn(ae.Message)
+		entry.Warn(ae.Message)
 
 	case ErrorType_INFO:
-		srvlog.GlobalLogger.WithFields(fields).Info(ae.Message)
+		entry.Info(ae.Message)
 
 	case ErrorType_DEBUG:
-		srvlog.GlobalLogger.WithFields(fields).Debug(ae.Message)
+		entry.Debug(ae.Message)
 
 	default:
 		// same as ErrorType_ERROR, ErrorType_UNKNOWN:
-		srvlog.GlobalLogger.WithFields(fields).Error(ae.Message)
+		entry.Error(ae.Message)
 
 	}
 }
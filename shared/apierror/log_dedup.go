@@ -0,0 +1,305 @@
+package apierror
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDedupWindow is the window used when a DedupConfig does not specify one
+	DefaultDedupWindow = time.Minute
+	// DefaultDedupMaxEntries is the number of fingerprints tracked unless set with SetMaxEntries
+	DefaultDedupMaxEntries = 10000
+)
+
+// DedupConfig controls how often a single error fingerprint is logged
+type DedupConfig struct {
+	// Window is the period over which repeats of the same fingerprint are collapsed
+	Window time.Duration
+	// MaxPerWindow is the number of occurrences logged in full within a window (default 1)
+	MaxPerWindow int
+	// Disabled passes every message straight through to the next logger
+	Disabled bool
+}
+
+// FingerprintCounter holds the counters for a single error fingerprint
+type FingerprintCounter struct {
+	Fingerprint string
+	ErrorType   ErrorType
+	// Total is the number of times this fingerprint has been seen
+	Total int64
+	// Suppressed is the number of times this fingerprint was not logged in full
+	Suppressed int64
+	FirstSeen  time.Time
+	LastSeen   time.Time
+}
+
+type dedupEntry struct {
+	counter FingerprintCounter
+	// windowStart is when the current window was opened
+	windowStart time.Time
+	// windowCount is the number of occurrences in the current window
+	windowCount int
+	// pending is the number of suppressed occurrences not yet reported in a summary
+	pending int
+	// last is the most recent suppressed APIError, used to build the summary message
+	last *APIError
+}
+
+// DedupLogger is an APILogger decorator that collapses repeated errors
+// (as identified by APIError.Fingerprint) into periodic "repeated N times" summaries.
+// The fingerprints idle for longer than their window are forgotten by Flush, and at most
+// DefaultDedupMaxEntries (see SetMaxEntries) are tracked.
+//
+//	apierror.Logger = apierror.NewDedupLogger(apierror.Logger, apierror.DedupConfig{Window: time.Minute})
+type DedupLogger struct {
+	next          APILogger
+	defaultConfig DedupConfig
+	typeConfigs   map[ErrorType]DedupConfig
+
+	mu         sync.Mutex
+	entries    map[string]*dedupEntry
+	maxEntries int
+
+	// now is used for testing
+	now func() time.Time
+}
+
+// NewDedupLogger returns a DedupLogger which forwards to next, using defaultConfig
+// for all ErrorTypes unless overridden with SetTypeConfig
+func NewDedupLogger(next APILogger, defaultConfig DedupConfig) *DedupLogger {
+	return &DedupLogger{
+		next:          next,
+		defaultConfig: defaultConfig,
+		typeConfigs:   make(map[ErrorType]DedupConfig),
+		entries:       make(map[string]*dedupEntry),
+		maxEntries:    DefaultDedupMaxEntries,
+		now:           time.Now,
+	}
+}
+
+// SetTypeConfig overrides the dedup config for a single ErrorType
+func (dl *DedupLogger) SetTypeConfig(errType ErrorType, cfg DedupConfig) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.typeConfigs[errType] = cfg
+}
+
+// SetMaxEntries sets the number of fingerprints tracked, the least recently seen one is
+// evicted (and its summary logged) to track a new one once there are maxEntries
+func (dl *DedupLogger) SetMaxEntries(maxEntries int) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.maxEntries = maxEntries
+}
+
+func (dl *DedupLogger) configFor(errType ErrorType) DedupConfig {
+	cfg, exists := dl.typeConfigs[errType]
+	if !exists {
+		cfg = dl.defaultConfig
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultDedupWindow
+	}
+	if cfg.MaxPerWindow <= 0 {
+		cfg.MaxPerWindow = 1
+	}
+	return cfg
+}
+
+// HandleLogMessageWithContext implements the APILogger interface
+func (dl *DedupLogger) HandleLogMessageWithContext(ctx context.Context, ae *APIError) {
+	if dl == nil || ae == nil {
+		return
+	}
+
+	dl.mu.Lock()
+	cfg := dl.configFor(ae.ErrorType)
+	if cfg.Disabled {
+		dl.mu.Unlock()
+		dl.forward(ctx, ae)
+		return
+	}
+
+	now := dl.now()
+	fp := ae.Fingerprint()
+	var summaries []*APIError
+	entry, exists := dl.entries[fp]
+	if !exists {
+		if dl.maxEntries > 0 && len(dl.entries) >= dl.maxEntries {
+			if summary := dl.evictOldest(); summary != nil {
+				summaries = append(summaries, summary)
+			}
+		}
+		entry = &dedupEntry{
+			counter: FingerprintCounter{
+				Fingerprint: fp,
+				ErrorType:   ae.ErrorType,
+				FirstSeen:   now,
+			},
+			windowStart: now,
+		}
+		dl.entries[fp] = entry
+	}
+
+	if now.Sub(entry.windowStart) >= cfg.Window {
+		if summary := dl.takeSummary(entry, cfg); summary != nil {
+			summaries = append(summaries, summary)
+		}
+		entry.windowStart = now
+		entry.windowCount = 0
+	}
+
+	entry.counter.Total++
+	entry.counter.LastSeen = now
+	entry.windowCount++
+
+	logFull := entry.windowCount <= cfg.MaxPerWindow
+	if !logFull {
+		entry.counter.Suppressed++
+		entry.pending++
+		entry.last = ae
+	}
+	dl.mu.Unlock()
+
+	// the summaries outlive the requests which they count, they are not logged with their context
+	for _, summary := range summaries {
+		dl.forward(context.Background(), summary)
+	}
+	if logFull {
+		dl.forward(ctx, ae)
+	}
+}
+
+// takeSummary builds the summary message for an entry and resets its pending count (must hold mu)
+func (dl *DedupLogger) takeSummary(entry *dedupEntry, cfg DedupConfig) *APIError {
+	if entry.pending == 0 || entry.last == nil {
+		return nil
+	}
+	summary := *entry.last
+	summary.Message = fmt.Sprintf("%s (repeated %d times in the last %s, fingerprint %s)",
+		entry.last.Error(), entry.pending, cfg.Window, entry.counter.Fingerprint)
+	entry.pending = 0
+	entry.last = nil
+	return &summary
+}
+
+// evictOldest forgets the least recently seen fingerprint, returning its summary (must hold mu)
+func (dl *DedupLogger) evictOldest() *APIError {
+	var oldest *dedupEntry
+	for _, entry := range dl.entries {
+		if oldest == nil || entry.counter.LastSeen.Before(oldest.counter.LastSeen) {
+			oldest = entry
+		}
+	}
+	if oldest == nil {
+		return nil
+	}
+	delete(dl.entries, oldest.counter.Fingerprint)
+	return dl.takeSummary(oldest, dl.configFor(oldest.counter.ErrorType))
+}
+
+// Flush emits summaries for every fingerprint whose window has expired, and forgets the
+// fingerprints not seen for longer than their window. Call it periodically (see StartFlushLoop)
+// so that repeats are reported even if the error stops occurring.
+func (dl *DedupLogger) Flush() {
+	dl.flush(false)
+}
+
+// FlushAll emits summaries for every fingerprint with suppressed occurrences,
+// regardless of their window. Typically used on shutdown.
+func (dl *DedupLogger) FlushAll() {
+	dl.flush(true)
+}
+
+func (dl *DedupLogger) flush(all bool) {
+	if dl == nil {
+		return
+	}
+
+	var summaries []*APIError
+
+	dl.mu.Lock()
+	now := dl.now()
+	for fp, entry := range dl.entries {
+		cfg := dl.configFor(entry.counter.ErrorType)
+		if !all && now.Sub(entry.windowStart) < cfg.Window {
+			continue
+		}
+		if summary := dl.takeSummary(entry, cfg); summary != nil {
+			summaries = append(summaries, summary)
+		} else if !all && now.Sub(entry.counter.LastSeen) >= cfg.Window {
+			// idle with nothing to report
+			delete(dl.entries, fp)
+			continue
+		}
+		if !all {
+			entry.windowStart = now
+			entry.windowCount = 0
+		}
+	}
+	dl.mu.Unlock()
+
+	for _, summary := range summaries {
+		dl.forward(context.Background(), summary)
+	}
+}
+
+// StartFlushLoop calls Flush every interval until ctx is done, then calls FlushAll
+func (dl *DedupLogger) StartFlushLoop(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultDedupWindow
+	}
+	go func() {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				dl.FlushAll()
+				return
+			case <-tick.C:
+				dl.Flush()
+			}
+		}
+	}()
+}
+
+// Counters returns a snapshot of the counters for every fingerprint tracked
+func (dl *DedupLogger) Counters() map[string]FingerprintCounter {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	resp := make(map[string]FingerprintCounter, len(dl.entries))
+	for fp, entry := range dl.entries {
+		resp[fp] = entry.counter
+	}
+	return resp
+}
+
+// Counter returns the counters for a single fingerprint
+func (dl *DedupLogger) Counter(fingerprint string) (FingerprintCounter, bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	entry, exists := dl.entries[fingerprint]
+	if !exists {
+		return FingerprintCounter{}, false
+	}
+	return entry.counter, true
+}
+
+// Reset clears all counters
+func (dl *DedupLogger) Reset() {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	dl.entries = make(map[string]*dedupEntry)
+}
+
+func (dl *DedupLogger) forward(ctx context.Context, ae *APIError) {
+	if dl.next != nil {
+		dl.next.HandleLogMessageWithContext(ctx, ae)
+	}
+}
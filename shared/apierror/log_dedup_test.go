@@ -0,0 +1,198 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	messages []*APIError
+}
+
+func (rl *recordingLogger) HandleLogMessageWithContext(ctx context.Context, ae *APIError) {
+	rl.messages = append(rl.messages, ae)
+}
+
+func TestNormaliseErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		same bool
+	}{
+		{name: "Numbers", a: "product 12 not found", b: "product 9876 not found", same: true},
+		{name: "UUIDs", a: "sale 123e4567-e89b-12d3-a456-426614174000 failed", b: "sale 00000000-0000-0000-0000-000000000001 failed", same: true},
+		{name: "Quoted", a: `dial tcp "redis:6379": refused`, b: `dial tcp "10.0.0.1:6379": refused`, same: true},
+		{name: "Whitespace", a: "redis   timeout", b: "redis timeout", same: true},
+		{name: "DifferentText", a: "redis timeout", b: "redis refused", same: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormaliseErrorMessage(tt.a) == NormaliseErrorMessage(tt.b)
+			if got != tt.same {
+				t.Errorf("NormaliseErrorMessage(%q)=%q, NormaliseErrorMessage(%q)=%q, want same=%v",
+					tt.a, NormaliseErrorMessage(tt.a), tt.b, NormaliseErrorMessage(tt.b), tt.same)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := &APIError{SourceFunc: "repos.GetProducts", ErrorCode: 500, ErrorMessage: "redis timeout after 30ms"}
+	b := &APIError{SourceFunc: "repos.GetProducts", ErrorCode: 500, ErrorMessage: "redis timeout after 45ms"}
+	c := &APIError{SourceFunc: "repos.CreateProduct", ErrorCode: 500, ErrorMessage: "redis timeout after 30ms"}
+	d := &APIError{SourceFunc: "repos.GetProducts", ErrorCode: 503, ErrorMessage: "redis timeout after 30ms"}
+
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("expected same fingerprint for (%v) and (%v)", a.ErrorMessage, b.ErrorMessage)
+	}
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Errorf("expected different fingerprint for different SourceFunc")
+	}
+	if a.Fingerprint() == d.Fingerprint() {
+		t.Errorf("expected different fingerprint for different ErrorCode")
+	}
+}
+
+func TestDedupLogger(t *testing.T) {
+	now := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	next := &recordingLogger{}
+	dl := NewDedupLogger(next, DedupConfig{Window: time.Minute})
+	dl.now = func() time.Time { return now }
+
+	newErr := func(n int) *APIError {
+		return &APIError{
+			ErrorType:    ErrorType_ERROR,
+			SourceFunc:   "repos.GetProducts",
+			ErrorCode:    500,
+			ErrorMessage: "redis timeout",
+			originErr:    errors.New("i/o timeout"),
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		dl.HandleLogMessageWithContext(context.Background(), newErr(i))
+	}
+	if len(next.messages) != 1 {
+		t.Fatalf("expected 1 message logged within the window, got %d", len(next.messages))
+	}
+
+	fp := newErr(0).Fingerprint()
+	counter, ok := dl.Counter(fp)
+	if !ok {
+		t.Fatalf("expected counter for fingerprint %s", fp)
+	}
+	if counter.Total != 5 || counter.Suppressed != 4 {
+		t.Errorf("expected Total=5 Suppressed=4, got Total=%d Suppressed=%d", counter.Total, counter.Suppressed)
+	}
+
+	// the next occurrence after the window emits a summary and is logged in full
+	now = now.Add(61 * time.Second)
+	dl.HandleLogMessageWithContext(context.Background(), newErr(5))
+	if len(next.messages) != 3 {
+		t.Fatalf("expected summary + new message, got %d messages", len(next.messages))
+	}
+	if !strings.Contains(next.messages[1].Message, "repeated 4 times") {
+		t.Errorf("expected summary message, got %q", next.messages[1].Message)
+	}
+
+	// Flush reports suppressed repeats once the window has expired
+	dl.HandleLogMessageWithContext(context.Background(), newErr(6))
+	dl.HandleLogMessageWithContext(context.Background(), newErr(7))
+	dl.Flush()
+	if len(next.messages) != 3 {
+		t.Fatalf("expected no summary before window expiry, got %d messages", len(next.messages))
+	}
+	now = now.Add(time.Minute)
+	dl.Flush()
+	if len(next.messages) != 4 || !strings.Contains(next.messages[3].Message, "repeated 2 times") {
+		t.Fatalf("expected flushed summary, got %d messages", len(next.messages))
+	}
+}
+
+func TestDedupLoggerTypeConfig(t *testing.T) {
+	next := &recordingLogger{}
+	dl := NewDedupLogger(next, DedupConfig{Window: time.Minute})
+	dl.SetTypeConfig(ErrorType_WARNING, DedupConfig{Disabled: true})
+	dl.SetTypeConfig(ErrorType_INFO, DedupConfig{Window: time.Minute, MaxPerWindow: 3})
+
+	for i := 0; i < 5; i++ {
+		dl.HandleLogMessageWithContext(context.Background(), &APIError{ErrorType: ErrorType_WARNING, ErrorMessage: "warn"})
+		dl.HandleLogMessageWithContext(context.Background(), &APIError{ErrorType: ErrorType_INFO, ErrorMessage: "info"})
+	}
+
+	warnings, infos := 0, 0
+	for _, msg := range next.messages {
+		switch msg.ErrorType {
+		case ErrorType_WARNING:
+			warnings++
+		case ErrorType_INFO:
+			infos++
+		}
+	}
+	if warnings != 5 {
+		t.Errorf("expected all 5 warnings with dedup disabled, got %d", warnings)
+	}
+	if infos != 3 {
+		t.Errorf("expected 3 infos with MaxPerWindow=3, got %d", infos)
+	}
+}
+
+type ctxKey struct{}
+
+// contextLogger records the context of every logged message
+type contextLogger struct {
+	recordingLogger
+	contexts []context.Context
+}
+
+func (cl *contextLogger) HandleLogMessageWithContext(ctx context.Context, ae *APIError) {
+	cl.recordingLogger.HandleLogMessageWithContext(ctx, ae)
+	cl.contexts = append(cl.contexts, ctx)
+}
+
+func TestDedupLoggerEviction(t *testing.T) {
+	now := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	next := &contextLogger{}
+	dl := NewDedupLogger(next, DedupConfig{Window: time.Minute})
+	dl.now = func() time.Time { return now }
+	dl.SetMaxEntries(2)
+
+	newErr := func(message string) *APIError {
+		return &APIError{ErrorType: ErrorType_ERROR, ErrorCode: 500, ErrorMessage: message}
+	}
+	requestCtx := context.WithValue(context.Background(), ctxKey{}, "request")
+
+	// a repeated error and one seen once
+	dl.HandleLogMessageWithContext(requestCtx, newErr("redis timeout"))
+	dl.HandleLogMessageWithContext(requestCtx, newErr("redis timeout"))
+	now = now.Add(time.Second)
+	dl.HandleLogMessageWithContext(requestCtx, newErr("product not found"))
+
+	// the third fingerprint evicts the least recently seen one, reporting its repeats
+	now = now.Add(time.Second)
+	dl.HandleLogMessageWithContext(requestCtx, newErr("invalid SKU"))
+	if _, ok := dl.Counter(newErr("redis timeout").Fingerprint()); ok {
+		t.Errorf("least recently seen fingerprint still tracked")
+	}
+	if len(dl.Counters()) != 2 {
+		t.Errorf("%d fingerprints tracked, want 2", len(dl.Counters()))
+	}
+	if len(next.messages) != 4 || !strings.Contains(next.messages[2].Message, "repeated 1 times") {
+		t.Fatalf("expected the summary of the evicted fingerprint, got %d messages", len(next.messages))
+	}
+	if next.contexts[2].Value(ctxKey{}) != nil {
+		t.Errorf("summary logged with the context of a request")
+	}
+
+	// Flush forgets the fingerprints idle for longer than their window
+	now = now.Add(time.Minute)
+	dl.Flush()
+	if len(dl.Counters()) != 0 {
+		t.Errorf("%d idle fingerprints still tracked after Flush", len(dl.Counters()))
+	}
+}
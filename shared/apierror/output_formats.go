@@ -1,16 +1,29 @@
 package apierror
 
-import "encoding/json"
+import (
+	"encoding/json"
 
-// GetExternalSafeAPIError returns a safe APIError object encoded in JSON
+	"github.com/ramseyjiang/go-micros/shared/srvlog/redact"
+)
+
+// GetExternalSafeAPIError returns a safe APIError object encoded in JSON,
+// sensitive data left in the messages is removed by redact.Default
 func (ae APIError) GetExternalSafeAPIError() *APIError {
 	newAE := ae
+	newAE.ErrorMessage = redact.String(ae.ErrorMessage)
+	newAE.Message = redact.String(ae.Message)
 	newAE.App = ""
 	newAE.SourceFile = ""
 	newAE.SourceFunc = ""
 	newAE.ErrorType = 0
 	newAE.OriginErrorMessage = ""
 	newAE.Stack = nil
+	newAE.Causes = nil
+	newAE.SpanID = ""
+	newAE.UserID = ""
+	newAE.Tenant = ""
+	newAE.RemoteMethod = ""
+	newAE.RemotePeer = ""
 	return &newAE
 }
 
@@ -0,0 +1,59 @@
+package apierror
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	ae := &APIError{
+		ErrorCode:          400,
+		ErrorMessage:       "card 4111 1111 1111 1111 declined",
+		Message:            "contact jane@example.com",
+		OriginErrorMessage: "auth failed: Bearer abc.def",
+		UserID:             "user-1",
+	}
+
+	tests := []struct {
+		name    string
+		output  func() string
+		leaked  []string
+		wantAll []string
+	}{
+		{
+			name:    "ExternalSafe",
+			output:  func() string { return ae.GetExternalSafeAPIError().GetJSONString() },
+			leaked:  []string{"4111", "jane@example.com", "Bearer"},
+			wantAll: []string{"[REDACTED:pan]", "[REDACTED:email]"},
+		},
+		{
+			name: "LogFields",
+			output: func() string {
+				fields := ae.GetMapStringInterface()
+				return fields["err_message"].(string) + " " + fields["message"].(string) + " " + fields["origin_err_message"].(string)
+			},
+			leaked:  []string{"4111", "jane@example.com", "abc.def"},
+			wantAll: []string{"[REDACTED:pan]", "[REDACTED:email]", "[REDACTED:bearer]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.output()
+			for _, leaked := range tt.leaked {
+				if strings.Contains(out, leaked) {
+					t.Errorf("output leaks %q: %s", leaked, out)
+				}
+			}
+			for _, want := range tt.wantAll {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q: %s", want, out)
+				}
+			}
+		})
+	}
+
+	if ae.ErrorMessage != "card 4111 1111 1111 1111 declined" {
+		t.Error("redaction must not change the original APIError")
+	}
+}
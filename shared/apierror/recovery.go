@@ -0,0 +1,167 @@
+package apierror
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// NewAPIErrorFromPanic converts a value returned by recover() into a 500 APIError.
+// It must be called from the deferred function that recovered, so that the
+// TraceFrames and source are taken from the panicking goroutine rather than
+// from the recovery code. The error is not logged, see RecoverPanic.
+// The client gets a generic message, the panic value is kept in OriginErrorMessage.
+func NewAPIErrorFromPanic(ctx context.Context, recovered interface{}) *APIError {
+	if recovered == nil {
+		return nil
+	}
+
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("%v", recovered)
+	}
+
+	resp := newAPIErrorNoLog(ctx, BackProcs+1, ErrorType_ERROR, err, http.StatusInternalServerError, "", "internal server error")
+	if resp == nil {
+		return nil
+	}
+	resp.ErrorCode = http.StatusInternalServerError
+	resp.OriginErrorMessage = fmt.Sprintf("panic: %v", recovered)
+	resp.applyPanicSource(panicCallers())
+
+	return resp
+}
+
+// RecoverPanic converts a value returned by recover() into a 500 APIError and
+// logs it through Logger. It must be called from the deferred function that recovered.
+func RecoverPanic(ctx context.Context, recovered interface{}) *APIError {
+	resp := NewAPIErrorFromPanic(ctx, recovered)
+	if resp == nil {
+		return nil
+	}
+
+	if Logger != nil {
+		Logger.HandleLogMessageWithContext(ctx, resp)
+	}
+
+	return resp
+}
+
+// panicCallers returns the program counters of the panicking goroutine,
+// starting at the frame which called panic (or faulted).
+func panicCallers() []uintptr {
+	pcs := make([]uintptr, 100)
+	pcs = pcs[:runtime.Callers(1, pcs)]
+
+	for i, pc := range pcs {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && fn.Name() == "runtime.gopanic" {
+			return pcs[i+1:]
+		}
+	}
+	return pcs
+}
+
+// applyPanicSource replaces the source and trace frames with the ones of the panic site
+func (ae *APIError) applyPanicSource(pcs []uintptr) {
+	if len(pcs) == 0 {
+		return
+	}
+
+	ae.originPCs = pcs
+	ae.traceFrames = filterFrames(extractFrames(pcs))
+
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		// runtime.panicmem, runtime.sigpanic etc. are not interesting
+		if !strings.HasPrefix(frame.Function, "runtime.") && frame.Function != "" {
+			ae.SourceFunc = stripPath(KeepNumDirs(frame.Function, NumPathsToLog))
+			ae.SourceFile = stripGitPath(frame.File)
+			if frame.Line > 0 && ae.SourceFile != "" {
+				ae.SourceFile = ae.SourceFile + ":" + strconv.Itoa(frame.Line)
+			}
+			if frame.Func != nil {
+				ae.tracePackage, ae.traceFunc = packageAndName(frame.Func)
+			} else {
+				ae.tracePackage, ae.traceFunc = splitQualifiedFunctionName(frame.Function)
+			}
+			ae.traceFile = frame.File
+			ae.traceLine = frame.Line
+			ae.tracePC = frame.PC
+			return
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// RecoveryUnaryServerInt should be passed to grpc.NewServer in your main
+// function so that a panicking handler returns a 500 APIError instead of
+// crashing the process.
+func RecoveryUnaryServerInt() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp = nil
+				err = RecoverPanic(ctx, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryUnaryInterceptor chains RecoveryUnaryServerInt after any interceptor
+// set with UnaryInterceptor, so it can be used alongside it.
+func RecoveryUnaryInterceptor() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(RecoveryUnaryServerInt())
+}
+
+// RecoveryStreamServerInt should be passed to grpc.NewServer in your main
+// function so that a panicking stream handler returns a 500 APIError instead
+// of crashing the process.
+func RecoveryStreamServerInt() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = RecoverPanic(ss.Context(), r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// RecoveryStreamInterceptor chains RecoveryStreamServerInt after any interceptor
+// set with StreamInterceptor, so it can be used alongside it.
+func RecoveryStreamInterceptor() grpc.ServerOption {
+	return grpc.ChainStreamInterceptor(RecoveryStreamServerInt())
+}
+
+// RecoveryHandler is the HTTP equivalent of RecoveryUnaryServerInt, a panicking
+// handler results in a 500 APIError response (see RequestErrorJSONAuto).
+// http.ErrAbortHandler is re-panicked as net/http expects.
+func RecoveryHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			ae := RecoverPanic(r.Context(), recovered)
+			code, body := ae.RequestErrorJSONAuto()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			_, _ = w.Write(body)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
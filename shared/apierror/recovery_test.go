@@ -0,0 +1,128 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type panickingProduct struct {
+	name string
+}
+
+func nilPointerHandler() (interface{}, error) {
+	var p *panickingProduct
+	return p.name, nil
+}
+
+func explicitPanicHandler() (interface{}, error) {
+	panic("stock went negative")
+}
+
+func assertPanicAPIError(t *testing.T, err error, wantFunc string) {
+	t.Helper()
+
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		t.Fatalf("expected APIError, got %T %v", err, err)
+	}
+	if ae.ErrorCode != http.StatusInternalServerError {
+		t.Errorf("ErrorCode = %d, want 500", ae.ErrorCode)
+	}
+	if ae.ErrorMessage != "internal server error" || !strings.HasPrefix(ae.OriginErrorMessage, "panic: ") {
+		t.Errorf("ErrorMessage = %q, OriginErrorMessage = %q, want the panic value in the origin message only", ae.ErrorMessage, ae.OriginErrorMessage)
+	}
+	if !strings.Contains(ae.SourceFunc, wantFunc) {
+		t.Errorf("SourceFunc = %q, want the panic site %q", ae.SourceFunc, wantFunc)
+	}
+
+	found := false
+	frames := ae.GetTraceFrames()
+	for i := range frames {
+		frame := &frames[i]
+		if strings.Contains(frame.Function, wantFunc) {
+			found = true
+		}
+		if frame.Module == "runtime" {
+			t.Errorf("unexpected runtime frame %+v", frame)
+		}
+	}
+	if !found {
+		t.Errorf("expected %s in TraceFrames, got %d frames", wantFunc, len(frames))
+	}
+}
+
+func TestRecoveryUnaryServerInt(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func() (interface{}, error)
+		wantFunc string
+	}{
+		{name: "NilPointer", handler: nilPointerHandler, wantFunc: "nilPointerHandler"},
+		{name: "ExplicitPanic", handler: explicitPanicHandler, wantFunc: "explicitPanicHandler"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return tt.handler()
+			}
+
+			resp, err := RecoveryUnaryServerInt()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Panic"}, handler)
+			if resp != nil {
+				t.Errorf("expected nil response, got %v", resp)
+			}
+			assertPanicAPIError(t, err, tt.wantFunc)
+
+			// the error conversion interceptor keeps it as an Internal error
+			_, err = UnaryServerInt()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Panic"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, err
+			})
+			if got := status.Code(err); got != codes.Internal {
+				t.Errorf("status code = %v, want %v", got, codes.Internal)
+			}
+		})
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *testServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func TestRecoveryStreamServerInt(t *testing.T) {
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		_, err := nilPointerHandler()
+		return err
+	}
+
+	err := RecoveryStreamServerInt()(nil, &testServerStream{}, &grpc.StreamServerInfo{FullMethod: "/test/PanicStream"}, handler)
+	assertPanicAPIError(t, err, "nilPointerHandler")
+}
+
+func TestRecoveryHandler(t *testing.T) {
+	handler := RecoveryHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = nilPointerHandler()
+	}))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/products", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("request %d: status = %d, want 500", i, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "internal server error") {
+			t.Errorf("request %d: expected APIError body, got %s", i, rec.Body.String())
+		}
+	}
+}
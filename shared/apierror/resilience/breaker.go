@@ -0,0 +1,92 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/apierror"
+)
+
+// ErrCircuitOpen is the cause of the APIError returned while the breaker of a target is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Breaker states
+const (
+	stateClosed = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// breaker opens after failures consecutive failures, rejecting the calls for openTimeout.
+// One call then probes the target (half open): a success closes the breaker, a failure opens it again.
+type breaker struct {
+	failures    int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mutex       sync.Mutex
+	state       int
+	consecutive int
+	openedAt    time.Time
+	probing     bool
+}
+
+// newBreaker returns a breaker, disabled when failures is 0
+func newBreaker(failures int, openTimeout time.Duration) *breaker {
+	return &breaker{failures: failures, openTimeout: openTimeout, now: time.Now}
+}
+
+// allow reports whether a call can be made
+func (b *breaker) allow() bool {
+	if b.failures <= 0 {
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return true
+	case stateHalfOpen:
+		// one probe at a time
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record records the result of an allowed call
+func (b *breaker) record(failure bool) {
+	if b.failures <= 0 {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+	if !failure {
+		b.state = stateClosed
+		b.consecutive = 0
+		return
+	}
+	b.consecutive++
+	if b.state == stateHalfOpen || b.consecutive >= b.failures {
+		b.state = stateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// openError is the fast Unavailable APIError returned instead of calling an unhealthy target
+func (c *client) openError(ctx context.Context, method string) error {
+	return apierror.NewAPIErrorWithContext(ctx, ErrCircuitOpen, http.StatusServiceUnavailable, "",
+		"%s is unavailable (%s), try again later", c.target, method)
+}
@@ -0,0 +1,67 @@
+package resilience
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+type hedgeResult struct {
+	reply       proto.Message
+	err         error
+	breakerOpen bool
+}
+
+// hedged sends another attempt each time Options.HedgeDelay passes without a response, or an
+// attempt fails with a retryable error, up to HedgeMaxAttempts. The first success is copied to
+// reply and the other attempts are cancelled. hedged is false when reply is not a proto.Message.
+func (c *client) hedged(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error, hedged bool) {
+	replyMessage, ok := reply.(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, c.opts.HedgeMaxAttempts)
+	launched := 0
+	launch := func() {
+		launched++
+		attemptReply := replyMessage.ProtoReflect().New().Interface()
+		go func() {
+			err, breakerOpen := c.attempt(ctx, method, req, attemptReply, cc, invoker, opts...)
+			results <- hedgeResult{reply: attemptReply, err: err, breakerOpen: breakerOpen}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(c.opts.HedgeDelay)
+	defer timer.Stop()
+	var lastErr error
+	for received := 0; received < launched; {
+		select {
+		case <-timer.C:
+			if launched < c.opts.HedgeMaxAttempts {
+				launch()
+				timer.Reset(c.opts.HedgeDelay)
+			}
+		case result := <-results:
+			received++
+			if result.err == nil {
+				proto.Reset(replyMessage)
+				proto.Merge(replyMessage, result.reply)
+				return nil, true
+			}
+			lastErr = result.err
+			if result.breakerOpen || !retryable(result.err) {
+				return lastErr, true
+			}
+			if launched < c.opts.HedgeMaxAttempts && ctx.Err() == nil {
+				launch()
+			}
+		}
+	}
+	return lastErr, true
+}
@@ -0,0 +1,178 @@
+// Package resilience protects the calls a service makes to another one: a default deadline,
+// retries with jittered backoff for the idempotent methods, a circuit breaker per target
+// failing fast with an Unavailable APIError, and hedged requests for the slow reads.
+package resilience
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options configures the resilience of the calls to one target. A zero value disables the feature.
+type Options struct {
+	// Timeout is the deadline of the calls made without one, retries and hedged attempts included
+	Timeout time.Duration
+
+	// RetryMethods are the full methods ("/package.Service/Method") retried, only list idempotent ones
+	RetryMethods []string
+	// RetryMaxAttempts is the number of attempts of a retried call, the first one included
+	RetryMaxAttempts int
+	// RetryInitialBackoff is the backoff before the first retry, doubled on each retry up to RetryMaxBackoff.
+	// Each backoff is jittered between half and all of it.
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+
+	// BreakerFailures is the number of consecutive failures (Unavailable, DeadlineExceeded,
+	// ResourceExhausted) opening the circuit breaker
+	BreakerFailures int
+	// BreakerOpenTimeout is how long the breaker stays open before letting one call probe the target
+	BreakerOpenTimeout time.Duration
+
+	// HedgeMethods are the full methods hedged: another attempt is sent when no response was
+	// received after HedgeDelay, up to HedgeMaxAttempts in flight, the first response wins
+	HedgeMethods     []string
+	HedgeDelay       time.Duration
+	HedgeMaxAttempts int
+}
+
+// DefaultOptions are the defaults of the viperconf grpc-client-* flags
+func DefaultOptions() Options {
+	return Options{
+		Timeout:             5 * time.Second,
+		RetryMaxAttempts:    3,
+		RetryInitialBackoff: 100 * time.Millisecond,
+		RetryMaxBackoff:     time.Second,
+		BreakerFailures:     5,
+		BreakerOpenTimeout:  30 * time.Second,
+		HedgeDelay:          100 * time.Millisecond,
+		HedgeMaxAttempts:    2,
+	}
+}
+
+// client applies the Options to the calls made to one target
+type client struct {
+	target  string
+	opts    Options
+	breaker *breaker
+	retry   map[string]bool
+	hedge   map[string]bool
+	// sleep waits for d unless ctx is done first, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newClient(target string, opts Options) *client {
+	c := &client{
+		target:  target,
+		opts:    opts,
+		breaker: newBreaker(opts.BreakerFailures, opts.BreakerOpenTimeout),
+		retry:   map[string]bool{},
+		hedge:   map[string]bool{},
+		sleep:   sleepContext,
+	}
+	for _, method := range opts.RetryMethods {
+		c.retry[strings.TrimSpace(method)] = true
+	}
+	for _, method := range opts.HedgeMethods {
+		c.hedge[strings.TrimSpace(method)] = true
+	}
+	return c
+}
+
+// UnaryClientInt applies opts to the unary calls made to target (used to name its circuit breaker),
+// create one per connection so that each target has its own breaker
+func UnaryClientInt(target string, opts Options) grpc.UnaryClientInterceptor {
+	return newClient(target, opts).intercept
+}
+
+// UnaryClientInterceptor chains UnaryClientInt
+func UnaryClientInterceptor(target string, opts Options) grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(UnaryClientInt(target, opts))
+}
+
+func (c *client) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline && c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+
+	if c.hedge[method] && c.opts.HedgeMaxAttempts > 1 {
+		if err, hedged := c.hedged(ctx, method, req, reply, cc, invoker, opts...); hedged {
+			return err
+		}
+	}
+
+	maxAttempts := 1
+	if c.retry[method] && c.opts.RetryMaxAttempts > 1 {
+		maxAttempts = c.opts.RetryMaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		err, breakerOpen := c.attempt(ctx, method, req, reply, cc, invoker, opts...)
+		if err == nil || breakerOpen || attempt >= maxAttempts || !retryable(err) {
+			return err
+		}
+		if c.sleep(ctx, c.backoff(attempt)) != nil {
+			return err
+		}
+	}
+}
+
+// attempt makes one call through the circuit breaker, breakerOpen is set when it was not made
+func (c *client) attempt(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error, breakerOpen bool) {
+	if !c.breaker.allow() {
+		return c.openError(ctx, method), true
+	}
+	err = invoker(ctx, method, req, reply, cc, opts...)
+	c.breaker.record(isFailure(err))
+	return err, false
+}
+
+// backoff returns the jittered backoff before the retry following attempt
+func (c *client) backoff(attempt int) time.Duration {
+	backoff := c.opts.RetryInitialBackoff
+	for i := 1; i < attempt && backoff < c.opts.RetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if c.opts.RetryMaxBackoff > 0 && backoff > c.opts.RetryMaxBackoff {
+		backoff = c.opts.RetryMaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryable reports whether another attempt may succeed
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// isFailure reports whether err shows the target is unhealthy, as opposed to a rejected request
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
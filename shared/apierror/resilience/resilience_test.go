@@ -0,0 +1,204 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	readMethod  = "/products.ProductService/GetProducts"
+	writeMethod = "/products.ProductService/CreateProduct"
+)
+
+// scriptedInvoker returns the errors of script in turn (then nil), counting the calls
+type scriptedInvoker struct {
+	mutex  sync.Mutex
+	script []error
+	calls  int
+}
+
+func (s *scriptedInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls++
+	if len(s.script) == 0 {
+		return nil
+	}
+	err := s.script[0]
+	s.script = s.script[1:]
+	return err
+}
+
+func newTestClient(opts Options) *client {
+	c := newClient("products", opts)
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+	return c
+}
+
+func TestRetry(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	tests := []struct {
+		name      string
+		method    string
+		script    []error
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{name: "RetriedUntilSuccess", method: readMethod, script: []error{unavailable, unavailable}, wantCalls: 3, wantCode: codes.OK},
+		{name: "GivesUpAfterMaxAttempts", method: readMethod, script: []error{unavailable, unavailable, unavailable, unavailable}, wantCalls: 3, wantCode: codes.Unavailable},
+		{name: "NotRetryableCode", method: readMethod, script: []error{status.Error(codes.NotFound, "no product")}, wantCalls: 1, wantCode: codes.NotFound},
+		{name: "NotIdempotent", method: writeMethod, script: []error{unavailable}, wantCalls: 1, wantCode: codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(Options{RetryMethods: []string{readMethod}, RetryMaxAttempts: 3, RetryInitialBackoff: time.Millisecond})
+			invoker := &scriptedInvoker{script: tt.script}
+			err := c.intercept(context.Background(), tt.method, nil, nil, nil, invoker.invoke)
+			if status.Code(err) != tt.wantCode || invoker.calls != tt.wantCalls {
+				t.Errorf("error %v after %d calls, want code %s after %d calls", err, invoker.calls, tt.wantCode, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := newTestClient(Options{RetryInitialBackoff: 100 * time.Millisecond, RetryMaxBackoff: 300 * time.Millisecond})
+	tests := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 2, min: 100 * time.Millisecond, max: 200 * time.Millisecond},
+		{attempt: 5, min: 150 * time.Millisecond, max: 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := c.backoff(tt.attempt); got < tt.min || got > tt.max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	c := newTestClient(Options{Timeout: time.Second})
+	tests := []struct {
+		name         string
+		ctx          func() (context.Context, context.CancelFunc)
+		wantDeadline time.Duration
+	}{
+		{name: "NoDeadline", ctx: func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) }, wantDeadline: time.Second},
+		{name: "CallerDeadlineKept", ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), time.Hour)
+		}, wantDeadline: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			err := c.intercept(ctx, readMethod, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				deadline, ok := ctx.Deadline()
+				if !ok || time.Until(deadline) > tt.wantDeadline || time.Until(deadline) < tt.wantDeadline-time.Minute/2 {
+					t.Errorf("deadline in %s, want about %s", time.Until(deadline), tt.wantDeadline)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	c := newTestClient(Options{BreakerFailures: 2, BreakerOpenTimeout: time.Minute})
+	c.breaker.now = func() time.Time { return now }
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	tests := []struct {
+		name        string
+		advance     time.Duration
+		script      []error
+		wantCalled  bool
+		wantCode    codes.Code
+		wantOpenErr bool
+	}{
+		{name: "FirstFailure", script: []error{unavailable}, wantCalled: true, wantCode: codes.Unavailable},
+		{name: "BusinessErrorsDoNotCount", script: []error{status.Error(codes.NotFound, "no product")}, wantCalled: true, wantCode: codes.NotFound},
+		{name: "SecondFailureOpens", script: []error{unavailable}, wantCalled: true, wantCode: codes.Unavailable},
+		{name: "ConsecutiveFailuresOpen", script: []error{unavailable}, wantCalled: true, wantCode: codes.Unavailable},
+		{name: "OpenFailsFast", wantCode: codes.Unavailable, wantOpenErr: true},
+		{name: "ProbeFailureReopens", advance: time.Minute, script: []error{unavailable}, wantCalled: true, wantCode: codes.Unavailable},
+		{name: "StillOpen", advance: time.Second, wantCode: codes.Unavailable, wantOpenErr: true},
+		{name: "ProbeSuccessCloses", advance: time.Minute, wantCalled: true, wantCode: codes.OK},
+		{name: "Closed", wantCalled: true, wantCode: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			invoker := &scriptedInvoker{script: tt.script}
+			err := c.intercept(context.Background(), writeMethod, nil, nil, nil, invoker.invoke)
+			if status.Code(err) != tt.wantCode || (invoker.calls > 0) != tt.wantCalled {
+				t.Errorf("error %v, called %v, want code %s, called %v", err, invoker.calls > 0, tt.wantCode, tt.wantCalled)
+			}
+			if errors.Is(err, ErrCircuitOpen) != tt.wantOpenErr {
+				t.Errorf("error %v, want the circuit open error %v", err, tt.wantOpenErr)
+			}
+		})
+	}
+}
+
+func TestHedging(t *testing.T) {
+	tests := []struct {
+		name      string
+		firstSlow bool
+		firstErr  error
+		wantCalls int32
+		wantValue string
+	}{
+		{name: "FastResponseNotHedged", wantCalls: 1, wantValue: "attempt 1"},
+		{name: "SlowResponseHedged", firstSlow: true, wantCalls: 2, wantValue: "attempt 2"},
+		{name: "RetryableFailureHedgedAtOnce", firstErr: status.Error(codes.Unavailable, "down"), wantCalls: 2, wantValue: "attempt 2"},
+		{name: "NotRetryableFailure", firstErr: status.Error(codes.InvalidArgument, "bad"), wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(Options{HedgeMethods: []string{readMethod}, HedgeDelay: 20 * time.Millisecond, HedgeMaxAttempts: 2})
+			var calls atomic.Int32
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempt := calls.Add(1)
+				if attempt == 1 {
+					if tt.firstErr != nil {
+						return tt.firstErr
+					}
+					if tt.firstSlow {
+						<-ctx.Done()
+						return status.FromContextError(ctx.Err()).Err()
+					}
+				}
+				reply.(*wrapperspb.StringValue).Value = "attempt " + string(rune('0'+attempt))
+				return nil
+			}
+
+			reply := &wrapperspb.StringValue{}
+			err := c.intercept(context.Background(), readMethod, nil, reply, nil, invoker)
+			if (err != nil) != (tt.wantValue == "") || reply.GetValue() != tt.wantValue {
+				t.Errorf("reply %q, error %v, want %q", reply.GetValue(), err, tt.wantValue)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d attempts, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
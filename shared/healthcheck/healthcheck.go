@@ -0,0 +1,200 @@
+// Package healthcheck reports the health of the services with the standard grpc.health.v1
+// service (Checker), from periodic dependency checks, and aggregates the health of the
+// backends of the gateway into the /healthz and /readyz HTTP endpoints (Aggregator).
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Default check interval and timeout, see Options
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultTimeout  = 2 * time.Second
+)
+
+// Check reports whether a dependency (Redis, a downstream service...) is usable, nil when it is
+type Check func(ctx context.Context) error
+
+// Options configures a Checker
+type Options struct {
+	// Interval between two runs of the checks, defaults to DefaultInterval
+	Interval time.Duration
+	// Timeout of each check, defaults to DefaultTimeout
+	Timeout time.Duration
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker serves grpc.health.v1: the server ("") and every registered service are SERVING
+// while all the checks pass, NOT_SERVING otherwise and once Shutdown is called.
+type Checker struct {
+	opts   Options
+	server *health.Server
+
+	mutex    sync.Mutex
+	checks   []namedCheck
+	services []string
+	results  map[string]error
+	checked  bool
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewChecker creates a Checker, NOT_SERVING until the checks have run (see Start)
+func NewChecker(opts Options) *Checker {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	c := &Checker{
+		opts:     opts,
+		server:   health.NewServer(),
+		services: []string{""},
+		results:  map[string]error{},
+		stop:     make(chan struct{}),
+	}
+	c.server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return c
+}
+
+// AddCheck adds a named dependency check, call it before Start
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Register registers the health service on grpcServer, reporting the status of every
+// service registered on it so far. Call it after registering the other services.
+func (c *Checker) Register(grpcServer *grpc.Server) {
+	healthpb.RegisterHealthServer(grpcServer, c.server)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for name := range grpcServer.GetServiceInfo() {
+		c.services = append(c.services, name)
+	}
+	sort.Strings(c.services)
+	status := c.statusLocked()
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+}
+
+// Server returns the grpc.health.v1 server
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// Start runs the checks now, then every Options.Interval until Shutdown
+func (c *Checker) Start() {
+	c.CheckNow(context.Background())
+	go func() {
+		ticker := time.NewTicker(c.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.CheckNow(context.Background())
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// CheckNow runs every check and updates the serving status, it returns the failed checks
+func (c *Checker) CheckNow(ctx context.Context) map[string]error {
+	c.mutex.Lock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mutex.Unlock()
+
+	results := make(map[string]error, len(checks))
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+		if err := check.check(checkCtx); err != nil {
+			results[check.name] = err
+		}
+		cancel()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for name, err := range results {
+		if previous := c.results[name]; previous == nil || previous.Error() != err.Error() {
+			srvlog.Warnf("Health check (%s) failed: %v", name, err)
+		}
+	}
+	for name := range c.results {
+		if _, failed := results[name]; !failed {
+			srvlog.Infof("Health check (%s) recovered", name)
+		}
+	}
+	c.results = results
+	c.checked = true
+	status := c.statusLocked()
+	// ignored by the health server once Shutdown was called
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, status)
+	}
+	return results
+}
+
+// Failures returns the failed checks of the last run
+func (c *Checker) Failures() map[string]error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	failures := make(map[string]error, len(c.results))
+	for name, err := range c.results {
+		failures[name] = err
+	}
+	return failures
+}
+
+// statusLocked must be called with mutex held
+func (c *Checker) statusLocked() healthpb.HealthCheckResponse_ServingStatus {
+	if !c.checked || len(c.results) > 0 {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+// Shutdown reports NOT_SERVING for good and stops the checks, call it when the graceful
+// shutdown starts so the callers stop sending requests
+func (c *Checker) Shutdown() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.server.Shutdown()
+	})
+}
+
+// GRPCCheck checks that the service (empty for the whole server) of a downstream
+// gRPC server is SERVING with grpc.health.v1
+func GRPCCheck(conn grpc.ClientConnInterface, service string) Check {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("status %s", resp.GetStatus())
+		}
+		return nil
+	}
+}
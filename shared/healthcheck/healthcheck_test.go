@@ -0,0 +1,145 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func serverStatus(t *testing.T, c *Checker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := c.Server().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q) error %v", service, err)
+	}
+	return resp.GetStatus()
+}
+
+func TestChecker(t *testing.T) {
+	var redisErr error
+	checker := NewChecker(Options{})
+	checker.AddCheck("redis", func(ctx context.Context) error { return redisErr })
+
+	grpcServer := grpc.NewServer()
+	reflection.Register(grpcServer)
+	checker.Register(grpcServer)
+	const service = "grpc.reflection.v1alpha.ServerReflection"
+
+	if got := serverStatus(t, checker, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status before the first checks %s, want NOT_SERVING", got)
+	}
+
+	tests := []struct {
+		name       string
+		redisErr   error
+		shutdown   bool
+		wantStatus healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{name: "DependenciesUp", wantStatus: healthpb.HealthCheckResponse_SERVING},
+		{name: "RedisDown", redisErr: errors.New("connection refused"), wantStatus: healthpb.HealthCheckResponse_NOT_SERVING},
+		{name: "RedisRecovered", wantStatus: healthpb.HealthCheckResponse_SERVING},
+		{name: "ShuttingDown", shutdown: true, wantStatus: healthpb.HealthCheckResponse_NOT_SERVING},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisErr = tt.redisErr
+			if tt.shutdown {
+				checker.Shutdown()
+			}
+			failures := checker.CheckNow(context.Background())
+			if (tt.redisErr != nil) != (failures["redis"] != nil) {
+				t.Errorf("failures %v, redis error %v", failures, tt.redisErr)
+			}
+			for _, name := range []string{"", service} {
+				if got := serverStatus(t, checker, name); got != tt.wantStatus {
+					t.Errorf("status of %q %s, want %s", name, got, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCCheckAndAggregator(t *testing.T) {
+	// a backend with its Checker, served over bufconn
+	listener := bufconn.Listen(1 << 20)
+	var dependencyErr error
+	checker := NewChecker(Options{})
+	checker.AddCheck("dependency", func(ctx context.Context) error { return dependencyErr })
+	grpcServer := grpc.NewServer()
+	checker.Register(grpcServer)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	aggregator := NewAggregator(0)
+	aggregator.AddBackend("products", healthpb.NewHealthClient(conn), "")
+
+	tests := []struct {
+		name          string
+		dependencyErr error
+		shuttingDown  bool
+		wantCheckErr  bool
+		wantReady     int
+		wantBackend   string
+	}{
+		{name: "Serving", wantReady: http.StatusOK, wantBackend: StatusServing},
+		{name: "DependencyDown", dependencyErr: errors.New("down"), wantCheckErr: true, wantReady: http.StatusServiceUnavailable, wantBackend: StatusNotServing},
+		{name: "GatewayShuttingDown", shuttingDown: true, wantReady: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dependencyErr = tt.dependencyErr
+			checker.CheckNow(context.Background())
+			if tt.shuttingDown {
+				aggregator.SetShuttingDown()
+				select {
+				case <-aggregator.ShuttingDown():
+				default:
+					t.Errorf("ShuttingDown() not closed by SetShuttingDown()")
+				}
+			}
+
+			if err := GRPCCheck(conn, "")(context.Background()); (err != nil) != tt.wantCheckErr {
+				t.Errorf("GRPCCheck() error %v, want error %v", err, tt.wantCheckErr)
+			}
+
+			recorder := httptest.NewRecorder()
+			aggregator.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			var resp HealthResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != tt.wantReady || resp.Backends["products"] != tt.wantBackend {
+				t.Errorf("/readyz %d %+v, want %d with products %q", recorder.Code, resp, tt.wantReady, tt.wantBackend)
+			}
+
+			recorder = httptest.NewRecorder()
+			aggregator.LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			resp = HealthResponse{}
+			if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != http.StatusOK || len(resp.Backends) != 0 {
+				t.Errorf("/healthz %d %+v, the gateway is alive without checking the backends", recorder.Code, resp)
+			}
+		})
+	}
+}
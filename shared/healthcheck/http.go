@@ -0,0 +1,133 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Statuses reported by the HTTP endpoints, next to the grpc.health.v1 ones of the backends
+const (
+	StatusServing    = "SERVING"
+	StatusNotServing = "NOT_SERVING"
+)
+
+type backend struct {
+	name    string
+	client  healthpb.HealthClient
+	service string
+}
+
+// Aggregator serves the /healthz (liveness) and /readyz (readiness) endpoints of the
+// gateway from the grpc.health.v1 status of its backends
+type Aggregator struct {
+	timeout      time.Duration
+	mutex        sync.RWMutex
+	backends     []backend
+	shuttingDown atomic.Bool
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// HealthResponse is the JSON body of the endpoints
+type HealthResponse struct {
+	Status   string            `json:"status"`
+	Backends map[string]string `json:"backends,omitempty"`
+}
+
+// NewAggregator creates an Aggregator, each backend check times out after timeout (DefaultTimeout when 0)
+func NewAggregator(timeout time.Duration) *Aggregator {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Aggregator{timeout: timeout, shutdown: make(chan struct{})}
+}
+
+// AddBackend adds a backend checked with client, service is empty for the whole server
+func (a *Aggregator) AddBackend(name string, client healthpb.HealthClient, service string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.backends = append(a.backends, backend{name: name, client: client, service: service})
+}
+
+// SetShuttingDown makes the readiness NOT_SERVING, call it when the graceful shutdown starts
+func (a *Aggregator) SetShuttingDown() {
+	a.shuttingDown.Store(true)
+	a.shutdownOnce.Do(func() { close(a.shutdown) })
+}
+
+// ShuttingDown is closed by SetShuttingDown, the long lived responses (e.g. event streams)
+// end on it so the clients reconnect to another instance
+func (a *Aggregator) ShuttingDown() <-chan struct{} {
+	return a.shutdown
+}
+
+// Check returns the status of every backend, checked concurrently, and whether they are all SERVING
+func (a *Aggregator) Check(ctx context.Context) (map[string]string, bool) {
+	a.mutex.RLock()
+	backends := append([]backend(nil), a.backends...)
+	a.mutex.RUnlock()
+
+	statuses := make([]string, len(backends))
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		wg.Add(1)
+		go func(i int, b backend) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, a.timeout)
+			defer cancel()
+			resp, err := b.client.Check(checkCtx, &healthpb.HealthCheckRequest{Service: b.service})
+			if err != nil {
+				statuses[i] = healthpb.HealthCheckResponse_UNKNOWN.String()
+				return
+			}
+			statuses[i] = resp.GetStatus().String()
+		}(i, b)
+	}
+	wg.Wait()
+
+	result := make(map[string]string, len(backends))
+	allServing := true
+	for i, b := range backends {
+		result[b.name] = statuses[i]
+		allServing = allServing && statuses[i] == StatusServing
+	}
+	return result, allServing
+}
+
+// LivenessHandler serves /healthz: the gateway is alive as long as it answers. The backends
+// are not checked, a slow or failing backend must not restart the gateway (see ReadinessHandler).
+func (a *Aggregator) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, HealthResponse{Status: StatusServing})
+	})
+}
+
+// ReadinessHandler serves /readyz: 200 when every backend is SERVING, 503 otherwise and
+// once the graceful shutdown started
+func (a *Aggregator) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.shuttingDown.Load() {
+			writeHealth(w, http.StatusServiceUnavailable, HealthResponse{Status: StatusNotServing})
+			return
+		}
+		backends, ready := a.Check(r.Context())
+		if !ready {
+			writeHealth(w, http.StatusServiceUnavailable, HealthResponse{Status: StatusNotServing, Backends: backends})
+			return
+		}
+		writeHealth(w, http.StatusOK, HealthResponse{Status: StatusServing, Backends: backends})
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, resp HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
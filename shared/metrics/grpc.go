@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// gRPCMetrics are the request count, latency and in flight metrics of one side (server or client)
+type gRPCMetrics struct {
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newGRPCMetrics(side string) *gRPCMetrics {
+	return &gRPCMetrics{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_" + side + "_handled_total",
+			Help: "gRPC calls completed by the " + side + ", by method and code.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_" + side + "_handling_seconds",
+			Help:    "Latency of the gRPC calls completed by the " + side + ", by method and code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_" + side + "_in_flight",
+			Help: "gRPC calls in progress on the " + side + ", by method.",
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+}
+
+var (
+	serverMetrics = newGRPCMetrics("server")
+	clientMetrics = newGRPCMetrics("client")
+)
+
+func registerGRPCMetrics() {
+	for _, m := range []*gRPCMetrics{serverMetrics, clientMetrics} {
+		Registry.MustRegister(m.handled, m.duration, m.inFlight)
+	}
+}
+
+// splitMethod splits "/package.Service/Method" into its service and method
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+// start counts a call in flight, the returned func records its completion
+func (m *gRPCMetrics) start(fullMethod string) func(err error) {
+	service, method := splitMethod(fullMethod)
+	start := time.Now()
+	inFlight := m.inFlight.WithLabelValues(service, method)
+	inFlight.Inc()
+	return func(err error) {
+		inFlight.Dec()
+		code := status.Code(err).String()
+		m.handled.WithLabelValues(service, method, code).Inc()
+		m.duration.WithLabelValues(service, method, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// UnaryServerInt records the metrics of the unary calls served, by method and code
+func UnaryServerInt() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		done := serverMetrics.start(info.FullMethod)
+		resp, err := handler(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+// UnaryServerInterceptor chains UnaryServerInt, put it first so the code is the one sent to the caller.
+// An interceptor set with grpc.UnaryInterceptor always runs before the chained ones, chain the
+// interceptors converting the errors (e.g. apierror.UnaryServerInt) after this one instead.
+func UnaryServerInterceptor() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(UnaryServerInt())
+}
+
+// StreamServerInt is the streaming equivalent of UnaryServerInt, a stream completes when its handler returns
+func StreamServerInt() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		done := serverMetrics.start(info.FullMethod)
+		err := handler(srv, ss)
+		done(err)
+		return err
+	}
+}
+
+// StreamServerInterceptor chains StreamServerInt, see UnaryServerInterceptor
+func StreamServerInterceptor() grpc.ServerOption {
+	return grpc.ChainStreamInterceptor(StreamServerInt())
+}
+
+// UnaryClientInt records the metrics of the unary calls made, by method and code
+func UnaryClientInt() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done := clientMetrics.start(method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		done(err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor chains UnaryClientInt
+func UnaryClientInterceptor() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(UnaryClientInt())
+}
+
+// StreamClientInt records the metrics of the streams opened, a stream completes once it is
+// opened (the messages are not followed)
+func StreamClientInt() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		done := clientMetrics.start(method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		done(err)
+		return stream, err
+	}
+}
+
+// StreamClientInterceptor chains StreamClientInt
+func StreamClientInterceptor() grpc.DialOption {
+	return grpc.WithChainStreamInterceptor(StreamClientInt())
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UnmatchedRoute labels the requests no route was set for (see SetRoute), e.g. 404s and rate limited requests
+const UnmatchedRoute = "unmatched"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests completed, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of the HTTP requests, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests in progress.",
+	})
+)
+
+func registerHTTPMetrics() {
+	Registry.MustRegister(httpRequests, httpDuration, httpInFlight)
+}
+
+type routeKey struct{}
+
+// SetRoute sets the route (path pattern) the metrics of the request of ctx are labelled with.
+// The router calls it once the request is matched, the path itself is never used as a label.
+func SetRoute(ctx context.Context, route string) {
+	if holder, ok := ctx.Value(routeKey{}).(*atomic.Value); ok {
+		holder.Store(route)
+	}
+}
+
+// statusRecorder keeps the status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps streamed responses working
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// HTTPMiddleware records the count, latency and in flight HTTP requests, by method, route and code
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		route := &atomic.Value{}
+		route.Store(UnmatchedRoute)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+
+		if recorder.code == 0 {
+			recorder.code = http.StatusOK
+		}
+		routeName := route.Load().(string)
+		httpRequests.WithLabelValues(r.Method, routeName, strconv.Itoa(recorder.code)).Inc()
+		httpDuration.WithLabelValues(r.Method, routeName).Observe(time.Since(start).Seconds())
+	})
+}
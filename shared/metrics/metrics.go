@@ -0,0 +1,101 @@
+// Package metrics exposes the Prometheus metrics of the services on /metrics: gRPC server and
+// client calls, gateway HTTP requests, rate limiter decisions, Redis commands and the business
+// counters (sales, revenue, products). Everything is registered with Registry.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path the metrics are served on
+const Path = "/metrics"
+
+// Registry holds every metric of this package, plus the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+// Decisions of the rate limiter, see RateLimitDecisions
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+var (
+	// RateLimitDecisions counts the requests allowed or denied by the gateway rate limiter, per policy
+	RateLimitDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimit_requests_total",
+		Help: "Requests allowed or denied by the rate limiter, by policy.",
+	}, []string{"policy", "decision"})
+
+	// RedisCommandDuration observes the latency of the Redis commands (a pipeline is "pipeline")
+	RedisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Latency of the Redis commands, by command and result.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "result"})
+
+	// SalesCreated counts the sales created by the trade service
+	SalesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sales_created_total",
+		Help: "Sales created.",
+	})
+
+	// SalesRevenue adds up the total price of the sales created
+	SalesRevenue = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sales_revenue_total",
+		Help: "Sum of the total price of the sales created.",
+	})
+
+	// ProductsCreated counts the products created by the products service
+	ProductsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "products_created_total",
+		Help: "Products created.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RateLimitDecisions,
+		RedisCommandDuration,
+		SalesCreated,
+		SalesRevenue,
+		ProductsCreated,
+	)
+	registerGRPCMetrics()
+	registerHTTPMetrics()
+}
+
+// Handler serves the metrics of Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveRedisCommand records the latency of a Redis command, a missing key is not an error
+func ObserveRedisCommand(command string, start time.Time, failed bool) {
+	result := "ok"
+	if failed {
+		result = "error"
+	}
+	RedisCommandDuration.WithLabelValues(command, result).Observe(time.Since(start).Seconds())
+}
+
+// ListenAndServe serves Handler on Path at addr (the admin address of the service) in the
+// background. The returned server is closed to stop it, errors other than a close are logged
+// with logf.
+func ListenAndServe(addr string, logf func(format string, args ...interface{})) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed && logf != nil {
+			logf("Failed to serve the metrics on %s: %v", addr, err)
+		}
+	}()
+	return server
+}
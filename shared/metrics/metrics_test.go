@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		route     string
+		status    int
+		wantRoute string
+		wantCode  string
+	}{
+		{name: "MatchedRoute", route: "/v1/sales", status: http.StatusOK, wantRoute: "/v1/sales", wantCode: "200"},
+		{name: "ImplicitOK", route: "/v1/products", wantRoute: "/v1/products", wantCode: "200"},
+		{name: "Unmatched", status: http.StatusNotFound, wantRoute: UnmatchedRoute, wantCode: "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.route != "" {
+					SetRoute(r.Context(), tt.route)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte("ok"))
+			}))
+			counter := httpRequests.WithLabelValues(http.MethodPost, tt.wantRoute, tt.wantCode)
+			before := testutil.ToFloat64(counter)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/sales/123", nil))
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("http_requests_total{route=%q,code=%q} increased by %v, want 1", tt.wantRoute, tt.wantCode, got)
+			}
+			if got := testutil.ToFloat64(httpInFlight); got != 0 {
+				t.Errorf("http_requests_in_flight = %v after the request", got)
+			}
+		})
+	}
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "OK", wantCode: codes.OK},
+		{name: "NotFound", err: status.Error(codes.NotFound, "product not found"), wantCode: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const fullMethod = "/trade.SalesService/CreateSale"
+			server := serverMetrics.handled.WithLabelValues("trade.SalesService", "CreateSale", tt.wantCode.String())
+			client := clientMetrics.handled.WithLabelValues("trade.SalesService", "CreateSale", tt.wantCode.String())
+			serverBefore, clientBefore := testutil.ToFloat64(server), testutil.ToFloat64(client)
+
+			serverInt := UnaryServerInt()
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				_, err := serverInt(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+					if got := testutil.ToFloat64(serverMetrics.inFlight.WithLabelValues("trade.SalesService", "CreateSale")); got != 1 {
+						t.Errorf("grpc_server_in_flight = %v during the call, want 1", got)
+					}
+					return nil, tt.err
+				})
+				return err
+			}
+			err := UnaryClientInt()(context.Background(), fullMethod, nil, nil, nil, invoker)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("error %v, want code %s", err, tt.wantCode)
+			}
+			if testutil.ToFloat64(server)-serverBefore != 1 || testutil.ToFloat64(client)-clientBefore != 1 {
+				t.Errorf("server and client calls with code %s should be counted once", tt.wantCode)
+			}
+			if got := testutil.ToFloat64(serverMetrics.inFlight.WithLabelValues("trade.SalesService", "CreateSale")); got != 0 {
+				t.Errorf("grpc_server_in_flight = %v after the call", got)
+			}
+		})
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	tests := []struct {
+		fullMethod  string
+		wantService string
+		wantMethod  string
+	}{
+		{fullMethod: "/products.ProductService/GetProducts", wantService: "products.ProductService", wantMethod: "GetProducts"},
+		{fullMethod: "GetProducts", wantService: "unknown", wantMethod: "GetProducts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fullMethod, func(t *testing.T) {
+			service, method := splitMethod(tt.fullMethod)
+			if service != tt.wantService || method != tt.wantMethod {
+				t.Errorf("splitMethod() = %q, %q, want %q, %q", service, method, tt.wantService, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	SalesCreated.Inc()
+	SalesRevenue.Add(25)
+	RateLimitDecisions.WithLabelValues("default", DecisionDeny).Inc()
+	ObserveRedisCommand("hgetall", time.Now(), false)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, Path, nil))
+	body, _ := io.ReadAll(recorder.Body)
+	for _, want := range []string{
+		"sales_created_total",
+		"sales_revenue_total",
+		`ratelimit_requests_total{decision="deny",policy="default"}`,
+		`redis_command_duration_seconds_count{command="hgetall",result="ok"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("%s is missing from the metrics", want)
+		}
+	}
+}
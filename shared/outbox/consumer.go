@@ -0,0 +1,229 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"google.golang.org/protobuf/proto"
+)
+
+// Handler handles an event, an error leaves it pending so it is delivered again. An event may
+// also be delivered again after it was handled, handlers must be idempotent.
+type Handler func(ctx context.Context, event *CloudEvent) error
+
+// ConsumerOptions configures a Consumer
+type ConsumerOptions struct {
+	// Stream the events are read from, see RelayOptions.Stream
+	Stream string
+	// Group shares the events between its consumers, each event is handled by one of them
+	Group string
+	// Consumer is the unique name of this consumer in the group (e.g. the hostname)
+	Consumer string
+	// Count is the maximum number of events read at once, defaults to 10
+	Count int64
+	// Block is how long a read waits for events before checking ctx, defaults to 5s
+	Block time.Duration
+	// ClaimMinIdle is how long an event stays pending (its consumer died or its handler
+	// failed) before another consumer takes it over, defaults to 1m
+	ClaimMinIdle time.Duration
+	// Backoff is how long Run waits after a handler failed, defaults to 1s
+	Backoff time.Duration
+}
+
+// Consumer reads the events of a stream in a consumer group
+type Consumer struct {
+	client *redis.Client
+	opts   ConsumerOptions
+}
+
+// NewConsumer creates a Consumer, start it with Run
+func NewConsumer(client *redis.Client, opts ConsumerOptions) *Consumer {
+	if opts.Count <= 0 {
+		opts.Count = 10
+	}
+	if opts.Block <= 0 {
+		opts.Block = 5 * time.Second
+	}
+	if opts.ClaimMinIdle <= 0 {
+		opts.ClaimMinIdle = time.Minute
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	return &Consumer{client: client, opts: opts}
+}
+
+// Run handles the events until ctx is done: first, once, the events still pending for this consumer
+// (e.g. after a restart), then the new ones, taking over the events idle for ClaimMinIdle. The events
+// whose handler failed are left pending to be taken over, and Run waits Backoff before reading again.
+// The group is created, from the start of the stream, if it does not exist.
+func (c *Consumer) Run(ctx context.Context, handler Handler) error {
+	if err := c.client.XGroupCreateMkStream(ctx, c.opts.Stream, c.opts.Group, "0").Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	readID := "0"
+	lastClaim := time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= c.opts.ClaimMinIdle {
+			lastClaim = time.Now()
+			failed, err := c.claimIdle(ctx, handler)
+			if err != nil {
+				c.pause(ctx, err)
+				continue
+			}
+			if failed {
+				c.wait(ctx, c.opts.Backoff)
+			}
+		}
+
+		block := c.opts.Block
+		if readID != ">" {
+			// the pending events are returned at once
+			block = -1
+		}
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.opts.Group,
+			Consumer: c.opts.Consumer,
+			Streams:  []string{c.opts.Stream, readID},
+			Count:    c.opts.Count,
+			Block:    block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			c.pause(ctx, err)
+			continue
+		}
+
+		handled, failed := 0, false
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				if !c.handle(ctx, handler, message) {
+					failed = true
+				}
+				handled++
+				if readID != ">" {
+					// the failed pending events are not read again, they are left to claimIdle
+					readID = message.ID
+				}
+			}
+		}
+		if readID != ">" && handled == 0 {
+			// no pending events left, read the new ones
+			readID = ">"
+		}
+		if failed {
+			c.wait(ctx, c.opts.Backoff)
+		}
+	}
+	return nil
+}
+
+// HandlePending handles the events pending and new at the time of the call, then returns
+func (c *Consumer) HandlePending(ctx context.Context, handler Handler) error {
+	if err := c.client.XGroupCreateMkStream(ctx, c.opts.Stream, c.opts.Group, "0").Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	for _, readID := range []string{"0", ">"} {
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.opts.Group,
+			Consumer: c.opts.Consumer,
+			Streams:  []string{c.opts.Stream, readID},
+			Count:    c.opts.Count,
+			Block:    -1,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				c.handle(ctx, handler, message)
+			}
+		}
+	}
+	return nil
+}
+
+// claimIdle takes over and handles the events idle for ClaimMinIdle, reporting whether a handler failed
+func (c *Consumer) claimIdle(ctx context.Context, handler Handler) (bool, error) {
+	failed := false
+	start := "0-0"
+	for {
+		messages, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.opts.Stream,
+			Group:    c.opts.Group,
+			Consumer: c.opts.Consumer,
+			MinIdle:  c.opts.ClaimMinIdle,
+			Start:    start,
+			Count:    c.opts.Count,
+		}).Result()
+		if err != nil {
+			return failed, err
+		}
+		for _, message := range messages {
+			if !c.handle(ctx, handler, message) {
+				failed = true
+			}
+		}
+		if next == "0-0" || len(messages) == 0 {
+			return failed, nil
+		}
+		start = next
+	}
+}
+
+// handle handles one stream entry, acknowledging it unless the handler failed, and reports whether it did
+func (c *Consumer) handle(ctx context.Context, handler Handler, message redis.XMessage) bool {
+	payload, _ := message.Values[FieldEvent].(string)
+	var event CloudEvent
+	if err := proto.Unmarshal([]byte(payload), &event); err != nil {
+		// never handleable, acknowledged so it is not delivered again
+		srvlog.Errorf("outbox: dropping undecodable entry (%s) of %s: %v", message.ID, c.opts.Stream, err)
+		c.ack(ctx, message.ID)
+		return true
+	}
+
+	eventCtx := ContextWithEvent(ctx, &event)
+	if requestID := event.GetExtensions()[ExtensionRequestID]; requestID != "" {
+		eventCtx = srvlog.ContextWithField(eventCtx, srvlog.FieldRequestID, requestID)
+	}
+	if err := handler(eventCtx, &event); err != nil {
+		srvlog.WithContext(eventCtx).Warnf("outbox: event (%s) %s of %s left pending: %v", event.GetId(), event.GetType(), c.opts.Stream, err)
+		return false
+	}
+	c.ack(ctx, message.ID)
+	return true
+}
+
+func (c *Consumer) ack(ctx context.Context, id string) {
+	if err := c.client.XAck(ctx, c.opts.Stream, c.opts.Group, id).Err(); err != nil {
+		srvlog.Warnf("outbox: unable to acknowledge entry (%s) of %s: %v", id, c.opts.Stream, err)
+	}
+}
+
+func (c *Consumer) pause(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	srvlog.Warnf("outbox: unable to read %s as %s/%s: %v", c.opts.Stream, c.opts.Group, c.opts.Consumer, err)
+	c.wait(ctx, time.Second)
+}
+
+// wait waits for d, or until ctx is done
+func (c *Consumer) wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,4 @@
+#!/bin/bash
+
+protoc --go_out=. --go_opt=paths=source_relative \
+       ./outbox.proto
@@ -0,0 +1,22 @@
+module github.com/ramseyjiang/go-micros/shared/outbox
+
+go 1.21.4
+
+require (
+	github.com/alicebob/miniredis/v2 v2.31.0
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/ramseyjiang/go-micros/shared/srvlog v0.0.0-20231203094911-a5b7f010a421
+	go.opentelemetry.io/otel v1.21.0
+	go.opentelemetry.io/otel/trace v1.21.0
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/konsorten/go-windows-terminal-sequences v1.0.3 // indirect
+	github.com/sirupsen/logrus v1.9.3 // indirect
+	github.com/yuin/gopher-lua v1.1.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
@@ -0,0 +1,90 @@
+// Package outbox publishes domain events with a transactional outbox: the event, a CloudEvent
+// wrapping a protobuf message, is queued (Enqueue) in the same Redis transaction as the state
+// change, then a Relay moves it to a Redis Stream. The delivery is at least once: an event is
+// delivered again when its relay or consumer stops before acknowledging it, so the handlers of
+// a Consumer must be idempotent (e.g. skip the event IDs already applied).
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CloudEvents attribute values
+const (
+	SpecVersion     = "1.0"
+	DataContentType = "application/protobuf"
+)
+
+// Extension attributes added by NewEvent
+const (
+	ExtensionTraceParent = "traceparent"
+	ExtensionRequestID   = "requestid"
+)
+
+// NewEvent wraps data in a CloudEvent from source about subject. The trace and the request ID
+// of ctx are added as extensions, so the consumers can continue them.
+func NewEvent(ctx context.Context, source, subject string, data proto.Message) (*CloudEvent, error) {
+	anyData, err := anypb.New(data)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: unable to wrap the event data: %w", err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("outbox: unable to generate the event ID: %w", err)
+	}
+
+	extensions := map[string]string{}
+	propagation.TraceContext{}.Inject(ctx, propagation.MapCarrier(extensions))
+	if requestID := srvlog.RequestIDFromContext(ctx); requestID != "" {
+		extensions[ExtensionRequestID] = requestID
+	}
+
+	return &CloudEvent{
+		Id:              hex.EncodeToString(id),
+		Source:          source,
+		SpecVersion:     SpecVersion,
+		Type:            string(data.ProtoReflect().Descriptor().FullName()),
+		Subject:         subject,
+		Time:            timestamppb.New(time.Now()),
+		DataContentType: DataContentType,
+		DataSchema:      anyData.GetTypeUrl(),
+		Data:            anyData,
+		Extensions:      extensions,
+	}, nil
+}
+
+// Enqueue queues event in the outbox list, call it on the transaction pipeline (see
+// redis.Client.TxPipelined) storing the state change, so both are written or neither is
+func Enqueue(ctx context.Context, pipe redis.Pipeliner, outboxKey string, event *CloudEvent) error {
+	payload, err := proto.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("outbox: unable to encode event (%s): %w", event.GetType(), err)
+	}
+	// the relay pops from the right, oldest first
+	pipe.LPush(ctx, outboxKey, payload)
+	return nil
+}
+
+// UnpackData returns the protobuf message of event
+func UnpackData(event *CloudEvent) (proto.Message, error) {
+	if event.GetData() == nil {
+		return nil, fmt.Errorf("outbox: event (%s) has no data", event.GetId())
+	}
+	return event.GetData().UnmarshalNew()
+}
+
+// ContextWithEvent returns ctx carrying the trace of event, see NewEvent
+func ContextWithEvent(ctx context.Context, event *CloudEvent) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier(event.GetExtensions()))
+}
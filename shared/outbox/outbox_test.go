@@ -0,0 +1,273 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	testOutbox = "test:outbox"
+	testStream = "events:test"
+)
+
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// enqueue writes a state change and its event in one transaction, as the services do
+func enqueue(t *testing.T, ctx context.Context, client *redis.Client, subject string) *CloudEvent {
+	t.Helper()
+	event, err := NewEvent(ctx, "test", subject, wrapperspb.String(subject))
+	if err != nil {
+		t.Fatalf("NewEvent() error %v", err)
+	}
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "state:"+subject, subject, 0)
+		return Enqueue(ctx, pipe, testOutbox, event)
+	}); err != nil {
+		t.Fatalf("TxPipelined() error %v", err)
+	}
+	return event
+}
+
+func TestNewEvent(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+	traced = srvlog.ContextWithField(traced, srvlog.FieldRequestID, "req-1")
+
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		wantExtensions map[string]string
+	}{
+		{
+			name:           "no trace nor request ID",
+			ctx:            context.Background(),
+			wantExtensions: map[string]string{},
+		},
+		{
+			name: "trace and request ID",
+			ctx:  traced,
+			wantExtensions: map[string]string{
+				ExtensionTraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+				ExtensionRequestID:   "req-1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := NewEvent(tt.ctx, "products", "p1", wrapperspb.String("p1"))
+			if err != nil {
+				t.Fatalf("NewEvent() error %v", err)
+			}
+			if event.GetId() == "" || event.GetSpecVersion() != SpecVersion || event.GetDataContentType() != DataContentType {
+				t.Errorf("NewEvent() missing required attributes: %v", event)
+			}
+			if event.GetType() != "google.protobuf.StringValue" || event.GetDataSchema() != "type.googleapis.com/google.protobuf.StringValue" {
+				t.Errorf("NewEvent() type %q schema %q", event.GetType(), event.GetDataSchema())
+			}
+			if len(event.GetExtensions()) != len(tt.wantExtensions) {
+				t.Errorf("NewEvent() extensions %v, want %v", event.GetExtensions(), tt.wantExtensions)
+			}
+			for k, v := range tt.wantExtensions {
+				if event.GetExtensions()[k] != v {
+					t.Errorf("NewEvent() extension %s = %q, want %q", k, event.GetExtensions()[k], v)
+				}
+			}
+			data, err := UnpackData(event)
+			if err != nil || data.(*wrapperspb.StringValue).GetValue() != "p1" {
+				t.Errorf("UnpackData() = %v, %v", data, err)
+			}
+			if got := trace.SpanContextFromContext(ContextWithEvent(context.Background(), event)).TraceID(); tt.ctx == traced && got != traceID {
+				t.Errorf("ContextWithEvent() trace %s, want %s", got, traceID)
+			}
+		})
+	}
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes in order", func(t *testing.T) {
+		client := newTestRedis(t)
+		first, second := enqueue(t, ctx, client, "a"), enqueue(t, ctx, client, "b")
+		if err := NewRelay(client, RelayOptions{Outbox: testOutbox, Stream: testStream}).RelayPending(ctx); err != nil {
+			t.Fatalf("RelayPending() error %v", err)
+		}
+		entries := client.XRange(ctx, testStream, "-", "+").Val()
+		if len(entries) != 2 || entries[0].Values[FieldEventID] != first.GetId() || entries[1].Values[FieldEventID] != second.GetId() {
+			t.Fatalf("stream entries %v, want %s then %s", entries, first.GetId(), second.GetId())
+		}
+		if entries[0].Values[FieldSubject] != "a" || entries[0].Values[FieldType] != first.GetType() {
+			t.Errorf("stream entry fields %v", entries[0].Values)
+		}
+		if n := client.LLen(ctx, testOutbox).Val() + client.LLen(ctx, testOutbox+":processing").Val(); n != 0 {
+			t.Errorf("%d events left after relaying", n)
+		}
+	})
+
+	t.Run("republishes the events of a stopped relay", func(t *testing.T) {
+		client := newTestRedis(t)
+		event := enqueue(t, ctx, client, "a")
+		// a relay stopped between taking the event and publishing it
+		client.RPopLPush(ctx, testOutbox, testOutbox+":processing")
+		if err := NewRelay(client, RelayOptions{Outbox: testOutbox, Stream: testStream}).RelayPending(ctx); err != nil {
+			t.Fatalf("RelayPending() error %v", err)
+		}
+		entries := client.XRange(ctx, testStream, "-", "+").Val()
+		if len(entries) != 1 || entries[0].Values[FieldEventID] != event.GetId() {
+			t.Errorf("stream entries %v, want %s", entries, event.GetId())
+		}
+	})
+
+	t.Run("run until cancelled", func(t *testing.T) {
+		client := newTestRedis(t)
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			NewRelay(client, RelayOptions{Outbox: testOutbox, Stream: testStream, Block: 50 * time.Millisecond}).Run(runCtx)
+			close(done)
+		}()
+		enqueue(t, ctx, client, "a")
+		deadline := time.Now().Add(5 * time.Second)
+		for client.XLen(ctx, testStream).Val() == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		<-done
+		if n := client.XLen(ctx, testStream).Val(); n != 1 {
+			t.Errorf("stream length %d, want 1", n)
+		}
+	})
+}
+
+func TestConsumer(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+	for _, subject := range []string{"a", "b", "c"} {
+		enqueue(t, ctx, client, subject)
+	}
+	if err := NewRelay(client, RelayOptions{Outbox: testOutbox, Stream: testStream}).RelayPending(ctx); err != nil {
+		t.Fatalf("RelayPending() error %v", err)
+	}
+	client.XAdd(ctx, &redis.XAddArgs{Stream: testStream, Values: map[string]interface{}{FieldEvent: "not an event"}})
+
+	var handled []string
+	failing := map[string]bool{"b": true}
+	handler := func(ctx context.Context, event *CloudEvent) error {
+		if failing[event.GetSubject()] {
+			return errors.New("temporary failure")
+		}
+		data, err := UnpackData(event)
+		if err != nil {
+			return err
+		}
+		handled = append(handled, data.(*wrapperspb.StringValue).GetValue())
+		return nil
+	}
+	consumer := NewConsumer(client, ConsumerOptions{Stream: testStream, Group: "test", Consumer: "c1"})
+
+	tests := []struct {
+		name        string
+		failing     map[string]bool
+		wantHandled []string
+		wantPending int64
+	}{
+		{name: "failed events stay pending", failing: map[string]bool{"b": true}, wantHandled: []string{"a", "c"}, wantPending: 1},
+		{name: "pending events are delivered again", failing: map[string]bool{}, wantHandled: []string{"b"}, wantPending: 0},
+		{name: "acknowledged events are not", failing: map[string]bool{}, wantHandled: nil, wantPending: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled, failing = nil, tt.failing
+			if err := consumer.HandlePending(ctx, handler); err != nil {
+				t.Fatalf("HandlePending() error %v", err)
+			}
+			if len(handled) != len(tt.wantHandled) {
+				t.Fatalf("handled %v, want %v", handled, tt.wantHandled)
+			}
+			for i := range handled {
+				if handled[i] != tt.wantHandled[i] {
+					t.Errorf("handled %v, want %v", handled, tt.wantHandled)
+				}
+			}
+			pending, err := client.XPending(ctx, testStream, "test").Result()
+			if err != nil {
+				t.Fatalf("XPending() error %v", err)
+			}
+			if pending.Count != tt.wantPending {
+				t.Errorf("%d pending events, want %d", pending.Count, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestConsumerRunFailingHandler(t *testing.T) {
+	ctx := context.Background()
+	client := newTestRedis(t)
+	relay := NewRelay(client, RelayOptions{Outbox: testOutbox, Stream: testStream})
+	for _, subject := range []string{"a", "b"} {
+		enqueue(t, ctx, client, subject)
+	}
+	if err := relay.RelayPending(ctx); err != nil {
+		t.Fatalf("RelayPending() error %v", err)
+	}
+
+	var mutex sync.Mutex
+	calls := map[string]int{}
+	handler := func(ctx context.Context, event *CloudEvent) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls[event.GetSubject()]++
+		return errors.New("permanent failure")
+	}
+	consumer := NewConsumer(client, ConsumerOptions{
+		Stream: testStream, Group: "test", Consumer: "c1",
+		Block: 10 * time.Millisecond, ClaimMinIdle: time.Hour, Backoff: 20 * time.Millisecond,
+	})
+	// the events are left pending for this consumer, as before a restart
+	if err := consumer.HandlePending(ctx, handler); err != nil {
+		t.Fatalf("HandlePending() error %v", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- consumer.Run(runCtx, handler) }()
+	time.Sleep(100 * time.Millisecond)
+	// a new event is read although the pending ones keep failing
+	enqueue(t, ctx, client, "c")
+	if err := relay.RelayPending(ctx); err != nil {
+		t.Fatalf("RelayPending() error %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// once by HandlePending and once replayed by Run, then left to claimIdle
+	want := map[string]int{"a": 2, "b": 2, "c": 1}
+	for subject, n := range want {
+		if calls[subject] != n {
+			t.Errorf("event %s handled %d times, want %d (calls %v)", subject, calls[subject], n, calls)
+		}
+	}
+}
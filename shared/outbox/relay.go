@@ -0,0 +1,152 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"google.golang.org/protobuf/proto"
+)
+
+// Fields of the stream entries written by the Relay
+const (
+	FieldEventID = "id"
+	FieldType    = "type"
+	FieldSubject = "subject"
+	FieldEvent   = "event"
+)
+
+// RelayOptions configures a Relay
+type RelayOptions struct {
+	// Outbox is the list the events are queued in (see Enqueue)
+	Outbox string
+	// Stream is the Redis Stream the events are published to
+	Stream string
+	// MaxLen approximately caps the stream length, defaults to 100000
+	MaxLen int64
+	// Block is how long the relay waits for an event before checking ctx, defaults to 5s
+	Block time.Duration
+	// RetryBackoff is the pause after a Redis error, defaults to 1s
+	RetryBackoff time.Duration
+}
+
+// Relay moves the outbox events to the stream. An event is moved to a processing list before
+// being published and removed once published, so a relay stopped in between publishes it again
+// when restarted: at least once delivery.
+type Relay struct {
+	client *redis.Client
+	opts   RelayOptions
+}
+
+// NewRelay creates a Relay, start it with Run
+func NewRelay(client *redis.Client, opts RelayOptions) *Relay {
+	if opts.MaxLen <= 0 {
+		opts.MaxLen = 100000
+	}
+	if opts.Block <= 0 {
+		opts.Block = 5 * time.Second
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = time.Second
+	}
+	return &Relay{client: client, opts: opts}
+}
+
+// processingKey holds the events being published
+func (r *Relay) processingKey() string {
+	return r.opts.Outbox + ":processing"
+}
+
+// Run publishes the outbox events until ctx is done, starting with the events a previous
+// run did not finish publishing
+func (r *Relay) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := r.publishProcessing(ctx); err != nil {
+			r.pause(ctx, err)
+			continue
+		}
+		payload, err := r.client.BRPopLPush(ctx, r.opts.Outbox, r.processingKey(), r.opts.Block).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			r.pause(ctx, err)
+			continue
+		}
+		if err := r.publish(ctx, payload); err != nil {
+			r.pause(ctx, err)
+		}
+	}
+}
+
+// RelayPending publishes every queued event and returns, e.g. from a test or a one off job
+func (r *Relay) RelayPending(ctx context.Context) error {
+	if err := r.publishProcessing(ctx); err != nil {
+		return err
+	}
+	for {
+		payload, err := r.client.RPopLPush(ctx, r.opts.Outbox, r.processingKey()).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.publish(ctx, payload); err != nil {
+			return err
+		}
+	}
+}
+
+// publishProcessing publishes the events left in the processing list, oldest first
+func (r *Relay) publishProcessing(ctx context.Context) error {
+	payloads, err := r.client.LRange(ctx, r.processingKey(), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for i := len(payloads) - 1; i >= 0; i-- {
+		if err := r.publish(ctx, payloads[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publish adds the event to the stream then removes it from the processing list
+func (r *Relay) publish(ctx context.Context, payload string) error {
+	var event CloudEvent
+	if err := proto.Unmarshal([]byte(payload), &event); err != nil {
+		// never publishable, dropping it unblocks the outbox
+		srvlog.Errorf("outbox: dropping an undecodable event from %s: %v", r.opts.Outbox, err)
+		return r.client.LRem(ctx, r.processingKey(), 1, payload).Err()
+	}
+	if err := r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.opts.Stream,
+		MaxLen: r.opts.MaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			FieldEventID: event.GetId(),
+			FieldType:    event.GetType(),
+			FieldSubject: event.GetSubject(),
+			FieldEvent:   payload,
+		},
+	}).Err(); err != nil {
+		return err
+	}
+	return r.client.LRem(ctx, r.processingKey(), 1, payload).Err()
+}
+
+func (r *Relay) pause(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	srvlog.Warnf("outbox: unable to relay the events of %s to %s: %v", r.opts.Outbox, r.opts.Stream, err)
+	timer := time.NewTimer(r.opts.RetryBackoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,49 @@
+package srvlog
+
+import (
+	"context"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"github.com/sirupsen/logrus"
+)
+
+// Field names used for the request scoped fields
+const (
+	FieldRequestID = "request_id"
+	FieldTraceID   = "trace_id"
+	FieldSpanID    = "span_id"
+	FieldUserID    = "user_id"
+	FieldTenant    = "tenant"
+)
+
+// ContextWithFields returns a copy of ctx carrying fields, every entry logged
+// for that context (see FromContext and WithContext) will include them
+func ContextWithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return logruswrapper.ContextWithFields(ctx, fields)
+}
+
+// ContextWithField returns a copy of ctx carrying a single field
+func ContextWithField(ctx context.Context, key string, value interface{}) context.Context {
+	return logruswrapper.ContextWithFields(ctx, logrus.Fields{key: value})
+}
+
+// FieldsFromContext returns a copy of the fields attached to ctx
+func FieldsFromContext(ctx context.Context) logrus.Fields {
+	fields := logruswrapper.FieldsFromContext(ctx)
+	resp := make(logrus.Fields, len(fields))
+	for k, v := range fields {
+		resp[k] = v
+	}
+	return resp
+}
+
+// RequestIDFromContext returns the request (correlation) ID attached to ctx
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := logruswrapper.FieldsFromContext(ctx)[FieldRequestID].(string)
+	return requestID
+}
+
+// FromContext returns an entry of the GlobalLogger with all the fields attached to ctx
+func FromContext(ctx context.Context) *logrus.Entry {
+	return GlobalLogger.WithContext(ctx).WithFields(logruswrapper.FieldsFromContext(ctx))
+}
@@ -2,9 +2,13 @@ package srvlog
 
 import (
 	"context"
+	"log/slog"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/rotatefile"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,14 +30,45 @@ func SetSyslogHost(host string) {
 	GlobalLogger.SetSyslogHost(host)
 }
 
+// SetLogFile sets a rotating log file as the output of the GlobalLogger
+func SetLogFile(opts rotatefile.Options) error {
+	return GlobalLogger.SetLogFile(opts)
+}
+
+// AddSink adds (or replaces) a sink on the GlobalLogger, see logruswrapper.Sink
+func AddSink(sink logruswrapper.Sink) error {
+	return GlobalLogger.AddSink(sink)
+}
+
+// RemoveSink removes the named sink from the GlobalLogger
+func RemoveSink(name string) bool {
+	return GlobalLogger.RemoveSink(name)
+}
+
+// SetSampling samples the entries of the GlobalLogger below error level, nil disables sampling
+func SetSampling(opts *logruswrapper.SamplingOptions) {
+	GlobalLogger.SetSampling(opts)
+}
+
+// SetPackageLevel overrides the GlobalLogger level for the entries logged from pkg, see
+// logruswrapper.PackageLevel. Only entries with caller fields (the global helpers) are matched.
+func SetPackageLevel(pkg string, level slog.Level, ttl time.Duration) error {
+	return GlobalLogger.SetPackageLevel(pkg, level, ttl)
+}
+
+// ClearPackageLevel removes the GlobalLogger level override of pkg
+func ClearPackageLevel(pkg string) bool {
+	return GlobalLogger.ClearPackageLevel(pkg)
+}
+
 // SetDebugMode sets the syslog host on the GlobalLogger
 func SetDebugMode(debugMode bool) {
 	GlobalLogger.SetDebugMode(debugMode)
 }
 
-// Add a context to the log entry.
+// Add a context to the log entry, including the fields attached to it (see ContextWithFields).
 func WithContext(ctx context.Context) *logrus.Entry {
-	return GlobalLogger.WithContext(ctx)
+	return FromContext(ctx)
 }
 
 // Tracef is a global helper / convenience function for accessing the GlobalLogger object
@@ -63,6 +98,11 @@ func GetCallerFields() logrus.Fields {
 		OriginFunc = runtimeFuncPtr.Name()
 	}
 
+	// the package level overrides match the full package path (see SetPackageLevel)
+	if OriginFunc != "" {
+		resp[logruswrapper.FieldOriginPackage] = logruswrapper.FuncPackage(OriginFunc)
+	}
+
 	OriginFunc = KeepNumDirs(OriginFunc, NumPathsToLog)
 	OriginFile = KeepNumDirs(OriginFile, NumPathsToLog)
 
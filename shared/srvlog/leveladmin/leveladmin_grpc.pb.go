@@ -0,0 +1,150 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: leveladmin.proto
+
+package leveladmin
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	LogLevelService_GetLogLevels_FullMethodName = "/leveladmin.LogLevelService/GetLogLevels"
+	LogLevelService_SetLogLevel_FullMethodName  = "/leveladmin.LogLevelService/SetLogLevel"
+)
+
+// LogLevelServiceClient is the client API for LogLevelService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LogLevelServiceClient interface {
+	// Gets the logger level and the package level overrides
+	GetLogLevels(ctx context.Context, in *GetLogLevelsRequest, opts ...grpc.CallOption) (*LogLevels, error)
+	// Sets the logger level, or the level of a package, optionally reverted after a TTL
+	SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevels, error)
+}
+
+type logLevelServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLogLevelServiceClient(cc grpc.ClientConnInterface) LogLevelServiceClient {
+	return &logLevelServiceClient{cc}
+}
+
+func (c *logLevelServiceClient) GetLogLevels(ctx context.Context, in *GetLogLevelsRequest, opts ...grpc.CallOption) (*LogLevels, error) {
+	out := new(LogLevels)
+	err := c.cc.Invoke(ctx, LogLevelService_GetLogLevels_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *logLevelServiceClient) SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevels, error) {
+	out := new(LogLevels)
+	err := c.cc.Invoke(ctx, LogLevelService_SetLogLevel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LogLevelServiceServer is the server API for LogLevelService service.
+// All implementations must embed UnimplementedLogLevelServiceServer
+// for forward compatibility
+type LogLevelServiceServer interface {
+	// Gets the logger level and the package level overrides
+	GetLogLevels(context.Context, *GetLogLevelsRequest) (*LogLevels, error)
+	// Sets the logger level, or the level of a package, optionally reverted after a TTL
+	SetLogLevel(context.Context, *SetLogLevelRequest) (*LogLevels, error)
+	mustEmbedUnimplementedLogLevelServiceServer()
+}
+
+// UnimplementedLogLevelServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedLogLevelServiceServer struct {
+}
+
+func (UnimplementedLogLevelServiceServer) GetLogLevels(context.Context, *GetLogLevelsRequest) (*LogLevels, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogLevels not implemented")
+}
+func (UnimplementedLogLevelServiceServer) SetLogLevel(context.Context, *SetLogLevelRequest) (*LogLevels, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
+func (UnimplementedLogLevelServiceServer) mustEmbedUnimplementedLogLevelServiceServer() {}
+
+// UnsafeLogLevelServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LogLevelServiceServer will
+// result in compilation errors.
+type UnsafeLogLevelServiceServer interface {
+	mustEmbedUnimplementedLogLevelServiceServer()
+}
+
+func RegisterLogLevelServiceServer(s grpc.ServiceRegistrar, srv LogLevelServiceServer) {
+	s.RegisterService(&LogLevelService_ServiceDesc, srv)
+}
+
+func _LogLevelService_GetLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogLevelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogLevelServiceServer).GetLogLevels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogLevelService_GetLogLevels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogLevelServiceServer).GetLogLevels(ctx, req.(*GetLogLevelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LogLevelService_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogLevelServiceServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogLevelService_SetLogLevel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogLevelServiceServer).SetLogLevel(ctx, req.(*SetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LogLevelService_ServiceDesc is the grpc.ServiceDesc for LogLevelService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LogLevelService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "leveladmin.LogLevelService",
+	HandlerType: (*LogLevelServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLogLevels",
+			Handler:    _LogLevelService_GetLogLevels_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _LogLevelService_SetLogLevel_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "leveladmin.proto",
+}
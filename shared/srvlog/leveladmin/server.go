@@ -0,0 +1,226 @@
+// Package leveladmin gets and sets the srvlog levels of a running service, over gRPC
+// (LogLevelService) and HTTP. Package levels (see logruswrapper.SetPackageLevel) turn on
+// DEBUG for one package only, and every change can be reverted after a TTL.
+package leveladmin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ErrInvalidRequest is returned for an unknown level or a missing package/level
+var ErrInvalidRequest = errors.New("invalid log level request")
+
+// Server implements LogLevelService and the HTTP admin handler
+type Server struct {
+	UnimplementedLogLevelServiceServer
+
+	logger *logruswrapper.LogrusWrapper
+
+	mu sync.Mutex
+	// the pending revert of the logger level, revertID tells a replaced revert apart
+	revert       *time.Timer
+	revertID     int
+	revertTo     slog.Level
+	levelExpires time.Time
+}
+
+// NewServer returns a Server changing the levels of logger, srvlog.GlobalLogger if nil
+func NewServer(logger *logruswrapper.LogrusWrapper) *Server {
+	if logger == nil {
+		logger = srvlog.GlobalLogger
+	}
+	return &Server{logger: logger}
+}
+
+// GetLogLevels implements LogLevelService
+func (s *Server) GetLogLevels(ctx context.Context, req *GetLogLevelsRequest) (*LogLevels, error) {
+	return s.levels(), nil
+}
+
+// SetLogLevel implements LogLevelService
+func (s *Server) SetLogLevel(ctx context.Context, req *SetLogLevelRequest) (*LogLevels, error) {
+	var ttl time.Duration
+	if req.GetTtl() != nil {
+		ttl = req.GetTtl().AsDuration()
+	}
+	if err := s.Set(req.GetPackage(), req.GetLevel(), ttl); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.levels(), nil
+}
+
+// Set sets the logger level (empty pkg) or the level of pkg, an empty level clears the
+// level of pkg. A positive ttl reverts the change after it.
+func (s *Server) Set(pkg string, levelName string, ttl time.Duration) error {
+	if levelName == "" {
+		if pkg == "" {
+			return fmt.Errorf("%w: level missing", ErrInvalidRequest)
+		}
+		s.logger.ClearPackageLevel(pkg)
+		srvlog.Infof("Log level of package (%s) cleared", pkg)
+		return nil
+	}
+	level, err := logruswrapper.ParseLevel(levelName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	if pkg != "" {
+		if err := s.logger.SetPackageLevel(pkg, level, ttl); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+		srvlog.Infof("Log level of package (%s) set to %s (ttl %s)", pkg, levelName, ttl)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revert != nil {
+		// a pending revert goes back to the level before the first change
+		s.revert.Stop()
+		s.revert = nil
+	} else {
+		s.revertTo = logruswrapper.SlogLevel(s.logger.Level())
+	}
+	s.logger.SetLevel(logruswrapper.LogrusLevel(level))
+	s.levelExpires = time.Time{}
+	if ttl > 0 {
+		s.revertID++
+		revertID := s.revertID
+		s.revert = time.AfterFunc(ttl, func() { s.revertLevel(revertID) })
+		s.levelExpires = time.Now().Add(ttl)
+	}
+	srvlog.Infof("Log level set to %s (ttl %s)", levelName, ttl)
+	return nil
+}
+
+// revertLevel puts the logger level back, unless the revert was replaced by a later change
+func (s *Server) revertLevel(revertID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revert == nil || s.revertID != revertID {
+		return
+	}
+	s.revert = nil
+	s.levelExpires = time.Time{}
+	s.logger.SetLevel(logruswrapper.LogrusLevel(s.revertTo))
+	srvlog.Infof("Log level reverted to %s", logruswrapper.LogrusLevel(s.revertTo))
+}
+
+func (s *Server) levels() *LogLevels {
+	levels := &LogLevels{Level: s.logger.Level().String()}
+	s.mu.Lock()
+	if !s.levelExpires.IsZero() {
+		levels.Expires = timestamppb.New(s.levelExpires)
+	}
+	s.mu.Unlock()
+	for _, override := range s.logger.PackageLevels() {
+		pkgLevel := &PackageLevel{
+			Package: override.Package,
+			Level:   logruswrapper.LogrusLevel(override.Level).String(),
+		}
+		if !override.Expires.IsZero() {
+			pkgLevel.Expires = timestamppb.New(override.Expires)
+		}
+		levels.Packages = append(levels.Packages, pkgLevel)
+	}
+	return levels
+}
+
+// httpSetRequest is the body of a PUT/POST request, e.g. {"package":"trade/internal/repos","level":"debug","ttl":"15m"}
+type httpSetRequest struct {
+	Package string `json:"package"`
+	Level   string `json:"level"`
+	TTL     string `json:"ttl"`
+}
+
+type httpPackageLevel struct {
+	Package string     `json:"package"`
+	Level   string     `json:"level"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+type httpLevels struct {
+	Level    string             `json:"level"`
+	Expires  *time.Time         `json:"expires,omitempty"`
+	Packages []httpPackageLevel `json:"packages"`
+}
+
+// ServeHTTP gets the levels (GET), sets a level (PUT or POST, see httpSetRequest) or
+// clears a package level (DELETE ?package=). Mount it on an admin port only.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req httpSetRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				writeHTTPError(w, http.StatusBadRequest, "invalid ttl: "+err.Error())
+				return
+			}
+		}
+		if err := s.Set(req.Package, req.Level, ttl); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case http.MethodDelete:
+		pkg := r.URL.Query().Get("package")
+		if pkg == "" {
+			writeHTTPError(w, http.StatusBadRequest, "package missing")
+			return
+		}
+		if err := s.Set(pkg, "", 0); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toHTTPLevels(s.levels()))
+}
+
+func toHTTPLevels(levels *LogLevels) httpLevels {
+	out := httpLevels{Level: levels.GetLevel(), Packages: []httpPackageLevel{}}
+	if levels.GetExpires() != nil {
+		expires := levels.GetExpires().AsTime()
+		out.Expires = &expires
+	}
+	for _, pkgLevel := range levels.GetPackages() {
+		level := httpPackageLevel{Package: pkgLevel.GetPackage(), Level: pkgLevel.GetLevel()}
+		if pkgLevel.GetExpires() != nil {
+			expires := pkgLevel.GetExpires().AsTime()
+			level.Expires = &expires
+		}
+		out.Packages = append(out.Packages, level)
+	}
+	return out
+}
+
+func writeHTTPError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -0,0 +1,124 @@
+package leveladmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	out := srvlog.GlobalLogger.Output()
+	srvlog.GlobalLogger.SetOutput(buf)
+	t.Cleanup(func() {
+		srvlog.GlobalLogger.SetOutput(out)
+		srvlog.GlobalLogger.SetLevel(logrus.InfoLevel)
+		for _, override := range srvlog.GlobalLogger.PackageLevels() {
+			srvlog.ClearPackageLevel(override.Package)
+		}
+	})
+	return buf
+}
+
+func TestHTTPPackageLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		pkg       string
+		wantDebug bool
+	}{
+		{name: "ThisPackage", pkg: "srvlog/leveladmin", wantDebug: true},
+		{name: "ParentPackage", pkg: "go-micros/shared", wantDebug: true},
+		{name: "OtherPackage", pkg: "trade/internal/repos", wantDebug: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			handler := NewServer(nil)
+
+			body := `{"package":"` + tt.pkg + `","level":"debug","ttl":"1h"}`
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/loglevels", strings.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			var levels httpLevels
+			if err := json.Unmarshal(rec.Body.Bytes(), &levels); err != nil {
+				t.Fatal(err)
+			}
+			if levels.Level != "info" || len(levels.Packages) != 1 || levels.Packages[0].Level != "debug" || levels.Packages[0].Expires == nil {
+				t.Fatalf("unexpected levels %+v", levels)
+			}
+
+			srvlog.Debugf("repository cache miss")
+			if got := strings.Contains(logs.String(), "repository cache miss"); got != tt.wantDebug {
+				t.Errorf("debug line logged %v, want %v:\n%s", got, tt.wantDebug, logs.String())
+			}
+
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/loglevels?package="+tt.pkg, nil))
+			if rec.Code != http.StatusOK || len(srvlog.GlobalLogger.PackageLevels()) != 0 {
+				t.Errorf("DELETE status %d, levels %v", rec.Code, srvlog.GlobalLogger.PackageLevels())
+			}
+		})
+	}
+}
+
+func TestTTLReverts(t *testing.T) {
+	captureLogs(t)
+	server := NewServer(nil)
+
+	if err := server.Set("srvlog/leveladmin", "trace", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.SetLogLevel(context.Background(), &SetLogLevelRequest{Level: "debug", Ttl: durationpb.New(20 * time.Millisecond)}); err != nil {
+		t.Fatal(err)
+	}
+	if srvlog.GlobalLogger.Level() != logrus.DebugLevel {
+		t.Fatalf("level %s, want debug", srvlog.GlobalLogger.Level())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		levels, _ := server.GetLogLevels(context.Background(), &GetLogLevelsRequest{})
+		if levels.GetLevel() == "info" && len(levels.GetPackages()) == 0 && levels.GetExpires() == nil {
+			if srvlog.GlobalLogger.GetLevel() != logrus.InfoLevel {
+				t.Errorf("logrus level %s should be back to info", srvlog.GlobalLogger.GetLevel())
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("levels were not reverted after their ttl")
+}
+
+func TestInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *SetLogLevelRequest
+	}{
+		{name: "UnknownLevel", req: &SetLogLevelRequest{Package: "trade", Level: "loud"}},
+		{name: "LoggerLevelMissing", req: &SetLogLevelRequest{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewServer(nil).SetLogLevel(context.Background(), tt.req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
@@ -33,6 +33,12 @@ func (ecdh contextDataHook) Fire(e *logrus.Entry) error {
 	} else if ecdh.data != nil && ecdh.data.ServiceName != "" {
 		e.Data["app"] = ecdh.data.ServiceName
 	}
+	// request scoped fields, fields set directly on the entry win
+	for k, v := range FieldsFromContext(e.Context) {
+		if _, exists := e.Data[k]; !exists {
+			e.Data[k] = v
+		}
+	}
 	if e.Logger.GetLevel() != logrus.DebugLevel {
 		delete(e.Data, "origin_file")
 		delete(e.Data, "origin_line")
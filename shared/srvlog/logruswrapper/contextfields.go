@@ -0,0 +1,37 @@
+package logruswrapper
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type contextFieldsKey struct{}
+
+// ContextWithFields returns a copy of ctx carrying fields, merged with (and
+// overriding) any fields already on ctx. Entries logged with WithContext(ctx)
+// receive these fields.
+func ContextWithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	existing := FieldsFromContext(ctx)
+	merged := make(logrus.Fields, len(existing)+len(fields))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, contextFieldsKey{}, merged)
+}
+
+// FieldsFromContext returns the fields set with ContextWithFields. The returned
+// map must not be modified.
+func FieldsFromContext(ctx context.Context) logrus.Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(contextFieldsKey{}).(logrus.Fields)
+	return fields
+}
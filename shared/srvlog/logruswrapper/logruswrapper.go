@@ -1,19 +1,40 @@
 package logruswrapper
 
 import (
+	"io"
+	"log/slog"
 	"os"
 	"runtime"
-	"strings"
+	"sync"
+	"time"
 
-	srslog "github.com/RackSec/srslog"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/rotatefile"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/syslogwriter"
 	"github.com/sirupsen/logrus"
 )
 
-// LogrusWrapper wraps a logrus wrapper with our own context and Syslog information
+// LogrusWrapper wraps a logrus wrapper with our own context and Syslog information.
+// Entries are written by a slog.Handler once the logrus hooks have fired, which fans
+// them out to the sinks (see AddSink) unless replaced by SetHandler.
 type LogrusWrapper struct {
 	*logrus.Logger
 	contextData logContextData
 	SyslogHost  string
+	// SyslogOptions are used by SetSyslogHost
+	SyslogOptions syslogwriter.Options
+
+	syslogWriter *syslogwriter.Writer
+	logFile      *rotatefile.Writer
+
+	handlerMutex sync.RWMutex
+	handler      slog.Handler
+	sinks        []Sink
+	level        slog.LevelVar
+	// formatChosen is set once SetStructuredLogging is called, SetSyslogHost then keeps the format
+	formatChosen bool
+	sampler      *Sampler
+	// packageLevels are the SetPackageLevel overrides, by package
+	packageLevels map[string]*packageLevel
 }
 
 // NewLogrusWrapper initializes the standard logger
@@ -21,8 +42,17 @@ func NewLogrusWrapper() *LogrusWrapper {
 	newLogrusLogger := logrus.New()
 	newLogger := LogrusWrapper{
 		Logger: newLogrusLogger,
+		sinks: []Sink{{
+			Name:      DefaultSinkName,
+			Writer:    newLogrusLogger.Out,
+			Format:    SinkFormatText,
+			UseColour: useColour(newLogrusLogger.Out),
+		}},
 	}
-	newLogger.SetStructuredLogging(false)
+	// logrus only runs the hooks, the slog handler writes the output
+	newLogrusLogger.Out = io.Discard
+	newLogrusLogger.Formatter = &slogBridgeFormatter{wrapper: &newLogger}
+	newLogger.rebuildHandler()
 	// newLogger.ReportCaller = true
 	newLogger.AddHook(contextDataHook{data: &newLogger.contextData})
 
@@ -44,25 +74,89 @@ func (l *LogrusWrapper) SetDebugMode(debugMode bool) {
 	}
 }
 
+// SetStructuredLogging selects the IQJSONHandler (structured) or IQTextHandler for the default sink
 func (l *LogrusWrapper) SetStructuredLogging(structured bool) {
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	l.formatChosen = true
+	l.setStructured(structured)
+}
+
+// setStructured must be called with handlerMutex held
+func (l *LogrusWrapper) setStructured(structured bool) {
+	sink := l.defaultSink()
 	if structured {
-		// l.Formatter = &logrus.JSONFormatter{
-		// 	TimestampFormat: time.RFC3339Nano,
-		// }
-		l.Formatter = &IQJSONFormatter{}
-		return
+		sink.Format = SinkFormatJSON
+	} else {
+		sink.Format = SinkFormatText
 	}
-	l.Formatter = &IQTextFormatter{
-		UseColour: checkIfTerminal(l.Logger.Out) && (runtime.GOOS != "windows"),
+	l.rebuildHandler()
+}
+
+// SetOutput sets the writer of the default sink
+func (l *LogrusWrapper) SetOutput(output io.Writer) {
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	sink := l.defaultSink()
+	sink.Writer = output
+	sink.UseColour = useColour(output)
+	l.rebuildHandler()
+}
+
+// Output returns the writer of the default sink
+func (l *LogrusWrapper) Output() io.Writer {
+	l.handlerMutex.RLock()
+	defer l.handlerMutex.RUnlock()
+	for _, sink := range l.sinks {
+		if sink.Name == DefaultSinkName {
+			return sink.Writer
+		}
 	}
+	return nil
 }
 
-func (l *LogrusWrapper) SetSyslogHost(newhost string) {
-	if newhost != "" && strings.Index(newhost, ":") == -1 {
-		// make sure we have a (UDP) port in the host definition
-		newhost = newhost + ":514"
+// defaultSink returns the default sink, adding it back (to StdErr) if it was removed.
+// It must be called with handlerMutex held.
+func (l *LogrusWrapper) defaultSink() *Sink {
+	for i := range l.sinks {
+		if l.sinks[i].Name == DefaultSinkName {
+			return &l.sinks[i]
+		}
 	}
+	l.sinks = append([]Sink{{
+		Name:      DefaultSinkName,
+		Writer:    os.Stderr,
+		Format:    SinkFormatText,
+		UseColour: useColour(os.Stderr),
+	}}, l.sinks...)
+	return &l.sinks[0]
+}
+
+func useColour(w io.Writer) bool {
+	return checkIfTerminal(w) && (runtime.GOOS != "windows")
+}
+
+// SetHandler replaces the slog handler every entry is written with,
+// changing the sinks (SetOutput, AddSink...) goes back to the sink handlers
+func (l *LogrusWrapper) SetHandler(handler slog.Handler) {
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	l.handler = handler
+}
+
+// Handler returns the slog handler every entry is written with
+func (l *LogrusWrapper) Handler() slog.Handler {
+	l.handlerMutex.RLock()
+	defer l.handlerMutex.RUnlock()
+	return l.handler
+}
 
+// SetSyslogHost sends the output of the default sink to syslog. newhost is a udp://, tcp://
+// or tcp+tls:// URL (see syslogwriter.ParseURL), a plain host[:port] uses UDP.
+// An empty host sends the log output back to StdErr.
+// Unless SetStructuredLogging was called, syslog gets JSON and StdErr gets text.
+// The connection is made in the background, a failure never stops the process.
+func (l *LogrusWrapper) SetSyslogHost(newhost string) {
 	if l.SyslogHost == newhost {
 		// no change
 		l.Debugf("Syslog host not changed to (%s) - already set to that", newhost)
@@ -70,30 +164,85 @@ func (l *LogrusWrapper) SetSyslogHost(newhost string) {
 	}
 
 	if newhost == "" {
-		l.Infof("Log output changed to StdErr", newhost)
+		l.Info("Log output changed to StdErr")
 		l.SetOutput(os.Stderr)
 
 		// Disable structured logging by default when sending to console/StdErr
-		l.SetStructuredLogging(false)
+		l.setDefaultStructured(false)
+
+		l.SyslogHost = ""
+		l.closeSyslog()
+		return
+	}
 
+	opts := l.SyslogOptions
+	if opts.AppName == "" {
+		opts.AppName = l.contextData.ServiceName
+	}
+	newSyslog, syslogErr := syslogwriter.New(newhost, opts)
+	if syslogErr != nil {
+		l.Errorf("ERROR: Unable to init syslog to (%s), keeping the current log output: %v", newhost, syslogErr)
 		return
 	}
 
-	newSyslog, syslogErr := srslog.Dial("udp", newhost, srslog.LOG_DAEMON|srslog.LOG_INFO, l.contextData.ServiceName)
-	if syslogErr == nil && newSyslog != nil {
-		newSyslog.SetFormatter(srslog.RFC3164Formatter)
-		l.SetOutput(newSyslog)
+	l.SetOutput(newSyslog)
 
-		// Enable structured logging by default when sending to Syslog
-		l.SetStructuredLogging(true)
+	// Enable structured logging by default when sending to Syslog
+	l.setDefaultStructured(true)
 
-		if l.contextData.ServiceName != "" {
-			l.Debugf("Log output for (%s) set to syslog to (%s)", l.contextData.ServiceName, newhost)
-		} else {
-			l.Debugf("Log output set to syslog to (%s)", newhost)
-		}
+	l.SyslogHost = newhost
+	l.closeSyslog()
+	l.syslogWriter = newSyslog
+
+	if l.contextData.ServiceName != "" {
+		l.Debugf("Log output for (%s) set to syslog to (%s)", l.contextData.ServiceName, newhost)
 	} else {
-		l.Errorf("ERROR: Unable to init syslog to (%s): %v", newhost, syslogErr)
-		os.Exit(1)
+		l.Debugf("Log output set to syslog to (%s)", newhost)
+	}
+}
+
+// setDefaultStructured changes the format of the default sink, unless it was chosen with SetStructuredLogging
+func (l *LogrusWrapper) setDefaultStructured(structured bool) {
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	if l.formatChosen {
+		return
+	}
+	l.setStructured(structured)
+}
+
+// closeSyslog closes the previous syslog writer, sending what is still buffered
+func (l *LogrusWrapper) closeSyslog() {
+	if l.syslogWriter != nil {
+		previous := l.syslogWriter
+		l.syslogWriter = nil
+		go previous.Close(5 * time.Second)
+	}
+}
+
+// SetLogFile sends the log output to a rotating log file (see rotatefile.Options),
+// reopened on SIGHUP. An empty path sends the log output back to StdErr.
+func (l *LogrusWrapper) SetLogFile(opts rotatefile.Options) error {
+	if opts.Path == "" {
+		if l.logFile != nil {
+			l.SetOutput(os.Stderr)
+			l.logFile.Close()
+			l.logFile = nil
+		}
+		return nil
+	}
+
+	newFile, err := rotatefile.New(opts)
+	if err != nil {
+		return err
+	}
+	newFile.ReopenOnSignal()
+
+	l.SetOutput(newFile)
+	if l.logFile != nil {
+		l.logFile.Close()
 	}
+	l.logFile = newFile
+	l.Debugf("Log output set to file (%s)", opts.Path)
+	return nil
 }
@@ -116,6 +116,11 @@ func (iqtf *IQTextFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	}
 
 	fmt.Fprintf(b, "%s ", entry.Message)
+
+	// Write the correlation ID so all lines of a request can be found
+	if requestID, exists := entry.Data["request_id"]; exists && requestID != "" {
+		fmt.Fprintf(b, "request_id=%v ", requestID)
+	}
 	// b.Write([]byte("test"))
 
 	b.WriteByte('\n')
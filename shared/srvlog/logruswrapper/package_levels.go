@@ -0,0 +1,157 @@
+package logruswrapper
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldOriginPackage holds the full package path of the caller (see srvlog.GetCallerFields),
+// it selects the package level and is removed before the entry is written. Entries
+// without it use the package of the caller logrus reports while overrides are set.
+const FieldOriginPackage = "origin_pkg"
+
+// PackageLevel overrides the logger level for the entries logged from a package
+type PackageLevel struct {
+	// Package matches the packages with this import path suffix and their sub packages,
+	// e.g. "trade/internal/repos" or "github.com/ramseyjiang/go-micros/sales/trade"
+	Package string
+	Level   slog.Level
+	// Expires is when the override is removed, zero when it never is
+	Expires time.Time
+}
+
+type packageLevel struct {
+	PackageLevel
+	timer *time.Timer
+}
+
+// SetPackageLevel overrides the logger level for the entries logged from pkg (the
+// sinks with their own level keep it). A positive ttl removes the override after it.
+func (l *LogrusWrapper) SetPackageLevel(pkg string, level slog.Level, ttl time.Duration) error {
+	pkg = strings.Trim(strings.TrimSpace(pkg), "/.")
+	if pkg == "" {
+		return errors.New("package missing")
+	}
+
+	override := &packageLevel{PackageLevel: PackageLevel{Package: pkg, Level: level}}
+	if ttl > 0 {
+		override.Expires = time.Now().Add(ttl)
+		override.timer = time.AfterFunc(ttl, func() { l.expirePackageLevel(override) })
+	}
+
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	if l.packageLevels == nil {
+		l.packageLevels = map[string]*packageLevel{}
+	}
+	if previous := l.packageLevels[pkg]; previous != nil && previous.timer != nil {
+		previous.timer.Stop()
+	}
+	l.packageLevels[pkg] = override
+	l.applyLevel()
+	return nil
+}
+
+// ClearPackageLevel removes the override of pkg
+func (l *LogrusWrapper) ClearPackageLevel(pkg string) bool {
+	pkg = strings.Trim(strings.TrimSpace(pkg), "/.")
+
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	override := l.packageLevels[pkg]
+	if override == nil {
+		return false
+	}
+	if override.timer != nil {
+		override.timer.Stop()
+	}
+	delete(l.packageLevels, pkg)
+	l.applyLevel()
+	return true
+}
+
+// expirePackageLevel removes override once its ttl is over, unless it was replaced
+func (l *LogrusWrapper) expirePackageLevel(override *packageLevel) {
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	if l.packageLevels[override.Package] != override {
+		return
+	}
+	delete(l.packageLevels, override.Package)
+	l.applyLevel()
+}
+
+// PackageLevels returns the package level overrides, sorted by package
+func (l *LogrusWrapper) PackageLevels() []PackageLevel {
+	l.handlerMutex.RLock()
+	defer l.handlerMutex.RUnlock()
+	levels := make([]PackageLevel, 0, len(l.packageLevels))
+	for _, override := range l.packageLevels {
+		levels = append(levels, override.PackageLevel)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Package < levels[j].Package })
+	return levels
+}
+
+// packageLevelFor returns the level of the longest override matching pkg
+func (l *LogrusWrapper) packageLevelFor(pkg string) (slog.Level, bool) {
+	l.handlerMutex.RLock()
+	defer l.handlerMutex.RUnlock()
+	if len(l.packageLevels) == 0 || pkg == "" {
+		return 0, false
+	}
+	var found *packageLevel
+	for pattern, override := range l.packageLevels {
+		if packageMatches(pkg, pattern) && (found == nil || len(pattern) > len(found.Package)) {
+			found = override
+		}
+	}
+	if found == nil {
+		return 0, false
+	}
+	return found.Level, true
+}
+
+// packageMatches reports whether pattern is a suffix of the import path pkg (on a "/" boundary),
+// or of one of its parent packages
+func packageMatches(pkg string, pattern string) bool {
+	for {
+		if pkg == pattern || strings.HasSuffix(pkg, "/"+pattern) {
+			return true
+		}
+		i := strings.LastIndex(pkg, "/")
+		if i < 0 {
+			return false
+		}
+		pkg = pkg[:i]
+	}
+}
+
+// FuncPackage returns the import path of the package of a runtime function name,
+// e.g. "github.com/a/b.(*T).M" returns "github.com/a/b"
+func FuncPackage(funcName string) string {
+	slash := strings.LastIndex(funcName, "/")
+	if dot := strings.Index(funcName[slash+1:], "."); dot >= 0 {
+		return funcName[:slash+1+dot]
+	}
+	return funcName
+}
+
+type levelOverrideKey struct{}
+
+// contextWithLevelOverride makes the sinks following the logger level use level instead
+func contextWithLevelOverride(ctx context.Context, level slog.Level) context.Context {
+	return context.WithValue(ctx, levelOverrideKey{}, level)
+}
+
+func levelOverrideFromContext(ctx context.Context) (slog.Level, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	level, ok := ctx.Value(levelOverrideKey{}).(slog.Level)
+	return level, ok
+}
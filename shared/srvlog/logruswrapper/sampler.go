@@ -0,0 +1,222 @@
+package logruswrapper
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog/msgtemplate"
+)
+
+// SamplingRatio logs the First entries of a message template per interval,
+// then one in Thereafter. Thereafter 0 drops every entry after the First ones.
+type SamplingRatio struct {
+	First      int
+	Thereafter int
+}
+
+// SamplingOptions configures a Sampler
+type SamplingOptions struct {
+	// Interval the First entries are counted over, defaults to 1 second
+	Interval time.Duration
+	// Levels are the ratios of the sampled levels, levels missing here are never sampled.
+	// Error and above are never sampled.
+	Levels map[slog.Level]SamplingRatio
+	// ReportInterval is how often the dropped entries are reported, 0 disables the reports
+	ReportInterval time.Duration
+	// Report receives the entries dropped per level since the last report, only called when some were dropped
+	Report func(dropped map[slog.Level]uint64)
+
+	// Clock returns the current time, defaults to time.Now (useful in tests)
+	Clock func() time.Time
+}
+
+// Sampler limits the entries logged per message template. Messages are grouped by
+// template with their numbers masked, so "sale 12 created" and "sale 13 created"
+// count together even when formatted with Infof.
+type Sampler struct {
+	opts SamplingOptions
+
+	mu        sync.Mutex
+	windowEnd time.Time
+	counts    map[samplingKey]int
+	dropped   map[slog.Level]uint64
+	reported  map[slog.Level]uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type samplingKey struct {
+	level    slog.Level
+	template string
+}
+
+// NewSampler returns a Sampler, reporting the dropped entries in the background until Stop
+func NewSampler(opts SamplingOptions) *Sampler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	s := &Sampler{
+		opts:     opts,
+		counts:   map[samplingKey]int{},
+		dropped:  map[slog.Level]uint64{},
+		reported: map[slog.Level]uint64{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if opts.ReportInterval > 0 && opts.Report != nil {
+		go s.reportLoop()
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+// Allow reports whether an entry should be logged, counting it as dropped otherwise
+func (s *Sampler) Allow(level slog.Level, message string) bool {
+	if level >= slog.LevelError {
+		return true
+	}
+	ratio, sampled := s.opts.Levels[level]
+	if !sampled {
+		return true
+	}
+	key := samplingKey{level: level, template: MessageTemplate(message)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.opts.Clock()
+	if !now.Before(s.windowEnd) {
+		// a new interval, which also stops the map growing with old templates
+		s.counts = map[samplingKey]int{}
+		s.windowEnd = now.Add(s.opts.Interval)
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= ratio.First {
+		return true
+	}
+	if ratio.Thereafter > 0 && (n-ratio.First)%ratio.Thereafter == 0 {
+		return true
+	}
+	s.dropped[level]++
+	return false
+}
+
+// Dropped returns the entries dropped per level since the Sampler was created
+func (s *Sampler) Dropped() map[slog.Level]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := make(map[slog.Level]uint64, len(s.dropped))
+	for level, n := range s.dropped {
+		dropped[level] = n
+	}
+	return dropped
+}
+
+// Stop stops the background reports, returning after a final one
+func (s *Sampler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+func (s *Sampler) reportLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.report()
+		case <-s.stop:
+			s.report()
+			return
+		}
+	}
+}
+
+// report passes the entries dropped since the last report to opts.Report
+func (s *Sampler) report() {
+	s.mu.Lock()
+	since := map[slog.Level]uint64{}
+	for level, n := range s.dropped {
+		if n > s.reported[level] {
+			since[level] = n - s.reported[level]
+			s.reported[level] = n
+		}
+	}
+	s.mu.Unlock()
+
+	if len(since) > 0 {
+		s.opts.Report(since)
+	}
+}
+
+// MessageTemplate returns message with its quoted values, ids and numbers masked (see msgtemplate.Normalise)
+func MessageTemplate(message string) string {
+	return msgtemplate.Normalise(message)
+}
+
+// SetSampling samples the entries below error level (see Sampler), nil disables sampling.
+// The dropped entries are reported, at warning level, to the sinks every ReportInterval.
+func (l *LogrusWrapper) SetSampling(opts *SamplingOptions) {
+	var sampler *Sampler
+	if opts != nil {
+		samplerOpts := *opts
+		if samplerOpts.Report == nil {
+			samplerOpts.Report = l.reportDropped
+		}
+		sampler = NewSampler(samplerOpts)
+	}
+
+	l.handlerMutex.Lock()
+	previous := l.sampler
+	l.sampler = sampler
+	l.handlerMutex.Unlock()
+
+	if previous != nil {
+		previous.Stop()
+	}
+}
+
+// Sampler returns the Sampler set by SetSampling, nil when not sampling
+func (l *LogrusWrapper) Sampler() *Sampler {
+	l.handlerMutex.RLock()
+	defer l.handlerMutex.RUnlock()
+	return l.sampler
+}
+
+// reportDropped writes the dropped counts straight to the handler, so the report is never sampled itself
+func (l *LogrusWrapper) reportDropped(dropped map[slog.Level]uint64) {
+	handler := l.Handler()
+	if handler == nil || !handler.Enabled(context.Background(), slog.LevelWarn) {
+		return
+	}
+
+	levels := make([]slog.Level, 0, len(dropped))
+	for level := range dropped {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	record := slog.NewRecord(time.Now(), slog.LevelWarn, "Log entries dropped by sampling", 0)
+	var total uint64
+	for _, level := range levels {
+		record.AddAttrs(slog.Uint64("dropped_"+strings.ToLower(LogrusLevel(level).String()), dropped[level]))
+		total += dropped[level]
+	}
+	record.AddAttrs(slog.Uint64("dropped", total))
+	if l.contextData.ServiceName != "" {
+		record.AddAttrs(slog.String("app", l.contextData.ServiceName))
+	}
+	handler.Handle(context.Background(), record)
+}
@@ -0,0 +1,123 @@
+package logruswrapper
+
+import (
+	"bytes"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	tests := []struct {
+		name        string
+		level       slog.Level
+		ratio       SamplingRatio
+		messages    int
+		wantAllowed int
+	}{
+		{name: "FirstThenOneInM", level: slog.LevelInfo, ratio: SamplingRatio{First: 3, Thereafter: 5}, messages: 20, wantAllowed: 3 + 3},
+		{name: "FirstOnly", level: slog.LevelInfo, ratio: SamplingRatio{First: 2}, messages: 20, wantAllowed: 2},
+		{name: "UnsampledLevel", level: slog.LevelWarn, ratio: SamplingRatio{First: 1}, messages: 20, wantAllowed: 20},
+		{name: "ErrorBypass", level: slog.LevelError, ratio: SamplingRatio{First: 1}, messages: 20, wantAllowed: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2023, 12, 3, 9, 0, 0, 0, time.UTC)
+			levels := map[slog.Level]SamplingRatio{slog.LevelInfo: tt.ratio}
+			if tt.level == slog.LevelError {
+				levels[slog.LevelError] = tt.ratio
+			}
+			s := NewSampler(SamplingOptions{Levels: levels, Clock: func() time.Time { return now }})
+			defer s.Stop()
+
+			allowed := 0
+			for i := 0; i < tt.messages; i++ {
+				// the number is masked, every message has the same template
+				if s.Allow(tt.level, "sale "+strconv.Itoa(i)+" created") {
+					allowed++
+				}
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d, want %d", allowed, tt.wantAllowed)
+			}
+			if dropped := s.Dropped()[tt.level]; dropped != uint64(tt.messages-tt.wantAllowed) {
+				t.Errorf("dropped %d, want %d", dropped, tt.messages-tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestSamplerInterval(t *testing.T) {
+	now := time.Date(2023, 12, 3, 9, 0, 0, 0, time.UTC)
+	s := NewSampler(SamplingOptions{
+		Interval: time.Second,
+		Levels:   map[slog.Level]SamplingRatio{slog.LevelDebug: {First: 1}},
+		Clock:    func() time.Time { return now },
+	})
+	defer s.Stop()
+
+	if !s.Allow(slog.LevelDebug, "cache miss") || s.Allow(slog.LevelDebug, "cache miss") {
+		t.Fatal("expected only the first entry of the interval")
+	}
+	if !s.Allow(slog.LevelDebug, "cache hit") {
+		t.Error("another template has its own count")
+	}
+	if s.Allow(slog.LevelDebug, `cache miss`) || !s.Allow(slog.LevelDebug, `product "TEE-S" 123e4567-e89b-12d3-a456-426614174000`) ||
+		s.Allow(slog.LevelDebug, `product "MUG" 00000000-0000-0000-0000-000000000001`) {
+		t.Error("expected the quoted values and ids to be masked in the template")
+	}
+	now = now.Add(time.Second)
+	if !s.Allow(slog.LevelDebug, "cache miss") {
+		t.Error("expected the count to reset in the next interval")
+	}
+}
+
+func TestSetSampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		structured bool
+		wantReport string
+	}{
+		{name: "Text", wantReport: "WARN Log entries dropped by sampling"},
+		{name: "JSON", structured: true, wantReport: `"dropped_info":8`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			l := NewLogrusWrapper()
+			l.SetOutput(buf)
+			l.SetStructuredLogging(tt.structured)
+			l.SetSampling(&SamplingOptions{
+				Interval:       time.Hour,
+				Levels:         map[slog.Level]SamplingRatio{slog.LevelInfo: {First: 2}},
+				ReportInterval: time.Hour,
+			})
+
+			for i := 0; i < 10; i++ {
+				l.Infof("request %d served", i)
+				l.Errorf("request %d failed", i)
+			}
+			sampler := l.Sampler()
+			// stopping the sampler reports the dropped entries
+			l.SetSampling(nil)
+
+			out := buf.String()
+			if got := strings.Count(out, "served"); got != 2 {
+				t.Errorf("%d info lines logged, want 2", got)
+			}
+			if got := strings.Count(out, "failed"); got != 10 {
+				t.Errorf("%d error lines logged, want 10", got)
+			}
+			if sampler.Dropped()[slog.LevelInfo] != 8 {
+				t.Errorf("dropped %v, want 8 info entries", sampler.Dropped())
+			}
+			if !strings.Contains(out, tt.wantReport) {
+				t.Errorf("output missing the dropped report %q:\n%s", tt.wantReport, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package logruswrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSinkName is the sink set up by NewLogrusWrapper, it is the one changed by
+// SetOutput, SetStructuredLogging and SetSyslogHost
+const DefaultSinkName = "default"
+
+// SinkFormat selects the IQ handler of a sink
+type SinkFormat string
+
+const (
+	SinkFormatText SinkFormat = "text"
+	SinkFormatJSON SinkFormat = "json"
+)
+
+// FieldFilter selects the fields a sink writes
+type FieldFilter struct {
+	// Include only writes these fields, all fields are written if empty
+	Include []string
+	// Exclude never writes these fields
+	Exclude []string
+}
+
+func (ff FieldFilter) empty() bool {
+	return len(ff.Include) == 0 && len(ff.Exclude) == 0
+}
+
+// apply removes the filtered fields from data
+func (ff FieldFilter) apply(data logrus.Fields) {
+	if len(ff.Include) > 0 {
+		for k := range data {
+			if !containsString(ff.Include, k) {
+				delete(data, k)
+			}
+		}
+	}
+	for _, k := range ff.Exclude {
+		delete(data, k)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Sink is one of the outputs every log entry is fanned out to
+type Sink struct {
+	// Name identifies the sink in AddSink and RemoveSink
+	Name string
+	// Writer receives the formatted lines, a LevelWriter also receives the level and fields
+	Writer io.Writer
+	// Handler, when set instead of Writer, receives the records (e.g. an OTLP exporter),
+	// Format and UseColour are then ignored
+	Handler slog.Handler
+	// Format defaults to SinkFormatText
+	Format SinkFormat
+	// Level is the minimum level written, nil follows the level of the logger (SetLevel)
+	Level slog.Leveler
+	// Fields filters the fields written
+	Fields FieldFilter
+	// UseColour colours the level of text sinks
+	UseColour bool
+}
+
+// ParseLevel parses a logrus level name (trace, debug, info, warning, error, fatal, panic) as a slog level
+func ParseLevel(name string) (slog.Level, error) {
+	level, err := logrus.ParseLevel(strings.TrimSpace(name))
+	if err != nil {
+		return slog.LevelInfo, err
+	}
+	return SlogLevel(level), nil
+}
+
+// AddSink adds a sink, or replaces the sink with the same name
+func (l *LogrusWrapper) AddSink(sink Sink) error {
+	if sink.Name == "" {
+		return errors.New("sink name missing")
+	}
+	if sink.Writer == nil && sink.Handler == nil {
+		return fmt.Errorf("sink (%s) writer missing", sink.Name)
+	}
+	switch sink.Format {
+	case "":
+		sink.Format = SinkFormatText
+	case SinkFormatText, SinkFormatJSON:
+	default:
+		return fmt.Errorf("sink (%s) has an invalid format (%s)", sink.Name, sink.Format)
+	}
+
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	replaced := false
+	for i := range l.sinks {
+		if l.sinks[i].Name == sink.Name {
+			l.sinks[i] = sink
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		l.sinks = append(l.sinks, sink)
+	}
+	l.rebuildHandler()
+	return nil
+}
+
+// RemoveSink removes the named sink, the writer is not closed
+func (l *LogrusWrapper) RemoveSink(name string) bool {
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	for i := range l.sinks {
+		if l.sinks[i].Name == name {
+			l.sinks = append(l.sinks[:i:i], l.sinks[i+1:]...)
+			l.rebuildHandler()
+			return true
+		}
+	}
+	return false
+}
+
+// Sinks returns a copy of the sinks
+func (l *LogrusWrapper) Sinks() []Sink {
+	l.handlerMutex.RLock()
+	defer l.handlerMutex.RUnlock()
+	return append([]Sink{}, l.sinks...)
+}
+
+// SetLevel sets the level of the sinks following the logger level. The logrus level is
+// lowered to the most verbose sink or package level, so they still get their entries.
+func (l *LogrusWrapper) SetLevel(level logrus.Level) {
+	l.handlerMutex.Lock()
+	defer l.handlerMutex.Unlock()
+	l.level.Set(SlogLevel(level))
+	l.applyLevel()
+}
+
+// Level returns the level set by SetLevel, GetLevel returns the logrus level entries are filtered with
+func (l *LogrusWrapper) Level() logrus.Level {
+	return LogrusLevel(l.level.Level())
+}
+
+// applyLevel must be called with handlerMutex held
+func (l *LogrusWrapper) applyLevel() {
+	lowest := l.level.Level()
+	for _, sink := range l.sinks {
+		if sink.Level != nil && sink.Level.Level() < lowest {
+			lowest = sink.Level.Level()
+		}
+	}
+	for _, override := range l.packageLevels {
+		if override.Level < lowest {
+			lowest = override.Level
+		}
+	}
+	l.Logger.SetLevel(LogrusLevel(lowest))
+	// the package of the entries logged without the srvlog global funcs (WithContext,
+	// GlobalLogger.Debugf...) is read from their caller, only needed while overrides exist
+	l.Logger.SetReportCaller(len(l.packageLevels) > 0)
+}
+
+// rebuildHandler must be called with handlerMutex held
+func (l *LogrusWrapper) rebuildHandler() {
+	handlers := make([]slog.Handler, 0, len(l.sinks))
+	for _, sink := range l.sinks {
+		handlers = append(handlers, l.sinkHandler(sink))
+	}
+	if len(handlers) == 1 {
+		l.handler = handlers[0]
+	} else {
+		l.handler = NewMultiHandler(handlers...)
+	}
+	l.applyLevel()
+}
+
+func (l *LogrusWrapper) sinkHandler(sink Sink) slog.Handler {
+	opts := &HandlerOptions{
+		Level:     sink.Level,
+		UseColour: sink.UseColour,
+		Fields:    sink.Fields,
+	}
+	if opts.Level == nil {
+		opts.Level = &l.level
+		opts.followsLogger = true
+	}
+	if sink.Handler != nil {
+		return &filterHandler{handler: sink.Handler, opts: *opts}
+	}
+	if sink.Format == SinkFormatJSON {
+		return NewIQJSONHandler(sink.Writer, opts)
+	}
+	return NewIQTextHandler(sink.Writer, opts)
+}
+
+// filterHandler applies the level and field filter of a sink to its own handler
+type filterHandler struct {
+	handler slog.Handler
+	opts    HandlerOptions
+}
+
+func (h *filterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	minLevel := h.opts.Level.Level()
+	if override, ok := levelOverrideFromContext(ctx); ok && h.opts.followsLogger {
+		minLevel = override
+	}
+	return level >= minLevel && h.handler.Enabled(ctx, level)
+}
+
+func (h *filterHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.opts.Fields.empty() {
+		return h.handler.Handle(ctx, r)
+	}
+	fields := make(logrus.Fields, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		addAttr(fields, "", attr)
+		return true
+	})
+	h.opts.Fields.apply(fields)
+	filtered := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	filtered.AddAttrs(FieldsToAttrs(fields)...)
+	return h.handler.Handle(ctx, filtered)
+}
+
+func (h *filterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &filterHandler{handler: h.handler.WithAttrs(attrs), opts: h.opts}
+}
+
+func (h *filterHandler) WithGroup(name string) slog.Handler {
+	return &filterHandler{handler: h.handler.WithGroup(name), opts: h.opts}
+}
+
+// multiHandler fans records out to several handlers
+type multiHandler struct {
+	handlers []slog.Handler
+}
+
+// NewMultiHandler returns a slog.Handler passing each record to every enabled handler
+func NewMultiHandler(handlers ...slog.Handler) slog.Handler {
+	return &multiHandler{handlers: handlers}
+}
+
+func (h *multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(ctx, r.Level) {
+			continue
+		}
+		// a failing sink doesn't stop the others
+		if err := handler.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+	return &multiHandler{handlers: handlers}
+}
+
+func (h *multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+	return &multiHandler{handlers: handlers}
+}
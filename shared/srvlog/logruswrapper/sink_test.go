@@ -0,0 +1,87 @@
+package logruswrapper
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSinks(t *testing.T) {
+	l := NewLogrusWrapper()
+	stderr, syslog, file := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	l.SetOutput(stderr)
+	for _, sink := range []Sink{
+		{Name: "syslog", Writer: syslog, Format: SinkFormatJSON, Level: slog.LevelError},
+		{Name: "file", Writer: file, Level: slog.LevelDebug, Fields: FieldFilter{Exclude: []string{"request_id"}}},
+	} {
+		if err := l.AddSink(sink); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.WithField("request_id", "req-1").Debug("cache miss")
+	l.WithField("request_id", "req-2").Info("sale created")
+	l.WithField("request_id", "req-3").Error("sale failed")
+
+	tests := []struct {
+		name      string
+		buf       *bytes.Buffer
+		want      []string
+		wantLines int
+	}{
+		{name: "StdErrFollowsLoggerLevel", buf: stderr, want: []string{"INFO sale created request_id=req-2", "ERRR sale failed request_id=req-3"}, wantLines: 2},
+		{name: "SyslogJSONErrorsOnly", buf: syslog, want: []string{`"msg":"sale failed"`, `"request_id":"req-3"`}, wantLines: 1},
+		{name: "FileDebugWithoutRequestID", buf: file, want: []string{"DBUG cache miss", "INFO sale created", "ERRR sale failed"}, wantLines: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.buf.String()
+			if lines := strings.Count(out, "\n"); lines != tt.wantLines {
+				t.Errorf("got %d lines, want %d:\n%s", lines, tt.wantLines, out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+	if strings.Contains(file.String(), "request_id") {
+		t.Errorf("file sink should exclude request_id:\n%s", file.String())
+	}
+
+	if !l.RemoveSink("file") || l.RemoveSink("file") {
+		t.Error("RemoveSink should only remove an existing sink")
+	}
+	if l.GetLevel() != l.Level() {
+		t.Errorf("logrus level %s should go back to %s once the debug sink is removed", l.GetLevel(), l.Level())
+	}
+}
+
+func TestSyslogHostKeepsChosenFormat(t *testing.T) {
+	tests := []struct {
+		name           string
+		chooseText     bool
+		wantStructured bool
+	}{
+		{name: "Default", wantStructured: true},
+		{name: "TextChosen", chooseText: true, wantStructured: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLogrusWrapper()
+			if tt.chooseText {
+				l.SetStructuredLogging(false)
+			}
+			l.SetSyslogHost("udp://127.0.0.1:9")
+			defer l.SetSyslogHost("")
+
+			format := l.Sinks()[0].Format
+			if (format == SinkFormatJSON) != tt.wantStructured {
+				t.Errorf("default sink format %s, want structured %v", format, tt.wantStructured)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package logruswrapper
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slog levels for the logrus levels slog doesn't define
+const (
+	LevelTrace = slog.Level(-8)
+	LevelFatal = slog.Level(12)
+	LevelPanic = slog.Level(16)
+)
+
+// SlogLevel converts a logrus level to the equivalent slog level
+func SlogLevel(level logrus.Level) slog.Level {
+	switch level {
+	case logrus.TraceLevel:
+		return LevelTrace
+	case logrus.DebugLevel:
+		return slog.LevelDebug
+	case logrus.WarnLevel:
+		return slog.LevelWarn
+	case logrus.ErrorLevel:
+		return slog.LevelError
+	case logrus.FatalLevel:
+		return LevelFatal
+	case logrus.PanicLevel:
+		return LevelPanic
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// LogrusLevel converts a slog level to the closest logrus level
+func LogrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= LevelPanic:
+		return logrus.PanicLevel
+	case level >= LevelFatal:
+		return logrus.FatalLevel
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
+
+// HandlerOptions configures the IQ slog handlers
+type HandlerOptions struct {
+	// Level is the minimum level logged, all levels are logged if nil
+	Level slog.Leveler
+	// Fields filters the fields written
+	Fields FieldFilter
+
+	// followsLogger makes the handler use the package level of the entry, see SetPackageLevel
+	followsLogger bool
+
+	// Text handler only, see IQTextFormatter
+	UseColour         bool
+	IncludeTimePrefix bool
+	TimePrefixFormat  string
+}
+
+// LevelWriter is implemented by writers which need the level and fields of
+// each line, e.g. syslog (see syslogwriter.Writer)
+type LevelWriter interface {
+	WriteLevel(level slog.Level, fields map[string]interface{}, p []byte) (int, error)
+}
+
+// formatterHandler is a slog.Handler writing records formatted by a logrus formatter,
+// so that the slog output is identical to the logrus output
+type formatterHandler struct {
+	formatter logrus.Formatter
+	level     slog.Leveler
+	fields    FieldFilter
+	// followsLogger is set for the sinks following the logger level
+	followsLogger bool
+	mu            *sync.Mutex
+	w             io.Writer
+	attrs         []slog.Attr
+	groups        []string
+}
+
+// NewIQTextHandler returns a slog.Handler writing the same (coloured) text as IQTextFormatter
+func NewIQTextHandler(w io.Writer, opts *HandlerOptions) slog.Handler {
+	if opts == nil {
+		opts = &HandlerOptions{}
+	}
+	return &formatterHandler{
+		formatter: &IQTextFormatter{
+			UseColour:         opts.UseColour,
+			IncludeTimePrefix: opts.IncludeTimePrefix,
+			TimePrefixFormat:  opts.TimePrefixFormat,
+		},
+		level:         opts.Level,
+		fields:        opts.Fields,
+		followsLogger: opts.followsLogger,
+		mu:            &sync.Mutex{},
+		w:             w,
+	}
+}
+
+// NewIQJSONHandler returns a slog.Handler writing the same JSON as IQJSONFormatter
+func NewIQJSONHandler(w io.Writer, opts *HandlerOptions) slog.Handler {
+	if opts == nil {
+		opts = &HandlerOptions{}
+	}
+	return &formatterHandler{
+		formatter:     &IQJSONFormatter{},
+		level:         opts.Level,
+		fields:        opts.Fields,
+		followsLogger: opts.followsLogger,
+		mu:            &sync.Mutex{},
+		w:             w,
+	}
+}
+
+func (h *formatterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.followsLogger {
+		if override, ok := levelOverrideFromContext(ctx); ok {
+			return level >= override
+		}
+	}
+	if h.level == nil {
+		return true
+	}
+	return level >= h.level.Level()
+}
+
+func (h *formatterHandler) Handle(ctx context.Context, r slog.Record) error {
+	data := make(logrus.Fields, len(h.attrs)+r.NumAttrs())
+	prefix := groupPrefix(h.groups)
+	for _, attr := range h.attrs {
+		// already flattened by WithAttrs
+		addAttr(data, "", attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		addAttr(data, prefix, attr)
+		return true
+	})
+	if !h.fields.empty() {
+		h.fields.apply(data)
+	}
+
+	entry := &logrus.Entry{
+		Data:    data,
+		Time:    r.Time,
+		Level:   LogrusLevel(r.Level),
+		Message: r.Message,
+		Context: ctx,
+	}
+	serialized, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lw, ok := h.w.(LevelWriter); ok {
+		_, err = lw.WriteLevel(r.Level, data, serialized)
+		return err
+	}
+	_, err = h.w.Write(serialized)
+	return err
+}
+
+func (h *formatterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	prefix := groupPrefix(h.groups)
+	clone.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, h.attrs...)
+	// attrs are flattened with the groups opened so far, later groups don't apply to them
+	fields := make(logrus.Fields, len(attrs))
+	for _, attr := range attrs {
+		addAttr(fields, prefix, attr)
+	}
+	clone.attrs = append(clone.attrs, FieldsToAttrs(fields)...)
+	return &clone
+}
+
+func (h *formatterHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groups = append(append([]string{}, h.groups...), name)
+	return &clone
+}
+
+func groupPrefix(groups []string) string {
+	prefix := ""
+	for _, group := range groups {
+		prefix += group + "."
+	}
+	return prefix
+}
+
+// AttrsToFields converts slog attributes to logrus fields, groups are flattened with a "." separator
+func AttrsToFields(attrs ...slog.Attr) logrus.Fields {
+	fields := make(logrus.Fields, len(attrs))
+	for _, attr := range attrs {
+		addAttr(fields, "", attr)
+	}
+	return fields
+}
+
+// addAttr adds a slog attribute to data, prefixed with the group names
+func addAttr(data logrus.Fields, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, groupAttr := range value.Group() {
+			addAttr(data, groupPrefix, groupAttr)
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+	data[prefix+attr.Key] = value.Any()
+}
+
+// FieldsToAttrs converts logrus fields to slog attributes
+func FieldsToAttrs(fields logrus.Fields) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for k, v := range fields {
+		attrs = append(attrs, slog.Any(k, v))
+	}
+	return attrs
+}
+
+// slogBridgeFormatter hands every logrus entry, after the hooks have fired, to
+// the slog handler of the wrapper. Nothing is left for logrus to write.
+type slogBridgeFormatter struct {
+	wrapper *LogrusWrapper
+}
+
+func (sbf *slogBridgeFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	handler := sbf.wrapper.Handler()
+	if handler == nil {
+		return nil, nil
+	}
+
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	level := SlogLevel(entry.Level)
+	data := entry.Data
+	pkg, hasOrigin := data[FieldOriginPackage].(string)
+	if !hasOrigin && entry.Caller != nil {
+		pkg = FuncPackage(entry.Caller.Function)
+	}
+	if override, ok := sbf.wrapper.packageLevelFor(pkg); ok {
+		ctx = contextWithLevelOverride(ctx, override)
+	}
+	if hasOrigin {
+		data = make(map[string]interface{}, len(entry.Data))
+		for k, v := range entry.Data {
+			if k != FieldOriginPackage {
+				data[k] = v
+			}
+		}
+	}
+	if !handler.Enabled(ctx, level) {
+		return nil, nil
+	}
+	if sampler := sbf.wrapper.Sampler(); sampler != nil && !sampler.Allow(level, entry.Message) {
+		return nil, nil
+	}
+
+	var pc uintptr
+	if entry.Caller != nil {
+		pc = entry.Caller.PC
+	}
+	record := slog.NewRecord(entry.Time, level, entry.Message, pc)
+	record.AddAttrs(FieldsToAttrs(data)...)
+	return nil, handler.Handle(ctx, record)
+}
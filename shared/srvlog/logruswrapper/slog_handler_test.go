@@ -0,0 +1,123 @@
+package logruswrapper
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestIQHandlersMatchFormatters(t *testing.T) {
+	when := time.Date(2023, 12, 3, 9, 49, 11, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		formatter logrus.Formatter
+		handler   func(buf *bytes.Buffer) slog.Handler
+	}{
+		{
+			name:      "Text",
+			formatter: &IQTextFormatter{UseColour: true, IncludeTimePrefix: true},
+			handler: func(buf *bytes.Buffer) slog.Handler {
+				return NewIQTextHandler(buf, &HandlerOptions{UseColour: true, IncludeTimePrefix: true})
+			},
+		},
+		{
+			name:      "JSON",
+			formatter: &IQJSONFormatter{},
+			handler: func(buf *bytes.Buffer) slog.Handler {
+				return NewIQJSONHandler(buf, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &logrus.Entry{
+				Time:    when,
+				Level:   logrus.WarnLevel,
+				Message: "stock is low",
+				Data: logrus.Fields{
+					"origin_func": "services.CreateSale",
+					"origin_file": "services/trade.go",
+					"origin_line": 42,
+					"request_id":  "req-1",
+					"sale.total":  12.5,
+				},
+			}
+			want, err := tt.formatter.Format(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf := &bytes.Buffer{}
+			handler := tt.handler(buf)
+			record := slog.NewRecord(when, slog.LevelWarn, "stock is low", 0)
+			record.AddAttrs(
+				slog.String("origin_func", "services.CreateSale"),
+				slog.String("origin_file", "services/trade.go"),
+				slog.Int("origin_line", 42),
+				slog.String("request_id", "req-1"),
+				slog.Group("sale", slog.Float64("total", 12.5)),
+			)
+			if err := handler.Handle(context.Background(), record); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != string(want) {
+				t.Errorf("handler output differs from the formatter\n got: %q\nwant: %q", got, string(want))
+			}
+		})
+	}
+}
+
+func TestLogrusWrapperWritesThroughHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewLogrusWrapper()
+	l.SetApplicationName("products")
+	l.SetOutput(buf)
+	l.SetStructuredLogging(true)
+
+	var hookFired bool
+	l.AddHook(testHook(func(e *logrus.Entry) { hookFired = true }))
+
+	l.WithContext(ContextWithFields(context.Background(), logrus.Fields{"request_id": "req-2"})).Info("created product")
+
+	if !hookFired {
+		t.Error("expected the logrus hook to fire")
+	}
+	for _, want := range []string{`"app":"products"`, `"request_id":"req-2"`, `"msg":"created product"`} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("expected %s in %s", want, buf.String())
+		}
+	}
+
+	// a custom handler receives every entry
+	var records []slog.Record
+	l.SetHandler(recordingHandler(func(r slog.Record) { records = append(records, r) }))
+	l.Warn("disk almost full")
+	if len(records) != 1 || records[0].Level != slog.LevelWarn || records[0].Message != "disk almost full" {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+type testHook func(e *logrus.Entry)
+
+func (th testHook) Levels() []logrus.Level { return logrus.AllLevels }
+func (th testHook) Fire(e *logrus.Entry) error {
+	th(e)
+	return nil
+}
+
+type recordingHandler func(r slog.Record)
+
+func (rh recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (rh recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	rh(r)
+	return nil
+}
+func (rh recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return rh }
+func (rh recordingHandler) WithGroup(string) slog.Handler      { return rh }
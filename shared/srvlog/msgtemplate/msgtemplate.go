@@ -0,0 +1,32 @@
+// Package msgtemplate reduces log and error messages to their template, so the messages
+// written by the same call site compare equal whatever their variable parts.
+package msgtemplate
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	uuidRegex   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	hexRegex    = regexp.MustCompile(`(?i)\b(0x)?[0-9a-f]{8,}\b`)
+	numberRegex = regexp.MustCompile(`[-+]?\d+(\.\d+)?`)
+	quoteRegex  = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	spaceRegex  = regexp.MustCompile(`\s+`)
+)
+
+// Normalise strips the variable parts (quoted values, ids, numbers) out of msg, and lower cases it
+func Normalise(msg string) string {
+	msg = quoteRegex.ReplaceAllString(msg, "<str>")
+	msg = uuidRegex.ReplaceAllString(msg, "<id>")
+	msg = hexRegex.ReplaceAllStringFunc(msg, func(s string) string {
+		// hex ids always contain a digit, this keeps long words like "deadbeef" intact
+		if !strings.ContainsAny(s, "0123456789") {
+			return s
+		}
+		return "<id>"
+	})
+	msg = numberRegex.ReplaceAllString(msg, "<num>")
+	msg = spaceRegex.ReplaceAllString(msg, " ")
+	return strings.ToLower(strings.TrimSpace(msg))
+}
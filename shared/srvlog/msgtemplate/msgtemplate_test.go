@@ -0,0 +1,26 @@
+package msgtemplate
+
+import "testing"
+
+func TestNormalise(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{name: "Numbers", msg: "product 12 not found", want: "product <num> not found"},
+		{name: "UUID", msg: "sale 123e4567-e89b-12d3-a456-426614174000 failed", want: "sale <id> failed"},
+		{name: "Hex", msg: "trace 4bf92f3577b34da6a3ce929d0e0e4736 dropped", want: "trace <id> dropped"},
+		{name: "HexWord", msg: "deadbeef cafebabe", want: "deadbeef cafebabe"},
+		{name: "Quoted", msg: `dial tcp "redis:6379": refused`, want: "dial tcp <str>: refused"},
+		{name: "Whitespace", msg: "  Redis   timeout ", want: "redis timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalise(tt.msg); got != tt.want {
+				t.Errorf("Normalise(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
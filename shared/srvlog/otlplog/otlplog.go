@@ -0,0 +1,491 @@
+// Package otlplog exports srvlog entries as OpenTelemetry log records over OTLP/HTTP
+// (protobuf). The Exporter is a slog.Handler, added to srvlog as a sink:
+//
+//	exporter, err := otlplog.New(otlplog.Options{Endpoint: "http://otel-collector:4318"})
+//	srvlog.AddSink(logruswrapper.Sink{Name: "otlp", Handler: exporter})
+//
+// Records are queued (dropping the newest when the queue is full) and posted in batches
+// in the background, so logging never waits for the collector.
+package otlplog
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// ScopeName is the instrumentation scope of the exported records
+const ScopeName = "github.com/ramseyjiang/go-micros/shared/srvlog"
+
+// DefaultPath is added to an Endpoint without a path
+const DefaultPath = "/v1/logs"
+
+// the fields holding the request scoped trace context (see srvlog.FieldTraceID)
+const (
+	fieldTraceID = "trace_id"
+	fieldSpanID  = "span_id"
+)
+
+var (
+	ErrClosed          = errors.New("otlplog: exporter closed")
+	ErrInvalidEndpoint = errors.New("otlplog: invalid endpoint")
+)
+
+// Options configures an Exporter
+type Options struct {
+	// Endpoint is the OTLP/HTTP receiver, e.g. http://localhost:4318 (DefaultPath is added)
+	Endpoint string
+	// Headers are sent with every request, e.g. an API key
+	Headers map[string]string
+	// ServiceName is the service.name resource attribute, defaults to the executable name
+	ServiceName string
+	// ResourceAttributes are added to the resource, e.g. deployment.environment
+	ResourceAttributes map[string]string
+
+	// QueueSize is the number of records waiting to be exported, defaults to 2048
+	QueueSize int
+	// BatchSize is the maximum number of records per request, defaults to 512
+	BatchSize int
+	// FlushInterval exports a partial batch after it, defaults to 1 second
+	FlushInterval time.Duration
+	// Timeout of a request, defaults to 10 seconds
+	Timeout time.Duration
+	// HTTPClient defaults to an http.Client with Timeout
+	HTTPClient *http.Client
+
+	// OnError receives the export errors, they are written to StdErr by default
+	// (never to srvlog, which could export them again)
+	OnError func(err error)
+}
+
+// Exporter is a slog.Handler exporting the records as OTLP log records
+type Exporter struct {
+	opts     Options
+	endpoint string
+	resource *resourcepb.Resource
+
+	queue chan *logspb.LogRecord
+	flush chan chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	// shared with the clones made by WithAttrs and WithGroup
+	closed   *atomic.Bool
+	dropped  *atomic.Uint64
+	stopOnce *sync.Once
+
+	// set by WithAttrs and WithGroup
+	attrs  map[string]interface{}
+	prefix string
+}
+
+// New returns an Exporter, exporting in the background until Shutdown
+func New(opts Options) (*Exporter, error) {
+	endpoint, err := endpointURL(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = filepath.Base(os.Args[0])
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 2048
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 512
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: opts.Timeout}
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			fmt.Fprintf(os.Stderr, "otlplog: %v\n", err)
+		}
+	}
+
+	resource := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringKeyValue("service.name", opts.ServiceName)}}
+	for k, v := range opts.ResourceAttributes {
+		resource.Attributes = append(resource.Attributes, stringKeyValue(k, v))
+	}
+
+	e := &Exporter{
+		opts:     opts,
+		endpoint: endpoint,
+		resource: resource,
+		queue:    make(chan *logspb.LogRecord, opts.QueueSize),
+		flush:    make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		closed:   &atomic.Bool{},
+		dropped:  &atomic.Uint64{},
+		stopOnce: &sync.Once{},
+	}
+	go e.run()
+	return e, nil
+}
+
+// endpointURL validates endpoint and adds DefaultPath when it has no path
+func endpointURL(endpoint string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%w (%s)", ErrInvalidEndpoint, endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = DefaultPath
+	}
+	return u.String(), nil
+}
+
+// Endpoint returns the URL the records are posted to
+func (e *Exporter) Endpoint() string {
+	return e.endpoint
+}
+
+// Dropped returns the number of records dropped because the queue was full
+func (e *Exporter) Dropped() uint64 {
+	return e.dropped.Load()
+}
+
+// Enabled implements slog.Handler, the level is filtered by the sink
+func (e *Exporter) Enabled(context.Context, slog.Level) bool {
+	return !e.closed.Load()
+}
+
+// Handle implements slog.Handler, queueing the record
+func (e *Exporter) Handle(ctx context.Context, r slog.Record) error {
+	if e.closed.Load() {
+		return ErrClosed
+	}
+
+	fields := make(map[string]interface{}, len(e.attrs)+r.NumAttrs())
+	for k, v := range e.attrs {
+		fields[k] = v
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		for k, v := range logruswrapper.AttrsToFields(attr) {
+			fields[e.prefix+k] = v
+		}
+		return true
+	})
+
+	record := newLogRecord(ctx, r, fields)
+	select {
+	case e.queue <- record:
+	default:
+		e.dropped.Add(1)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (e *Exporter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return e
+	}
+	clone := e.clone()
+	for k, v := range logruswrapper.AttrsToFields(attrs...) {
+		clone.attrs[e.prefix+k] = v
+	}
+	return clone
+}
+
+// WithGroup implements slog.Handler
+func (e *Exporter) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return e
+	}
+	clone := e.clone()
+	clone.prefix = e.prefix + name + "."
+	return clone
+}
+
+// clone shares the queue and worker of e, with a copy of its attrs
+func (e *Exporter) clone() *Exporter {
+	clone := &Exporter{
+		opts:     e.opts,
+		endpoint: e.endpoint,
+		resource: e.resource,
+		queue:    e.queue,
+		flush:    e.flush,
+		stop:     e.stop,
+		done:     e.done,
+		closed:   e.closed,
+		dropped:  e.dropped,
+		stopOnce: e.stopOnce,
+		attrs:    make(map[string]interface{}, len(e.attrs)),
+		prefix:   e.prefix,
+	}
+	for k, v := range e.attrs {
+		clone.attrs[k] = v
+	}
+	return clone
+}
+
+// ForceFlush exports the queued records, waiting until they are sent or ctx is done
+func (e *Exporter) ForceFlush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case e.flush <- flushed:
+	case <-e.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops accepting records and exports the queued ones, waiting until ctx is done
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() {
+		e.closed.Store(true)
+		close(e.stop)
+	})
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*logspb.LogRecord, 0, e.opts.BatchSize)
+	export := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = make([]*logspb.LogRecord, 0, e.opts.BatchSize)
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case record := <-e.queue:
+				batch = append(batch, record)
+				if len(batch) >= e.opts.BatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case record := <-e.queue:
+			batch = append(batch, record)
+			if len(batch) >= e.opts.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-e.flush:
+			drain()
+			close(flushed)
+		case <-e.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// export posts a batch, a failed batch is reported to OnError and dropped
+func (e *Exporter) export(records []*logspb.LogRecord) {
+	body, err := proto.Marshal(&collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: e.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: ScopeName},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		e.opts.OnError(fmt.Errorf("marshalling %d records: %w", len(records), err))
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		e.opts.OnError(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range e.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.opts.HTTPClient.Do(req)
+	if err != nil {
+		e.opts.OnError(fmt.Errorf("exporting %d records: %w", len(records), err))
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e.opts.OnError(fmt.Errorf("exporting %d records: %s", len(records), resp.Status))
+	}
+}
+
+// newLogRecord maps a slog record and its fields to an OTLP log record. The trace context
+// comes from the OpenTelemetry span of ctx, or the trace_id/span_id request fields.
+func newLogRecord(ctx context.Context, r slog.Record, fields map[string]interface{}) *logspb.LogRecord {
+	level := logruswrapper.LogrusLevel(r.Level)
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(r.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severityNumber(r.Level),
+		SeverityText:         strings.ToUpper(level.String()),
+		Body:                 anyValue(r.Message),
+	}
+	if r.Time.IsZero() {
+		record.TimeUnixNano = record.ObservedTimeUnixNano
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		traceID, spanID := spanContext.TraceID(), spanContext.SpanID()
+		record.TraceId = traceID[:]
+		record.SpanId = spanID[:]
+		record.Flags = uint32(spanContext.TraceFlags())
+	} else {
+		record.TraceId = hexField(fields[fieldTraceID], 16)
+		record.SpanId = hexField(fields[fieldSpanID], 8)
+	}
+	if record.TraceId != nil {
+		delete(fields, fieldTraceID)
+	}
+	if record.SpanId != nil {
+		delete(fields, fieldSpanID)
+	}
+
+	record.Attributes = make([]*commonpb.KeyValue, 0, len(fields))
+	for k, v := range fields {
+		record.Attributes = append(record.Attributes, &commonpb.KeyValue{Key: k, Value: anyValue(v)})
+	}
+	return record
+}
+
+// severityNumber maps the srvlog levels to the OTel severity numbers
+func severityNumber(level slog.Level) logspb.SeverityNumber {
+	switch {
+	case level >= logruswrapper.LevelPanic:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4
+	case level >= logruswrapper.LevelFatal:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case level >= slog.LevelError:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case level >= slog.LevelWarn:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case level >= slog.LevelInfo:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case level >= slog.LevelDebug:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+	}
+}
+
+// hexField decodes a hex ID field of size bytes, nil if it isn't one
+func hexField(value interface{}, size int) []byte {
+	s, ok := value.(string)
+	if !ok || len(s) != size*2 {
+		return nil
+	}
+	id, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	for _, b := range id {
+		if b != 0 {
+			return id
+		}
+	}
+	return nil
+}
+
+func stringKeyValue(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+// anyValue converts a field value, values without an OTLP type are formatted with %v
+func anyValue(v interface{}) *commonpb.AnyValue {
+	switch value := v.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: value}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(value)}}
+	case int32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(value)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}
+	case uint32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(value)}}
+	case uint64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(value)}}
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(value)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: value}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: value}}
+	case time.Duration:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value.String()}}
+	case time.Time:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value.Format(time.RFC3339Nano)}}
+	case error:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value.Error()}}
+	case map[string]interface{}:
+		kvs := make([]*commonpb.KeyValue, 0, len(value))
+		for k, item := range value {
+			kvs = append(kvs, &commonpb.KeyValue{Key: k, Value: anyValue(item)})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+	case []interface{}:
+		values := make([]*commonpb.AnyValue, 0, len(value))
+		for _, item := range value {
+			values = append(values, anyValue(item))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case nil:
+		return &commonpb.AnyValue{}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprintf("%v", value)}}
+	}
+}
@@ -0,0 +1,205 @@
+package otlplog
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// receiver is a local OTLP/HTTP logs receiver
+type receiver struct {
+	mu       sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+	headers  []http.Header
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := &collogspb.ExportLogsServiceRequest{}
+	if r.URL.Path != DefaultPath || r.Header.Get("Content-Type") != "application/x-protobuf" || proto.Unmarshal(body, req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	rcv.mu.Lock()
+	rcv.requests = append(rcv.requests, req)
+	rcv.headers = append(rcv.headers, r.Header)
+	rcv.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write([]byte{})
+}
+
+func (rcv *receiver) records() []*logspb.LogRecord {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	var records []*logspb.LogRecord
+	for _, req := range rcv.requests {
+		for _, resourceLogs := range req.ResourceLogs {
+			for _, scopeLogs := range resourceLogs.ScopeLogs {
+				records = append(records, scopeLogs.LogRecords...)
+			}
+		}
+	}
+	return records
+}
+
+func TestExportThroughSink(t *testing.T) {
+	rcv := &receiver{}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	exporter, err := New(Options{
+		Endpoint:    server.URL,
+		ServiceName: "trade",
+		Headers:     map[string]string{"X-Api-Key": "k"},
+		OnError:     func(err error) { t.Errorf("export error: %v", err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logruswrapper.NewLogrusWrapper()
+	l.SetOutput(io.Discard)
+	if err := l.AddSink(logruswrapper.Sink{Name: "otlp", Handler: exporter, Level: slog.LevelWarn}); err != nil {
+		t.Fatal(err)
+	}
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	spanCtx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	l.WithContext(spanCtx).WithField("sale_id", 42).Error("sale failed")
+	l.WithField("trace_id", "4bf92f3577b34da6a3ce929d0e0e4736").WithField("span_id", "00f067aa0ba902b7").Warn("stock low")
+	l.Info("below the sink level")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	records := rcv.records()
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if rcv.headers[0].Get("X-Api-Key") != "k" {
+		t.Errorf("missing header, got %v", rcv.headers[0])
+	}
+	if got := rcv.requests[0].ResourceLogs[0].Resource.Attributes[0]; got.Key != "service.name" || got.Value.GetStringValue() != "trade" {
+		t.Errorf("unexpected resource attribute %v", got)
+	}
+
+	tests := []struct {
+		name         string
+		record       *logspb.LogRecord
+		wantBody     string
+		wantSeverity logspb.SeverityNumber
+		wantTraceID  string
+		wantSpanID   string
+	}{
+		{name: "SpanFromContext", record: records[0], wantBody: "sale failed", wantSeverity: logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, wantTraceID: "0102030405060708090a0b0c0d0e0f10", wantSpanID: "0102030405060708"},
+		{name: "RequestFields", record: records[1], wantBody: "stock low", wantSeverity: logspb.SeverityNumber_SEVERITY_NUMBER_WARN, wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736", wantSpanID: "00f067aa0ba902b7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.record.Body.GetStringValue() != tt.wantBody || tt.record.SeverityNumber != tt.wantSeverity {
+				t.Errorf("body %q severity %v, want %q %v", tt.record.Body.GetStringValue(), tt.record.SeverityNumber, tt.wantBody, tt.wantSeverity)
+			}
+			if hex.EncodeToString(tt.record.TraceId) != tt.wantTraceID || hex.EncodeToString(tt.record.SpanId) != tt.wantSpanID {
+				t.Errorf("trace %x span %x, want %s %s", tt.record.TraceId, tt.record.SpanId, tt.wantTraceID, tt.wantSpanID)
+			}
+			for _, attr := range tt.record.Attributes {
+				if attr.Key == "trace_id" || attr.Key == "span_id" {
+					t.Errorf("trace context should not be repeated as attribute %s", attr.Key)
+				}
+			}
+		})
+	}
+
+	var saleID int64
+	for _, attr := range records[0].Attributes {
+		if attr.Key == "sale_id" {
+			saleID = attr.Value.GetIntValue()
+		}
+	}
+	if saleID != 42 {
+		t.Errorf("sale_id attribute = %d, want 42", saleID)
+	}
+}
+
+func TestBoundedQueue(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	exporter, err := New(Options{Endpoint: server.URL, QueueSize: 4, BatchSize: 1, OnError: func(error) {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := exporter.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "busy", 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Handle must not wait for the receiver")
+	}
+	if exporter.Dropped() == 0 {
+		t.Error("expected records to be dropped once the queue is full")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	exporter.Shutdown(ctx)
+	if err := exporter.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0)); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Shutdown, got %v", err)
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "http://collector:4318", want: "http://collector:4318/v1/logs"},
+		{endpoint: "https://collector/custom/logs", want: "https://collector/custom/logs"},
+		{endpoint: "collector:4318", wantErr: true},
+		{endpoint: "grpc://collector:4317", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := endpointURL(tt.endpoint)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidEndpoint) {
+					t.Errorf("expected ErrInvalidEndpoint, got %v", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("endpointURL() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
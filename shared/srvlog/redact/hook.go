@@ -0,0 +1,29 @@
+package redact
+
+import "github.com/sirupsen/logrus"
+
+// Hook redacts the fields and message of every logrus entry. Add it after the
+// hooks adding fields (the srvlog GlobalLogger adds it with the Default engine).
+type Hook struct {
+	Engine *Engine
+}
+
+// NewHook returns a Hook using engine, the Default engine if nil
+func NewHook(engine *Engine) *Hook {
+	return &Hook{Engine: engine}
+}
+
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *Hook) Fire(e *logrus.Entry) error {
+	engine := h.Engine
+	if engine == nil {
+		engine = Default
+	}
+	// entries share their Data map with the logger they were made from, so replace it
+	e.Data = logrus.Fields(engine.Fields(e.Data))
+	e.Message = engine.String(e.Message)
+	return nil
+}
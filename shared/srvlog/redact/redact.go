@@ -0,0 +1,341 @@
+// Package redact removes sensitive data (passwords, tokens, card numbers, emails...)
+// from log fields and error messages. Values are redacted when their key matches a
+// key rule, and strings are searched for the value rules.
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mask replaces the value of a protected key
+const Mask = "[REDACTED]"
+
+// DefaultKeySubstrings protect every key containing one of them, keys are
+// lower cased and "_" is read as "-" (so "api_key" matches "-key")
+var DefaultKeySubstrings = []string{"password", "passwd", "apikey", "api-key", "secret", "-key", "-passphrase", "-token"}
+
+// DefaultKeys protect the keys equal to one of them
+var DefaultKeys = []string{"token", "authorization", "cookie", "set-cookie", "pan", "card-number", "cvv", "cvc"}
+
+// Rule replaces every match of Pattern in a string value with Replacement
+type Rule struct {
+	// Name identifies the rule, the default replacement is [REDACTED:<name>]
+	Name    string
+	Pattern *regexp.Regexp
+	// Validate, when set, only replaces the matches it returns true for (e.g. a Luhn check)
+	Validate    func(match string) bool
+	Replacement string
+}
+
+// DefaultRules find card numbers (PAN), JWTs, bearer tokens and emails
+var DefaultRules = []Rule{
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	{Name: "bearer", Pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`)},
+	{Name: "pan", Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Validate: panValid},
+	{Name: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+}
+
+// Engine holds the key and value rules
+type Engine struct {
+	mu            sync.RWMutex
+	keySubstrings []string
+	keys          []string
+	rules         []Rule
+}
+
+// New returns an Engine without rules
+func New() *Engine {
+	return &Engine{}
+}
+
+// NewDefault returns an Engine with the default key and value rules
+func NewDefault() *Engine {
+	e := New()
+	e.AddKeySubstrings(DefaultKeySubstrings...)
+	e.AddKeys(DefaultKeys...)
+	for _, rule := range DefaultRules {
+		e.AddRule(rule)
+	}
+	return e
+}
+
+// AddKeySubstrings protects every key containing one of substrings
+func (e *Engine) AddKeySubstrings(substrings ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, substring := range substrings {
+		e.keySubstrings = append(e.keySubstrings, normalizeKey(substring))
+	}
+}
+
+// AddKeys protects the keys equal to one of keys
+func (e *Engine) AddKeys(keys ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, key := range keys {
+		e.keys = append(e.keys, normalizeKey(key))
+	}
+}
+
+// AddRule adds a value rule, a rule with the same name is replaced
+func (e *Engine) AddRule(rule Rule) error {
+	if rule.Name == "" {
+		return errors.New("redact: rule name missing")
+	}
+	if rule.Pattern == nil {
+		return fmt.Errorf("redact: rule (%s) pattern missing", rule.Name)
+	}
+	if rule.Replacement == "" {
+		rule.Replacement = "[REDACTED:" + rule.Name + "]"
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.rules {
+		if e.rules[i].Name == rule.Name {
+			e.rules[i] = rule
+			return nil
+		}
+	}
+	e.rules = append(e.rules, rule)
+	return nil
+}
+
+// RemoveRule removes the named value rule
+func (e *Engine) RemoveRule(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.rules {
+		if e.rules[i].Name == name {
+			e.rules = append(e.rules[:i:i], e.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// IsProtectedKey reports whether the values of key are always masked
+func (e *Engine) IsProtectedKey(key string) bool {
+	key = normalizeKey(key)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, protected := range e.keys {
+		if key == protected {
+			return true
+		}
+	}
+	for _, substring := range e.keySubstrings {
+		if strings.Contains(key, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// String replaces the matches of the value rules in s
+func (e *Engine) String(s string) string {
+	if s == "" {
+		return s
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, rule := range e.rules {
+		if rule.Validate == nil {
+			s = rule.Pattern.ReplaceAllLiteralString(s, rule.Replacement)
+			continue
+		}
+		s = rule.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.Validate(match) {
+				return rule.Replacement
+			}
+			return match
+		})
+	}
+	return s
+}
+
+// Value returns v redacted, Mask when key is protected. Strings, errors, maps and
+// slices of them are searched for the value rules, other values are returned as is.
+func (e *Engine) Value(key string, v interface{}) interface{} {
+	if key != "" && e.IsProtectedKey(key) {
+		return Mask
+	}
+	switch value := v.(type) {
+	case string:
+		return e.String(value)
+	case []byte:
+		return e.String(string(value))
+	case error:
+		if redacted := e.String(value.Error()); redacted != value.Error() {
+			return redacted
+		}
+		return value
+	case map[string]interface{}:
+		return e.Fields(value)
+	case logrus.Fields:
+		return logrus.Fields(e.Fields(value))
+	case map[string]string:
+		redacted := make(map[string]string, len(value))
+		for k, s := range value {
+			if e.IsProtectedKey(k) {
+				redacted[k] = Mask
+			} else {
+				redacted[k] = e.String(s)
+			}
+		}
+		return redacted
+	case []map[string]interface{}:
+		redacted := make([]map[string]interface{}, 0, len(value))
+		for _, fields := range value {
+			redacted = append(redacted, e.Fields(fields))
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			redacted = append(redacted, e.Value("", item))
+		}
+		return redacted
+	case []string:
+		redacted := make([]string, 0, len(value))
+		for _, s := range value {
+			redacted = append(redacted, e.String(s))
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// Fields returns a redacted copy of fields
+func (e *Engine) Fields(fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		redacted[k] = e.Value(k, v)
+	}
+	return redacted
+}
+
+// cardRanges are the issuer identification number (IIN) prefix ranges of the
+// major card networks with the lengths their card numbers can have
+var cardRanges = []struct {
+	prefixLow, prefixHigh int
+	prefixDigits          int
+	minLength, maxLength  int
+}{
+	{prefixLow: 4, prefixHigh: 4, prefixDigits: 1, minLength: 13, maxLength: 19},       // Visa
+	{prefixLow: 51, prefixHigh: 55, prefixDigits: 2, minLength: 16, maxLength: 16},     // Mastercard
+	{prefixLow: 2221, prefixHigh: 2720, prefixDigits: 4, minLength: 16, maxLength: 16}, // Mastercard 2-series
+	{prefixLow: 34, prefixHigh: 34, prefixDigits: 2, minLength: 15, maxLength: 15},     // American Express
+	{prefixLow: 37, prefixHigh: 37, prefixDigits: 2, minLength: 15, maxLength: 15},     // American Express
+	{prefixLow: 300, prefixHigh: 305, prefixDigits: 3, minLength: 14, maxLength: 19},   // Diners Club
+	{prefixLow: 36, prefixHigh: 36, prefixDigits: 2, minLength: 14, maxLength: 19},     // Diners Club
+	{prefixLow: 38, prefixHigh: 39, prefixDigits: 2, minLength: 16, maxLength: 19},     // Diners Club
+	{prefixLow: 3528, prefixHigh: 3589, prefixDigits: 4, minLength: 16, maxLength: 19}, // JCB
+	{prefixLow: 6011, prefixHigh: 6011, prefixDigits: 4, minLength: 16, maxLength: 19}, // Discover
+	{prefixLow: 644, prefixHigh: 649, prefixDigits: 3, minLength: 16, maxLength: 19},   // Discover
+	{prefixLow: 65, prefixHigh: 65, prefixDigits: 2, minLength: 16, maxLength: 19},     // Discover
+	{prefixLow: 62, prefixHigh: 62, prefixDigits: 2, minLength: 16, maxLength: 19},     // UnionPay
+	{prefixLow: 50, prefixHigh: 50, prefixDigits: 2, minLength: 12, maxLength: 19},     // Maestro
+	{prefixLow: 56, prefixHigh: 58, prefixDigits: 2, minLength: 12, maxLength: 19},     // Maestro
+	{prefixLow: 2200, prefixHigh: 2204, prefixDigits: 4, minLength: 16, maxLength: 19}, // Mir
+}
+
+// panValid checks that a card number candidate has the prefix and length of a known
+// card network and passes the Luhn check, so that other long numbers (timestamps,
+// Redis stream IDs "<ms>-<seq>", order numbers...) are not redacted
+func panValid(match string) bool {
+	digits := make([]byte, 0, len(match))
+	for i := 0; i < len(match); i++ {
+		if match[i] >= '0' && match[i] <= '9' {
+			digits = append(digits, match[i])
+		}
+	}
+	return knownCardRange(digits) && luhnValid(digits)
+}
+
+// knownCardRange reports whether digits starts with the IIN of a card network and has one of its lengths
+func knownCardRange(digits []byte) bool {
+	for _, r := range cardRanges {
+		if len(digits) < r.minLength || len(digits) > r.maxLength {
+			continue
+		}
+		prefix := 0
+		for _, d := range digits[:r.prefixDigits] {
+			prefix = prefix*10 + int(d-'0')
+		}
+		if prefix >= r.prefixLow && prefix <= r.prefixHigh {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid checks the Luhn check digit of digits
+func luhnValid(digits []byte) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// Default is the Engine used by the package functions, the srvlog hook and apierror
+var Default = NewDefault()
+
+// String replaces the matches of the Default value rules in s
+func String(s string) string {
+	return Default.String(s)
+}
+
+// Value returns v redacted by the Default engine
+func Value(key string, v interface{}) interface{} {
+	return Default.Value(key, v)
+}
+
+// Fields returns a copy of fields redacted by the Default engine
+func Fields(fields map[string]interface{}) map[string]interface{} {
+	return Default.Fields(fields)
+}
+
+// IsProtectedKey reports whether the Default engine masks the values of key
+func IsProtectedKey(key string) bool {
+	return Default.IsProtectedKey(key)
+}
+
+// AddRule adds a value rule to the Default engine
+func AddRule(rule Rule) error {
+	return Default.AddRule(rule)
+}
+
+// AddKeySubstrings protects every key containing one of substrings in the Default engine
+func AddKeySubstrings(substrings ...string) {
+	Default.AddKeySubstrings(substrings...)
+}
+
+// AddKeys protects the keys equal to one of keys in the Default engine
+func AddKeys(keys ...string) {
+	Default.AddKeys(keys...)
+}
@@ -0,0 +1,155 @@
+package requestlog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// seedIncomingContext attaches the request scoped fields read from the incoming
+// metadata to ctx. A generated request ID is also added to the incoming metadata
+// so that packages reading metadata (e.g. apierror) see the same ID.
+func seedIncomingContext(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx, requestID := seedContext(ctx, func(key string) string {
+		if vals := md.Get(key); len(vals) > 0 {
+			return vals[0]
+		}
+		return ""
+	})
+	if len(md.Get(MetadataRequestID)) == 0 {
+		md = md.Copy()
+		md.Set(MetadataRequestID, requestID)
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+	return ctx, requestID
+}
+
+// healthMethodPrefix is the prefix of the grpc.health.v1 methods, logged at debug level
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	entry := srvlog.FromContext(ctx).WithFields(logrus.Fields{
+		"grpc_method": method,
+		"grpc_code":   status.Code(err).String(),
+		"duration_ms": fmt.Sprintf("%.3f", float64(time.Since(start).Microseconds())/1000),
+	})
+	if err != nil {
+		entry.Warn("finished grpc call with error")
+		return
+	}
+	if strings.HasPrefix(method, healthMethodPrefix) {
+		// probed every few seconds
+		entry.Debug("finished grpc call")
+		return
+	}
+	entry.Info("finished grpc call")
+}
+
+// UnaryServerInt seeds the request scoped log fields from the incoming metadata,
+// returns the request ID in the response header and logs the completed call
+func UnaryServerInt() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, requestID := seedIncomingContext(ctx)
+		if err := grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, requestID)); err != nil {
+			srvlog.FromContext(ctx).Debugf("requestlog: unable to set the request ID header: %v", err)
+		}
+
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// UnaryServerInterceptor chains UnaryServerInt, so it can be used alongside
+// an interceptor set with grpc.UnaryInterceptor
+func UnaryServerInterceptor() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(UnaryServerInt())
+}
+
+type seededServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *seededServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInt is the streaming equivalent of UnaryServerInt
+func StreamServerInt() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, requestID := seedIncomingContext(ss.Context())
+		if err := ss.SetHeader(metadata.Pairs(MetadataRequestID, requestID)); err != nil {
+			srvlog.FromContext(ctx).Debugf("requestlog: unable to set the request ID header: %v", err)
+		}
+
+		err := handler(srv, &seededServerStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// StreamServerInterceptor chains StreamServerInt, so it can be used alongside
+// an interceptor set with grpc.StreamInterceptor
+func StreamServerInterceptor() grpc.ServerOption {
+	return grpc.ChainStreamInterceptor(StreamServerInt())
+}
+
+// outgoingContext forwards the request scoped fields of ctx as outgoing
+// metadata, keys already set on the outgoing metadata are left alone
+func outgoingContext(ctx context.Context) context.Context {
+	fields := srvlog.FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return ctx
+	}
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	var pairs []string
+	for field, key := range forwardedKeys {
+		value, ok := fields[field].(string)
+		if !ok || value == "" || len(md.Get(key)) > 0 {
+			continue
+		}
+		pairs = append(pairs, key, value)
+	}
+	if len(pairs) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// UnaryClientInt forwards the request scoped log fields to the called service
+func UnaryClientInt() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryClientInterceptor chains UnaryClientInt, so it can be used alongside
+// an interceptor set with grpc.WithUnaryInterceptor
+func UnaryClientInterceptor() grpc.DialOption {
+	return grpc.WithChainUnaryInterceptor(UnaryClientInt())
+}
+
+// StreamClientInt forwards the request scoped log fields to the called service
+func StreamClientInt() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingContext(ctx), desc, cc, method, opts...)
+	}
+}
+
+// StreamClientInterceptor chains StreamClientInt, so it can be used alongside
+// an interceptor set with grpc.WithStreamInterceptor
+func StreamClientInterceptor() grpc.DialOption {
+	return grpc.WithChainStreamInterceptor(StreamClientInt())
+}
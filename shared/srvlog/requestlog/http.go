@@ -0,0 +1,48 @@
+package requestlog
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/sirupsen/logrus"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// HTTPMiddleware seeds the request scoped log fields from the request headers,
+// generating an X-Request-ID when absent. The request ID is set on both the
+// request (so it is forwarded) and the response, and the completed request is logged.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, requestID := seedContext(r.Context(), r.Header.Get)
+		r.Header.Set(HeaderRequestID, requestID)
+		w.Header().Set(HeaderRequestID, requestID)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		srvlog.FromContext(ctx).WithFields(logrus.Fields{
+			"http_method": r.Method,
+			"http_path":   r.URL.Path,
+			"http_status": recorder.status,
+			"duration_ms": fmt.Sprintf("%.3f", float64(time.Since(start).Microseconds())/1000),
+		}).Info("finished http request")
+	})
+}
@@ -0,0 +1,94 @@
+// Package requestlog seeds the srvlog request scoped fields (see srvlog.ContextWithFields)
+// from incoming gRPC metadata and HTTP headers, and forwards them to downstream gRPC calls,
+// so that every log line written for a request shares one correlation ID.
+package requestlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID is the HTTP header carrying the request (correlation) ID
+const HeaderRequestID = "X-Request-ID"
+
+// Metadata (and lower-cased HTTP header) keys the request scoped fields are read from
+const (
+	MetadataRequestID   = "x-request-id"
+	MetadataTraceParent = "traceparent"
+	MetadataUserID      = "x-user-id"
+	MetadataTenant      = "x-tenant-id"
+)
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// fieldsFromValues builds the request scoped fields using get to read a metadata key or header
+func fieldsFromValues(get func(key string) string) logrus.Fields {
+	fields := logrus.Fields{}
+	if requestID := get(MetadataRequestID); requestID != "" {
+		fields[srvlog.FieldRequestID] = requestID
+	}
+	if traceID, spanID, ok := ParseTraceParent(get(MetadataTraceParent)); ok {
+		fields[srvlog.FieldTraceID] = traceID
+		fields[srvlog.FieldSpanID] = spanID
+	}
+	if userID := get(MetadataUserID); userID != "" {
+		fields[srvlog.FieldUserID] = userID
+	}
+	if tenant := get(MetadataTenant); tenant != "" {
+		fields[srvlog.FieldTenant] = tenant
+	}
+	return fields
+}
+
+// forwardedKeys maps the request scoped fields forwarded to downstream services to their metadata key
+var forwardedKeys = map[string]string{
+	srvlog.FieldRequestID: MetadataRequestID,
+	srvlog.FieldUserID:    MetadataUserID,
+	srvlog.FieldTenant:    MetadataTenant,
+}
+
+// ParseTraceParent parses a W3C traceparent header ("00-<trace-id>-<span-id>-<flags>")
+func ParseTraceParent(traceParent string) (traceID string, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		// all zero ids are invalid
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// seedContext attaches the request scoped fields to ctx, generating a request ID if none was received
+func seedContext(ctx context.Context, get func(key string) string) (context.Context, string) {
+	fields := fieldsFromValues(get)
+	requestID, _ := fields[srvlog.FieldRequestID].(string)
+	if requestID == "" {
+		// keep a request ID already attached by the caller
+		requestID = srvlog.RequestIDFromContext(ctx)
+	}
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	fields[srvlog.FieldRequestID] = requestID
+	// the span of this service (see the tracing package) wins over the received traceparent
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields[srvlog.FieldTraceID] = spanContext.TraceID().String()
+		fields[srvlog.FieldSpanID] = spanContext.SpanID().String()
+	}
+	return srvlog.ContextWithFields(ctx, fields), requestID
+}
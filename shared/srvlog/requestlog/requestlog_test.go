@@ -0,0 +1,118 @@
+package requestlog
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	out := srvlog.GlobalLogger.Output()
+	srvlog.GlobalLogger.SetOutput(buf)
+	t.Cleanup(func() { srvlog.GlobalLogger.SetOutput(out) })
+	return buf
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		requestID     string
+		wantRequestID string
+	}{
+		{name: "Received", requestID: "req-123", wantRequestID: "req-123"},
+		{name: "Generated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+
+			var handlerRequestID string
+			handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerRequestID = srvlog.RequestIDFromContext(r.Context())
+				srvlog.FromContext(r.Context()).Info("inside handler")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+			if tt.requestID != "" {
+				req.Header.Set(HeaderRequestID, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			responseID := rec.Header().Get(HeaderRequestID)
+			if responseID == "" || responseID != handlerRequestID {
+				t.Fatalf("response request ID %q, handler request ID %q", responseID, handlerRequestID)
+			}
+			if tt.wantRequestID != "" && responseID != tt.wantRequestID {
+				t.Errorf("request ID = %q, want %q", responseID, tt.wantRequestID)
+			}
+
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("expected 2 log lines, got %q", logs.String())
+			}
+			for _, line := range lines {
+				if !strings.Contains(line, responseID) {
+					t.Errorf("log line missing request ID %q: %s", responseID, line)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCPropagation(t *testing.T) {
+	captureLogs(t)
+
+	// the gateway (or another service) calls the server with a request scoped context
+	ctx := srvlog.ContextWithFields(context.Background(), map[string]interface{}{
+		srvlog.FieldRequestID: "req-456",
+		srvlog.FieldUserID:    "user-1",
+	})
+
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	if err := UnaryClientInt()(ctx, "/products.ProductService/GetProducts", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+	if got := outgoing.Get(MetadataRequestID); len(got) != 1 || got[0] != "req-456" {
+		t.Fatalf("outgoing request ID = %v", got)
+	}
+
+	// the server seeds its context from the incoming metadata
+	serverCtx := metadata.NewIncomingContext(context.Background(), outgoing)
+	var handlerFields map[string]interface{}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerFields = srvlog.FieldsFromContext(ctx)
+		return nil, nil
+	}
+	if _, err := UnaryServerInt()(serverCtx, nil, &grpc.UnaryServerInfo{FullMethod: "/products.ProductService/GetProducts"}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if handlerFields[srvlog.FieldRequestID] != "req-456" || handlerFields[srvlog.FieldUserID] != "user-1" {
+		t.Errorf("unexpected server fields %v", handlerFields)
+	}
+}
+
+func TestSeedIncomingContextGeneratesRequestID(t *testing.T) {
+	ctx, requestID := seedIncomingContext(context.Background())
+	if requestID == "" {
+		t.Fatal("expected a generated request ID")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if got := md.Get(MetadataRequestID); len(got) != 1 || got[0] != requestID {
+		t.Errorf("incoming metadata request ID = %v, want %q", got, requestID)
+	}
+}
@@ -0,0 +1,380 @@
+// Package rotatefile is an io.Writer appending to a log file which is rotated
+// by size and/or time. Rotated files are renamed with a timestamp, optionally
+// gzip compressed, and removed once older than MaxAge or beyond MaxBackups.
+// Reopen (e.g. on SIGHUP) makes it compatible with an external logrotate.
+package rotatefile
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeFormat is the timestamp added to rotated file names
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+const compressSuffix = ".gz"
+
+var ErrClosed = errors.New("rotating file closed")
+
+// Options configures a Writer
+type Options struct {
+	// Path of the log file, rotated files are written next to it
+	Path string
+	// MaxSize in bytes before the file is rotated, 0 disables size based rotation
+	MaxSize int64
+	// Interval rotates the file when it has been open for longer, 0 disables time based rotation
+	Interval time.Duration
+	// MaxAge removes rotated files older than this, 0 keeps them
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, 0 keeps them all
+	MaxBackups int
+	// Compress gzips the rotated files
+	Compress bool
+	// FileMode of new files, defaults to 0644
+	FileMode os.FileMode
+
+	// Clock returns the current time, defaults to time.Now (useful in tests)
+	Clock func() time.Time
+}
+
+// Writer is a concurrency safe rotating log file
+type Writer struct {
+	opts Options
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+
+	// background compression and cleanup, one rotated file at a time
+	millWait  sync.WaitGroup
+	millMutex sync.Mutex
+
+	stopSignals chan struct{}
+}
+
+// New opens (or creates) the log file at opts.Path
+func New(opts Options) (*Writer, error) {
+	if opts.Path == "" {
+		return nil, errors.New("rotatefile: missing path")
+	}
+	if opts.FileMode == 0 {
+		opts.FileMode = 0644
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+
+	w := &Writer{opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the current log file
+func (w *Writer) Path() string {
+	return w.opts.Path
+}
+
+// open opens the file at the configured path, the caller must hold mu
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.opts.Path), 0755); err != nil {
+		return fmt.Errorf("rotatefile: creating log directory: %w", err)
+	}
+	file, err := os.OpenFile(w.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.opts.FileMode)
+	if err != nil {
+		return fmt.Errorf("rotatefile: opening %s: %w", w.opts.Path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("rotatefile: stat %s: %w", w.opts.Path, err)
+	}
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = w.opts.Clock()
+	return nil
+}
+
+// Write appends p to the log file, rotating it first if p does not fit
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *Writer) shouldRotate(writeLen int64) bool {
+	if w.size == 0 {
+		// never rotate an empty file, even if a single line is bigger than MaxSize
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+writeLen > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.Interval > 0 && w.opts.Clock().Sub(w.openedAt) >= w.opts.Interval
+}
+
+// Rotate closes the current file, renames it with a timestamp and opens a new one
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	return w.rotate()
+}
+
+// rotate must be called with mu held
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("rotatefile: closing %s: %w", w.opts.Path, err)
+		}
+		w.file = nil
+	}
+
+	backup := w.backupName(w.opts.Clock())
+	if err := os.Rename(w.opts.Path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotatefile: renaming %s: %w", w.opts.Path, err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.millWait.Add(1)
+	go func() {
+		defer w.millWait.Done()
+		w.millMutex.Lock()
+		defer w.millMutex.Unlock()
+		w.mill(backup)
+	}()
+	return nil
+}
+
+// Reopen closes and reopens the log file at the configured path, without
+// renaming it. Use it after an external tool (logrotate) moved the file.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+// ReopenOnSignal reopens the log file whenever SIGHUP is received, until Close
+func (w *Writer) ReopenOnSignal() {
+	w.mu.Lock()
+	if w.stopSignals != nil || w.closed {
+		w.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	w.stopSignals = stop
+	w.mu.Unlock()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				if err := w.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "rotatefile: reopen on SIGHUP: %v\n", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close closes the log file, waiting for rotated files to be compressed
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	if w.stopSignals != nil {
+		close(w.stopSignals)
+	}
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.millWait.Wait()
+	return err
+}
+
+// backupName returns the name of the file rotated at t (in UTC), e.g. /var/log/trade-2023-12-03T09-49-11.000.log
+func (w *Writer) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	t = t.UTC()
+	name := filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+	// a second rotation within the same millisecond gets a suffix
+	for i := 1; fileExists(name) || fileExists(name+compressSuffix); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s%s.%d%s", prefix, t.Format(backupTimeFormat), i, ext))
+	}
+	return name
+}
+
+func (w *Writer) nameParts() (dir string, prefix string, ext string) {
+	dir = filepath.Dir(w.opts.Path)
+	base := filepath.Base(w.opts.Path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+type backupFile struct {
+	path      string
+	timestamp time.Time
+}
+
+// Backups returns the rotated files, newest first
+func (w *Writer) Backups() ([]string, error) {
+	backups, err := w.backups()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		paths = append(paths, backup.path)
+	}
+	return paths, nil
+}
+
+func (w *Writer) backups() ([]backupFile, error) {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(strings.TrimSuffix(name, compressSuffix), prefix)
+		stamp = strings.TrimSuffix(stamp, ext)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		timestamp, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.UTC)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), timestamp: timestamp})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].timestamp.Equal(backups[j].timestamp) {
+			return backups[i].path > backups[j].path
+		}
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+	return backups, nil
+}
+
+// mill compresses the newly rotated file and applies the retention rules
+func (w *Writer) mill(rotated string) {
+	if w.opts.Compress {
+		if err := compressFile(rotated); err != nil {
+			fmt.Fprintf(os.Stderr, "rotatefile: compressing %s: %v\n", rotated, err)
+		}
+	}
+
+	if w.opts.MaxAge <= 0 && w.opts.MaxBackups <= 0 {
+		return
+	}
+	backups, err := w.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotatefile: listing backups: %v\n", err)
+		return
+	}
+
+	cutoff := w.opts.Clock().Add(-w.opts.MaxAge)
+	for i, backup := range backups {
+		tooMany := w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups
+		tooOld := w.opts.MaxAge > 0 && backup.timestamp.Before(cutoff)
+		if tooMany || tooOld {
+			if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "rotatefile: removing %s: %v\n", backup.path, err)
+			}
+		}
+	}
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(name + compressSuffix)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + compressSuffix)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
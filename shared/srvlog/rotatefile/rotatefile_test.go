@@ -0,0 +1,224 @@
+package rotatefile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a manually advanced clock
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (tc *testClock) Now() time.Time {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.now
+}
+
+func (tc *testClock) Advance(d time.Duration) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.now = tc.now.Add(d)
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2023, 12, 3, 9, 0, 0, 0, time.UTC)}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, compressSuffix) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		writes      int
+		advance     time.Duration
+		wantBackups int
+		wantSuffix  string
+	}{
+		{
+			name:        "BySize",
+			opts:        Options{MaxSize: 20},
+			writes:      5, // 10 bytes each, 2 per file
+			wantBackups: 2,
+			wantSuffix:  ".log",
+		},
+		{
+			name:        "ByInterval",
+			opts:        Options{Interval: time.Hour},
+			writes:      3,
+			advance:     time.Hour,
+			wantBackups: 2,
+			wantSuffix:  ".log",
+		},
+		{
+			name:        "Compressed",
+			opts:        Options{MaxSize: 10, Compress: true},
+			writes:      3,
+			wantBackups: 2,
+			wantSuffix:  ".log.gz",
+		},
+		{
+			name:        "MaxBackups",
+			opts:        Options{MaxSize: 10, MaxBackups: 2},
+			writes:      6,
+			advance:     time.Second,
+			wantBackups: 2,
+			wantSuffix:  ".log",
+		},
+		{
+			name:        "MaxAge",
+			opts:        Options{MaxSize: 10, MaxAge: 90 * time.Minute},
+			writes:      5,
+			advance:     time.Hour,
+			wantBackups: 2, // rotated at +1h,+2h,+3h,+4h, only +3h and +4h are younger than 90m at +4h
+			wantSuffix:  ".log",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newTestClock()
+			opts := tt.opts
+			opts.Path = filepath.Join(t.TempDir(), "trade.log")
+			opts.Clock = clock.Now
+
+			w, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.writes; i++ {
+				if i > 0 {
+					clock.Advance(tt.advance)
+				}
+				if _, err := fmt.Fprintf(w, "line %04d\n", i); err != nil {
+					t.Fatal(err)
+				}
+				// wait for each rotated file to be processed, so retention is deterministic
+				w.millWait.Wait()
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			backups, err := w.Backups()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) != tt.wantBackups {
+				t.Fatalf("got %d backups %v, want %d", len(backups), backups, tt.wantBackups)
+			}
+			for _, backup := range backups {
+				if !strings.HasSuffix(backup, tt.wantSuffix) {
+					t.Errorf("backup %s does not end with %s", backup, tt.wantSuffix)
+				}
+				if content := readFile(t, backup); !strings.HasPrefix(content, "line ") {
+					t.Errorf("unexpected backup content %q", content)
+				}
+			}
+
+			current := readFile(t, opts.Path)
+			if want := fmt.Sprintf("line %04d\n", tt.writes-1); !strings.HasSuffix(current, want) {
+				t.Errorf("current file %q does not end with the last line", current)
+			}
+		})
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.log")
+	w, err := New(Options{Path: path, Clock: newTestClock().Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	fmt.Fprintln(w, "before logrotate")
+
+	// logrotate moves the file away and sends SIGHUP
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(w, "after logrotate")
+
+	if got := readFile(t, path+".1"); got != "before logrotate\n" {
+		t.Errorf("moved file = %q", got)
+	}
+	if got := readFile(t, path); got != "after logrotate\n" {
+		t.Errorf("reopened file = %q", got)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.log")
+	w, err := New(Options{Path: path, MaxSize: 1000, Clock: newTestClock().Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, lines = 8, 200
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				fmt.Fprintf(w, "writer %d line %03d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := w.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, name := range append(backups, path) {
+		for _, line := range strings.Split(strings.TrimSpace(readFile(t, name)), "\n") {
+			if !strings.HasPrefix(line, "writer ") || len(line) != len("writer 0 line 000") {
+				t.Fatalf("interleaved line %q in %s", line, name)
+			}
+			total++
+		}
+	}
+	if total != writers*lines {
+		t.Errorf("got %d lines, want %d", total, writers*lines)
+	}
+}
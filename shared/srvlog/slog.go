@@ -0,0 +1,91 @@
+package srvlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"github.com/sirupsen/logrus"
+)
+
+// Slog returns a *slog.Logger writing through the GlobalLogger, so library code
+// can accept a *slog.Logger while the logrus hooks and request scoped fields still apply
+func Slog() *slog.Logger {
+	return NewSlogLogger(GlobalLogger)
+}
+
+// NewSlogLogger returns a *slog.Logger writing through l
+func NewSlogLogger(l *logruswrapper.LogrusWrapper) *slog.Logger {
+	return slog.New(&logrusHandler{logger: l})
+}
+
+// SetHandler replaces the slog handler of the GlobalLogger
+func SetHandler(handler slog.Handler) {
+	GlobalLogger.SetHandler(handler)
+}
+
+// logrusHandler is a slog.Handler logging records as logrus entries, so that the
+// logrus hooks fire before the entry reaches the slog handler of the wrapper
+type logrusHandler struct {
+	logger *logruswrapper.LogrusWrapper
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (h *logrusHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.IsLevelEnabled(logruswrapper.LogrusLevel(level))
+}
+
+func (h *logrusHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, len(h.attrs)+r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	fields := logruswrapper.AttrsToFields(append(append([]slog.Attr{}, h.attrs...), attrs...)...)
+
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		if frame.Function != "" {
+			fields["origin_func"] = KeepNumDirs(frame.Function, NumPathsToLog)
+			fields[logruswrapper.FieldOriginPackage] = logruswrapper.FuncPackage(frame.Function)
+		}
+		if frame.File != "" {
+			fields["origin_file"] = KeepNumDirs(frame.File, NumPathsToLog)
+			fields["origin_line"] = frame.Line
+		}
+	}
+
+	level := logruswrapper.LogrusLevel(r.Level)
+	if level < logrus.FatalLevel {
+		// slog callers don't expect a panic
+		level = logrus.FatalLevel
+	}
+	FromContext(ctx).WithTime(r.Time).WithFields(fields).Log(level, r.Message)
+	return nil
+}
+
+func (h *logrusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+func (h *logrusHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groups = append(append([]string{}, h.groups...), name)
+	return &clone
+}
@@ -0,0 +1,79 @@
+package srvlog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := GlobalLogger.Output()
+	GlobalLogger.SetOutput(buf)
+	GlobalLogger.SetStructuredLogging(true)
+	defer func() {
+		GlobalLogger.SetOutput(out)
+		GlobalLogger.SetStructuredLogging(false)
+	}()
+
+	ctx := ContextWithField(context.Background(), FieldRequestID, "req-3")
+	Slog().With("component", "repo").WithGroup("product").InfoContext(ctx, "cached", "id", "42")
+	Infof("global %s", "info")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	for _, want := range []string{`"request_id":"req-3"`, `"component":"repo"`, `"product.id":"42"`, `"origin_func":"shared/srvlog.TestSlog"`, `"msg":"cached"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected %s in %s", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], `"msg":"global info"`) {
+		t.Errorf("expected the global funcs to write through the same handler, got %s", lines[1])
+	}
+}
+
+func TestPackageLevelWithContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := GlobalLogger.Output()
+	GlobalLogger.SetOutput(buf)
+	defer func() {
+		GlobalLogger.SetOutput(out)
+		ClearPackageLevel("shared/srvlog")
+		ClearPackageLevel("sales/trade")
+	}()
+
+	ctx := ContextWithField(context.Background(), FieldRequestID, "req-4")
+	WithContext(ctx).Debugf("before override")
+	if err := SetPackageLevel("sales/trade", slog.LevelDebug, 0); err != nil {
+		t.Fatal(err)
+	}
+	WithContext(ctx).Debugf("other package override")
+	if err := SetPackageLevel("shared/srvlog", slog.LevelDebug, 0); err != nil {
+		t.Fatal(err)
+	}
+	WithContext(ctx).Debugf("with context")
+	GlobalLogger.Debugf("global logger")
+	Debugf("global func")
+
+	logged := buf.String()
+	for _, unwanted := range []string{"before override", "other package override"} {
+		if strings.Contains(logged, unwanted) {
+			t.Errorf("unexpected %q in %q", unwanted, logged)
+		}
+	}
+	for _, want := range []string{"with context", "req-4", "global logger", "global func"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected %q in %q", want, logged)
+		}
+	}
+
+	ClearPackageLevel("shared/srvlog")
+	ClearPackageLevel("sales/trade")
+	if GlobalLogger.ReportCaller {
+		t.Error("expected the caller to be reported only while overrides are set")
+	}
+}
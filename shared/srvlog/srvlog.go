@@ -1,11 +1,17 @@
 package srvlog
 
-import "github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+import (
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/redact"
+)
 
 // GlobalLogger is used by the Global Logging functions
 var GlobalLogger = NewGlobalSRVLogger()
 
-// NewGlobalSRVLogger creates and return a new Logger
+// NewGlobalSRVLogger creates and return a new Logger, redacting sensitive
+// fields and messages with the redact.Default engine
 func NewGlobalSRVLogger() *logruswrapper.LogrusWrapper {
-	return logruswrapper.NewLogrusWrapper()
+	logger := logruswrapper.NewLogrusWrapper()
+	logger.AddHook(redact.NewHook(nil))
+	return logger
 }
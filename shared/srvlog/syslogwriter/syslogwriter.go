@@ -0,0 +1,490 @@
+// Package syslogwriter sends log lines to a remote syslog server using RFC5424
+// messages over udp://, tcp:// or tcp+tls://. TCP messages use octet-counting
+// framing (RFC6587). Lines are queued in a bounded in-memory buffer and written
+// by a background goroutine which reconnects with backoff, so a slow or
+// unreachable syslog server never blocks or kills the process.
+package syslogwriter
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Supported URL schemes
+const (
+	SchemeUDP = "udp"
+	SchemeTCP = "tcp"
+	SchemeTLS = "tcp+tls"
+)
+
+// Default ports per scheme
+const (
+	DefaultPortUDP = "514"
+	DefaultPortTCP = "601"
+	DefaultPortTLS = "6514"
+)
+
+// Facilities, see RFC5424 section 6.2.1
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+)
+
+var (
+	ErrInvalidURL = errors.New("invalid syslog URL")
+	ErrClosed     = errors.New("syslog writer closed")
+)
+
+// Options configures a Writer, zero values use the defaults
+type Options struct {
+	// AppName is the APP-NAME of every message, defaults to the executable name
+	AppName string
+	// Hostname is the HOSTNAME of every message, defaults to os.Hostname
+	Hostname string
+	// Facility defaults to FacilityDaemon
+	Facility int
+	// SDID is the SD-ID of the structured data element, defaults to "srvlog@32473"
+	SDID string
+	// SDFields are the fields written as structured data (see WriteLevel)
+	SDFields []string
+
+	// TLSConfig is used for tcp+tls://
+	TLSConfig *tls.Config
+	// DialTimeout defaults to 5s
+	DialTimeout time.Duration
+	// WriteTimeout defaults to 5s
+	WriteTimeout time.Duration
+
+	// BufferSize is the maximum number of messages held while disconnected,
+	// the oldest messages are dropped first. Defaults to 1000.
+	BufferSize int
+	// MinBackoff and MaxBackoff bound the reconnect delay, defaults 100ms and 30s
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnError is called when a connection or write fails, defaults to printing to stderr
+	OnError func(err error)
+
+	// dial is replaced in tests
+	dial func(network, address string) (net.Conn, error)
+}
+
+// DefaultSDFields are the request scoped fields written as structured data
+var DefaultSDFields = []string{"app", "request_id", "trace_id", "span_id", "user_id", "tenant"}
+
+// ParseURL parses udp://, tcp:// and tcp+tls:// syslog URLs.
+// A URL without a scheme ("host" or "host:port") uses UDP.
+func ParseURL(rawURL string) (scheme string, address string, err error) {
+	scheme = SchemeUDP
+	hostPort := rawURL
+	if strings.Contains(rawURL, "://") {
+		parsed, parseErr := url.Parse(rawURL)
+		if parseErr != nil {
+			return "", "", fmt.Errorf("%w %q: %v", ErrInvalidURL, rawURL, parseErr)
+		}
+		scheme = strings.ToLower(parsed.Scheme)
+		hostPort = parsed.Host
+	}
+
+	defaultPort := ""
+	switch scheme {
+	case SchemeUDP:
+		defaultPort = DefaultPortUDP
+	case SchemeTCP:
+		defaultPort = DefaultPortTCP
+	case SchemeTLS:
+		defaultPort = DefaultPortTLS
+	default:
+		return "", "", fmt.Errorf("%w %q: unsupported scheme %q", ErrInvalidURL, rawURL, scheme)
+	}
+
+	if hostPort == "" {
+		return "", "", fmt.Errorf("%w %q: missing host", ErrInvalidURL, rawURL)
+	}
+	if _, _, splitErr := net.SplitHostPort(hostPort); splitErr != nil {
+		hostPort = net.JoinHostPort(strings.Trim(hostPort, "[]"), defaultPort)
+	}
+	return scheme, hostPort, nil
+}
+
+type queuedMessage struct {
+	seq uint64
+	msg []byte
+}
+
+// Writer is an io.Writer sending every Write as one syslog message
+type Writer struct {
+	scheme  string
+	address string
+	opts    Options
+	procID  string
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []queuedMessage
+	nextSeq uint64
+	closed  bool
+	dropped uint64
+
+	conn net.Conn
+	done chan struct{}
+
+	now func() time.Time
+}
+
+// New returns a Writer for a udp://, tcp:// or tcp+tls:// URL. The connection
+// is made in the background, New only fails if the URL is invalid.
+func New(rawURL string, opts Options) (*Writer, error) {
+	scheme, address, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.Facility == 0 {
+		opts.Facility = FacilityDaemon
+	}
+	if opts.SDID == "" {
+		opts.SDID = "srvlog@32473"
+	}
+	if opts.SDFields == nil {
+		opts.SDFields = DefaultSDFields
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 5 * time.Second
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1000
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = 30 * time.Second
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			fmt.Fprintf(os.Stderr, "syslogwriter: %v\n", err)
+		}
+	}
+
+	w := &Writer{
+		scheme:  scheme,
+		address: address,
+		opts:    opts,
+		procID:  strconv.Itoa(os.Getpid()),
+		done:    make(chan struct{}),
+		now:     time.Now,
+	}
+	w.cond = sync.NewCond(&w.mu)
+	if w.opts.dial == nil {
+		w.opts.dial = w.dial
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Address returns the host:port messages are sent to
+func (w *Writer) Address() string {
+	return w.address
+}
+
+// Dropped returns the number of messages dropped because the buffer was full
+func (w *Writer) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Write queues p as one INFO message
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteLevel(slog.LevelInfo, nil, p)
+}
+
+// WriteLevel queues p as one message with the severity of level. The string
+// values of fields listed in Options.SDFields are written as structured data.
+func (w *Writer) WriteLevel(level slog.Level, fields map[string]interface{}, p []byte) (int, error) {
+	msg := w.format(level, fields, p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if len(w.queue) >= w.opts.BufferSize {
+		// drop the oldest message
+		w.queue[0] = queuedMessage{}
+		w.queue = w.queue[1:]
+		atomic.AddUint64(&w.dropped, 1)
+	}
+	w.nextSeq++
+	w.queue = append(w.queue, queuedMessage{seq: w.nextSeq, msg: msg})
+	w.cond.Signal()
+	return len(p), nil
+}
+
+// Close stops the background writer, waiting up to timeout for the buffer to be sent
+func (w *Writer) Close(timeout time.Duration) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+		w.mu.Lock()
+		if w.conn != nil {
+			w.conn.Close()
+		}
+		w.mu.Unlock()
+	}
+	return nil
+}
+
+// severity maps slog levels to RFC5424 severities
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.Level(16):
+		return 1 // alert (panic)
+	case level >= slog.Level(12):
+		return 2 // critical (fatal)
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// format builds the RFC5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (w *Writer) format(level slog.Level, fields map[string]interface{}, p []byte) []byte {
+	var b strings.Builder
+	b.Grow(len(p) + 128)
+
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s - ",
+		w.opts.Facility*8+severity(level),
+		w.now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerValue(w.opts.Hostname, 255),
+		headerValue(w.opts.AppName, 48),
+		headerValue(w.procID, 128),
+	)
+	b.WriteString(w.structuredData(fields))
+	if msg := strings.TrimRight(string(p), "\r\n"); msg != "" {
+		b.WriteByte(' ')
+		b.WriteString(msg)
+	}
+	return []byte(b.String())
+}
+
+func (w *Writer) structuredData(fields map[string]interface{}) string {
+	var params []string
+	for _, name := range w.opts.SDFields {
+		value, ok := fields[name]
+		if !ok || value == nil {
+			continue
+		}
+		strValue := fmt.Sprintf("%v", value)
+		if strValue == "" {
+			continue
+		}
+		params = append(params, sdName(name)+`="`+sdEscaper.Replace(strValue)+`"`)
+	}
+	if len(params) == 0 {
+		return "-"
+	}
+	return "[" + sdName(w.opts.SDID) + " " + strings.Join(params, " ") + "]"
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// sdName strips the characters not allowed in an SD-NAME
+func sdName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// headerValue returns a printable header field, "-" if empty
+func headerValue(value string, maxLen int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	return value
+}
+
+func (w *Writer) dial(network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: w.opts.DialTimeout}
+	if w.scheme == SchemeTLS {
+		return tls.DialWithDialer(dialer, "tcp", address, w.opts.TLSConfig)
+	}
+	return dialer.Dial(network, address)
+}
+
+// frame returns the bytes to write for msg, TCP uses octet-counting
+func (w *Writer) frame(msg []byte) []byte {
+	if w.scheme == SchemeUDP {
+		return msg
+	}
+	return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+}
+
+// next waits for a queued message, it returns false once closed and empty
+func (w *Writer) next() (queuedMessage, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && !w.closed {
+		w.cond.Wait()
+	}
+	if len(w.queue) == 0 {
+		return queuedMessage{}, false
+	}
+	return w.queue[0], true
+}
+
+// pop removes the sent message from the head of the queue, unless it has been dropped meanwhile
+func (w *Writer) pop(sent queuedMessage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.queue) > 0 && w.queue[0].seq == sent.seq {
+		w.queue[0] = queuedMessage{}
+		w.queue = w.queue[1:]
+	}
+}
+
+func (w *Writer) isClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+func (w *Writer) setConn(conn net.Conn) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn != nil && w.conn != conn {
+		w.conn.Close()
+	}
+	w.conn = conn
+}
+
+// run writes the queued messages, reconnecting with backoff
+func (w *Writer) run() {
+	defer close(w.done)
+	defer w.setConn(nil)
+
+	network := "udp"
+	if w.scheme != SchemeUDP {
+		network = "tcp"
+	}
+	backoff := w.opts.MinBackoff
+
+	var conn net.Conn
+	for {
+		queued, ok := w.next()
+		if !ok {
+			return
+		}
+
+		if conn == nil {
+			var err error
+			conn, err = w.opts.dial(network, w.address)
+			if err != nil {
+				conn = nil
+				w.opts.OnError(fmt.Errorf("connecting to %s://%s: %w (retrying in %v)", w.scheme, w.address, err, backoff))
+				if !w.sleep(backoff) {
+					return
+				}
+				backoff = nextBackoff(backoff, w.opts.MaxBackoff)
+				continue
+			}
+			w.setConn(conn)
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(w.opts.WriteTimeout))
+		if _, err := conn.Write(w.frame(queued.msg)); err != nil {
+			w.opts.OnError(fmt.Errorf("writing to %s://%s: %w", w.scheme, w.address, err))
+			// the message stays queued and is sent again after reconnecting
+			w.setConn(nil)
+			conn = nil
+			if !w.sleep(backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff, w.opts.MaxBackoff)
+			continue
+		}
+		backoff = w.opts.MinBackoff
+		w.pop(queued)
+	}
+}
+
+// sleep waits for d, returning false if the writer was closed meanwhile
+// (queued messages are not retried once closed)
+func (w *Writer) sleep(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if w.isClosed() {
+			return false
+		}
+		step := time.Until(deadline)
+		if step > 50*time.Millisecond {
+			step = 50 * time.Millisecond
+		}
+		time.Sleep(step)
+	}
+	return !w.isClosed()
+}
+
+func nextBackoff(current, max time.Duration) time.Duration {
+	next := current * 2
+	if next > max {
+		return max
+	}
+	return next
+}
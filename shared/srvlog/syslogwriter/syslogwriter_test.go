@@ -0,0 +1,199 @@
+package syslogwriter
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		wantScheme  string
+		wantAddress string
+		wantErr     bool
+	}{
+		{url: "logs.internal", wantScheme: SchemeUDP, wantAddress: "logs.internal:514"},
+		{url: "logs.internal:5140", wantScheme: SchemeUDP, wantAddress: "logs.internal:5140"},
+		{url: "udp://logs.internal", wantScheme: SchemeUDP, wantAddress: "logs.internal:514"},
+		{url: "tcp://logs.internal", wantScheme: SchemeTCP, wantAddress: "logs.internal:601"},
+		{url: "tcp+tls://logs.internal:7000", wantScheme: SchemeTLS, wantAddress: "logs.internal:7000"},
+		{url: "tcp+tls://[::1]", wantScheme: SchemeTLS, wantAddress: "[::1]:6514"},
+		{url: "http://logs.internal", wantErr: true},
+		{url: "tcp://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			scheme, address, err := ParseURL(tt.url)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidURL) {
+					t.Fatalf("expected ErrInvalidURL, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scheme != tt.wantScheme || address != tt.wantAddress {
+				t.Errorf("ParseURL() = %s, %s, want %s, %s", scheme, address, tt.wantScheme, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	w := &Writer{
+		opts: Options{
+			AppName:  "trade",
+			Hostname: "host-1",
+			Facility: FacilityDaemon,
+			SDID:     "srvlog@32473",
+			SDFields: DefaultSDFields,
+		},
+		procID: "123",
+		now:    func() time.Time { return time.Date(2023, 12, 3, 9, 49, 11, 0, time.UTC) },
+	}
+
+	got := string(w.format(slog.LevelError, map[string]interface{}{
+		"request_id": "req-1",
+		"tenant":     `acme "quoted" ]`,
+		"ignored":    "x",
+	}, []byte("{\"msg\":\"sale failed\"}\n")))
+	want := `<27>1 2023-12-03T09:49:11.000000Z host-1 trade 123 - [srvlog@32473 request_id="req-1" tenant="acme \"quoted\" \]"] {"msg":"sale failed"}`
+	if got != want {
+		t.Errorf("format()\n got: %s\nwant: %s", got, want)
+	}
+
+	if got := string(w.format(slog.LevelInfo, nil, []byte("hello"))); !strings.HasPrefix(got, "<30>1 ") || !strings.HasSuffix(got, " - - hello") {
+		t.Errorf("unexpected message without structured data: %s", got)
+	}
+}
+
+// readFrames reads octet-counted frames from conn
+func readFrames(conn net.Conn, frames chan<- string) {
+	reader := bufio.NewReader(conn)
+	for {
+		lengthStr, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(lengthStr))
+		if err != nil {
+			return
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return
+		}
+		frames <- string(buf)
+	}
+}
+
+func TestTCPReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	frames := make(chan string, 10)
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go readFrames(conn, frames)
+		}
+	}()
+
+	w, err := New("tcp://"+listener.Addr().String(), Options{
+		AppName:    "products",
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+		OnError:    func(err error) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close(time.Second)
+
+	w.Write([]byte("first"))
+	if frame := waitFrame(t, frames); !strings.HasSuffix(frame, " first") {
+		t.Fatalf("unexpected frame %q", frame)
+	}
+
+	// the server drops the connection, later lines arrive after reconnecting
+	(<-conns).Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 0; time.Now().Before(deadline); i++ {
+		w.Write([]byte("after " + strconv.Itoa(i)))
+		select {
+		case frame := <-frames:
+			if !strings.Contains(frame, " after ") {
+				t.Fatalf("unexpected frame %q", frame)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	t.Fatal("no frame received after reconnecting")
+}
+
+func waitFrame(t *testing.T, frames <-chan string) string {
+	t.Helper()
+	select {
+	case frame := <-frames:
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a frame")
+		return ""
+	}
+}
+
+func TestBoundedBufferWhileDisconnected(t *testing.T) {
+	w, err := New("tcp://127.0.0.1:1", Options{
+		BufferSize: 5,
+		MinBackoff: time.Hour,
+		OnError:    func(err error) {},
+		dial: func(network, address string) (net.Conn, error) {
+			return nil, errors.New("connection refused")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := w.Write([]byte("line " + strconv.Itoa(i))); err != nil {
+			t.Fatalf("Write() error %v, a disconnected writer must keep accepting lines", err)
+		}
+	}
+
+	w.mu.Lock()
+	queued := len(w.queue)
+	last := string(w.queue[len(w.queue)-1].msg)
+	w.mu.Unlock()
+	if queued != 5 || w.Dropped() != 15 {
+		t.Errorf("queued %d, dropped %d, want 5 and 15", queued, w.Dropped())
+	}
+	if !strings.HasSuffix(last, "line 19") {
+		t.Errorf("expected the newest lines to be kept, last is %q", last)
+	}
+
+	if err := w.Close(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("closed")); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+)
+
+// ServerOption creates a server span for every incoming gRPC call, continuing the trace
+// of the caller (traceparent metadata). Call Setup before creating the server.
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler())
+}
+
+// DialOption creates a client span for every outgoing gRPC call and sends its
+// traceparent in the metadata. Call Setup before dialing.
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+}
@@ -0,0 +1,18 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// HTTPMiddleware creates a server span named "<method> <path>" for every HTTP request,
+// continuing the trace of an incoming traceparent header. The gRPC calls made with the
+// request context (see DialOption) are its children.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.request",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+}
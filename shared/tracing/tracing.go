@@ -0,0 +1,119 @@
+// Package tracing sets up OpenTelemetry tracing for the services: the tracer provider
+// and its exporter (OTLP/HTTP or stdout), W3C traceparent propagation, and the gRPC
+// and HTTP instrumentation. The span of a request is also added to its srvlog fields.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracers created by the services
+const InstrumentationName = "github.com/ramseyjiang/go-micros"
+
+// Exporters selected by Options.Exporter
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+var ErrUnknownExporter = errors.New("tracing: unknown exporter")
+
+// Options configures Setup, the services read them from the config with viperconf.TracingOptions
+type Options struct {
+	ServiceName string
+	// Enabled installs a tracer provider, when false the traceparent is still propagated
+	Enabled bool
+	// Exporter is ExporterOTLP (default) or ExporterStdout
+	Exporter string
+	// OTLPEndpoint is the OTLP/HTTP traces receiver, e.g. http://otel-collector:4318,
+	// the OTEL_EXPORTER_OTLP_* environment variables apply when empty
+	OTLPEndpoint string
+	// SampleRatio of the root spans sampled, defaults to 1 (child spans follow their parent)
+	SampleRatio float64
+
+	// SpanExporter replaces Exporter, e.g. a tracetest.InMemoryExporter in tests
+	SpanExporter sdktrace.SpanExporter
+	// Syncer exports each span when it ends instead of in batches (tests)
+	Syncer bool
+}
+
+// Setup installs the global tracer provider and propagator. The returned func flushes
+// the spans and stops the exporter, call it when the service stops.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !opts.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter := opts.SpanExporter
+	if exporter == nil {
+		var err error
+		if exporter, err = newExporter(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: resource: %w", err)
+	}
+
+	sampleRatio := opts.SampleRatio
+	if sampleRatio <= 0 || sampleRatio > 1 {
+		sampleRatio = 1
+	}
+	spanProcessor := sdktrace.WithBatcher(exporter)
+	if opts.Syncer {
+		spanProcessor = sdktrace.WithSyncer(exporter)
+	}
+	provider := sdktrace.NewTracerProvider(
+		spanProcessor,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(opts.Exporter) {
+	case "", ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			u, err := url.Parse(opts.OTLPEndpoint)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("tracing: invalid OTLP endpoint (%s)", opts.OTLPEndpoint)
+			}
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(u.Host))
+			if u.Scheme == "http" {
+				clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+			}
+			if u.Path != "" && u.Path != "/" {
+				clientOpts = append(clientOpts, otlptracehttp.WithURLPath(u.Path))
+			}
+		}
+		return otlptracehttp.New(ctx, clientOpts...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("%w (%s)", ErrUnknownExporter, opts.Exporter)
+	}
+}
+
+// Tracer returns the tracer of the services, from the global tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
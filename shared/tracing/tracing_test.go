@@ -0,0 +1,146 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/requestlog"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// loggedHealthServer records the srvlog fields of the calls it serves
+type loggedHealthServer struct {
+	*health.Server
+	fields map[string]interface{}
+}
+
+func (s *loggedHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.fields = srvlog.FieldsFromContext(ctx)
+	return s.Server.Check(ctx, req)
+}
+
+func TestHTTPToGRPCSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Setup(context.Background(), Options{ServiceName: "test", Enabled: true, SpanExporter: exporter, Syncer: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	// the "products" side: a gRPC server
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(ServerOption(), requestlog.UnaryServerInterceptor())
+	healthServer := &loggedHealthServer{Server: health.NewServer()}
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// the "gateway" side: an HTTP handler calling the gRPC server
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		DialOption(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := client.Check(r.Context(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Errorf("Check() error %v", err)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/sales", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected an HTTP server, a gRPC client and a gRPC server span, got %d spans", len(spans))
+	}
+	httpSpan := rootSpan(t, spans)
+	clientSpan := spanOfKind(t, spans, trace.SpanKindClient)
+	serverSpan := grpcServerSpan(t, spans)
+	if httpSpan.Name != "POST /v1/sales" {
+		t.Errorf("HTTP span name %q", httpSpan.Name)
+	}
+
+	tests := []struct {
+		name       string
+		child      tracetest.SpanStub
+		wantParent trace.SpanContext
+	}{
+		{name: "ClientChildOfHTTP", child: clientSpan, wantParent: httpSpan.SpanContext},
+		{name: "ServerChildOfClient", child: serverSpan, wantParent: clientSpan.SpanContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.child.Parent.SpanID() != tt.wantParent.SpanID() {
+				t.Errorf("%s parent span %s, want %s", tt.child.Name, tt.child.Parent.SpanID(), tt.wantParent.SpanID())
+			}
+			if tt.child.SpanContext.TraceID() != httpSpan.SpanContext.TraceID() {
+				t.Errorf("%s is in trace %s, want %s", tt.child.Name, tt.child.SpanContext.TraceID(), httpSpan.SpanContext.TraceID())
+			}
+		})
+	}
+
+	if healthServer.fields[srvlog.FieldTraceID] != serverSpan.SpanContext.TraceID().String() || healthServer.fields[srvlog.FieldSpanID] != serverSpan.SpanContext.SpanID().String() {
+		t.Errorf("log fields %v should carry the server span", healthServer.fields)
+	}
+}
+
+func spanOfKind(t *testing.T, spans tracetest.SpanStubs, kind trace.SpanKind) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.SpanKind == kind {
+			return span
+		}
+	}
+	t.Fatalf("no %s span", kind)
+	return tracetest.SpanStub{}
+}
+
+func rootSpan(t *testing.T, spans tracetest.SpanStubs) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if !span.Parent.IsValid() {
+			return span
+		}
+	}
+	t.Fatal("no root span")
+	return tracetest.SpanStub{}
+}
+
+func grpcServerSpan(t *testing.T, spans tracetest.SpanStubs) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.SpanKind == trace.SpanKindServer && span.Parent.IsValid() {
+			return span
+		}
+	}
+	t.Fatal("no gRPC server span")
+	return tracetest.SpanStub{}
+}
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{Enabled: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if _, err := Setup(context.Background(), Options{Enabled: true, Exporter: "zipkin"}); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
@@ -0,0 +1,446 @@
+package viperconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/logruswrapper"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/otlplog"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/rotatefile"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/syslogwriter"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// setupLogFileFlags defines the log file flags, see ApplyLogFileConfig
+func setupLogFileFlags() {
+	if viper.Get("log-file") == nil {
+		viper.SetDefault("log-file", "")
+	}
+	if pflag.Lookup("log-file") == nil {
+		pflag.String("log-file", viper.GetString("log-file"), "Log file path (logs go to StdErr/syslog when empty)")
+		viper.BindPFlag("log-file", pflag.Lookup("log-file"))
+	}
+
+	if viper.Get("log-file-max-size-mb") == nil {
+		viper.SetDefault("log-file-max-size-mb", 100)
+	}
+	if pflag.Lookup("log-file-max-size-mb") == nil {
+		pflag.Int("log-file-max-size-mb", viper.GetInt("log-file-max-size-mb"), "Log file size in MB before it is rotated (0 disables size based rotation)")
+		viper.BindPFlag("log-file-max-size-mb", pflag.Lookup("log-file-max-size-mb"))
+	}
+
+	if viper.Get("log-file-rotate-interval") == nil {
+		viper.SetDefault("log-file-rotate-interval", time.Duration(0))
+	}
+	if pflag.Lookup("log-file-rotate-interval") == nil {
+		pflag.Duration("log-file-rotate-interval", viper.GetDuration("log-file-rotate-interval"), "Rotate the log file after this duration, e.g. 24h (0 disables time based rotation)")
+		viper.BindPFlag("log-file-rotate-interval", pflag.Lookup("log-file-rotate-interval"))
+	}
+
+	if viper.Get("log-file-max-age") == nil {
+		viper.SetDefault("log-file-max-age", 7*24*time.Hour)
+	}
+	if pflag.Lookup("log-file-max-age") == nil {
+		pflag.Duration("log-file-max-age", viper.GetDuration("log-file-max-age"), "Remove rotated log files older than this (0 keeps them)")
+		viper.BindPFlag("log-file-max-age", pflag.Lookup("log-file-max-age"))
+	}
+
+	if viper.Get("log-file-max-backups") == nil {
+		viper.SetDefault("log-file-max-backups", 10)
+	}
+	if pflag.Lookup("log-file-max-backups") == nil {
+		pflag.Int("log-file-max-backups", viper.GetInt("log-file-max-backups"), "Number of rotated log files kept (0 keeps them all)")
+		viper.BindPFlag("log-file-max-backups", pflag.Lookup("log-file-max-backups"))
+	}
+
+	if viper.Get("log-file-compress") == nil {
+		viper.SetDefault("log-file-compress", true)
+	}
+	if pflag.Lookup("log-file-compress") == nil {
+		pflag.Bool("log-file-compress", viper.GetBool("log-file-compress"), "Gzip rotated log files?")
+		viper.BindPFlag("log-file-compress", pflag.Lookup("log-file-compress"))
+	}
+}
+
+// setupLogSamplingFlags defines the log sampling flags, see ApplyLogSamplingConfig
+func setupLogSamplingFlags() {
+	if viper.Get("log-sampling-interval") == nil {
+		viper.SetDefault("log-sampling-interval", time.Duration(0))
+	}
+	if pflag.Lookup("log-sampling-interval") == nil {
+		pflag.Duration("log-sampling-interval", viper.GetDuration("log-sampling-interval"), "Log sampling interval, e.g. 1s (0 disables sampling)")
+		viper.BindPFlag("log-sampling-interval", pflag.Lookup("log-sampling-interval"))
+	}
+
+	if viper.Get("log-sampling-first") == nil {
+		viper.SetDefault("log-sampling-first", 100)
+	}
+	if pflag.Lookup("log-sampling-first") == nil {
+		pflag.Int("log-sampling-first", viper.GetInt("log-sampling-first"), "Log entries logged per message per sampling interval before sampling")
+		viper.BindPFlag("log-sampling-first", pflag.Lookup("log-sampling-first"))
+	}
+
+	if viper.Get("log-sampling-thereafter") == nil {
+		viper.SetDefault("log-sampling-thereafter", 100)
+	}
+	if pflag.Lookup("log-sampling-thereafter") == nil {
+		pflag.Int("log-sampling-thereafter", viper.GetInt("log-sampling-thereafter"), "Log 1 in this many entries once sampling (0 drops them all)")
+		viper.BindPFlag("log-sampling-thereafter", pflag.Lookup("log-sampling-thereafter"))
+	}
+
+	if viper.Get("log-sampling-report-interval") == nil {
+		viper.SetDefault("log-sampling-report-interval", time.Minute)
+	}
+	if pflag.Lookup("log-sampling-report-interval") == nil {
+		pflag.Duration("log-sampling-report-interval", viper.GetDuration("log-sampling-report-interval"), "How often the number of log entries dropped by sampling is logged")
+		viper.BindPFlag("log-sampling-report-interval", pflag.Lookup("log-sampling-report-interval"))
+	}
+}
+
+// LogSamplingRatioConfig is an entry of the "log-sampling-levels" config map, e.g. in YAML
+//
+//	log-sampling-levels:
+//	  debug: {first: 10, thereafter: 1000}
+//	  warning: {first: 1000, thereafter: 10}
+type LogSamplingRatioConfig struct {
+	First      int `mapstructure:"first"`
+	Thereafter int `mapstructure:"thereafter"`
+}
+
+// LogSamplingOptions returns the log sampling options from the config, nil when sampling is disabled.
+// Trace to warning levels use the log-sampling-first/thereafter flags unless set in "log-sampling-levels".
+func LogSamplingOptions(viperCfg *viper.Viper) (*logruswrapper.SamplingOptions, error) {
+	interval := viperCfg.GetDuration("log-sampling-interval")
+	if interval <= 0 {
+		return nil, nil
+	}
+
+	ratio := logruswrapper.SamplingRatio{
+		First:      viperCfg.GetInt("log-sampling-first"),
+		Thereafter: viperCfg.GetInt("log-sampling-thereafter"),
+	}
+	opts := &logruswrapper.SamplingOptions{
+		Interval:       interval,
+		ReportInterval: viperCfg.GetDuration("log-sampling-report-interval"),
+		Levels: map[slog.Level]logruswrapper.SamplingRatio{
+			logruswrapper.LevelTrace: ratio,
+			slog.LevelDebug:          ratio,
+			slog.LevelInfo:           ratio,
+			slog.LevelWarn:           ratio,
+		},
+	}
+
+	var levels map[string]LogSamplingRatioConfig
+	if err := viperCfg.UnmarshalKey("log-sampling-levels", &levels); err != nil {
+		return nil, fmt.Errorf("invalid log-sampling-levels config: %w", err)
+	}
+	for name, levelRatio := range levels {
+		level, err := logruswrapper.ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log-sampling-levels config: %w", err)
+		}
+		opts.Levels[level] = logruswrapper.SamplingRatio(levelRatio)
+	}
+	return opts, nil
+}
+
+var appliedLogSampling *logruswrapper.SamplingOptions
+
+// ApplyLogSamplingConfig sets the srvlog sampling from the config,
+// it is called on start up and whenever the config file changes
+func ApplyLogSamplingConfig(viperCfg *viper.Viper) {
+	opts, err := LogSamplingOptions(viperCfg)
+	if err != nil {
+		srvlog.Errorf("%v", err)
+		return
+	}
+	if reflect.DeepEqual(opts, appliedLogSampling) {
+		return
+	}
+	srvlog.SetSampling(opts)
+	appliedLogSampling = opts
+}
+
+// LogFileOptions returns the rotating log file options from the config
+func LogFileOptions(viperCfg *viper.Viper) rotatefile.Options {
+	return rotatefile.Options{
+		Path:       viperCfg.GetString("log-file"),
+		MaxSize:    viperCfg.GetInt64("log-file-max-size-mb") * 1024 * 1024,
+		Interval:   viperCfg.GetDuration("log-file-rotate-interval"),
+		MaxAge:     viperCfg.GetDuration("log-file-max-age"),
+		MaxBackups: viperCfg.GetInt("log-file-max-backups"),
+		Compress:   viperCfg.GetBool("log-file-compress"),
+	}
+}
+
+var appliedLogFileOptions rotatefile.Options
+
+// sameLogFileOptions reports whether the config options are unchanged (Clock is never set from the config)
+func sameLogFileOptions(a, b rotatefile.Options) bool {
+	return a.Path == b.Path && a.MaxSize == b.MaxSize && a.Interval == b.Interval &&
+		a.MaxAge == b.MaxAge && a.MaxBackups == b.MaxBackups && a.Compress == b.Compress && a.FileMode == b.FileMode
+}
+
+// ApplyLogFileConfig sets the srvlog log file from the config,
+// it is called on start up and whenever the config file changes
+func ApplyLogFileConfig(viperCfg *viper.Viper) {
+	opts := LogFileOptions(viperCfg)
+	if sameLogFileOptions(opts, appliedLogFileOptions) {
+		return
+	}
+	if err := srvlog.SetLogFile(opts); err != nil {
+		srvlog.Errorf("Unable to set the log file (%s): %v", opts.Path, err)
+		return
+	}
+	appliedLogFileOptions = opts
+}
+
+// LogSinkConfig is an entry of the "log-sinks" config list, e.g. in YAML
+//
+//	log-sinks:
+//	  - name: syslog
+//	    output: tcp+tls://logs.internal
+//	    format: json
+//	    level: error
+//	  - name: debug-file
+//	    output: file:///var/log/trade/debug.log
+//	    level: debug
+//	    exclude-fields: [origin_file, origin_line]
+type LogSinkConfig struct {
+	Name string `mapstructure:"name"`
+	// Output is stderr, stdout, file://<path>, an OTLP/HTTP logs receiver (otlp+http://, otlp+https://)
+	// or a syslog URL (udp://, tcp://, tcp+tls://)
+	Output string `mapstructure:"output"`
+	// Format is text (default) or json
+	Format string `mapstructure:"format"`
+	// Level is the minimum level, empty follows the debug flag
+	Level         string   `mapstructure:"level"`
+	IncludeFields []string `mapstructure:"include-fields"`
+	ExcludeFields []string `mapstructure:"exclude-fields"`
+	// Colour colours the level of text sinks
+	Colour bool `mapstructure:"colour"`
+	// File rotation, see the log-file-* flags
+	MaxSizeMB      int           `mapstructure:"max-size-mb"`
+	RotateInterval time.Duration `mapstructure:"rotate-interval"`
+	MaxAge         time.Duration `mapstructure:"max-age"`
+	MaxBackups     int           `mapstructure:"max-backups"`
+	Compress       bool          `mapstructure:"compress"`
+}
+
+// LogSinksConfig returns the "log-sinks" config list, with an "otlp" sink
+// when the log-otlp-endpoint flag is set
+func LogSinksConfig(viperCfg *viper.Viper) ([]LogSinkConfig, error) {
+	var sinks []LogSinkConfig
+	if err := viperCfg.UnmarshalKey("log-sinks", &sinks); err != nil {
+		return nil, fmt.Errorf("invalid log-sinks config: %w", err)
+	}
+	if endpoint := viperCfg.GetString("log-otlp-endpoint"); endpoint != "" {
+		sinks = append(sinks, LogSinkConfig{
+			Name:   "otlp",
+			Output: "otlp+" + endpoint,
+			Level:  viperCfg.GetString("log-otlp-level"),
+		})
+	}
+	return sinks, nil
+}
+
+// setupLogOTLPFlags defines the OTLP log export flags, see LogSinksConfig
+func setupLogOTLPFlags() {
+	if viper.Get("log-otlp-endpoint") == nil {
+		viper.SetDefault("log-otlp-endpoint", "")
+	}
+	if pflag.Lookup("log-otlp-endpoint") == nil {
+		pflag.String("log-otlp-endpoint", viper.GetString("log-otlp-endpoint"), "OTLP/HTTP logs receiver the logs are exported to, e.g. http://otel-collector:4318 (empty disables the export)")
+		viper.BindPFlag("log-otlp-endpoint", pflag.Lookup("log-otlp-endpoint"))
+	}
+
+	if viper.Get("log-otlp-level") == nil {
+		viper.SetDefault("log-otlp-level", "")
+	}
+	if pflag.Lookup("log-otlp-level") == nil {
+		pflag.String("log-otlp-level", viper.GetString("log-otlp-level"), "Minimum level of the exported logs (empty follows the debug flag)")
+		viper.BindPFlag("log-otlp-level", pflag.Lookup("log-otlp-level"))
+	}
+}
+
+// configuredSink is a sink added by ApplyLogSinksConfig, its writer is closed when the config changes
+type configuredSink struct {
+	name   string
+	closer func()
+}
+
+var (
+	appliedLogSinks []LogSinkConfig
+	configuredSinks []configuredSink
+)
+
+// ApplyLogSinksConfig adds the "log-sinks" config as srvlog sinks, next to the default
+// sink (see the log-file flags). It is called on start up and whenever the config file
+// changes, the sinks of the previous config are removed and their writers closed.
+func ApplyLogSinksConfig(viperCfg *viper.Viper) {
+	configs, err := LogSinksConfig(viperCfg)
+	if err != nil {
+		srvlog.Errorf("%v", err)
+		return
+	}
+	if reflect.DeepEqual(configs, appliedLogSinks) {
+		return
+	}
+
+	sinks := make([]logruswrapper.Sink, 0, len(configs))
+	closers := make([]configuredSink, 0, len(configs))
+	closeAll := func() {
+		for _, sink := range closers {
+			sink.closer()
+		}
+	}
+	for _, cfg := range configs {
+		sink, closer, err := newLogSink(cfg)
+		if err != nil {
+			closeAll()
+			srvlog.Errorf("Unable to set up the log sink (%s), keeping the current log sinks: %v", cfg.Name, err)
+			return
+		}
+		sinks = append(sinks, sink)
+		closers = append(closers, configuredSink{name: cfg.Name, closer: closer})
+	}
+
+	for _, previous := range configuredSinks {
+		srvlog.RemoveSink(previous.name)
+		previous.closer()
+	}
+	for _, sink := range sinks {
+		// newLogSink already validated the sink
+		srvlog.AddSink(sink)
+	}
+	configuredSinks = closers
+	appliedLogSinks = configs
+}
+
+// newLogSink opens the writer of a sink, the returned func closes it
+func newLogSink(cfg LogSinkConfig) (logruswrapper.Sink, func(), error) {
+	sink := logruswrapper.Sink{
+		Name:   cfg.Name,
+		Format: logruswrapper.SinkFormat(strings.ToLower(cfg.Format)),
+		Fields: logruswrapper.FieldFilter{Include: cfg.IncludeFields, Exclude: cfg.ExcludeFields},
+	}
+	if sink.Name == "" || sink.Name == logruswrapper.DefaultSinkName {
+		return sink, nil, fmt.Errorf("a log sink needs a name other than (%s)", logruswrapper.DefaultSinkName)
+	}
+	switch sink.Format {
+	case "", logruswrapper.SinkFormatText, logruswrapper.SinkFormatJSON:
+	default:
+		return sink, nil, fmt.Errorf("invalid format (%s)", cfg.Format)
+	}
+	if cfg.Level != "" {
+		level, err := logruswrapper.ParseLevel(cfg.Level)
+		if err != nil {
+			return sink, nil, err
+		}
+		sink.Level = level
+	}
+
+	var writer io.Writer
+	closer := func() {}
+	switch output := strings.TrimSpace(cfg.Output); {
+	case strings.EqualFold(output, "stderr"):
+		writer = os.Stderr
+	case strings.EqualFold(output, "stdout"):
+		writer = os.Stdout
+	case strings.HasPrefix(strings.ToLower(output), "file://"):
+		file, err := rotatefile.New(rotatefile.Options{
+			Path:       output[len("file://"):],
+			MaxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+			Interval:   cfg.RotateInterval,
+			MaxAge:     cfg.MaxAge,
+			MaxBackups: cfg.MaxBackups,
+			Compress:   cfg.Compress,
+		})
+		if err != nil {
+			return sink, nil, err
+		}
+		file.ReopenOnSignal()
+		writer = file
+		closer = func() { file.Close() }
+	case strings.HasPrefix(strings.ToLower(output), "otlp+"):
+		exporter, err := otlplog.New(otlplog.Options{Endpoint: output[len("otlp+"):]})
+		if err != nil {
+			return sink, nil, err
+		}
+		sink.Handler = exporter
+		closer = func() {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				exporter.Shutdown(ctx)
+			}()
+		}
+		return sink, closer, nil
+	case output != "":
+		syslog, err := syslogwriter.New(output, srvlog.GlobalLogger.SyslogOptions)
+		if err != nil {
+			return sink, nil, err
+		}
+		writer = syslog
+		closer = func() { go syslog.Close(5 * time.Second) }
+	default:
+		return sink, nil, fmt.Errorf("missing output")
+	}
+	sink.Writer = writer
+	sink.UseColour = cfg.Colour
+	return sink, closer, nil
+}
+
+// LogLevelConfig is an entry of the "log-levels" config list, e.g. in YAML
+//
+//	log-levels:
+//	  - package: trade/internal/repos
+//	    level: debug
+type LogLevelConfig struct {
+	Package string `mapstructure:"package"`
+	Level   string `mapstructure:"level"`
+}
+
+var appliedLogLevels []LogLevelConfig
+
+// ApplyLogLevelsConfig sets the "log-levels" package levels (see srvlog.SetPackageLevel),
+// it is called on start up and whenever the config file changes. The package levels
+// removed from the config are cleared, even when later set by the admin endpoint.
+func ApplyLogLevelsConfig(viperCfg *viper.Viper) {
+	var configs []LogLevelConfig
+	if err := viperCfg.UnmarshalKey("log-levels", &configs); err != nil {
+		srvlog.Errorf("invalid log-levels config: %v", err)
+		return
+	}
+	if reflect.DeepEqual(configs, appliedLogLevels) {
+		return
+	}
+
+	levels := make(map[string]slog.Level, len(configs))
+	for _, cfg := range configs {
+		level, err := logruswrapper.ParseLevel(cfg.Level)
+		if err != nil || cfg.Package == "" {
+			srvlog.Errorf("invalid log-levels config for package (%s), keeping the current levels: %v", cfg.Package, err)
+			return
+		}
+		levels[cfg.Package] = level
+	}
+
+	for _, previous := range appliedLogLevels {
+		if _, kept := levels[previous.Package]; !kept {
+			srvlog.ClearPackageLevel(previous.Package)
+		}
+	}
+	for pkg, level := range levels {
+		srvlog.SetPackageLevel(pkg, level, 0)
+	}
+	appliedLogLevels = configs
+}
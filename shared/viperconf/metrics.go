@@ -0,0 +1,52 @@
+package viperconf
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ramseyjiang/go-micros/shared/metrics"
+	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// setupMetricsFlags defines the admin address the metrics are served on, next to export-metrics
+func setupMetricsFlags() {
+	if viper.Get("metrics-addr") == nil {
+		viper.SetDefault("metrics-addr", "localhost:9100")
+	}
+	if pflag.Lookup("metrics-addr") == nil {
+		pflag.String("metrics-addr", viper.GetString("metrics-addr"), "Admin address the Prometheus metrics are served on (/metrics) when export-metrics is set")
+		viper.BindPFlag("metrics-addr", pflag.Lookup("metrics-addr"))
+	}
+}
+
+var (
+	metricsServer      *http.Server
+	appliedMetricsAddr string
+)
+
+// ApplyMetricsConfig serves the metrics (see the metrics package) on metrics-addr while
+// export-metrics is set, it is called on start up and whenever the config file changes
+func ApplyMetricsConfig(viperCfg *viper.Viper) {
+	addr := viperCfg.GetString("metrics-addr")
+	if !viperCfg.GetBool("export-metrics") {
+		addr = ""
+	}
+	if addr == appliedMetricsAddr {
+		return
+	}
+
+	if metricsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		metricsServer.Shutdown(ctx)
+		cancel()
+		metricsServer = nil
+	}
+	if addr != "" {
+		srvlog.Infof("Serving the metrics on %s%s", addr, metrics.Path)
+		metricsServer = metrics.ListenAndServe(addr, srvlog.Errorf)
+	}
+	appliedMetricsAddr = addr
+}
@@ -0,0 +1,126 @@
+package viperconf
+
+import (
+	"strings"
+
+	"github.com/ramseyjiang/go-micros/shared/apierror/resilience"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// setupGRPCClientFlags defines the flags read by GRPCClientOptions
+func setupGRPCClientFlags() {
+	defaults := resilience.DefaultOptions()
+
+	if viper.Get("grpc-client-timeout") == nil {
+		viper.SetDefault("grpc-client-timeout", defaults.Timeout)
+	}
+	if pflag.Lookup("grpc-client-timeout") == nil {
+		pflag.Duration("grpc-client-timeout", viper.GetDuration("grpc-client-timeout"), "Deadline of the gRPC calls made without one (0 disables it)")
+		viper.BindPFlag("grpc-client-timeout", pflag.Lookup("grpc-client-timeout"))
+	}
+
+	if viper.Get("grpc-client-retry-methods") == nil {
+		viper.SetDefault("grpc-client-retry-methods", []string{})
+	}
+	if pflag.Lookup("grpc-client-retry-methods") == nil {
+		pflag.StringSlice("grpc-client-retry-methods", viper.GetStringSlice("grpc-client-retry-methods"), "Full gRPC methods (/package.Service/Method) retried, only list idempotent ones")
+		viper.BindPFlag("grpc-client-retry-methods", pflag.Lookup("grpc-client-retry-methods"))
+	}
+
+	if viper.Get("grpc-client-retry-max-attempts") == nil {
+		viper.SetDefault("grpc-client-retry-max-attempts", defaults.RetryMaxAttempts)
+	}
+	if pflag.Lookup("grpc-client-retry-max-attempts") == nil {
+		pflag.Int("grpc-client-retry-max-attempts", viper.GetInt("grpc-client-retry-max-attempts"), "Attempts of a retried gRPC call, the first one included (1 disables the retries)")
+		viper.BindPFlag("grpc-client-retry-max-attempts", pflag.Lookup("grpc-client-retry-max-attempts"))
+	}
+
+	if viper.Get("grpc-client-retry-initial-backoff") == nil {
+		viper.SetDefault("grpc-client-retry-initial-backoff", defaults.RetryInitialBackoff)
+	}
+	if pflag.Lookup("grpc-client-retry-initial-backoff") == nil {
+		pflag.Duration("grpc-client-retry-initial-backoff", viper.GetDuration("grpc-client-retry-initial-backoff"), "Backoff before the first retry of a gRPC call, doubled on each retry")
+		viper.BindPFlag("grpc-client-retry-initial-backoff", pflag.Lookup("grpc-client-retry-initial-backoff"))
+	}
+
+	if viper.Get("grpc-client-retry-max-backoff") == nil {
+		viper.SetDefault("grpc-client-retry-max-backoff", defaults.RetryMaxBackoff)
+	}
+	if pflag.Lookup("grpc-client-retry-max-backoff") == nil {
+		pflag.Duration("grpc-client-retry-max-backoff", viper.GetDuration("grpc-client-retry-max-backoff"), "Maximum backoff between two retries of a gRPC call")
+		viper.BindPFlag("grpc-client-retry-max-backoff", pflag.Lookup("grpc-client-retry-max-backoff"))
+	}
+
+	if viper.Get("grpc-client-breaker-failures") == nil {
+		viper.SetDefault("grpc-client-breaker-failures", defaults.BreakerFailures)
+	}
+	if pflag.Lookup("grpc-client-breaker-failures") == nil {
+		pflag.Int("grpc-client-breaker-failures", viper.GetInt("grpc-client-breaker-failures"), "Consecutive failures opening the circuit breaker of a gRPC target (0 disables it)")
+		viper.BindPFlag("grpc-client-breaker-failures", pflag.Lookup("grpc-client-breaker-failures"))
+	}
+
+	if viper.Get("grpc-client-breaker-open-timeout") == nil {
+		viper.SetDefault("grpc-client-breaker-open-timeout", defaults.BreakerOpenTimeout)
+	}
+	if pflag.Lookup("grpc-client-breaker-open-timeout") == nil {
+		pflag.Duration("grpc-client-breaker-open-timeout", viper.GetDuration("grpc-client-breaker-open-timeout"), "How long the circuit breaker of a gRPC target stays open before probing it")
+		viper.BindPFlag("grpc-client-breaker-open-timeout", pflag.Lookup("grpc-client-breaker-open-timeout"))
+	}
+
+	if viper.Get("grpc-client-hedge-methods") == nil {
+		viper.SetDefault("grpc-client-hedge-methods", []string{})
+	}
+	if pflag.Lookup("grpc-client-hedge-methods") == nil {
+		pflag.StringSlice("grpc-client-hedge-methods", viper.GetStringSlice("grpc-client-hedge-methods"), "Full gRPC methods (/package.Service/Method) hedged")
+		viper.BindPFlag("grpc-client-hedge-methods", pflag.Lookup("grpc-client-hedge-methods"))
+	}
+
+	if viper.Get("grpc-client-hedge-delay") == nil {
+		viper.SetDefault("grpc-client-hedge-delay", defaults.HedgeDelay)
+	}
+	if pflag.Lookup("grpc-client-hedge-delay") == nil {
+		pflag.Duration("grpc-client-hedge-delay", viper.GetDuration("grpc-client-hedge-delay"), "Delay without a response before a hedged gRPC call is sent again")
+		viper.BindPFlag("grpc-client-hedge-delay", pflag.Lookup("grpc-client-hedge-delay"))
+	}
+
+	if viper.Get("grpc-client-hedge-max-attempts") == nil {
+		viper.SetDefault("grpc-client-hedge-max-attempts", defaults.HedgeMaxAttempts)
+	}
+	if pflag.Lookup("grpc-client-hedge-max-attempts") == nil {
+		pflag.Int("grpc-client-hedge-max-attempts", viper.GetInt("grpc-client-hedge-max-attempts"), "Attempts of a hedged gRPC call in flight at once (1 disables the hedging)")
+		viper.BindPFlag("grpc-client-hedge-max-attempts", pflag.Lookup("grpc-client-hedge-max-attempts"))
+	}
+}
+
+// GRPCClientOptions returns the resilience options (deadline, retries, circuit breaker and
+// hedging) of the gRPC clients from the config. Like every flag, they can be set with
+// environment variables (GRPC_CLIENT_TIMEOUT, GRPC_CLIENT_RETRY_METHODS...), the methods
+// are then comma separated.
+func GRPCClientOptions(viperCfg *viper.Viper) resilience.Options {
+	return resilience.Options{
+		Timeout:             viperCfg.GetDuration("grpc-client-timeout"),
+		RetryMethods:        methodsConfig(viperCfg, "grpc-client-retry-methods"),
+		RetryMaxAttempts:    viperCfg.GetInt("grpc-client-retry-max-attempts"),
+		RetryInitialBackoff: viperCfg.GetDuration("grpc-client-retry-initial-backoff"),
+		RetryMaxBackoff:     viperCfg.GetDuration("grpc-client-retry-max-backoff"),
+		BreakerFailures:     viperCfg.GetInt("grpc-client-breaker-failures"),
+		BreakerOpenTimeout:  viperCfg.GetDuration("grpc-client-breaker-open-timeout"),
+		HedgeMethods:        methodsConfig(viperCfg, "grpc-client-hedge-methods"),
+		HedgeDelay:          viperCfg.GetDuration("grpc-client-hedge-delay"),
+		HedgeMaxAttempts:    viperCfg.GetInt("grpc-client-hedge-max-attempts"),
+	}
+}
+
+// methodsConfig reads a list of gRPC methods, the environment variables give it as one comma separated string
+func methodsConfig(viperCfg *viper.Viper, key string) []string {
+	var methods []string
+	for _, value := range viperCfg.GetStringSlice(key) {
+		for _, method := range strings.Split(value, ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				methods = append(methods, method)
+			}
+		}
+	}
+	return methods
+}
@@ -0,0 +1,47 @@
+package viperconf
+
+import (
+	"github.com/ramseyjiang/go-micros/shared/tracing"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// setupTracingFlags defines the flags read by TracingOptions, next to tracing-enabled
+func setupTracingFlags() {
+	if viper.Get("tracing-exporter") == nil {
+		viper.SetDefault("tracing-exporter", tracing.ExporterOTLP)
+	}
+	if pflag.Lookup("tracing-exporter") == nil {
+		pflag.String("tracing-exporter", viper.GetString("tracing-exporter"), "Traces exporter when tracing is enabled (otlp or stdout)")
+		viper.BindPFlag("tracing-exporter", pflag.Lookup("tracing-exporter"))
+	}
+
+	if viper.Get("tracing-otlp-endpoint") == nil {
+		viper.SetDefault("tracing-otlp-endpoint", "")
+	}
+	if pflag.Lookup("tracing-otlp-endpoint") == nil {
+		pflag.String("tracing-otlp-endpoint", viper.GetString("tracing-otlp-endpoint"), "OTLP/HTTP traces receiver, e.g. http://otel-collector:4318 (empty uses the OTEL_EXPORTER_OTLP_* variables)")
+		viper.BindPFlag("tracing-otlp-endpoint", pflag.Lookup("tracing-otlp-endpoint"))
+	}
+
+	if viper.Get("tracing-sample-ratio") == nil {
+		viper.SetDefault("tracing-sample-ratio", 1.0)
+	}
+	if pflag.Lookup("tracing-sample-ratio") == nil {
+		pflag.Float64("tracing-sample-ratio", viper.GetFloat64("tracing-sample-ratio"), "Ratio of the traces sampled (0 to 1)")
+		viper.BindPFlag("tracing-sample-ratio", pflag.Lookup("tracing-sample-ratio"))
+	}
+}
+
+// TracingOptions returns the tracing.Setup options of serviceName from the config. Like every
+// flag, they can be set with environment variables: TRACING_ENABLED, TRACING_EXPORTER,
+// TRACING_OTLP_ENDPOINT and TRACING_SAMPLE_RATIO.
+func TracingOptions(viperCfg *viper.Viper, serviceName string) tracing.Options {
+	return tracing.Options{
+		ServiceName:  serviceName,
+		Enabled:      viperCfg.GetBool("tracing-enabled"),
+		Exporter:     viperCfg.GetString("tracing-exporter"),
+		OTLPEndpoint: viperCfg.GetString("tracing-otlp-endpoint"),
+		SampleRatio:  viperCfg.GetFloat64("tracing-sample-ratio"),
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/ramseyjiang/go-micros/shared/apierror"
 	"github.com/ramseyjiang/go-micros/shared/helpers"
 	"github.com/ramseyjiang/go-micros/shared/srvlog"
+	"github.com/ramseyjiang/go-micros/shared/srvlog/redact"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -51,7 +52,9 @@ func loadRemoteConfig(thisViper *viper.Viper, configLocation string, setDefaults
 }
 
 var defaultProtectedConfigItems = []string{"api-key", "jwt-passphrase", "db-loc", "sql-loc", "sql-location", "nosql-loc", "nosql-location", "oracle-db", "oracle-dsn", "vault-token", "zendesk-auth"}
-var defaultProtectedSubstrings = []string{"password", "passwd", "apikey", "api-key", "secret", "-key", "-passphrase", "-token"}
+
+// defaultProtectedSubstrings are shared with the log redaction (see redact.DefaultKeySubstrings)
+var defaultProtectedSubstrings = redact.DefaultKeySubstrings
 var ProtectedConfigItems []string
 
 func ShowConfig() {
@@ -87,7 +90,7 @@ func ShowConfig() {
 				continue
 			}
 		}
-		srvlog.GlobalLogger.Debugf("  %s: %v", cfgVarName, viper.Get(cfgVarName))
+		srvlog.GlobalLogger.Debugf("  %s: %v", cfgVarName, redact.Value(cfgVarName, viper.Get(cfgVarName)))
 	}
 }
 
@@ -194,6 +197,7 @@ func SetupViperV2(logger LogInterface, applicationName string, setParamFunction
 		pflag.String("grpc-auth-jwt-file", viper.GetString("grpc-auth-jwt-file"), "GRPC authentication credentials file")
 		viper.BindPFlag("grpc-auth-jwt-file", pflag.Lookup("grpc-auth-jwt-file"))
 	}
+	setupGRPCClientFlags()
 
 	if viper.Get("tracing-enabled") == nil {
 		viper.SetDefault("tracing-enabled", false)
@@ -202,6 +206,7 @@ func SetupViperV2(logger LogInterface, applicationName string, setParamFunction
 		pflag.Bool("tracing-enabled", viper.GetBool("tracing-enabled"), "Enable tracing?")
 		viper.BindPFlag("tracing-enabled", pflag.Lookup("tracing-enabled"))
 	}
+	setupTracingFlags()
 
 	if viper.Get("export-metrics") == nil {
 		viper.SetDefault("export-metrics", false)
@@ -210,6 +215,7 @@ func SetupViperV2(logger LogInterface, applicationName string, setParamFunction
 		pflag.Bool("export-metrics", viper.GetBool("export-metrics"), "Export metrics?")
 		viper.BindPFlag("export-metrics", pflag.Lookup("export-metrics"))
 	}
+	setupMetricsFlags()
 
 	if viper.Get("datadog-tracing") == nil {
 		viper.SetDefault("datadog-tracing", false)
@@ -235,12 +241,21 @@ func SetupViperV2(logger LogInterface, applicationName string, setParamFunction
 		viper.BindPFlag("gcp-project", pflag.Lookup("gcp-project"))
 	}
 
+	setupLogFileFlags()
+	setupLogSamplingFlags()
+	setupLogOTLPFlags()
+
 	setParamFunction()
 
 	pflag.Parse()
 
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		srvlog.Infof("Config file changed: %s", e.Name)
+		ApplyLogFileConfig(viper.GetViper())
+		ApplyLogSinksConfig(viper.GetViper())
+		ApplyLogSamplingConfig(viper.GetViper())
+		ApplyLogLevelsConfig(viper.GetViper())
+		ApplyMetricsConfig(viper.GetViper())
 		if runWhenConfigChanges != nil {
 			runWhenConfigChanges()
 		}
@@ -270,6 +285,12 @@ func SetupViperV2(logger LogInterface, applicationName string, setParamFunction
 
 	setProxyFlags(viper.GetViper())
 
+	ApplyLogFileConfig(viper.GetViper())
+	ApplyLogSinksConfig(viper.GetViper())
+	ApplyLogSamplingConfig(viper.GetViper())
+	ApplyLogLevelsConfig(viper.GetViper())
+	ApplyMetricsConfig(viper.GetViper())
+
 	return nil
 }
 